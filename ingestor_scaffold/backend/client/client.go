@@ -0,0 +1,861 @@
+// Package client provides a typed Go client for the ingestor HTTP API
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Request and response types shared with the server
+type (
+	Column                     = model.Column
+	ClickHouseConnectionParams = model.ClickHouseConnectionParams
+	DisconnectRequest          = model.DisconnectRequest
+	TokenRefreshRequest        = model.TokenRefreshRequest
+	ConnectionDiagnostics      = model.ConnectionDiagnostics
+	FlatFileParams             = model.FlatFileParams
+	FileSchema                 = model.FileSchema
+	HeaderRename               = model.HeaderRename
+	ColumnInference            = model.ColumnInference
+	TypeCandidate              = model.TypeCandidate
+	ColumnOverride             = model.ColumnOverride
+	SchemaOverrideRequest      = model.SchemaOverrideRequest
+	SchemaOverrideResult       = model.SchemaOverrideResult
+	ColumnOverrideCheck        = model.ColumnOverrideCheck
+	PreviewParams              = model.PreviewParams
+	PreviewTruncation          = model.PreviewTruncation
+	CountParams                = model.CountParams
+	RowCount                   = model.RowCount
+	ProfileParams              = model.ProfileParams
+	DataProfile                = model.DataProfile
+	ColumnProfile              = model.ColumnProfile
+	ValueCount                 = model.ValueCount
+	CostEstimate               = model.CostEstimate
+	QueryPlan                  = model.QueryPlan
+	ReadEstimate               = model.ReadEstimate
+	QueryValidationRequest     = model.QueryValidationRequest
+	QueryValidation            = model.QueryValidation
+	IngestionValidation        = model.IngestionValidation
+	IngestionParams            = model.IngestionParams
+	ColumnMapping              = model.ColumnMapping
+	ColumnTransform            = model.ColumnTransform
+	ColumnRule                 = model.ColumnRule
+	DerivedColumn              = model.DerivedColumn
+	ColumnDefault              = model.ColumnDefault
+	TTL                        = model.TTL
+	ColumnTTL                  = model.ColumnTTL
+	ColumnStorage              = model.ColumnStorage
+	DistributedTable           = model.DistributedTable
+	AsyncInsertSpec            = model.AsyncInsertSpec
+	ParallelExport             = model.ParallelExport
+	VerifySpec                 = model.VerifySpec
+	Verification               = model.Verification
+	ColumnChecksum             = model.ColumnChecksum
+	JSONPathColumn             = model.JSONPathColumn
+	DDLRewrite                 = model.DDLRewrite
+	ChaosSpec                  = model.ChaosSpec
+	ChaosFaults                = model.ChaosFaults
+	JoinTableInfo              = model.JoinTableInfo
+	JoinKey                    = model.JoinKey
+	JoinParams                 = model.JoinParams
+	TableFunction              = model.TableFunction
+	ExportedObject             = model.ExportedObject
+	Filter                     = model.Filter
+	ProgressUpdate             = model.ProgressUpdate
+	IngestionResult            = model.IngestionResult
+	BatchError                 = model.BatchError
+	RowError                   = model.RowError
+	RuleViolation              = model.RuleViolation
+	SchemaDifference           = model.SchemaDifference
+	ColumnLineage              = model.ColumnLineage
+	ExportManifest             = model.ExportManifest
+	Job                        = model.Job
+	JobSummary                 = model.JobSummary
+	ReingestRequest            = model.ReingestRequest
+	Schedule                   = model.Schedule
+	ScheduleRequest            = model.ScheduleRequest
+	PreflightResult            = model.PreflightResult
+	ScheduleStats              = model.ScheduleStats
+	ScheduleRunStats           = model.ScheduleRunStats
+	SavedTemplate              = model.SavedTemplate
+	SavedTemplateRequest       = model.SavedTemplateRequest
+	Quota                      = model.Quota
+	QuotaUsage                 = model.QuotaUsage
+	Pipeline                   = model.Pipeline
+	PipelineStep               = model.PipelineStep
+	PipelineRun                = model.PipelineRun
+	PipelineStepRun            = model.PipelineStepRun
+	ParamsTemplate             = model.ParamsTemplate
+	ScheduleSpec               = model.ScheduleSpec
+	DoctorReport               = model.DoctorReport
+	DoctorCheck                = model.DoctorCheck
+	ApplyBundle                = model.ApplyBundle
+	ApplyResult                = model.ApplyResult
+	ApplySummary               = model.ApplySummary
+	ApplyChange                = model.ApplyChange
+	FieldChange                = model.FieldChange
+)
+
+// sessionHeader carries the ClickHouse session ID
+const sessionHeader = "X-Session-ID"
+
+// Client is a client for the ingestor API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    http.Header
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header sent with every request
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Add(key, value)
+	}
+}
+
+// WithBearerToken authenticates every request with a JWT bearer token
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.headers.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithAPIKey authenticates every request with an API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.headers.Set("X-API-Key", key)
+	}
+}
+
+// WithSession sends an existing ClickHouse session ID with every request
+func WithSession(sessionID string) Option {
+	return func(c *Client) {
+		c.headers.Set(sessionHeader, sessionID)
+	}
+}
+
+// New creates a new client for the API served at baseURL (e.g. http://localhost:8080)
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		headers:    make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned when the server responds with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+
+	// RequestID identifies the failed request in server logs and the ClickHouse query_log
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("ingestor API error (%d, request %s): %s", e.StatusCode, e.RequestID, e.Message)
+	}
+	return fmt.Sprintf("ingestor API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// Health checks that the server is up
+func (c *Client) Health(ctx context.Context) error {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/health", nil, &resp); err != nil {
+		return err
+	}
+	if resp.Status != "up" {
+		return fmt.Errorf("unexpected health status: %s", resp.Status)
+	}
+	return nil
+}
+
+// ConnectToClickHouse opens a ClickHouse session and returns the available tables.
+// Later requests made with this client run on the new session.
+func (c *Client) ConnectToClickHouse(ctx context.Context, params ClickHouseConnectionParams) ([]string, error) {
+	var resp struct {
+		SessionID string   `json:"sessionId"`
+		Tables    []string `json:"tables"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clickhouse/connect", params, &resp); err != nil {
+		return nil, err
+	}
+	c.headers.Set(sessionHeader, resp.SessionID)
+	return resp.Tables, nil
+}
+
+// TestClickHouseConnection reports the server version, timezone, databases, grants
+// and latency of a connection without opening a session. A failed connection
+// is not an error: its diagnostics carry the error and a hint at the cause.
+func (c *Client) TestClickHouseConnection(ctx context.Context, params ClickHouseConnectionParams) (ConnectionDiagnostics, error) {
+	var resp struct {
+		Diagnostics ConnectionDiagnostics `json:"diagnostics"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clickhouse/connect/test", params, &resp); err != nil {
+		return ConnectionDiagnostics{}, err
+	}
+	return resp.Diagnostics, nil
+}
+
+// SessionID returns the ClickHouse session this client uses, or "" when not connected
+func (c *Client) SessionID() string {
+	return c.headers.Get(sessionHeader)
+}
+
+// DisconnectClickHouse closes the client's ClickHouse session
+func (c *Client) DisconnectClickHouse(ctx context.Context) error {
+	req := DisconnectRequest{SessionID: c.SessionID()}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clickhouse/disconnect", req, nil); err != nil {
+		return err
+	}
+	c.headers.Del(sessionHeader)
+	return nil
+}
+
+// RefreshToken replaces the JWT of the client's ClickHouse session before it expires
+func (c *Client) RefreshToken(ctx context.Context, token string) error {
+	req := TokenRefreshRequest{SessionID: c.SessionID(), Token: token}
+	return c.do(ctx, http.MethodPost, "/api/v1/clickhouse/token", req, nil)
+}
+
+// GetTableColumns returns the columns of a ClickHouse table
+func (c *Client) GetTableColumns(ctx context.Context, tableName string) ([]Column, error) {
+	var resp struct {
+		Columns []Column `json:"columns"`
+	}
+	path := "/api/v1/clickhouse/tables/" + url.PathEscape(tableName) + "/columns"
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Columns, nil
+}
+
+// GetTableDDL returns the CREATE TABLE statement of a ClickHouse table
+func (c *Client) GetTableDDL(ctx context.Context, tableName string) (string, error) {
+	var resp struct {
+		DDL string `json:"ddl"`
+	}
+	path := "/api/v1/clickhouse/tables/" + url.PathEscape(tableName) + "/ddl"
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.DDL, nil
+}
+
+// DiscoverFlatFileSchema discovers the schema of a flat file
+func (c *Client) DiscoverFlatFileSchema(ctx context.Context, params FlatFileParams) ([]Column, error) {
+	schema, err := c.DiscoverFileSchema(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return schema.Columns, nil
+}
+
+// DiscoverFileSchema discovers the schema of a flat file, with the file's
+// fingerprint and the header names renamed to keep columns unique
+func (c *Client) DiscoverFileSchema(ctx context.Context, params FlatFileParams) (FileSchema, error) {
+	var resp FileSchema
+	if err := c.do(ctx, http.MethodPost, "/api/v1/flatfile/schema", params, &resp); err != nil {
+		return FileSchema{}, err
+	}
+	return resp, nil
+}
+
+// OverrideFileSchema applies column overrides to the discovered schema of a flat
+// file, checking them against sampled rows; pass the returned columns to ingestion
+func (c *Client) OverrideFileSchema(ctx context.Context, req SchemaOverrideRequest) (SchemaOverrideResult, error) {
+	var resp struct {
+		Schema SchemaOverrideResult `json:"schema"`
+	}
+	if err := c.do(ctx, http.MethodPatch, "/api/v1/flatfile/schema", req, &resp); err != nil {
+		return SchemaOverrideResult{}, err
+	}
+	return resp.Schema, nil
+}
+
+// PreviewData returns preview rows from a ClickHouse table or flat file
+func (c *Client) PreviewData(ctx context.Context, params PreviewParams) ([]map[string]interface{}, error) {
+	preview, err := c.Preview(ctx, params)
+	return preview.Data, err
+}
+
+// PreviewResult is a preview along with how its values were rendered and truncated
+type PreviewResult struct {
+	Data []map[string]interface{} `json:"data"`
+
+	// Encoding ("hex" or "base64") applied to each binary column
+	BinaryColumns map[string]string `json:"binaryColumns"`
+
+	// Format ("wkt" or "geojson") applied to each geo column
+	GeoColumns map[string]string `json:"geoColumns"`
+	Truncation PreviewTruncation `json:"truncation"`
+
+	// Set when previewing a dead-letter file: the column holding each row's reject reason
+	RejectReasonColumn string `json:"rejectReasonColumn,omitempty"`
+}
+
+// Preview returns preview rows with binary and geo rendering hints and truncation metadata
+func (c *Client) Preview(ctx context.Context, params PreviewParams) (PreviewResult, error) {
+	var resp PreviewResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/preview", params, &resp); err != nil {
+		return PreviewResult{}, err
+	}
+	return resp, nil
+}
+
+// JoinPreview builds a join query and returns it with preview rows
+func (c *Client) JoinPreview(ctx context.Context, params JoinParams) (string, []map[string]interface{}, error) {
+	var resp struct {
+		Query string                   `json:"query"`
+		Data  []map[string]interface{} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/join/preview", params, &resp); err != nil {
+		return "", nil, err
+	}
+	return resp.Query, resp.Data, nil
+}
+
+// CountRows returns the exact or estimated number of rows of a table, join or flat file
+func (c *Client) CountRows(ctx context.Context, params CountParams) (RowCount, error) {
+	var resp struct {
+		Count RowCount `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/count", params, &resp); err != nil {
+		return RowCount{}, err
+	}
+	return resp.Count, nil
+}
+
+// ProfileData returns per-column statistics of a table or a sample of a flat file
+func (c *Client) ProfileData(ctx context.Context, params ProfileParams) (DataProfile, error) {
+	var resp struct {
+		Profile DataProfile `json:"profile"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/profile", params, &resp); err != nil {
+		return DataProfile{}, err
+	}
+	return resp.Profile, nil
+}
+
+// GetJob returns a job record
+func (c *Client) GetJob(ctx context.Context, id string) (Job, error) {
+	var resp struct {
+		Job Job `json:"job"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id), nil, &resp); err != nil {
+		return Job{}, err
+	}
+	return resp.Job, nil
+}
+
+// GetJobSummary returns the CI verdict for a job using the server's default thresholds
+func (c *Client) GetJobSummary(ctx context.Context, id string) (JobSummary, error) {
+	var summary JobSummary
+	if err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id)+"/summary", nil, &summary); err != nil {
+		return JobSummary{}, err
+	}
+	return summary, nil
+}
+
+// CancelJob stops a running job
+func (c *Client) CancelJob(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/jobs/"+url.PathEscape(id)+"/cancel", nil, nil)
+}
+
+// ReingestDeadLetter re-runs a flat file job on its dead-letter file, or on the corrected
+// copy named in req, and returns the new job
+func (c *Client) ReingestDeadLetter(ctx context.Context, id string, req ReingestRequest) (Job, error) {
+	var resp struct {
+		Job Job `json:"job"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/jobs/"+url.PathEscape(id)+"/reingest", req, &resp); err != nil {
+		return Job{}, err
+	}
+	return resp.Job, nil
+}
+
+// CreateSchedule registers a recurring ingestion
+func (c *Client) CreateSchedule(ctx context.Context, req ScheduleRequest) (Schedule, error) {
+	var resp struct {
+		Schedule Schedule `json:"schedule"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/schedules", req, &resp); err != nil {
+		return Schedule{}, err
+	}
+	return resp.Schedule, nil
+}
+
+// ListSchedules returns all schedules
+func (c *Client) ListSchedules(ctx context.Context) ([]Schedule, error) {
+	var resp struct {
+		Schedules []Schedule `json:"schedules"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/schedules", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Schedules, nil
+}
+
+// GetSchedule returns a schedule
+func (c *Client) GetSchedule(ctx context.Context, id string) (Schedule, error) {
+	var resp struct {
+		Schedule Schedule `json:"schedule"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/schedules/"+url.PathEscape(id), nil, &resp); err != nil {
+		return Schedule{}, err
+	}
+	return resp.Schedule, nil
+}
+
+// DeleteSchedule removes a schedule
+func (c *Client) DeleteSchedule(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/schedules/"+url.PathEscape(id), nil, nil)
+}
+
+// SetScheduleEnabled enables or disables a schedule
+func (c *Client) SetScheduleEnabled(ctx context.Context, id string, enabled bool) (Schedule, error) {
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	var resp struct {
+		Schedule Schedule `json:"schedule"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/schedules/"+url.PathEscape(id)+"/"+action, nil, &resp); err != nil {
+		return Schedule{}, err
+	}
+	return resp.Schedule, nil
+}
+
+// PreflightSchedule validates the next run of a schedule without starting it
+func (c *Client) PreflightSchedule(ctx context.Context, id string) (PreflightResult, error) {
+	var resp struct {
+		Preflight PreflightResult `json:"preflight"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/schedules/"+url.PathEscape(id)+"/preflight", nil, &resp); err != nil {
+		return PreflightResult{}, err
+	}
+	return resp.Preflight, nil
+}
+
+// CreateTemplate saves a named ingestion template
+func (c *Client) CreateTemplate(ctx context.Context, req SavedTemplateRequest) (SavedTemplate, error) {
+	var resp struct {
+		Template SavedTemplate `json:"template"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/templates", req, &resp); err != nil {
+		return SavedTemplate{}, err
+	}
+	return resp.Template, nil
+}
+
+// ListTemplates returns all saved templates
+func (c *Client) ListTemplates(ctx context.Context) ([]SavedTemplate, error) {
+	var resp struct {
+		Templates []SavedTemplate `json:"templates"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/templates", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Templates, nil
+}
+
+// GetTemplate returns a saved template
+func (c *Client) GetTemplate(ctx context.Context, name string) (SavedTemplate, error) {
+	var resp struct {
+		Template SavedTemplate `json:"template"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/templates/"+url.PathEscape(name), nil, &resp); err != nil {
+		return SavedTemplate{}, err
+	}
+	return resp.Template, nil
+}
+
+// UpdateTemplate replaces the description and params of a saved template
+func (c *Client) UpdateTemplate(ctx context.Context, name string, req SavedTemplateRequest) (SavedTemplate, error) {
+	var resp struct {
+		Template SavedTemplate `json:"template"`
+	}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/templates/"+url.PathEscape(name), req, &resp); err != nil {
+		return SavedTemplate{}, err
+	}
+	return resp.Template, nil
+}
+
+// DeleteTemplate removes a saved template
+func (c *Client) DeleteTemplate(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/templates/"+url.PathEscape(name), nil, nil)
+}
+
+// RunTemplate starts a saved template as a background job on the client's session
+func (c *Client) RunTemplate(ctx context.Context, name string) (Job, error) {
+	var resp struct {
+		Job Job `json:"job"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/templates/"+url.PathEscape(name)+"/run", nil, &resp); err != nil {
+		return Job{}, err
+	}
+	return resp.Job, nil
+}
+
+// ListScheduleStats returns the data quality stats of every schedule that has run
+func (c *Client) ListScheduleStats(ctx context.Context) ([]ScheduleStats, error) {
+	var resp struct {
+		Stats []ScheduleStats `json:"stats"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/schedules", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Stats, nil
+}
+
+// GetScheduleStats returns the data quality stats of a schedule
+func (c *Client) GetScheduleStats(ctx context.Context, id string) (ScheduleStats, error) {
+	var resp struct {
+		Stats ScheduleStats `json:"stats"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/schedules/"+url.PathEscape(id), nil, &resp); err != nil {
+		return ScheduleStats{}, err
+	}
+	return resp.Stats, nil
+}
+
+// ListQuotaUsage returns the consumption of every quota in the current day
+func (c *Client) ListQuotaUsage(ctx context.Context) ([]QuotaUsage, error) {
+	var resp struct {
+		Quotas []QuotaUsage `json:"quotas"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/quotas", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Quotas, nil
+}
+
+// ListPipelines returns all pipelines declared on the server
+func (c *Client) ListPipelines(ctx context.Context) ([]Pipeline, error) {
+	var resp struct {
+		Pipelines []Pipeline `json:"pipelines"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/pipelines", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Pipelines, nil
+}
+
+// GetPipeline returns a declared pipeline
+func (c *Client) GetPipeline(ctx context.Context, name string) (Pipeline, error) {
+	var resp struct {
+		Pipeline Pipeline `json:"pipeline"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/pipelines/"+url.PathEscape(name), nil, &resp); err != nil {
+		return Pipeline{}, err
+	}
+	return resp.Pipeline, nil
+}
+
+// RunPipeline starts a declared pipeline in the background and returns its run
+func (c *Client) RunPipeline(ctx context.Context, name string) (PipelineRun, error) {
+	var resp struct {
+		Run PipelineRun `json:"run"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/pipelines/"+url.PathEscape(name)+"/run", nil, &resp); err != nil {
+		return PipelineRun{}, err
+	}
+	return resp.Run, nil
+}
+
+// GetPipelineRun returns a run of a declared pipeline with the outputs of its finished steps
+func (c *Client) GetPipelineRun(ctx context.Context, name, id string) (PipelineRun, error) {
+	var resp struct {
+		Run PipelineRun `json:"run"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/pipelines/"+url.PathEscape(name)+"/runs/"+url.PathEscape(id), nil, &resp); err != nil {
+		return PipelineRun{}, err
+	}
+	return resp.Run, nil
+}
+
+// Apply reconciles a bundle of templates, pipelines and schedules with the server.
+// With prune, managed objects missing from the bundle are deleted.
+func (c *Client) Apply(ctx context.Context, bundle ApplyBundle, prune bool) (ApplyResult, error) {
+	path := "/api/v1/apply"
+	if prune {
+		path += "?prune=true"
+	}
+	var resp struct {
+		Result ApplyResult `json:"result"`
+	}
+	if err := c.do(ctx, http.MethodPost, path, bundle, &resp); err != nil {
+		return ApplyResult{}, err
+	}
+	return resp.Result, nil
+}
+
+// Plan reports the changes Apply would make for a bundle without making any
+func (c *Client) Plan(ctx context.Context, bundle ApplyBundle, prune bool) (ApplyResult, error) {
+	path := "/api/v1/plan"
+	if prune {
+		path += "?prune=true"
+	}
+	var resp struct {
+		Result ApplyResult `json:"result"`
+	}
+	if err := c.do(ctx, http.MethodPost, path, bundle, &resp); err != nil {
+		return ApplyResult{}, err
+	}
+	return resp.Result, nil
+}
+
+// Export returns the server's current configuration as a bundle
+func (c *Client) Export(ctx context.Context) (ApplyBundle, error) {
+	var resp struct {
+		Bundle ApplyBundle `json:"bundle"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/export", nil, &resp); err != nil {
+		return ApplyBundle{}, err
+	}
+	return resp.Bundle, nil
+}
+
+// Doctor validates the server's configuration and returns its readiness report;
+// notify sends a test Slack message
+func (c *Client) Doctor(ctx context.Context, notify bool) (DoctorReport, error) {
+	path := "/api/v1/doctor"
+	if notify {
+		path += "?notify=true"
+	}
+	var resp struct {
+		Report DoctorReport `json:"report"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return DoctorReport{}, err
+	}
+	return resp.Report, nil
+}
+
+// IngestionStream is a running ingestion whose progress is delivered on Updates
+type IngestionStream struct {
+	JobID   string
+	Updates <-chan ProgressUpdate
+}
+
+// StartIngestion starts an ingestion job and streams its progress updates.
+// The Updates channel is closed after the final update or when ctx is canceled;
+// canceling ctx disconnects from the server, which stops the job.
+func (c *Client) StartIngestion(ctx context.Context, params IngestionParams) (*IngestionStream, error) {
+	return c.stream(ctx, http.MethodPost, "/api/v1/ingest", params)
+}
+
+// EstimateCost returns the estimated cost of a job at the server's unit costs
+func (c *Client) EstimateCost(ctx context.Context, params IngestionParams) (CostEstimate, error) {
+	var resp struct {
+		Cost CostEstimate `json:"cost"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/ingest/estimate", params, &resp); err != nil {
+		return CostEstimate{}, err
+	}
+	return resp.Cost, nil
+}
+
+// ExplainQuery returns ClickHouse's plan for the query an export would run
+func (c *Client) ExplainQuery(ctx context.Context, params IngestionParams) (QueryPlan, error) {
+	var resp struct {
+		Plan QueryPlan `json:"plan"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/ingest/explain", params, &resp); err != nil {
+		return QueryPlan{}, err
+	}
+	return resp.Plan, nil
+}
+
+// ValidateQuery checks custom SQL for read-only safety and syntax without running it
+func (c *Client) ValidateQuery(ctx context.Context, query string) (QueryValidation, error) {
+	var resp struct {
+		Validation QueryValidation `json:"validation"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/query/validate", QueryValidationRequest{Query: query}, &resp); err != nil {
+		return QueryValidation{}, err
+	}
+	return resp.Validation, nil
+}
+
+// ValidateIngestion checks the columns a flat file load would insert against the
+// target table, or the table it would create, without moving any data
+func (c *Client) ValidateIngestion(ctx context.Context, params IngestionParams) (IngestionValidation, error) {
+	var resp struct {
+		Validation IngestionValidation `json:"validation"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/ingest/validate", params, &resp); err != nil {
+		return IngestionValidation{}, err
+	}
+	return resp.Validation, nil
+}
+
+// StartSchemaDiscovery discovers a flat file's schema in a background job; follow
+// it with StreamJob or GetJob, whose result carries the columns once it completes
+func (c *Client) StartSchemaDiscovery(ctx context.Context, params FlatFileParams) (Job, error) {
+	var resp struct {
+		Job Job `json:"job"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/flatfile/schema/jobs", params, &resp); err != nil {
+		return Job{}, err
+	}
+	return resp.Job, nil
+}
+
+// StreamJob streams the progress of a running job until it completes. Unlike
+// StartIngestion, canceling ctx only stops following the job.
+func (c *Client) StreamJob(ctx context.Context, id string) (*IngestionStream, error) {
+	return c.stream(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id)+"/events", nil)
+}
+
+// stream sends a request answered with server-sent progress updates
+func (c *Client) stream(ctx context.Context, method, path string, body interface{}) (*IngestionStream, error) {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// Progress streams outlive the client's default timeout
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+
+	updates := make(chan ProgressUpdate, 10)
+	go func() {
+		defer resp.Body.Close()
+		defer close(updates)
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+
+			var update ProgressUpdate
+			if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &update); err != nil {
+				continue
+			}
+
+			select {
+			case updates <- update:
+			case <-ctx.Done():
+				return
+			}
+			if update.Completed {
+				return
+			}
+		}
+	}()
+
+	return &IngestionStream{
+		JobID:   resp.Header.Get("X-Job-ID"),
+		Updates: updates,
+	}, nil
+}
+
+// do sends a JSON request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// newRequest builds a request with the client's headers and an optional JSON body
+func (c *Client) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range c.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// decodeError converts an error response into an APIError
+func decodeError(resp *http.Response) error {
+	var payload struct {
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Message == "" {
+		payload.Message = strings.TrimSpace(string(data))
+	}
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    payload.Message,
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+}
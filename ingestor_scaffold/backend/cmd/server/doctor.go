@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/middleware"
+	"github.com/ingestor/internal/model"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// runDoctor validates the configuration, prints a readiness report and returns the
+// exit code: 0 when ready, 1 when a check failed
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	notify := flags.Bool("notify", false, "send a test Slack message")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Only problems the checks do not report themselves are logged
+	log := logrus.New()
+	log.SetOutput(os.Stderr)
+	log.SetLevel(logrus.ErrorLevel)
+
+	var report model.DoctorReport
+	if cfg, err := config.Load(); err != nil {
+		report = model.DoctorReport{
+			Checks:    []model.DoctorCheck{{Name: "config", Status: service.DoctorFail, Message: err.Error()}},
+			CheckedAt: time.Now(),
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		report = service.Doctor(ctx, cfg, log, *notify, middleware.DoctorChecks(cfg)...)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		for _, check := range report.Checks {
+			line := fmt.Sprintf("[%s] %s", check.Status, check.Name)
+			if check.Message != "" {
+				line += ": " + check.Message
+			}
+			fmt.Println(line)
+		}
+		if report.Ready {
+			fmt.Println("ready")
+		} else {
+			fmt.Println("not ready")
+		}
+	}
+
+	if !report.Ready {
+		return 1
+	}
+	return 0
+}
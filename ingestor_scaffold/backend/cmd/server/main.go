@@ -13,6 +13,11 @@ import (
 )
 
 func main() {
+	// "doctor" checks the configuration and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor(os.Args[2:]))
+	}
+
 	// Setup logger
 	log := logrus.New()
 	log.SetFormatter(&logrus.JSONFormatter{})
@@ -0,0 +1,325 @@
+// Package codegen generates client code from the API model types
+package codegen
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Endpoint describes an API endpoint exposed in generated clients
+type Endpoint struct {
+	Name     string
+	Method   string
+	Path     string
+	Request  interface{}
+	Response string
+	Stream   bool
+}
+
+// Endpoints lists the API endpoints included in generated clients
+var Endpoints = []Endpoint{
+	{Name: "connectToClickHouse", Method: "POST", Path: "/api/v1/clickhouse/connect", Request: model.ClickHouseConnectionParams{}, Response: "{ status: string; sessionId: string; tables: string[] }"},
+	{Name: "testClickHouseConnection", Method: "POST", Path: "/api/v1/clickhouse/connect/test", Request: model.ClickHouseConnectionParams{}, Response: "{ status: string; diagnostics: ConnectionDiagnostics }"},
+	{Name: "disconnectClickHouse", Method: "POST", Path: "/api/v1/clickhouse/disconnect", Request: model.DisconnectRequest{}, Response: "{ status: string }"},
+	{Name: "refreshClickHouseToken", Method: "POST", Path: "/api/v1/clickhouse/token", Request: model.TokenRefreshRequest{}, Response: "{ status: string }"},
+	{Name: "getTableColumns", Method: "GET", Path: "/api/v1/clickhouse/tables/:tableName/columns", Response: "{ status: string; columns: Column[] }"},
+	{Name: "getTableDDL", Method: "GET", Path: "/api/v1/clickhouse/tables/:tableName/ddl", Response: "{ status: string; ddl: string }"},
+	{Name: "startSchemaDiscovery", Method: "POST", Path: "/api/v1/flatfile/schema/jobs", Request: model.FlatFileParams{}, Response: "{ status: string; job: Job }"},
+	{Name: "discoverFlatFileSchema", Method: "POST", Path: "/api/v1/flatfile/schema", Request: model.FlatFileParams{}, Response: "{ status: string; columns: Column[]; fingerprint: string; renames?: HeaderRename[]; inference?: ColumnInference[]; rejectReasonColumn?: string }"},
+	{Name: "overrideFlatFileSchema", Method: "PATCH", Path: "/api/v1/flatfile/schema", Request: model.SchemaOverrideRequest{}, Response: "{ status: string; schema: SchemaOverrideResult }"},
+	{Name: "previewData", Method: "POST", Path: "/api/v1/preview", Request: model.PreviewParams{}, Response: "{ status: string; data: Record<string, unknown>[]; count: number; binaryColumns: Record<string, string>; geoColumns: Record<string, string>; truncation: PreviewTruncation; offset: number; nextOffset?: number; nextCursor?: string; rejectReasonColumn?: string }"},
+	{Name: "joinPreview", Method: "POST", Path: "/api/v1/join/preview", Request: model.JoinParams{}, Response: "{ status: string; query: string; args?: unknown[]; data: Record<string, unknown>[]; count: number; binaryColumns: Record<string, string>; geoColumns: Record<string, string>; truncation: PreviewTruncation }"},
+	{Name: "countRows", Method: "POST", Path: "/api/v1/count", Request: model.CountParams{}, Response: "{ status: string; count: RowCount }"},
+	{Name: "profileData", Method: "POST", Path: "/api/v1/profile", Request: model.ProfileParams{}, Response: "{ status: string; profile: DataProfile }"},
+	{Name: "startIngestion", Method: "POST", Path: "/api/v1/ingest", Request: model.IngestionParams{}, Stream: true},
+	{Name: "estimateCost", Method: "POST", Path: "/api/v1/ingest/estimate", Request: model.IngestionParams{}, Response: "{ status: string; cost: CostEstimate; configured: boolean }"},
+	{Name: "explainQuery", Method: "POST", Path: "/api/v1/ingest/explain", Request: model.IngestionParams{}, Response: "{ status: string; plan: QueryPlan }"},
+	{Name: "validateIngestion", Method: "POST", Path: "/api/v1/ingest/validate", Request: model.IngestionParams{}, Response: "{ status: string; validation: IngestionValidation }"},
+	{Name: "validateQuery", Method: "POST", Path: "/api/v1/query/validate", Request: model.QueryValidationRequest{}, Response: "{ status: string; validation: QueryValidation }"},
+	{Name: "listJobs", Method: "GET", Path: "/api/v1/jobs", Response: "{ status: string; jobs: Job[] }"},
+	{Name: "getJob", Method: "GET", Path: "/api/v1/jobs/:id", Response: "{ status: string; job: Job }"},
+	{Name: "streamJob", Method: "GET", Path: "/api/v1/jobs/:id/events", Stream: true},
+	{Name: "getJobSummary", Method: "GET", Path: "/api/v1/jobs/:id/summary", Response: "JobSummary"},
+	{Name: "cancelJob", Method: "POST", Path: "/api/v1/jobs/:id/cancel", Response: "{ status: string }"},
+	{Name: "reingestDeadLetter", Method: "POST", Path: "/api/v1/jobs/:id/reingest", Request: model.ReingestRequest{}, Response: "{ status: string; job: Job }"},
+	{Name: "createSchedule", Method: "POST", Path: "/api/v1/schedules", Request: model.ScheduleRequest{}, Response: "{ status: string; schedule: Schedule }"},
+	{Name: "listSchedules", Method: "GET", Path: "/api/v1/schedules", Response: "{ status: string; schedules: Schedule[] }"},
+	{Name: "getSchedule", Method: "GET", Path: "/api/v1/schedules/:id", Response: "{ status: string; schedule: Schedule }"},
+	{Name: "deleteSchedule", Method: "DELETE", Path: "/api/v1/schedules/:id", Response: "{ status: string }"},
+	{Name: "enableSchedule", Method: "POST", Path: "/api/v1/schedules/:id/enable", Response: "{ status: string; schedule: Schedule }"},
+	{Name: "disableSchedule", Method: "POST", Path: "/api/v1/schedules/:id/disable", Response: "{ status: string; schedule: Schedule }"},
+	{Name: "preflightSchedule", Method: "POST", Path: "/api/v1/schedules/:id/preflight", Response: "{ status: string; preflight: PreflightResult }"},
+	{Name: "createTemplate", Method: "POST", Path: "/api/v1/templates", Request: model.SavedTemplateRequest{}, Response: "{ status: string; template: SavedTemplate }"},
+	{Name: "listTemplates", Method: "GET", Path: "/api/v1/templates", Response: "{ status: string; templates: SavedTemplate[] }"},
+	{Name: "getTemplate", Method: "GET", Path: "/api/v1/templates/:name", Response: "{ status: string; template: SavedTemplate }"},
+	{Name: "updateTemplate", Method: "PUT", Path: "/api/v1/templates/:name", Request: model.SavedTemplateRequest{}, Response: "{ status: string; template: SavedTemplate }"},
+	{Name: "deleteTemplate", Method: "DELETE", Path: "/api/v1/templates/:name", Response: "{ status: string }"},
+	{Name: "runTemplate", Method: "POST", Path: "/api/v1/templates/:name/run", Response: "{ status: string; job: Job }"},
+	{Name: "listScheduleStats", Method: "GET", Path: "/api/v1/stats/schedules", Response: "{ status: string; stats: ScheduleStats[] }"},
+	{Name: "getScheduleStats", Method: "GET", Path: "/api/v1/stats/schedules/:id", Response: "{ status: string; stats: ScheduleStats }"},
+	{Name: "listQuotaUsage", Method: "GET", Path: "/api/v1/stats/quotas", Response: "{ status: string; quotas: QuotaUsage[] }"},
+	{Name: "listPipelines", Method: "GET", Path: "/api/v1/pipelines", Response: "{ status: string; pipelines: Pipeline[] }"},
+	{Name: "getPipeline", Method: "GET", Path: "/api/v1/pipelines/:name", Response: "{ status: string; pipeline: Pipeline }"},
+	{Name: "runPipeline", Method: "POST", Path: "/api/v1/pipelines/:name/run", Response: "{ status: string; run: PipelineRun }"},
+	{Name: "getPipelineRun", Method: "GET", Path: "/api/v1/pipelines/:name/runs/:id", Response: "{ status: string; run: PipelineRun }"},
+	{Name: "getDoctorReport", Method: "GET", Path: "/api/v1/doctor", Response: "{ status: string; report: DoctorReport }"},
+	{Name: "applyBundle", Method: "POST", Path: "/api/v1/apply", Request: model.ApplyBundle{}, Response: "{ status: string; result: ApplyResult }"},
+	{Name: "planBundle", Method: "POST", Path: "/api/v1/plan", Request: model.ApplyBundle{}, Response: "{ status: string; result: ApplyResult }"},
+	{Name: "exportBundle", Method: "GET", Path: "/api/v1/export", Response: "{ status: string; bundle: ApplyBundle }"},
+}
+
+// Types lists the model types emitted as TypeScript interfaces
+var Types = []interface{}{
+	model.Column{},
+	model.ClickHouseConnectionParams{},
+	model.ConnectionDiagnostics{},
+	model.DisconnectRequest{},
+	model.TokenRefreshRequest{},
+	model.FlatFileParams{},
+	model.FileSchema{},
+	model.SchemaOverrideRequest{},
+	model.SchemaOverrideResult{},
+	model.PreviewParams{},
+	model.PreviewTruncation{},
+	model.CountParams{},
+	model.RowCount{},
+	model.ProfileParams{},
+	model.DataProfile{},
+	model.CostEstimate{},
+	model.QueryPlan{},
+	model.ReadEstimate{},
+	model.QueryValidationRequest{},
+	model.QueryValidation{},
+	model.IngestionValidation{},
+	model.IngestionParams{},
+	model.TableOptions{},
+	model.NotificationSpec{},
+	model.JSONPathColumn{},
+	model.DDLRewrite{},
+	model.ChaosSpec{},
+	model.JoinKey{},
+	model.JoinTableInfo{},
+	model.JoinParams{},
+	model.ProgressUpdate{},
+	model.IngestionResult{},
+	model.ColumnLineage{},
+	model.ExportManifest{},
+	model.Job{},
+	model.JobSummary{},
+	model.ReingestRequest{},
+	model.Schedule{},
+	model.ScheduleRequest{},
+	model.PreflightResult{},
+	model.ScheduleStats{},
+	model.SavedTemplate{},
+	model.SavedTemplateRequest{},
+	model.Quota{},
+	model.QuotaUsage{},
+	model.Pipeline{},
+	model.PipelineRun{},
+	model.ApplyBundle{},
+	model.ApplyResult{},
+	model.DoctorReport{},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// TypeScriptTypes renders the model types as TypeScript interfaces
+func TypeScriptTypes() string {
+	var b strings.Builder
+	b.WriteString("// Code generated by the ingestor API. DO NOT EDIT.\n\n")
+
+	// Collect nested struct types so every referenced interface is emitted
+	seen := make(map[string]reflect.Type)
+	for _, v := range Types {
+		collectStructs(reflect.TypeOf(v), seen)
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		writeInterface(&b, seen[name])
+	}
+	return b.String()
+}
+
+// TypeScriptClient renders the types followed by a fetch-based client
+func TypeScriptClient() string {
+	var b strings.Builder
+	b.WriteString(TypeScriptTypes())
+	b.WriteString(clientPrelude)
+
+	for _, ep := range Endpoints {
+		params := pathParams(ep.Path)
+		args := make([]string, 0, len(params)+1)
+		for _, p := range params {
+			args = append(args, p+": string")
+		}
+		if ep.Request != nil {
+			args = append(args, "body: "+reflect.TypeOf(ep.Request).Name())
+		}
+
+		// Build the path expression with escaped parameters
+		path := ep.Path
+		for _, p := range params {
+			path = strings.Replace(path, ":"+p, "${encodeURIComponent("+p+")}", 1)
+		}
+		body := "undefined"
+		if ep.Request != nil {
+			body = "body"
+		}
+
+		if ep.Stream {
+			fmt.Fprintf(&b, "\n  %s(%s, onUpdate: (update: ProgressUpdate) => void, signal?: AbortSignal): Promise<string> {\n", ep.Name, strings.Join(args, ", "))
+			fmt.Fprintf(&b, "    return this.stream('%s', `%s`, %s, onUpdate, signal);\n  }\n", ep.Method, path, body)
+			continue
+		}
+		fmt.Fprintf(&b, "\n  %s(%s): Promise<%s> {\n", ep.Name, strings.Join(args, ", "), ep.Response)
+		fmt.Fprintf(&b, "    return this.request('%s', `%s`, %s);\n  }\n", ep.Method, path, body)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// collectStructs records t and every struct type reachable from its fields
+func collectStructs(t reflect.Type, seen map[string]reflect.Type) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return
+	}
+	if _, ok := seen[t.Name()]; ok {
+		return
+	}
+	seen[t.Name()] = t
+	for i := 0; i < t.NumField(); i++ {
+		collectStructs(t.Field(i).Type, seen)
+	}
+}
+
+// writeInterface emits a TypeScript interface for a struct type
+func writeInterface(b *strings.Builder, t reflect.Type) {
+	fmt.Fprintf(b, "export interface %s {\n", t.Name())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		optional := ""
+		if strings.Contains(opts, "omitempty") || field.Type.Kind() == reflect.Ptr {
+			optional = "?"
+		}
+
+		fmt.Fprintf(b, "  %s%s: %s;\n", name, optional, tsType(field.Type))
+	}
+	b.WriteString("}\n\n")
+}
+
+// tsType maps a Go type to its TypeScript equivalent
+func tsType(t reflect.Type) string {
+	if t == timeType {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return tsType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return tsType(t.Elem()) + "[]"
+	case reflect.Map:
+		return "Record<string, " + tsType(t.Elem()) + ">"
+	case reflect.Struct:
+		return t.Name()
+	default:
+		return "unknown"
+	}
+}
+
+// pathParams returns the names of :param segments in a route path
+func pathParams(path string) []string {
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segment[1:])
+		}
+	}
+	return params
+}
+
+const clientPrelude = `export class IngestorClient {
+  constructor(private baseUrl: string = '', private headers: Record<string, string> = {}) {}
+
+  // setSession sends the ClickHouse session ID returned by connectToClickHouse with every request
+  setSession(sessionId: string): void {
+    this.headers = { ...this.headers, 'X-Session-ID': sessionId };
+  }
+
+  private async request<T>(method: string, path: string, body?: unknown): Promise<T> {
+    const res = await fetch(this.baseUrl + path, {
+      method,
+      headers: { 'Content-Type': 'application/json', ...this.headers },
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const payload = await res.json();
+    if (!res.ok) {
+      throw new Error(payload.message || res.statusText);
+    }
+    return payload as T;
+  }
+
+  private async stream(method: string, path: string, body: unknown, onUpdate: (update: ProgressUpdate) => void, signal?: AbortSignal): Promise<string> {
+    const res = await fetch(this.baseUrl + path, {
+      method,
+      headers: { 'Content-Type': 'application/json', Accept: 'text/event-stream', ...this.headers },
+      body: body === undefined ? undefined : JSON.stringify(body),
+      signal,
+    });
+    if (!res.ok || !res.body) {
+      throw new Error(res.statusText);
+    }
+    const jobId = res.headers.get('X-Job-ID') || '';
+    const reader = res.body.getReader();
+    const decoder = new TextDecoder();
+    let buffer = '';
+    for (;;) {
+      const { done, value } = await reader.read();
+      if (done) {
+        return jobId;
+      }
+      buffer += decoder.decode(value, { stream: true });
+      const events = buffer.split('\n\n');
+      buffer = events.pop() || '';
+      for (const event of events) {
+        if (event.startsWith('data:')) {
+          onUpdate(JSON.parse(event.slice(5).trim()) as ProgressUpdate);
+        }
+      }
+    }
+  }
+`
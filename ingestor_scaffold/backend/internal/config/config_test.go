@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAllowedFileRoots(t *testing.T) {
+	tests := []struct {
+		environment, roots string
+		ok                 bool
+	}{
+		{"development", "", true},
+		{"production", "", false},
+		{"staging", "", false},
+		{"production", "/data", true},
+		{"production", " , ", false},
+		{"development", " , ", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENVIRONMENT", tt.environment)
+		t.Setenv("ALLOWED_FILE_ROOTS", tt.roots)
+		_, err := Load()
+		if tt.ok {
+			assert.NoError(t, err, "%s %q", tt.environment, tt.roots)
+		} else {
+			assert.Error(t, err, "%s %q", tt.environment, tt.roots)
+		}
+	}
+}
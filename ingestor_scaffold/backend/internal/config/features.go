@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Optional features, turned off with DISABLED_FEATURES or the minimal build
+const (
+	FeatureScheduler     = "scheduler"
+	FeatureNotifications = "notifications"
+	FeaturePipelines     = "pipelines"
+	FeatureApply         = "apply"
+	FeatureTemplates     = "templates"
+	FeatureSDK           = "sdk"
+)
+
+// features lists every optional feature
+var features = []string{FeatureScheduler, FeatureNotifications, FeaturePipelines, FeatureApply, FeatureTemplates, FeatureSDK}
+
+// featureDependencies are the features each feature needs; a feature is off when
+// any of them is
+var featureDependencies = map[string][]string{
+	FeatureApply: {FeatureScheduler, FeaturePipelines},
+}
+
+// FeatureEnabled reports whether an optional feature is on
+func (c *Config) FeatureEnabled(name string) bool {
+	for _, disabled := range strings.Split(c.DisabledFeatures, ",") {
+		if strings.TrimSpace(disabled) == name {
+			return false
+		}
+	}
+	for _, dependency := range featureDependencies[name] {
+		if !c.FeatureEnabled(dependency) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateFeatures checks that DISABLED_FEATURES names only known features
+func validateFeatures(disabled string) error {
+	for _, name := range strings.Split(disabled, ",") {
+		name = strings.TrimSpace(name)
+		known := name == ""
+		for _, feature := range features {
+			known = known || feature == name
+		}
+		if !known {
+			return fmt.Errorf("invalid DISABLED_FEATURES entry %q: must be one of %s", name, strings.Join(features, ", "))
+		}
+	}
+	return nil
+}
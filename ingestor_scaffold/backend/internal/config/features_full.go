@@ -0,0 +1,6 @@
+//go:build !minimal
+
+package config
+
+// defaultDisabledFeatures is empty in the full build
+const defaultDisabledFeatures = ""
@@ -0,0 +1,7 @@
+//go:build minimal
+
+package config
+
+// defaultDisabledFeatures turns every optional feature off in the minimal build
+// (go build -tags minimal); DISABLED_FEATURES still overrides it
+const defaultDisabledFeatures = "scheduler,notifications,pipelines,apply,templates,sdk"
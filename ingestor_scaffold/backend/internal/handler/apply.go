@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// maxBundleBytes bounds the size of an apply request body
+const maxBundleBytes = 4 << 20
+
+// ApplyHandler handles declarative configuration endpoints
+type ApplyHandler struct {
+	applyService service.ApplyService
+	cfg          *config.Config
+	logger       *logrus.Logger
+}
+
+// NewApplyHandler creates a new apply handler
+func NewApplyHandler(
+	applyService service.ApplyService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *ApplyHandler {
+	return &ApplyHandler{
+		applyService: applyService,
+		cfg:          cfg,
+		logger:       logger,
+	}
+}
+
+// Apply reconciles a YAML (or JSON) bundle of templates, pipelines and schedules.
+// ?prune=true also deletes managed objects missing from the bundle.
+func (h *ApplyHandler) Apply(c *gin.Context) {
+	bundle, ok := bindBundle(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.applyService.Apply(bundle, c.Query("prune") == "true")
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to apply configuration")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+			"result":  result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"result": result,
+	})
+}
+
+// Plan reports what applying a bundle would create, update or delete without
+// changing anything. It takes the same body and ?prune flag as Apply.
+func (h *ApplyHandler) Plan(c *gin.Context) {
+	bundle, ok := bindBundle(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.applyService.Plan(bundle, c.Query("prune") == "true")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"result": result,
+	})
+}
+
+// Export returns the current configuration as a bundle; ?format=yaml returns
+// the YAML document itself, ready to commit
+func (h *ApplyHandler) Export(c *gin.Context) {
+	bundle := h.applyService.Export()
+
+	if c.Query("format") == "yaml" {
+		data, err := yaml.Marshal(bundle)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to encode bundle: " + err.Error(),
+			})
+			return
+		}
+		c.Data(http.StatusOK, "application/yaml", data)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"bundle": bundle,
+	})
+}
+
+// bindBundle decodes a bundle from the request body, rejecting unknown fields
+func bindBundle(c *gin.Context) (model.ApplyBundle, bool) {
+	var bundle model.ApplyBundle
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBundleBytes))
+	if err == nil {
+		err = yaml.UnmarshalStrict(data, &bundle)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid bundle: " + err.Error(),
+		})
+		return bundle, false
+	}
+	return bundle, true
+}
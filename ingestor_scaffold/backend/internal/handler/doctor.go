@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// DoctorHandler handles the configuration self-check endpoint
+type DoctorHandler struct {
+	checks []service.DoctorCheckFunc
+	cfg    *config.Config
+	logger *logrus.Logger
+}
+
+// NewDoctorHandler creates a new doctor handler; checks run after the built-in ones
+func NewDoctorHandler(
+	checks []service.DoctorCheckFunc,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *DoctorHandler {
+	return &DoctorHandler{
+		checks: checks,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// GetDoctorReport validates the effective configuration and returns a readiness
+// report; ?notify=true sends a test Slack message
+func (h *DoctorHandler) GetDoctorReport(c *gin.Context) {
+	report := service.Doctor(c.Request.Context(), h.cfg, h.logger, c.Query("notify") == "true", h.checks...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"report": report,
+	})
+}
@@ -2,10 +2,9 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,31 +16,41 @@ import (
 
 // IngestHandler handles all ingestion related endpoints
 type IngestHandler struct {
-	clickhouseService service.ClickHouseService
-	flatFileService   service.FlatFileService
-	ingestService     service.IngestService
-	cfg               *config.Config
-	logger            *logrus.Logger
+	flatFileService service.FlatFileService
+	ingestService   service.IngestService
+	jobService      service.JobService
+	jobRunner       *service.JobRunner
+	sessionService  service.SessionService
+	quotaService    service.QuotaService
+	cfg             *config.Config
+	logger          *logrus.Logger
 }
 
 // NewIngestHandler creates a new ingest handler
 func NewIngestHandler(
-	clickhouseService service.ClickHouseService,
 	flatFileService service.FlatFileService,
 	ingestService service.IngestService,
+	jobService service.JobService,
+	jobRunner *service.JobRunner,
+	sessionService service.SessionService,
+	quotaService service.QuotaService,
 	cfg *config.Config,
 	logger *logrus.Logger,
 ) *IngestHandler {
 	return &IngestHandler{
-		clickhouseService: clickhouseService,
-		flatFileService:   flatFileService,
-		ingestService:     ingestService,
-		cfg:               cfg,
-		logger:            logger,
+		flatFileService: flatFileService,
+		ingestService:   ingestService,
+		jobService:      jobService,
+		jobRunner:       jobRunner,
+		sessionService:  sessionService,
+		quotaService:    quotaService,
+		cfg:             cfg,
+		logger:          logger,
 	}
 }
 
-// ConnectToClickHouse handles establishing connection to ClickHouse and fetching tables
+// ConnectToClickHouse opens a ClickHouse session and fetches its tables. The returned
+// session ID must be sent in the X-Session-ID header of later requests.
 func (h *IngestHandler) ConnectToClickHouse(c *gin.Context) {
 	var params model.ClickHouseConnectionParams
 	if err := c.ShouldBindJSON(&params); err != nil {
@@ -52,26 +61,34 @@ func (h *IngestHandler) ConnectToClickHouse(c *gin.Context) {
 		return
 	}
 
+	// Labels attribute the session's queries, and this request's log line, to a team
+	if err := service.ValidateLabels(params.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Request = c.Request.WithContext(service.WithLabels(c.Request.Context(), params.Labels))
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	// Get JWT token from request
-	token := params.Token
-
-	// Connect to ClickHouse
-	err := h.clickhouseService.Connect(ctx, params, token)
+	// Connect to ClickHouse in a session of its own
+	sessionID, conn, err := h.sessionService.Open(ctx, params)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to connect to ClickHouse")
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
 			"message": "Failed to connect to ClickHouse: " + err.Error(),
+			"hint":    service.ConnectionHint(err, params),
 		})
 		return
 	}
 
 	// Get list of tables
-	tables, err := h.clickhouseService.ListTables(ctx)
+	tables, err := conn.ListTables(ctx)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to list tables")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -81,9 +98,118 @@ func (h *IngestHandler) ConnectToClickHouse(c *gin.Context) {
 		return
 	}
 
+	c.Writer.Header().Set(SessionHeader, sessionID)
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"sessionId": sessionID,
+		"tables":    tables,
+	})
+}
+
+// TestClickHouseConnection connects without opening a session and reports the
+// server version, timezone, databases, grants and latency. A failed connection
+// is reported in the diagnostics, with a hint at the likely cause.
+func (h *IngestHandler) TestClickHouseConnection(c *gin.Context) {
+	var params model.ClickHouseConnectionParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	diagnostics := h.sessionService.Diagnose(ctx, params)
+	if !diagnostics.Connected {
+		h.logger.WithField("error", diagnostics.Error).Warn("ClickHouse connection test failed")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "success",
+		"diagnostics": diagnostics,
+	})
+}
+
+// DisconnectClickHouse closes a ClickHouse session, named by the body's sessionId
+// or the X-Session-ID header
+func (h *IngestHandler) DisconnectClickHouse(c *gin.Context) {
+	var req model.DisconnectRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+	if req.SessionID == "" {
+		req.SessionID = c.GetHeader(SessionHeader)
+	}
+	if req.SessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Session ID is required",
+		})
+		return
+	}
+
+	if err := h.sessionService.Close(req.SessionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// RefreshClickHouseToken replaces the JWT of a ClickHouse session before it
+// expires, so long-running jobs keep connecting
+func (h *IngestHandler) RefreshClickHouseToken(c *gin.Context) {
+	var req model.TokenRefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = c.GetHeader(SessionHeader)
+	}
+	if req.SessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Session ID is required",
+		})
+		return
+	}
+
+	if _, err := h.sessionService.Get(req.SessionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := h.sessionService.RefreshToken(req.SessionID, req.Token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
-		"tables": tables,
 	})
 }
 
@@ -98,12 +224,17 @@ func (h *IngestHandler) GetTableColumns(c *gin.Context) {
 		return
 	}
 
+	conn, ok := clickhouseSession(c, h.sessionService)
+	if !ok {
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
 	// Get columns
-	columns, err := h.clickhouseService.GetTableColumns(ctx, tableName)
+	columns, err := conn.GetTableColumns(ctx, tableName)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get table columns")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -113,10 +244,14 @@ func (h *IngestHandler) GetTableColumns(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"status":  "success",
 		"columns": columns,
-	})
+	}
+	if hasColumn(columns, service.RejectReasonColumn) {
+		response["rejectReasonColumn"] = service.RejectReasonColumn
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // DiscoverFlatFileSchema discovers the schema of a flat file
@@ -134,20 +269,89 @@ func (h *IngestHandler) DiscoverFlatFileSchema(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
-	// Discover schema
-	columns, err := h.flatFileService.DiscoverSchema(ctx, params.FilePath, params.Delimiter)
+	// Discover schema, from the cache when the file is unchanged
+	schema, err := h.flatFileService.DiscoverSchema(ctx, params, nil)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to discover flat file schema")
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(fileErrorStatus(err), gin.H{
 			"status":  "error",
 			"message": "Failed to discover schema: " + err.Error(),
 		})
 		return
 	}
 
+	// Renames report header names changed to keep columns unique; inference how
+	// each type was chosen
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"columns": columns,
+		"status":      "success",
+		"columns":     schema.Columns,
+		"fingerprint": schema.Fingerprint,
+		"renames":     schema.Renames,
+		"inference":   schema.Inference,
+	})
+}
+
+// OverrideFlatFileSchema applies column type, nullability and format overrides to a
+// file's discovered schema and reports how many sampled rows would fail under them
+func (h *IngestHandler) OverrideFlatFileSchema(c *gin.Context) {
+	var req model.SchemaOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := h.flatFileService.OverrideSchema(ctx, req)
+	if err != nil {
+		status := fileErrorStatus(err)
+		if errors.Is(err, service.ErrInvalidOverride) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": "Failed to override schema: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"schema": result,
+	})
+}
+
+// GetTableDDL returns the CREATE TABLE statement of a specific table
+func (h *IngestHandler) GetTableDDL(c *gin.Context) {
+	tableName := c.Param("tableName")
+
+	conn, ok := clickhouseSession(c, h.sessionService)
+	if !ok {
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	ddl, err := conn.ShowCreateTable(ctx, tableName)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get table DDL")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to get table DDL: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"ddl":    ddl,
 	})
 }
 
@@ -162,26 +366,107 @@ func (h *IngestHandler) PreviewData(c *gin.Context) {
 		return
 	}
 
+	if err := service.ValidateBinaryEncoding(params.BinaryEncoding); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := service.ValidateGeoFormat(params.GeoFormat); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	page, err := newPreviewPage(params, h.cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	var previewData []map[string]interface{}
-	var err error
+	var order []string
+	var typed []model.Column // only ClickHouse values are typed; file cells are already text
 
 	switch params.SourceType {
 	case "clickhouse":
+		conn, ok := clickhouseSession(c, h.sessionService)
+		if !ok {
+			return
+		}
+
+		// Resolve the table's columns so SELECT * never fetches a very wide table in full
+		columns := params.Columns
+		if len(columns) == 0 {
+			if tableColumns, colErr := conn.GetTableColumns(ctx, params.TableName); colErr == nil {
+				columns = tableColumns
+			}
+		}
+
 		// Extract column names
+		columnNames := make([]string, len(columns))
+		for i, col := range columns {
+			columnNames[i] = col.Name
+		}
+		order = columnNames
+		typed = columns
+
+		// Preview data from ClickHouse, one row past the page to learn whether more follow
+		previewData, err = conn.PreviewData(ctx, params.TableName, service.PreviewColumns(columnNames, h.cfg), params.Filters, page.offset, page.limit+1)
+	case "flatfile":
+		if len(params.Filters) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Filters are only supported for ClickHouse previews",
+			})
+			return
+		}
+
+		// Pages of a file must all come from the same version of it
+		var filePath, fingerprint string
+		if filePath, err = h.flatFileService.ResolvePath(params.FilePath); err != nil {
+			break
+		}
+		if fingerprint, err = service.FileFingerprint(filePath, params.Delimiter); err != nil {
+			break
+		}
+		if page.fingerprint != "" && page.fingerprint != fingerprint {
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  "error",
+				"message": "The file changed since the previous page; start again from the first page",
+			})
+			return
+		}
+		page.fingerprint = fingerprint
+
+		// Preview every column of the file when none are given
+		if len(params.Columns) == 0 {
+			fileParams := model.FlatFileParams{FilePath: params.FilePath, Delimiter: params.Delimiter}
+			var schema model.FileSchema
+			if schema, err = h.flatFileService.DiscoverSchema(ctx, fileParams, nil); err != nil {
+				break
+			}
+			params.Columns = schema.Columns
+		}
+
 		columnNames := make([]string, len(params.Columns))
 		for i, col := range params.Columns {
 			columnNames[i] = col.Name
 		}
+		order = columnNames
 
-		// Preview data from ClickHouse
-		previewData, err = h.clickhouseService.PreviewData(ctx, params.TableName, columnNames, h.cfg.MaxPreviewRows)
-	case "flatfile":
 		// Preview data from flat file
-		previewData, err = h.flatFileService.PreviewData(ctx, params.FilePath, params.Delimiter, params.Columns, h.cfg.MaxPreviewRows)
+		columns := params.Columns[:len(service.PreviewColumns(columnNames, h.cfg))]
+		previewData, err = h.flatFileService.PreviewData(ctx, params.FilePath, params.Delimiter, columns, page.offset, page.limit+1)
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  "error",
@@ -192,20 +477,340 @@ func (h *IngestHandler) PreviewData(c *gin.Context) {
 
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to preview data")
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(fileErrorStatus(err), gin.H{
 			"status":  "error",
 			"message": "Failed to preview data: " + err.Error(),
 		})
 		return
 	}
 
+	previewData = page.fit(previewData)
+
+	// Render JSON, binary and geo values, then enforce response caps and report what was cut
+	service.RenderPreviewJSON(previewData, params.Columns)
+	binaryColumns := service.EncodePreviewBinary(previewData, typed, params.BinaryEncoding)
+	geoColumns := service.RenderPreviewGeo(previewData, params.GeoFormat)
+	limiter := service.NewPreviewLimiter(previewData, order, h.cfg)
+
+	response := gin.H{
+		"status":        "success",
+		"binaryColumns": binaryColumns,
+		"geoColumns":    geoColumns,
+	}
+	// Dead-letter files carry why each row was rejected
+	if params.SourceType == "flatfile" && hasColumn(params.Columns, service.RejectReasonColumn) {
+		response["rejectReasonColumn"] = service.RejectReasonColumn
+	}
+	if err := writePreview(c, response, previewData, limiter, page); err != nil {
+		h.logger.WithError(err).Warn("Failed to write preview response")
+	}
+}
+
+// CountRows returns the exact or estimated number of rows of a table, a join or a
+// flat file, to size a job before starting it
+func (h *IngestHandler) CountRows(c *gin.Context) {
+	var params model.CountParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Exact counts scan the whole source
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+
+	var count model.RowCount
+	var err error
+	switch params.SourceType {
+	case "clickhouse":
+		conn, ok := clickhouseSession(c, h.sessionService)
+		if !ok {
+			return
+		}
+		count, err = conn.CountRows(ctx, params.TableName, params.Filters, params.Exact)
+	case "join":
+		if params.Join == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Join parameters are required",
+			})
+			return
+		}
+		conn, ok := clickhouseSession(c, h.sessionService)
+		if !ok {
+			return
+		}
+		query, args, buildErr := conn.BuildJoinQuery(*params.Join)
+		if buildErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Failed to build join query: " + buildErr.Error(),
+			})
+			return
+		}
+		count, err = conn.CountQuery(ctx, query, args)
+	case "flatfile":
+		if len(params.Filters) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Filters are only supported for ClickHouse counts",
+			})
+			return
+		}
+		count, err = h.flatFileService.CountRows(ctx, params.FilePath, params.Exact)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid source type",
+		})
+		return
+	}
+
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to count rows")
+		c.JSON(fileErrorStatus(err), gin.H{
+			"status":  "error",
+			"message": "Failed to count rows: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"count":  count,
+	})
+}
+
+// ProfileData computes per-column statistics of a ClickHouse table or a sample of a
+// flat file, to help choose keys and check data before ingesting it
+func (h *IngestHandler) ProfileData(c *gin.Context) {
+	var params model.ProfileParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	params, err := service.CheckProfileParams(params, h.cfg.MaxProfileSampleRows)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Unsampled tables are scanned in full
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+
+	var profile model.DataProfile
+	switch params.SourceType {
+	case "clickhouse":
+		conn, ok := clickhouseSession(c, h.sessionService)
+		if !ok {
+			return
+		}
+		profile, err = conn.ProfileTable(ctx, params.TableName, params.Columns, params.SampleRows, params.TopValues)
+	case "flatfile":
+		if params.SampleRows == 0 {
+			params.SampleRows = h.cfg.ProfileSampleRows
+		}
+		profile, err = h.flatFileService.ProfileFile(ctx, params.FilePath, params.Delimiter, params.Columns, params.SampleRows, params.TopValues)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid source type",
+		})
+		return
+	}
+
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to profile data")
+		c.JSON(fileErrorStatus(err), gin.H{
+			"status":  "error",
+			"message": "Failed to profile data: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"profile": profile,
+	})
+}
+
+// EstimateCost returns the estimated cost of a job at the configured unit costs,
+// without starting it. Actual costs are recorded in the job result.
+func (h *IngestHandler) EstimateCost(c *gin.Context) {
+	var params model.IngestionParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+
+	// ClickHouse sources are sized on the caller's session
+	if params.SourceType == "clickhouse" {
+		conn, ok := clickhouseSession(c, h.sessionService)
+		if !ok {
+			return
+		}
+		ctx = service.WithClickHouse(ctx, conn)
+	}
+
+	cost, err := h.ingestService.EstimateCost(ctx, params)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to estimate job cost")
+		c.JSON(fileErrorStatus(err), gin.H{
+			"status":  "error",
+			"message": "Failed to estimate cost: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"cost":       cost,
+		"configured": service.CostsEnabled(h.cfg),
+	})
+}
+
+// ExplainQuery returns ClickHouse's plan for the query an export would run, to catch
+// cartesian joins and full scans before starting it
+func (h *IngestHandler) ExplainQuery(c *gin.Context) {
+	var params model.IngestionParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	conn, ok := clickhouseSession(c, h.sessionService)
+	if !ok {
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	plan, err := h.ingestService.Explain(service.WithClickHouse(ctx, conn), params)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to explain query")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Failed to explain query: " + err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
-		"data":   previewData,
-		"count":  len(previewData),
+		"plan":   plan,
+	})
+}
+
+// ValidateQuery checks custom SQL for read-only safety and syntax without running it.
+// Invalid queries are reported in the body with a 200 status.
+func (h *IngestHandler) ValidateQuery(c *gin.Context) {
+	var req model.QueryValidationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	conn, ok := clickhouseSession(c, h.sessionService)
+	if !ok {
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"validation": h.ingestService.ValidateQuery(service.WithClickHouse(ctx, conn), req.Query),
+	})
+}
+
+// ValidateIngestion cross-checks the source columns of a flat file load against the
+// target table, or the table it would create, before any data moves.
+// Incompatibilities are reported in the body with a 200 status.
+func (h *IngestHandler) ValidateIngestion(c *gin.Context) {
+	var params model.IngestionParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	conn, ok := clickhouseSession(c, h.sessionService)
+	if !ok {
+		return
+	}
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	validation, err := h.ingestService.ValidateIngestion(service.WithClickHouse(ctx, conn), params)
+	if err != nil {
+		status := fileErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": "Failed to validate ingestion: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"validation": validation,
 	})
 }
 
+// hasColumn reports whether columns include one with the given name
+func hasColumn(columns []model.Column, name string) bool {
+	for _, col := range columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fileErrorStatus maps a flat file error to its HTTP status
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrPathNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrSourceTooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
 // StartIngestion initiates the ingestion process
 func (h *IngestHandler) StartIngestion(c *gin.Context) {
 	var params model.IngestionParams
@@ -217,87 +822,151 @@ func (h *IngestHandler) StartIngestion(c *gin.Context) {
 		return
 	}
 
-	// Create a new context that's canceled if client disconnects
-	ctx, cancel := context.WithCancel(c.Request.Context())
-	defer cancel()
+	if err := service.ValidateLabels(params.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Flat files must lie inside the allowed directories
+	if params.SourceType == "flatfile" || params.TargetType == "flatfile" {
+		if _, err := h.flatFileService.ResolvePath(params.FlatFileParams.FilePath); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, service.ErrPathNotAllowed) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	// ClickHouse sources and targets run on the caller's session
+	if params.SessionID == "" {
+		params.SessionID = c.GetHeader(SessionHeader)
+	}
+	var conn service.ClickHouseService
+	if params.SessionID != "" {
+		var release func()
+		var err error
+		if conn, release, err = h.sessionService.Acquire(params.SessionID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		defer release()
+	} else if params.SourceType == "clickhouse" || params.TargetType == "clickhouse" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Missing " + SessionHeader + " header, connect to ClickHouse first",
+		})
+		return
+	}
+
+	// Jobs carry their connection's labels under their own, on every query and log line
+	params.Labels = service.MergeLabels(h.sessionService.Labels(params.SessionID), params.Labels)
+	c.Request = c.Request.WithContext(service.WithLabels(c.Request.Context(), params.Labels))
+
+	// Jobs count against the quotas of their caller and labels; the caller is never
+	// taken from the request body
+	params.User = service.UserFromContext(c.Request.Context())
+	if usage, over := h.quotaService.Check(params.User, params.Labels); over && h.cfg.QuotaPolicy != "queue" {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"status":  "error",
+			"message": "Quota exceeded, try again after " + usage.ResetAt.Format(time.RFC3339),
+			"quota":   usage,
+		})
+		return
+	}
+
+	// The job runs like any background job; this request only relays its progress
+	requestID := service.RequestIDFromContext(c.Request.Context())
+	job, stream := h.jobRunner.StartStream(requestID, params, logrus.Fields{"requestId": requestID})
+	defer stream.Close()
 
 	// Setup SSE response
+	c.Writer.Header().Set("X-Job-ID", job.ID)
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
 	c.Writer.Header().Set("Transfer-Encoding", "chunked")
 	c.Writer.WriteHeader(http.StatusOK)
 
-	// Create a progress channel
-	progressCh := make(chan model.ProgressUpdate, 10)
-	
-	// Start ingestion in a goroutine
-	go func() {
-		var result model.IngestionResult
-		var err error
+	// Health checks run on the caller's session
+	ctx := c.Request.Context()
+	if conn != nil {
+		ctx = service.WithClickHouse(ctx, conn)
+	}
 
-		switch {
-		case params.SourceType == "clickhouse" && params.TargetType == "flatfile":
-			// ClickHouse to Flat File
-			result, err = h.ingestService.IngestClickHouseToFlatFile(
-				ctx,
-				params.TableName,
-				params.Columns,
-				params.FlatFileParams,
-				params.Query,
-				progressCh,
-			)
-		case params.SourceType == "flatfile" && params.TargetType == "clickhouse":
-			// Flat File to ClickHouse
-			result, err = h.ingestService.IngestFlatFileToClickHouse(
-				ctx,
-				params.FlatFileParams,
-				params.TableName,
-				params.Columns,
-				progressCh,
-			)
-		default:
-			err = fmt.Errorf("invalid source or target type")
-		}
+	// Emit heartbeats so stalled jobs are distinguishable from slow ones
+	var heartbeatCh <-chan time.Time
+	if h.cfg.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(h.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeatCh = ticker.C
+	}
+	lastProgressAt := time.Now()
+	lastCount := 0
 
-		// Send final result or error
-		if err != nil {
-			h.logger.WithError(err).Error("Ingestion failed")
-			progressCh <- model.ProgressUpdate{
-				Status:    "error",
-				Message:   err.Error(),
-				Count:     0,
-				Completed: true,
+	// Stream progress updates to client
+	flush := c.Writer.Flush
+	for {
+		var progress model.ProgressUpdate
+		select {
+		case update, ok := <-stream.Updates:
+			if !ok {
+				return
 			}
-		} else {
-			progressCh <- model.ProgressUpdate{
-				Status:    "success",
-				Message:   "Ingestion completed successfully",
-				Count:     result.TotalRecords,
-				Completed: true,
+			progress = update
+			lastProgressAt = time.Now()
+			lastCount = update.Count
+		case <-heartbeatCh:
+			health := h.ingestService.CheckHealth(ctx, params)
+			health.LastProgressCount = lastCount
+			health.SecondsSinceProgress = time.Since(lastProgressAt).Seconds()
+			progress = model.ProgressUpdate{
+				JobID:     job.ID,
+				Status:    "heartbeat",
+				Message:   "Job is running",
+				Count:     lastCount,
+				Completed: false,
+				Health:    &health,
 			}
 		}
-		close(progressCh)
-	}()
 
-	// Stream progress updates to client
-	flush := c.Writer.Flush
-	for progress := range progressCh {
 		// Check if client disconnected
-		if c.Request.Context().Err() != nil {
-			h.logger.Info("Client disconnected, stopping ingestion")
-			cancel()
+		if ctx.Err() != nil {
+			h.logger.WithField("jobId", job.ID).Info("Client disconnected, stopping ingestion")
+			h.stopJob(job.ID, "client disconnected")
 			return
 		}
 
+		// Attach warnings and byte counts so far
+		progress.Warnings = stream.Warnings.Snapshot()
+		progress.BytesRead, progress.BytesWritten = stream.Counters.Read(), stream.Counters.Written()
+
 		// Format as SSE
 		data := fmt.Sprintf("data: %s\n\n", progress.ToJSON())
 		_, err := fmt.Fprint(c.Writer, data)
 		if err != nil {
 			h.logger.WithError(err).Error("Failed to write progress update")
-			cancel()
+			h.stopJob(job.ID, "progress stream failed")
 			return
 		}
 		flush()
 	}
-}
\ No newline at end of file
+}
+
+// stopJob cancels a streamed job whose client went away; jobs that already
+// finished are left as they are
+func (h *IngestHandler) stopJob(id, reason string) {
+	if err := h.jobService.CancelJob(id, reason); err != nil {
+		h.logger.WithError(err).WithField("jobId", id).Debug("Job not cancelled")
+	}
+}
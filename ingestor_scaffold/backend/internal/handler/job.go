@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// JobHandler handles job tracking endpoints
+type JobHandler struct {
+	jobService service.JobService
+	jobRunner  *service.JobRunner
+	cfg        *config.Config
+	logger     *logrus.Logger
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(
+	jobService service.JobService,
+	jobRunner *service.JobRunner,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *JobHandler {
+	return &JobHandler{
+		jobService: jobService,
+		jobRunner:  jobRunner,
+		cfg:        cfg,
+		logger:     logger,
+	}
+}
+
+// ListJobs returns the job history, newest first. Repeated label=key=value query
+// parameters keep the jobs carrying every label; status keeps one status.
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	labels, err := service.ParseLabelFilters(c.QueryArray("label"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"jobs":   service.RedactJobs(h.jobService.ListJobs(c.Query("status"), labels)),
+	})
+}
+
+// GetJob returns the full record of a job
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, err := h.jobService.GetJob(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"job":    service.RedactJob(job),
+	})
+}
+
+// GetJobSummary returns a compact verdict for a job, evaluated against reject-ratio gates.
+// Thresholds default to config and may be overridden with warnRejectRatio and failRejectRatio query parameters.
+func (h *JobHandler) GetJobSummary(c *gin.Context) {
+	warnRatio, err := parseRatioQuery(c, "warnRejectRatio", h.cfg.SummaryWarnRejectRatio)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	failRatio, err := parseRatioQuery(c, "failRejectRatio", h.cfg.SummaryFailRejectRatio)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	summary, err := h.jobService.Summarize(c.Param("id"), warnRatio, failRatio)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Running jobs have no verdict yet
+	code := http.StatusOK
+	if summary.Verdict == "pending" {
+		code = http.StatusAccepted
+	}
+
+	c.JSON(code, summary)
+}
+
+// CancelJob stops a running job
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	if err := h.jobService.CancelJob(c.Param("id"), "cancelled by user"); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// ReingestDeadLetter re-runs a flat file job on its corrected dead-letter file, reusing the
+// original job's mapping. The body may name a corrected copy in filePath; by default the
+// dead-letter file itself is ingested. Its reject-reason column is not mapped and is ignored.
+func (h *JobHandler) ReingestDeadLetter(c *gin.Context) {
+	var req model.ReingestRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	job, err := h.jobService.GetJob(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	if job.Kind != "" || job.Params.SourceType != "flatfile" {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "only flat file jobs have dead-letter files",
+		})
+		return
+	}
+
+	filePath := req.FilePath
+	if filePath == "" {
+		filePath = job.Result.DeadLetterFile
+	}
+	if filePath == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("job %s has no dead-letter file", job.ID),
+		})
+		return
+	}
+
+	params := job.Params
+	params.FlatFileParams.FilePath = filePath
+	reingest, _ := h.jobRunner.Start(params, logrus.Fields{"reingestOf": job.ID})
+
+	c.Header("X-Job-ID", reingest.ID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+		"job":    service.RedactJob(reingest),
+	})
+}
+
+// StartSchemaDiscovery discovers a flat file's schema in the background, for files
+// too large to scan within a request. Follow it with GET /jobs/:id or /jobs/:id/events;
+// the columns are in the job result's schema.
+func (h *JobHandler) StartSchemaDiscovery(c *gin.Context) {
+	var params model.FlatFileParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	job := h.jobRunner.StartDiscovery(params)
+
+	c.Header("X-Job-ID", job.ID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+		"job":    service.RedactJob(job),
+	})
+}
+
+// StreamJob streams a job's progress as server-sent events, in the format of the
+// ingestion stream, until the job completes
+func (h *JobHandler) StreamJob(c *gin.Context) {
+	id := c.Param("id")
+	job, err := h.jobService.GetJob(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.Writer.Header().Set("X-Job-ID", job.ID)
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	interval := h.cfg.HeartbeatInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		update := jobUpdate(job)
+		if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", update.ToJSON()); err != nil {
+			return
+		}
+		c.Writer.Flush()
+		if update.Completed {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.Request.Context().Done():
+			return
+		}
+		if job, err = h.jobService.GetJob(id); err != nil {
+			return
+		}
+	}
+}
+
+// jobUpdate renders the state of a job as a progress update
+func jobUpdate(job model.Job) model.ProgressUpdate {
+	update := model.ProgressUpdate{Status: "processing", Count: job.LastCount}
+	if job.Progress != nil {
+		update = *job.Progress
+	}
+	update.JobID = job.ID
+
+	switch job.Status {
+	case "running", "queued":
+		update.Completed = false
+	case "success":
+		update.Status, update.Message, update.Completed = "success", "Job completed successfully", true
+		if job.Kind == model.JobKindSchemaDiscovery {
+			update.Message = fmt.Sprintf("Discovered %d columns", len(job.Result.Schema))
+		} else {
+			update.Count = job.Result.TotalRecords
+		}
+	default:
+		update.Status, update.Message, update.Completed = job.Status, job.Error, true
+	}
+	return update
+}
+
+// parseRatioQuery reads a ratio between 0 and 1 from the query string
+func parseRatioQuery(c *gin.Context, key string, fallback float64) (float64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("invalid value for %s: %s", key, value)
+	}
+	return ratio, nil
+}
@@ -14,21 +14,21 @@ import (
 
 // JoinHandler handles the join functionality
 type JoinHandler struct {
-	clickhouseService service.ClickHouseService
-	cfg               *config.Config
-	logger            *logrus.Logger
+	sessionService service.SessionService
+	cfg            *config.Config
+	logger         *logrus.Logger
 }
 
 // NewJoinHandler creates a new join handler
 func NewJoinHandler(
-	clickhouseService service.ClickHouseService,
+	sessionService service.SessionService,
 	cfg *config.Config,
 	logger *logrus.Logger,
 ) *JoinHandler {
 	return &JoinHandler{
-		clickhouseService: clickhouseService,
-		cfg:               cfg,
-		logger:            logger,
+		sessionService: sessionService,
+		cfg:            cfg,
+		logger:         logger,
 	}
 }
 
@@ -43,15 +43,20 @@ func (h *JoinHandler) BuildJoinPreview(c *gin.Context) {
 		return
 	}
 
+	conn, ok := clickhouseSession(c, h.sessionService)
+	if !ok {
+		return
+	}
+
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// Build query
-	query, err := h.clickhouseService.BuildJoinQuery(params)
+	query, args, err := conn.BuildJoinQuery(params)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to build join query")
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  "error",
 			"message": "Failed to build join query: " + err.Error(),
 		})
@@ -67,7 +72,7 @@ func (h *JoinHandler) BuildJoinPreview(c *gin.Context) {
 	}
 
 	// Preview data
-	data, err := h.clickhouseService.ExecuteJoinPreview(ctx, query, h.cfg.MaxPreviewRows)
+	data, err := conn.ExecuteJoinPreview(ctx, query, args, h.cfg.MaxPreviewRows)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to execute join preview")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -77,10 +82,22 @@ func (h *JoinHandler) BuildJoinPreview(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"query":  query,
-		"data":   data,
-		"count":  len(data),
-	})
+	// Render binary and geo values as text, then enforce response caps and report what was cut
+	binaryColumns := service.EncodePreviewBinary(data, nil, "")
+	geoColumns := service.RenderPreviewGeo(data, "")
+	limiter := service.NewPreviewLimiter(data, nil, h.cfg)
+
+	response := gin.H{
+		"status":        "success",
+		"query":         query,
+		"binaryColumns": binaryColumns,
+		"geoColumns":    geoColumns,
+	}
+	// Filter values bound to the query's ? parameters
+	if len(args) > 0 {
+		response["args"] = args
+	}
+	if err := writePreview(c, response, data, limiter, nil); err != nil {
+		h.logger.WithError(err).Warn("Failed to write join preview response")
+	}
 }
\ No newline at end of file
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// PipelineHandler handles declared pipeline endpoints
+type PipelineHandler struct {
+	pipelineService service.PipelineService
+	cfg             *config.Config
+	logger          *logrus.Logger
+}
+
+// NewPipelineHandler creates a new pipeline handler
+func NewPipelineHandler(
+	pipelineService service.PipelineService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *PipelineHandler {
+	return &PipelineHandler{
+		pipelineService: pipelineService,
+		cfg:             cfg,
+		logger:          logger,
+	}
+}
+
+// ListPipelines returns all declared pipelines
+func (h *PipelineHandler) ListPipelines(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"pipelines": service.RedactPipelines(h.pipelineService.ListPipelines()),
+	})
+}
+
+// GetPipeline returns a single pipeline
+func (h *PipelineHandler) GetPipeline(c *gin.Context) {
+	pipeline, err := h.pipelineService.GetPipeline(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"pipeline": service.RedactPipeline(pipeline),
+	})
+}
+
+// RunPipeline starts a pipeline in the background and returns its run
+func (h *PipelineHandler) RunPipeline(c *gin.Context) {
+	run, err := h.pipelineService.RunPipeline(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if len(run.Steps) > 0 && run.Steps[0].JobID != "" {
+		c.Header("X-Job-ID", run.Steps[0].JobID)
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+		"run":    run,
+	})
+}
+
+// GetPipelineRun returns the progress of a pipeline run and the outputs of its finished steps
+func (h *PipelineHandler) GetPipelineRun(c *gin.Context) {
+	run, err := h.pipelineService.GetRun(c.Param("name"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"run":    run,
+	})
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/ingestor/internal/service"
+)
+
+// previewFlushBytes is how much of a preview response is written between flushes
+const previewFlushBytes = 64 * 1024
+
+// flushWriter flushes the response every previewFlushBytes written
+type flushWriter struct {
+	w       gin.ResponseWriter
+	pending int
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if f.pending += n; f.pending >= previewFlushBytes {
+		f.w.Flush()
+		f.pending = 0
+	}
+	return n, err
+}
+
+// writeString writes literal JSON punctuation
+func (f *flushWriter) writeString(s string) error {
+	_, err := f.Write([]byte(s))
+	return err
+}
+
+// previewPage is the window of rows a preview request asks for
+type previewPage struct {
+	offset      int
+	limit       int
+	fingerprint string // of the flat file paged through
+	more        bool   // rows remain after the ones fetched
+}
+
+// newPreviewPage resolves the page of a preview request from its offset or cursor.
+// Pages hold at most MaxPreviewRows rows.
+func newPreviewPage(params model.PreviewParams, cfg *config.Config) (*previewPage, error) {
+	if params.Offset < 0 || params.Limit < 0 {
+		return nil, fmt.Errorf("offset and limit must not be negative")
+	}
+	if params.Offset > 0 && params.Cursor != "" {
+		return nil, fmt.Errorf("give either an offset or a cursor, not both")
+	}
+
+	page := &previewPage{offset: params.Offset, limit: cfg.MaxPreviewRows}
+	if params.Limit > 0 && params.Limit < page.limit {
+		page.limit = params.Limit
+	}
+	if params.Cursor != "" {
+		cursor, err := service.DecodePreviewCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		page.offset, page.fingerprint = cursor.Offset, cursor.Fingerprint
+	}
+	return page, nil
+}
+
+// fit drops the extra row fetched to learn whether another page follows
+func (p *previewPage) fit(rows []map[string]interface{}) []map[string]interface{} {
+	if len(rows) > p.limit {
+		p.more = true
+		return rows[:p.limit]
+	}
+	return rows
+}
+
+// writePreview streams a preview response instead of encoding it in one piece:
+// fields first, then the "data" array element by element as the limiter encodes
+// each row, then "count" and "truncation", which are known only once the rows are
+// written. Large previews are flushed as they go so the UI can start parsing early.
+// For a paged preview, "offset" and, while rows remain, "nextOffset" and
+// "nextCursor" follow the count.
+func writePreview(c *gin.Context, fields gin.H, rows []map[string]interface{}, limiter *service.PreviewLimiter, page *previewPage) error {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	w := &flushWriter{w: c.Writer}
+	enc := json.NewEncoder(w)
+	field := func(key string, value interface{}) error {
+		if err := enc.Encode(key); err != nil {
+			return err
+		}
+		if err := w.writeString(":"); err != nil {
+			return err
+		}
+		if err := enc.Encode(value); err != nil {
+			return err
+		}
+		return w.writeString(",")
+	}
+
+	// Leading fields, in a stable order
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := w.writeString("{"); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := field(key, fields[key]); err != nil {
+			return err
+		}
+	}
+
+	// Rows are written as they are encoded
+	if err := w.writeString(`"data":[`); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		encoded, more := limiter.Encode(row)
+		if !more {
+			break
+		}
+		if encoded == nil {
+			continue
+		}
+		if limiter.Written() > 1 {
+			if err := w.writeString(","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return err
+		}
+	}
+	if err := w.writeString("],"); err != nil {
+		return err
+	}
+
+	if err := field("count", limiter.Written()); err != nil {
+		return err
+	}
+	if page != nil {
+		if err := field("offset", page.offset); err != nil {
+			return err
+		}
+		// Rows cut by the response size limit start the next page
+		if page.more || limiter.Consumed() < len(rows) {
+			next := page.offset + limiter.Consumed()
+			if err := field("nextOffset", next); err != nil {
+				return err
+			}
+			cursor := service.EncodePreviewCursor(service.PreviewCursor{Offset: next, Fingerprint: page.fingerprint})
+			if err := field("nextCursor", cursor); err != nil {
+				return err
+			}
+		}
+	}
+	if err := enc.Encode("truncation"); err != nil {
+		return err
+	}
+	if err := w.writeString(":"); err != nil {
+		return err
+	}
+	if err := enc.Encode(limiter.Truncation()); err != nil {
+		return err
+	}
+	if err := w.writeString("}"); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
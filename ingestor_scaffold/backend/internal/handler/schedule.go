@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// ScheduleHandler handles recurring ingestion endpoints
+type ScheduleHandler struct {
+	schedulerService service.SchedulerService
+	cfg              *config.Config
+	logger           *logrus.Logger
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(
+	schedulerService service.SchedulerService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *ScheduleHandler {
+	return &ScheduleHandler{
+		schedulerService: schedulerService,
+		cfg:              cfg,
+		logger:           logger,
+	}
+}
+
+// CreateSchedule registers a new recurring ingestion
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var req model.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	// Scheduled runs reuse the caller's ClickHouse session
+	if req.Params.SessionID == "" {
+		req.Params.SessionID = c.GetHeader(SessionHeader)
+	}
+	// Scheduled runs count against the creator's quotas
+	req.Params.User = service.UserFromContext(c.Request.Context())
+
+	schedule, err := h.schedulerService.CreateSchedule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Failed to create schedule: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   "success",
+		"schedule": service.RedactSchedule(schedule),
+	})
+}
+
+// ListSchedules returns all schedules
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"schedules": service.RedactSchedules(h.schedulerService.ListSchedules()),
+	})
+}
+
+// GetSchedule returns a single schedule
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, err := h.schedulerService.GetSchedule(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"schedule": service.RedactSchedule(schedule),
+	})
+}
+
+// DeleteSchedule removes a schedule
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	if err := h.schedulerService.DeleteSchedule(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// PreflightSchedule validates the next run of a schedule without starting it
+func (h *ScheduleHandler) PreflightSchedule(c *gin.Context) {
+	result, err := h.schedulerService.Preflight(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"preflight": result,
+	})
+}
+
+// EnableSchedule resumes firing a schedule
+func (h *ScheduleHandler) EnableSchedule(c *gin.Context) {
+	h.setEnabled(c, true)
+}
+
+// DisableSchedule stops firing a schedule without deleting it
+func (h *ScheduleHandler) DisableSchedule(c *gin.Context) {
+	h.setEnabled(c, false)
+}
+
+func (h *ScheduleHandler) setEnabled(c *gin.Context, enabled bool) {
+	schedule, err := h.schedulerService.SetEnabled(c.Param("id"), enabled)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"schedule": service.RedactSchedule(schedule),
+	})
+}
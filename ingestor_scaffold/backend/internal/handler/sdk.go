@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/codegen"
+	"github.com/ingestor/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// SDKHandler serves generated client code for the API
+type SDKHandler struct {
+	cfg    *config.Config
+	logger *logrus.Logger
+}
+
+// NewSDKHandler creates a new SDK handler
+func NewSDKHandler(cfg *config.Config, logger *logrus.Logger) *SDKHandler {
+	return &SDKHandler{
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// GetTypeScriptTypes serves TypeScript interfaces for the API models
+func (h *SDKHandler) GetTypeScriptTypes(c *gin.Context) {
+	c.Data(http.StatusOK, "application/typescript; charset=utf-8", []byte(codegen.TypeScriptTypes()))
+}
+
+// GetTypeScriptClient serves a fetch-based TypeScript client including the model types
+func (h *SDKHandler) GetTypeScriptClient(c *gin.Context) {
+	c.Data(http.StatusOK, "application/typescript; charset=utf-8", []byte(codegen.TypeScriptClient()))
+}
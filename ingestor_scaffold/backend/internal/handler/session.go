@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/service"
+)
+
+// SessionHeader carries the ClickHouse session ID returned by /clickhouse/connect
+const SessionHeader = "X-Session-ID"
+
+// clickhouseSession resolves the ClickHouse connection of the request's session,
+// writing an error response when there is none
+func clickhouseSession(c *gin.Context, sessions service.SessionService) (service.ClickHouseService, bool) {
+	id := c.GetHeader(SessionHeader)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Missing " + SessionHeader + " header, connect to ClickHouse first",
+		})
+		return nil, false
+	}
+
+	conn, err := sessions.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return nil, false
+	}
+	return conn, true
+}
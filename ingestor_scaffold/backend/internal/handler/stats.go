@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// StatsHandler serves data quality stats and Prometheus metrics
+type StatsHandler struct {
+	statsService service.StatsService
+	quotaService service.QuotaService
+	cfg          *config.Config
+	logger       *logrus.Logger
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(
+	statsService service.StatsService,
+	quotaService service.QuotaService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *StatsHandler {
+	return &StatsHandler{
+		statsService: statsService,
+		quotaService: quotaService,
+		cfg:          cfg,
+		logger:       logger,
+	}
+}
+
+// ListScheduleStats returns the data quality stats of every schedule that has run
+func (h *StatsHandler) ListScheduleStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"stats":  h.statsService.ListScheduleStats(),
+	})
+}
+
+// GetScheduleStats returns the data quality stats of a schedule
+func (h *StatsHandler) GetScheduleStats(c *gin.Context) {
+	stats, err := h.statsService.GetScheduleStats(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"stats":  stats,
+	})
+}
+
+// ListQuotaUsage returns the consumption of every quota in the current day
+func (h *StatsHandler) ListQuotaUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"quotas": h.quotaService.Usage(),
+	})
+}
+
+// GetMetrics serves metrics in the Prometheus text exposition format
+func (h *StatsHandler) GetMetrics(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := h.statsService.WritePrometheus(&buf); err != nil {
+		h.logger.WithError(err).Error("Failed to render metrics")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// TemplateHandler handles saved template endpoints
+type TemplateHandler struct {
+	templateService service.SavedTemplateService
+	jobRunner       *service.JobRunner
+	sessionService  service.SessionService
+	quotaService    service.QuotaService
+	cfg             *config.Config
+	logger          *logrus.Logger
+}
+
+// NewTemplateHandler creates a new template handler
+func NewTemplateHandler(
+	templateService service.SavedTemplateService,
+	jobRunner *service.JobRunner,
+	sessionService service.SessionService,
+	quotaService service.QuotaService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *TemplateHandler {
+	return &TemplateHandler{
+		templateService: templateService,
+		jobRunner:       jobRunner,
+		sessionService:  sessionService,
+		quotaService:    quotaService,
+		cfg:             cfg,
+		logger:          logger,
+	}
+}
+
+// CreateTemplate saves a new template
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	var req model.SavedTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	template, err := h.templateService.CreateTemplate(req, service.UserFromContext(c.Request.Context()))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Failed to save template: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   "success",
+		"template": template,
+	})
+}
+
+// UpdateTemplate replaces a template's description and params
+func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
+	var req model.SavedTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	name := c.Param("name")
+	if _, err := h.templateService.GetTemplate(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	template, err := h.templateService.UpdateTemplate(name, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Failed to update template: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"template": template,
+	})
+}
+
+// ListTemplates returns all saved templates
+func (h *TemplateHandler) ListTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"templates": h.templateService.ListTemplates(),
+	})
+}
+
+// GetTemplate returns a single template
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	template, err := h.templateService.GetTemplate(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"template": template,
+	})
+}
+
+// DeleteTemplate removes a template
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	if err := h.templateService.DeleteTemplate(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// RunTemplate starts a template as a background job on the caller's ClickHouse
+// session. Follow it with GET /jobs/:id or /jobs/:id/events.
+func (h *TemplateHandler) RunTemplate(c *gin.Context) {
+	template, err := h.templateService.GetTemplate(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	params := template.Params
+	if params.SourceType == "clickhouse" || params.TargetType == "clickhouse" {
+		if _, ok := clickhouseSession(c, h.sessionService); !ok {
+			return
+		}
+		params.SessionID = c.GetHeader(SessionHeader)
+	}
+
+	// Runs count against the quotas of the caller, not the template's author
+	params.User = service.UserFromContext(c.Request.Context())
+	labels := service.MergeLabels(h.sessionService.Labels(params.SessionID), params.Labels)
+	if usage, over := h.quotaService.Check(params.User, labels); over && h.cfg.QuotaPolicy != "queue" {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"status":  "error",
+			"message": "Quota exceeded, try again after " + usage.ResetAt.Format(time.RFC3339),
+			"quota":   usage,
+		})
+		return
+	}
+
+	job, _ := h.jobRunner.Start(params, logrus.Fields{"template": template.Name})
+
+	c.Header("X-Job-ID", job.ID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+		"job":    service.RedactJob(job),
+	})
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyHeader carries an API key on requests
+const APIKeyHeader = "X-API-Key"
+
+// apiKey is a configured key, held only as its SHA-256 digest
+type apiKey struct {
+	name   string
+	digest []byte
+}
+
+// Authenticate protects a route group with API keys and, when a JWKS URL is configured,
+// JWT bearer tokens. Requests carrying X-API-Key are checked against the keys; all others
+// must present a valid JWT. The caller identity is exposed to handlers under UserKey.
+func Authenticate(cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
+	keys, err := loadAPIKeys(cfg)
+	if err != nil {
+		// Fail closed: a broken key configuration rejects every key
+		logger.WithError(err).Error("Failed to load API keys")
+		keys = nil
+	}
+
+	var jwtAuth gin.HandlerFunc
+	if cfg.JWKSURL != "" {
+		jwtAuth = JWTAuth(cfg, logger)
+	}
+
+	return func(c *gin.Context) {
+		presented := c.GetHeader(APIKeyHeader)
+		if presented == "" && jwtAuth != nil {
+			jwtAuth(c)
+			return
+		}
+		if presented == "" {
+			abortUnauthorized(c, "missing API key")
+			return
+		}
+
+		name, ok := matchAPIKey(keys, presented)
+		if !ok {
+			logger.WithField("requestId", c.GetString(RequestIDKey)).Warn("Rejected API key")
+			abortUnauthorized(c, "invalid API key")
+			return
+		}
+
+		setUser(c, "apikey:"+name)
+		c.Next()
+	}
+}
+
+// matchAPIKey returns the name of the key matching presented, comparing digests in constant time
+func matchAPIKey(keys []apiKey, presented string) (string, bool) {
+	digest := sha256.Sum256([]byte(presented))
+
+	name, found := "", false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare(key.digest, digest[:]) == 1 {
+			name, found = key.name, true
+		}
+	}
+	return name, found
+}
+
+// loadAPIKeys reads keys from API_KEYS (comma-separated) and API_KEYS_FILE (one per line).
+// Each entry is "name=secret" or a bare secret; a secret of the form "sha256:<hex>" is a
+// pre-hashed key so the plain key never has to be stored on the server.
+func loadAPIKeys(cfg *config.Config) ([]apiKey, error) {
+	entries := strings.Split(cfg.APIKeys, ",")
+
+	if cfg.APIKeysFile != "" {
+		file, err := os.Open(cfg.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open API keys file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read API keys file: %w", err)
+		}
+	}
+
+	var keys []apiKey
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, secret, named := strings.Cut(entry, "=")
+		if !named {
+			name, secret = fmt.Sprintf("key%d", i+1), entry
+		}
+		key, err := parseAPIKey(name, secret)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseAPIKey builds a key from a plain secret or a "sha256:<hex>" digest
+func parseAPIKey(name, secret string) (apiKey, error) {
+	if hashed, ok := strings.CutPrefix(secret, "sha256:"); ok {
+		digest, err := hex.DecodeString(hashed)
+		if err != nil || len(digest) != sha256.Size {
+			return apiKey{}, fmt.Errorf("API key %q: invalid sha256 digest", name)
+		}
+		return apiKey{name: name, digest: digest}, nil
+	}
+
+	if secret == "" {
+		return apiKey{}, fmt.Errorf("API key %q is empty", name)
+	}
+	digest := sha256.Sum256([]byte(secret))
+	return apiKey{name: name, digest: digest[:]}, nil
+}
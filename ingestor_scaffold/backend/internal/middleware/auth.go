@@ -2,14 +2,13 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/service"
 	"github.com/sirupsen/logrus"
 )
 
 // Logger middleware adds logging to requests
 func Logger(logger *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Start timer
-		start := logger.WithTime(c.Request.Time)
 		path := c.Request.URL.Path
 		
 		// Process request
@@ -23,7 +22,12 @@ func Logger(logger *logrus.Logger) gin.HandlerFunc {
 			"latency":    c.Writer.Header().Get("X-Response-Time"),
 			"ip":         c.ClientIP(),
 			"user-agent": c.Request.UserAgent(),
+			"requestId":  c.GetString(RequestIDKey),
+			"user":       c.GetString(UserKey),
 		})
+		if labels := service.LabelsFromContext(c.Request.Context()); len(labels) > 0 {
+			end = end.WithField("labels", labels)
+		}
 		
 		// Log based on status code
 		if c.Writer.Status() >= 500 {
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/ingestor/internal/service"
+)
+
+// DoctorChecks returns the self-checks of the authentication settings: the API keys
+// load and the JWKS endpoint serves usable signing keys
+func DoctorChecks(cfg *config.Config) []service.DoctorCheckFunc {
+	return []service.DoctorCheckFunc{checkAPIKeys(cfg), checkJWKS(cfg)}
+}
+
+func checkAPIKeys(cfg *config.Config) service.DoctorCheckFunc {
+	return func(ctx context.Context) model.DoctorCheck {
+		check := model.DoctorCheck{Name: "api_keys"}
+		if cfg.APIKeys == "" && cfg.APIKeysFile == "" {
+			check.Status = service.DoctorSkip
+			if cfg.JWKSURL == "" {
+				check.Status, check.Message = service.DoctorWarn, "no API keys or JWKS_URL; the API is unauthenticated"
+			}
+			return check
+		}
+
+		keys, err := loadAPIKeys(cfg)
+		if err != nil {
+			check.Status, check.Message = service.DoctorFail, err.Error()
+			return check
+		}
+		check.Status, check.Message = service.DoctorOK, fmt.Sprintf("%d keys", len(keys))
+		return check
+	}
+}
+
+func checkJWKS(cfg *config.Config) service.DoctorCheckFunc {
+	return func(ctx context.Context) model.DoctorCheck {
+		check := model.DoctorCheck{Name: "jwks"}
+		if cfg.JWKSURL == "" {
+			check.Status = service.DoctorSkip
+			return check
+		}
+
+		keys := &jwksCache{
+			url:    cfg.JWKSURL,
+			client: service.NewHTTPClient(cfg.OutboundProxy, 10*time.Second),
+		}
+		keys.mu.Lock()
+		err := keys.fetchLocked(ctx)
+		count := len(keys.keys)
+		keys.mu.Unlock()
+
+		switch {
+		case err != nil:
+			check.Status, check.Message = service.DoctorFail, err.Error()
+		case count == 0:
+			check.Status, check.Message = service.DoctorFail, "JWKS has no usable signing keys"
+		default:
+			check.Status, check.Message = service.DoctorOK, fmt.Sprintf("%d signing keys", count)
+		}
+		return check
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ingestor/internal/config"
+)
+
+// RequireFeature answers 501 with the feature's name on routes of an optional
+// feature that is turned off, so its services are never reached
+func RequireFeature(cfg *config.Config, feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.FeatureEnabled(feature) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{
+			"status":  "error",
+			"message": "The " + feature + " feature is disabled on this server",
+			"feature": feature,
+		})
+	}
+}
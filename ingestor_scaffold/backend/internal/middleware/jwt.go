@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// UserKey is the gin context key holding the authenticated subject
+const UserKey = "user"
+
+// ClaimsKey is the gin context key holding the verified token claims
+const ClaimsKey = "claims"
+
+// jwksMinRefetch bounds how often an unknown key ID triggers a JWKS refetch
+const jwksMinRefetch = 30 * time.Second
+
+// JWTAuth verifies bearer tokens against the configured JWKS, issuer and audience,
+// and exposes the token subject to handlers under UserKey
+func JWTAuth(cfg *config.Config, logger *logrus.Logger) gin.HandlerFunc {
+	keys := &jwksCache{
+		url:     cfg.JWKSURL,
+		refresh: cfg.JWKSRefreshInterval,
+		client:  service.NewHTTPClient(cfg.OutboundProxy, 10*time.Second),
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.JWTLeeway),
+	}
+	if cfg.JWTIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.JWTIssuer))
+	}
+	if cfg.JWTAudience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.JWTAudience))
+	}
+	parser := jwt.NewParser(opts...)
+
+	return func(c *gin.Context) {
+		raw, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			abortUnauthorized(c, "missing bearer token")
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return keys.key(c.Request.Context(), kid)
+		})
+		if err != nil {
+			logger.WithError(err).WithField("requestId", c.GetString(RequestIDKey)).Warn("Rejected bearer token")
+			abortUnauthorized(c, "invalid token")
+			return
+		}
+
+		subject, _ := claims.GetSubject()
+		setUser(c, subject)
+		c.Set(ClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// setUser exposes the authenticated caller to handlers and, through the request
+// context, to the services that attribute jobs to it
+func setUser(c *gin.Context, user string) {
+	c.Set(UserKey, user)
+	c.Request = c.Request.WithContext(service.WithUser(c.Request.Context(), user))
+}
+
+// bearerToken extracts the token from an Authorization header
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// abortUnauthorized ends the request with a 401 in the API's error format
+func abortUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="ingestor"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// jwksCache fetches and caches signing keys from a JWKS endpoint
+type jwksCache struct {
+	mu        sync.Mutex
+	url       string
+	refresh   time.Duration
+	client    *http.Client
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// key returns the public key for kid, refetching when the set is stale or the kid is unknown
+func (j *jwksCache) key(ctx context.Context, kid string) (interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	age := time.Since(j.fetchedAt)
+	stale := j.refresh > 0 && age >= j.refresh
+	key, ok := j.keys[kid]
+	if ok && !stale {
+		return key, nil
+	}
+
+	// Unknown key IDs refetch at most every jwksMinRefetch so bad tokens cannot hammer the endpoint
+	if !ok && !stale && age < jwksMinRefetch {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := j.fetchLocked(ctx); err != nil {
+		// Keep serving a cached key while the endpoint is unavailable
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, ok = j.keys[kid]; !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// fetchLocked downloads the key set and replaces the cached keys
+func (j *jwksCache) fetchLocked(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Skip key types we cannot verify with rather than failing the whole set
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+// jsonWebKey is a single RSA or EC entry of a JWKS document
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes the key material into an *rsa.PublicKey or *ecdsa.PublicKey
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
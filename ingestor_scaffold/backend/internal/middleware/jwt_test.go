@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ingestor/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"keys":[{"kid":"test","kty":"RSA","use":"sig","n":"`+
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes())+`","e":"`+
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())+`"}]}`)
+	}))
+	defer jwks.Close()
+
+	cfg := &config.Config{
+		JWKSURL:     jwks.URL,
+		JWTIssuer:   "https://issuer.example",
+		JWTAudience: "ingestor",
+	}
+	r := gin.New()
+	r.Use(JWTAuth(cfg, logger))
+	r.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(UserKey))
+	})
+
+	claims := func(mutate func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub": "alice",
+			"iss": "https://issuer.example",
+			"aud": "ingestor",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		if mutate != nil {
+			mutate(c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, signingKey interface{}, c jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, c)
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(signingKey)
+		assert.NoError(t, err)
+		return signed
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid", sign(jwt.SigningMethodRS256, key, claims(nil)), http.StatusOK},
+		{"hmac with public key", sign(jwt.SigningMethodHS256, publicDER, claims(nil)), http.StatusUnauthorized},
+		{"alg none", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(nil)), http.StatusUnauthorized},
+		{"wrong issuer", sign(jwt.SigningMethodRS256, key, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" })), http.StatusUnauthorized},
+		{"wrong audience", sign(jwt.SigningMethodRS256, key, claims(func(c jwt.MapClaims) { c["aud"] = "billing" })), http.StatusUnauthorized},
+		{"expired", sign(jwt.SigningMethodRS256, key, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), http.StatusUnauthorized},
+		{"no expiry", sign(jwt.SigningMethodRS256, key, claims(func(c jwt.MapClaims) { delete(c, "exp") })), http.StatusUnauthorized},
+		{"bad signature", sign(jwt.SigningMethodRS256, otherKey, claims(nil)), http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.status, w.Code, tt.name)
+		if tt.status == http.StatusOK {
+			assert.Equal(t, "alice", w.Body.String(), tt.name)
+		}
+	}
+}
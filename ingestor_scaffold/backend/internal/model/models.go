@@ -9,21 +9,203 @@ import (
 type Column struct {
 	Name string `json:"name"`
 	Type string `json:"type"`
+
+	// Layout of flat file values of a Date or DateTime column, as a Go layout
+	// ("02-Jan-2006 15:04") or strptime format ("%d-%b-%Y %H:%M")
+	Format string `json:"format,omitempty"`
 }
 
 // ClickHouseConnectionParams contains connection parameters for ClickHouse
 type ClickHouseConnectionParams struct {
-	Host     string `json:"host"`
+	Host     string `json:"host"` // hostname, IPv4 or IPv6 literal
 	Port     int    `json:"port"`
 	Database string `json:"database"`
 	User     string `json:"user"`
-	Token    string `json:"token"`
+	Token    string `json:"token"`              // JWT access token
+	Password string `json:"password,omitempty"` // alternative to Token for password-authenticated users
+
+	// Preset "cloud" connects to ClickHouse Cloud: TLS, port 9440 (8443 over HTTP)
+	// unless given, and settings tuned for it. Protocol is "native" or "http".
+	Preset   string `json:"preset,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+
+	// Port of the HTTP interface that streams server-formatted exports, when the
+	// connection uses the native protocol; defaults to 8123, or 8443 with TLS
+	HTTPPort int `json:"httpPort,omitempty"`
+
+	// Failover: extra replicas ("host" or "host:port"), expansion of DNS names
+	// resolving to several addresses, and "in_order" or "round_robin" selection
+	Hosts        []string `json:"hosts,omitempty"`
+	ExpandDNS    bool     `json:"expandDns,omitempty"`
+	OpenStrategy string   `json:"openStrategy,omitempty"`
+
+	// Optional overrides of the server's client tuning defaults
+	Compression        string `json:"compression,omitempty"`
+	CompressionLevel   int    `json:"compressionLevel,omitempty"`
+	DialTimeoutSeconds int    `json:"dialTimeoutSeconds,omitempty"`
+	ReadTimeoutSeconds int    `json:"readTimeoutSeconds,omitempty"`
+	Proxy              string `json:"proxy,omitempty"`
+
+	// TLS: Secure enables it; PEM-encoded CA bundle and client certificate for mTLS.
+	// Any certificate field implies Secure.
+	Secure             bool   `json:"secure,omitempty"`
+	TLSServerName      string `json:"tlsServerName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	CACert             string `json:"caCert,omitempty"`
+	ClientCert         string `json:"clientCert,omitempty"`
+	ClientKey          string `json:"clientKey,omitempty"`
+
+	// Cost attribution labels (team, project, ticket) applied to every job and query on the connection
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Cluster that tables are created, altered and optimized ON CLUSTER across,
+	// as named in the server's remote_servers; empty for a single node. Replicated
+	// creates MergeTree family tables with their Replicated engines.
+	Cluster    string `json:"cluster,omitempty"`
+	Replicated bool   `json:"replicated,omitempty"`
+}
+
+// ConnectionDiagnostics describes a tested ClickHouse connection, or why it failed
+type ConnectionDiagnostics struct {
+	Connected     bool     `json:"connected"`
+	Error         string   `json:"error,omitempty"`
+	Hint          string   `json:"hint,omitempty"` // likely cause of the error
+	ServerVersion string   `json:"serverVersion,omitempty"`
+	Timezone      string   `json:"timezone,omitempty"`
+	User          string   `json:"user,omitempty"`
+	Database      string   `json:"database,omitempty"`
+	Databases     []string `json:"databases,omitempty"`
+	Grants        []string `json:"grants,omitempty"`
+	LatencyMs     float64  `json:"latencyMs,omitempty"` // ping round trip
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// DisconnectRequest names the ClickHouse session to close
+type DisconnectRequest struct {
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// TokenRefreshRequest replaces the JWT of a ClickHouse session before it expires;
+// connections opened from then on, running jobs included, authenticate with it
+type TokenRefreshRequest struct {
+	SessionID string `json:"sessionId,omitempty"`
+	Token     string `json:"token"`
 }
 
 // FlatFileParams contains parameters for flat file operations
 type FlatFileParams struct {
 	FilePath  string `json:"filePath"`
 	Delimiter string `json:"delimiter"`
+
+	// Export durability: fsync the file and its directory entry, and
+	// re-read the published file to verify its checksum
+	Fsync          bool `json:"fsync,omitempty"`
+	VerifyChecksum bool `json:"verifyChecksum,omitempty"`
+
+	// Text encoding of FixedString columns in the file: "hex", "base64" or empty for raw bytes
+	BinaryEncoding string `json:"binaryEncoding,omitempty"`
+
+	// Format of exported geo columns: "wkt" (default) or "geojson"; imports accept either
+	GeoFormat string `json:"geoFormat,omitempty"`
+
+	// Re-scan the file for schema discovery even if its schema is cached
+	RefreshSchema bool `json:"refreshSchema,omitempty"`
+
+	// Infer column types from every row instead of a sample. Slow on large files,
+	// so best run as a schema discovery job that reports progress. Overrides the
+	// sampling settings below.
+	FullScan bool `json:"fullScan,omitempty"`
+
+	// Schema discovery sampling: "head" (default) infers types from the first
+	// SampleSize rows, "random" from rows kept with probability SampleRate (default
+	// 0.1) until SampleSize are kept, and "reservoir" from SampleSize rows drawn
+	// uniformly from the whole file. SampleSize defaults to 100; a SampleSeed makes
+	// random samples repeatable, and cached.
+	SampleStrategy string  `json:"sampleStrategy,omitempty"`
+	SampleSize     int     `json:"sampleSize,omitempty"`
+	SampleRate     float64 `json:"sampleRate,omitempty"`
+	SampleSeed     int64   `json:"sampleSeed,omitempty"`
+
+	// Handling of values that do not parse as their column's type: "strict" rejects
+	// the row, "lenient" loads NULL into Nullable columns and rejects the row
+	// otherwise, and "legacy" loads the type's zero value. Defaults to COERCION_POLICY.
+	CoercionPolicy string `json:"coercionPolicy,omitempty"`
+
+	// Workers converting records to rows on load, defaulting to PARSE_WORKERS and
+	// at most MAX_PARSE_WORKERS.
+	// Parallel rows keep the file's order unless UnorderedParse relaxes it.
+	ParseWorkers   int  `json:"parseWorkers,omitempty"`
+	UnorderedParse bool `json:"unorderedParse,omitempty"`
+}
+
+// HeaderRename records a CSV header name changed to keep column names unique
+type HeaderRename struct {
+	Position int    `json:"position"` // zero-based column position
+	Original string `json:"original"`
+	Name     string `json:"name"`
+	Reason   string `json:"reason"` // blank, duplicate or case_duplicate
+}
+
+// FileSchema is the discovered schema of a flat file
+type FileSchema struct {
+	Columns     []Column          `json:"columns"`
+	Fingerprint string            `json:"fingerprint"`
+	Renames     []HeaderRename    `json:"renames,omitempty"`
+	Inference   []ColumnInference `json:"inference,omitempty"`
+}
+
+// ColumnInference explains the type inferred for a column from the sampled values:
+// the share of values matching each candidate type and examples of values that do
+// not fit the chosen one. Ambiguous columns have such conflicting values.
+type ColumnInference struct {
+	Column     string          `json:"column"`
+	Type       string          `json:"type"`
+	Candidates []TypeCandidate `json:"candidates"`
+	Conflicts  []string        `json:"conflicts,omitempty"`
+	Ambiguous  bool            `json:"ambiguous"`
+}
+
+// TypeCandidate is a type inferred for some of a column's sampled values; empty
+// values count as Nullable(String)
+type TypeCandidate struct {
+	Type    string  `json:"type"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// ColumnOverride changes the type, nullability or date format of a discovered column
+type ColumnOverride struct {
+	Column   string `json:"column"`
+	Type     string `json:"type,omitempty"`
+	Nullable *bool  `json:"nullable,omitempty"`
+	Format   string `json:"format,omitempty"`
+}
+
+// SchemaOverrideRequest applies column overrides to the discovered schema of a file,
+// checking them against up to SampleSize rows
+type SchemaOverrideRequest struct {
+	FlatFileParams FlatFileParams   `json:"flatFileParams"`
+	Overrides      []ColumnOverride `json:"overrides"`
+	SampleSize     int              `json:"sampleSize,omitempty"`
+}
+
+// SchemaOverrideResult is a discovered schema with overrides applied, ready to pass
+// as the columns of an ingestion, and how many sampled rows the overrides would fail
+type SchemaOverrideResult struct {
+	Columns     []Column              `json:"columns"`
+	Fingerprint string                `json:"fingerprint"`
+	SampledRows int                   `json:"sampledRows"`
+	FailingRows int                   `json:"failingRows"`
+	Checks      []ColumnOverrideCheck `json:"checks"`
+}
+
+// ColumnOverrideCheck counts the sampled rows whose value of an overridden column
+// does not parse as its new type, with a few of those values
+type ColumnOverrideCheck struct {
+	Column      string   `json:"column"`
+	Type        string   `json:"type"`
+	FailingRows int      `json:"failingRows"`
+	Examples    []string `json:"examples,omitempty"`
 }
 
 // PreviewParams contains parameters for data preview
@@ -34,38 +216,612 @@ type PreviewParams struct {
 	Delimiter   string    `json:"delimiter"`
 	Columns     []Column  `json:"columns"`
 	Query       string    `json:"query,omitempty"`
+
+	// Conditions on ClickHouse previews; flat files cannot be filtered
+	Filters []Filter `json:"filters,omitempty"`
+
+	// Text encoding for binary values: "base64" (default) or "hex"
+	BinaryEncoding string `json:"binaryEncoding,omitempty"`
+
+	// Rendering of geo values: "wkt" (default) or "geojson"
+	GeoFormat string `json:"geoFormat,omitempty"`
+
+	// Paging: start at Offset rows, or at the nextCursor of a previous page, and
+	// return up to Limit rows (at most MAX_PREVIEW_ROWS, the default)
+	Offset int    `json:"offset,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// CountParams selects the rows to count: a ClickHouse table, optionally filtered,
+// a join of tables, or a flat file
+type CountParams struct {
+	SourceType string      `json:"sourceType"` // "clickhouse", "join" or "flatfile"
+	TableName  string      `json:"tableName,omitempty"`
+	Filters    []Filter    `json:"filters,omitempty"`
+	Join       *JoinParams `json:"join,omitempty"`
+	FilePath   string      `json:"filePath,omitempty"`
+	Delimiter  string      `json:"delimiter,omitempty"`
+
+	// Exact counts every row; otherwise tables report their metadata and large files
+	// are sampled
+	Exact bool `json:"exact,omitempty"`
+}
+
+// RowCount is the number of rows of a source and how it was obtained
+type RowCount struct {
+	Rows  int64 `json:"rows"`
+	Exact bool  `json:"exact"`
+
+	// "count" (a count() query), "metadata" (the table's total_rows), "lines"
+	// (every line of the file) or "sample" (lines of evenly spaced file chunks)
+	Method string `json:"method"`
+
+	// Uncompressed size of a table, from its metadata
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// Flat files: the file's size and the bytes read to count it
+	FileBytes    int64 `json:"fileBytes,omitempty"`
+	SampledBytes int64 `json:"sampledBytes,omitempty"`
+}
+
+// ProfileParams selects the data to profile: a ClickHouse table, or a sample of the
+// first rows of a flat file
+type ProfileParams struct {
+	SourceType string   `json:"sourceType"` // "clickhouse" or "flatfile"
+	TableName  string   `json:"tableName,omitempty"`
+	FilePath   string   `json:"filePath,omitempty"`
+	Delimiter  string   `json:"delimiter,omitempty"`
+	Columns    []string `json:"columns,omitempty"` // all columns when empty
+
+	// Rows profiled: flat files default to PROFILE_SAMPLE_ROWS, tables to every row
+	SampleRows int `json:"sampleRows,omitempty"`
+
+	// Most frequent values reported per column, 5 by default
+	TopValues int `json:"topValues,omitempty"`
+}
+
+// DataProfile holds the statistics of each profiled column
+type DataProfile struct {
+	Rows    int64           `json:"rows"`
+	Sampled bool            `json:"sampled"` // only the first rows were profiled
+	Columns []ColumnProfile `json:"columns"`
+}
+
+// ColumnProfile describes the values of a column. Distinct counts of tables are
+// estimated by ClickHouse; those of flat files are exact within the sample. Min and
+// max compare numbers numerically and other values as text.
+type ColumnProfile struct {
+	Name        string       `json:"name"`
+	Type        string       `json:"type"`
+	Nulls       int64        `json:"nulls"`
+	NullPercent float64      `json:"nullPercent"`
+	Distinct    int64        `json:"distinct"`
+	Min         string       `json:"min,omitempty"`
+	Max         string       `json:"max,omitempty"`
+	Mean        *float64     `json:"mean,omitempty"` // numeric columns only
+	TopValues   []ValueCount `json:"topValues,omitempty"`
+}
+
+// ValueCount is a value and the rows holding it
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// QueryValidationRequest carries custom SQL to check, as used in IngestionParams.Query
+type QueryValidationRequest struct {
+	Query string `json:"query"`
+}
+
+// QueryValidation is the outcome of checking custom SQL without running it
+type QueryValidation struct {
+	Valid    bool   `json:"valid"`
+	ReadOnly bool   `json:"readOnly"`
+	Error    string `json:"error,omitempty"`
+}
+
+// QueryPlan is ClickHouse's plan for the query a ClickHouse export would run, with
+// its estimate of the rows read from each MergeTree table
+type QueryPlan struct {
+	Query    string         `json:"query"`
+	Args     []interface{}  `json:"args,omitempty"`
+	Plan     []string       `json:"plan"`
+	Estimate []ReadEstimate `json:"estimate,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// ReadEstimate is a row of EXPLAIN ESTIMATE: what a query reads from one table
+type ReadEstimate struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Parts    uint64 `json:"parts"`
+	Rows     uint64 `json:"rows"`
+	Marks    uint64 `json:"marks"`
+}
+
+// CostEstimate prices a job's use of metered ClickHouse at the configured unit costs:
+// bytes scanned by source queries, transferred to or from ClickHouse, and stored in
+// target tables. Estimates come from table metadata and file sizes; actuals from the
+// bytes a job moved.
+type CostEstimate struct {
+	Rows             int64 `json:"rows"`
+	Exact            bool  `json:"exact"`
+	BytesScanned     int64 `json:"bytesScanned"`
+	BytesTransferred int64 `json:"bytesTransferred"`
+	BytesStored      int64 `json:"bytesStored"`
+
+	Scanned     float64 `json:"scanned"`
+	Transferred float64 `json:"transferred"`
+	Stored      float64 `json:"stored"`
+	Total       float64 `json:"total"`
+	Currency    string  `json:"currency"`
+}
+
+// PreviewTruncation reports what a preview cut to stay within server limits
+type PreviewTruncation struct {
+	Truncated      bool     `json:"truncated"`
+	MaxRows        int      `json:"maxRows"`
+	MaxColumns     int      `json:"maxColumns"`
+	MaxCellBytes   int      `json:"maxCellBytes"`
+	MaxBytes       int      `json:"maxBytes"`
+	OmittedColumns []string `json:"omittedColumns,omitempty"`
+	OmittedRows    int      `json:"omittedRows,omitempty"`
+	TruncatedCells int      `json:"truncatedCells,omitempty"`
+	Reasons        []string `json:"reasons,omitempty"`
 }
 
 // IngestionParams contains parameters for data ingestion
 type IngestionParams struct {
-	SourceType     string        `json:"sourceType"`
-	TargetType     string        `json:"targetType"`
-	TableName      string        `json:"tableName"`
-	FlatFileParams FlatFileParams `json:"flatFileParams"`
-	Columns        []Column      `json:"columns"`
-	Query          string        `json:"query,omitempty"`
+	SessionID        string         `json:"sessionId,omitempty"` // ClickHouse session from /clickhouse/connect
+	SourceType       string         `json:"sourceType"`
+	TargetType       string         `json:"targetType"`
+	TableName        string         `json:"tableName"`
+	FlatFileParams   FlatFileParams `json:"flatFileParams"`
+	Columns          []Column       `json:"columns"`
+	Query            string         `json:"query,omitempty"`
+	MaxRowsPerSecond int            `json:"maxRowsPerSecond,omitempty"`
+
+	// Renames columns between source and target, e.g. the CSV header "Customer ID" to
+	// the ClickHouse column customer_id. Keys, cursor and dedup columns keep naming
+	// source columns.
+	ColumnMappings []ColumnMapping `json:"columnMappings,omitempty"`
+
+	// Cleansing applied in order to flat file rows between read and insert; they
+	// name source columns
+	Transforms []ColumnTransform `json:"transforms,omitempty"`
+
+	// Target columns computed for each flat file row from its source columns, after
+	// transforms; they are added after the source columns
+	DerivedColumns []DerivedColumn `json:"derivedColumns,omitempty"`
+
+	// Values for columns of an existing target table that the flat file lacks;
+	// without one such columns get the table's own defaults
+	ColumnDefaults []ColumnDefault `json:"columnDefaults,omitempty"`
+
+	// Data-quality checks on flat file rows after transforms; rows breaking one are
+	// bad rows, handled by the error policy
+	Rules []ColumnRule `json:"rules,omitempty"`
+
+	// Engine of a table created by an append load: MergeTree (default),
+	// ReplacingMergeTree, SummingMergeTree or Log, with column arguments such as
+	// the version column of ReplacingMergeTree or the summed columns
+	Engine     string   `json:"engine,omitempty"`
+	EngineArgs []string `json:"engineArgs,omitempty"`
+
+	// Keys of a created table. OrderBy sorts append loads only, as upsert and cdc
+	// modes sort by UpsertKey; PrimaryKey must be a prefix of the sorting key, and
+	// PartitionBy is a column or a date function of one, such as toYYYYMM(ts)
+	OrderBy     []string `json:"orderBy,omitempty"`
+	PrimaryKey  []string `json:"primaryKey,omitempty"`
+	PartitionBy string   `json:"partitionBy,omitempty"`
+
+	// Rows, or values of single columns, of a created table expire this long
+	// after a date column; MergeTree family engines only
+	TTL        *TTL        `json:"ttl,omitempty"`
+	ColumnTTLs []ColumnTTL `json:"columnTtls,omitempty"`
+
+	// Codecs and LowCardinality wrappers of the columns of a created table
+	ColumnStorage []ColumnStorage `json:"columnStorage,omitempty"`
+
+	// Cluster and replication of the target table, overriding the connection's.
+	// Replicas register under ZooKeeperPath as ReplicaName, by default the
+	// /clickhouse/tables/{shard}/{database}/{table} path and {replica} macros.
+	Cluster       string `json:"cluster,omitempty"`
+	Replicated    *bool  `json:"replicated,omitempty"`
+	ZooKeeperPath string `json:"zooKeeperPath,omitempty"`
+	ReplicaName   string `json:"replicaName,omitempty"`
+
+	// Shards a created table across the cluster
+	Distributed *DistributedTable `json:"distributed,omitempty"`
+
+	// ClickHouse to flat file exports ClickHouse formats itself, streamed to the
+	// file over the HTTP interface without reading rows: "CSVWithNames",
+	// "TSVWithNames" or "Parquet". Row processing options do not apply.
+	ServerFormat string `json:"serverFormat,omitempty"`
+
+	// Splits a ClickHouse to flat file export into ranges exported concurrently
+	Parallel *ParallelExport `json:"parallel,omitempty"`
+
+	// Compares the target with the source once the job succeeds
+	Verify *VerifySpec `json:"verify,omitempty"`
+
+	// How rows are inserted: "sync" prepared batches (default), durable once
+	// acknowledged, or "async" inserts the server buffers and flushes together,
+	// suited to trickle loads
+	InsertMode  string           `json:"insertMode,omitempty"`
+	AsyncInsert *AsyncInsertSpec `json:"asyncInsert,omitempty"`
+
+	// Handling of rows that cannot be read, converted or inserted: "skip" (default)
+	// drops them, failing once more than MaxErrors are dropped if it is set; "abort"
+	// fails on the first; "collect" drops them and reports each in RowErrors
+	ErrorPolicy string `json:"errorPolicy,omitempty"`
+	MaxErrors   int    `json:"maxErrors,omitempty"`
+
+	// Upsert mode creates a ReplacingMergeTree keyed on UpsertKey;
+	// cdc mode creates a (Versioned)CollapsingMergeTree keyed on UpsertKey
+	Mode               string   `json:"mode,omitempty"`
+	UpsertKey          []string `json:"upsertKey,omitempty"`
+	VersionColumn      string   `json:"versionColumn,omitempty"`
+	OptimizeFinal      bool     `json:"optimizeFinal,omitempty"`
+	CDCEngine          string   `json:"cdcEngine,omitempty"`
+	DeleteMarkerColumn string   `json:"deleteMarkerColumn,omitempty"`
+
+	// Rows repeating these key columns are dropped within the job
+	DedupKey []string `json:"dedupKey,omitempty"`
+
+	// The largest value of this column among the moved rows is reported as MaxCursor
+	CursorColumn string `json:"cursorColumn,omitempty"`
+
+	// Notifications sent when the job finishes
+	Notifications *NotificationSpec `json:"notifications,omitempty"`
+
+	// Values extracted from JSON columns into extra export columns
+	JSONPaths []JSONPathColumn `json:"jsonPaths,omitempty"`
+
+	// Export of AggregateFunction columns: "error" (default), "skip" or "finalize" via -Merge combinators
+	AggregatePolicy string `json:"aggregatePolicy,omitempty"`
+
+	// ClickHouse-to-ClickHouse copies replay the source DDL on the target instance
+	TargetConnection *ClickHouseConnectionParams `json:"targetConnection,omitempty"`
+	TargetTableName  string                      `json:"targetTableName,omitempty"`
+	DDLRewrite       *DDLRewrite                 `json:"ddlRewrite,omitempty"`
+
+	// Fault injection for the synthetic "chaos" source/target type (non-production only)
+	Chaos *ChaosSpec `json:"chaos,omitempty"`
+
+	// Exports a join instead of a single table; output column lineage is recorded
+	Join *JoinParams `json:"join,omitempty"`
+
+	// Reads a ClickHouse table function instead of a table. Loads into ClickHouse run
+	// on the server as INSERT INTO targetTableName SELECT, without passing the rows
+	// through this service.
+	TableFunction *TableFunction `json:"tableFunction,omitempty"`
+
+	// Pushes a ClickHouse export down to the server, which writes it through this
+	// table function (target type "function"), for example s3 with format Parquet.
+	// OverwriteTarget replaces an existing object instead of failing.
+	TargetFunction  *TableFunction `json:"targetFunction,omitempty"`
+	OverwriteTarget bool           `json:"overwriteTarget,omitempty"`
+
+	// Moves rows as positional slices instead of maps; implied for tables of at least
+	// WIDE_TABLE_COLUMNS columns. JSON path extraction is unavailable in this mode.
+	WideTable bool `json:"wideTable,omitempty"`
+
+	// Handling of an existing target table whose schema differs from the source:
+	// "fail", "map" (load only the matching columns) or "evolve" (add missing
+	// columns as Nullable). Defaults to TARGET_SCHEMA_POLICY.
+	SchemaPolicy string `json:"schemaPolicy,omitempty"`
+
+	// Cost attribution labels; they override the labels of the session's connection
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Authenticated caller that started the job, set by the server for quotas
+	User string `json:"user,omitempty"`
+}
+
+// ColumnMapping lands a source column under another name in the target
+type ColumnMapping struct {
+	SourceColumn string `json:"sourceColumn"`
+	TargetColumn string `json:"targetColumn"`
+}
+
+// ColumnTransform rewrites the values of a column. Functions: trim, upper, lower,
+// substring (from Start, counting from 1, for Length characters or to the end),
+// replace (regular expression Pattern with Replacement, $1 for groups), concat
+// (Columns joined with Separator) and scale (value * Factor + Offset, Factor
+// defaulting to 1).
+type ColumnTransform struct {
+	Column      string   `json:"column"`
+	Function    string   `json:"function"`
+	Start       int      `json:"start,omitempty"`
+	Length      int      `json:"length,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Replacement string   `json:"replacement,omitempty"`
+	Columns     []string `json:"columns,omitempty"`
+	Separator   string   `json:"separator,omitempty"`
+	Factor      float64  `json:"factor,omitempty"`
+	Offset      float64  `json:"offset,omitempty"`
+}
+
+// DerivedColumn is a target column computed by an expression over the source
+// columns, e.g. concat(first, ' ', last) or amount * rate. Columns whose names are
+// not identifiers are read as $env["Customer ID"].
+type DerivedColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Expression string `json:"expression"`
+}
+
+// ColumnDefault fills a target table column missing from the source with a constant
+// Value or, if set, an Expression over the source columns like those of
+// DerivedColumn
+type ColumnDefault struct {
+	Column     string      `json:"column"`
+	Value      interface{} `json:"value,omitempty"`
+	Expression string      `json:"expression,omitempty"`
+}
+
+// TableFunction is a ClickHouse table function call: s3, url, hdfs or file, allowed by
+// ALLOWED_TABLE_FUNCTIONS. Arguments are passed as string literals, for example
+// ["https://bucket.s3.amazonaws.com/data/*.csv.gz", "CSVWithNames"].
+type TableFunction struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+}
+
+// ChaosSpec configures the synthetic chaos connector. Faults are drawn from Seed,
+// so a run with the same spec fails the same way every time.
+type ChaosSpec struct {
+	Seed   int64       `json:"seed,omitempty"`
+	Rows   int         `json:"rows,omitempty"`
+	Source ChaosFaults `json:"source"`
+	Target ChaosFaults `json:"target"`
+}
+
+// ChaosFaults are the faults injected on one end of a chaos connection.
+// Row counts are 1-based; zero disables a fault.
+type ChaosFaults struct {
+	LatencyMs     int     `json:"latencyMs,omitempty"`
+	ErrorRate     float64 `json:"errorRate,omitempty"`
+	FailAfter     int     `json:"failAfter,omitempty"`
+	TruncateAfter int     `json:"truncateAfter,omitempty"`
+}
+
+// DDLRewrite adjusts a source CREATE TABLE statement before it is replayed on the target
+type DDLRewrite struct {
+	Engine  string `json:"engine,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// JSONPathColumn exports the value at Path inside JSON column Column as column Name.
+// Paths are dotted keys with optional array indexes, e.g. "user.tags[0]".
+type JSONPathColumn struct {
+	Name   string `json:"name"`
+	Column string `json:"column"`
+	Path   string `json:"path"`
+}
+
+// NotificationSpec selects where and when job notifications are sent
+type NotificationSpec struct {
+	OnSuccess bool     `json:"onSuccess"`
+	OnFailure bool     `json:"onFailure"`
+	Slack     bool     `json:"slack"`
+	Emails    []string `json:"emails,omitempty"`
+}
+
+// TableOptions controls the engine, keys, TTLs and column storage of a created table
+type TableOptions struct {
+	Engine        string          `json:"engine,omitempty"`
+	EngineArgs    []string        `json:"engineArgs,omitempty"`
+	OrderBy       []string        `json:"orderBy,omitempty"`
+	PrimaryKey    []string        `json:"primaryKey,omitempty"`
+	PartitionBy   string          `json:"partitionBy,omitempty"`
+	TTL           *TTL            `json:"ttl,omitempty"`
+	ColumnTTLs    []ColumnTTL     `json:"columnTtls,omitempty"`
+	ColumnStorage []ColumnStorage `json:"columnStorage,omitempty"`
+
+	// Cluster and replication, the job's or else the connection's
+	Cluster       string            `json:"cluster,omitempty"`
+	Replicated    bool              `json:"replicated,omitempty"`
+	ZooKeeperPath string            `json:"zooKeeperPath,omitempty"`
+	ReplicaName   string            `json:"replicaName,omitempty"`
+	Distributed   *DistributedTable `json:"distributed,omitempty"`
+}
+
+// AsyncInsertSpec tunes async inserts. Inserts wait for their flush unless Wait is
+// false, which trades durability for latency.
+type AsyncInsertSpec struct {
+	Wait               *bool `json:"wait,omitempty"`
+	WaitTimeoutSeconds int   `json:"waitTimeoutSeconds,omitempty"`
+	BusyTimeoutMs      int   `json:"busyTimeoutMs,omitempty"`
+	MaxDataSize        int   `json:"maxDataSize,omitempty"`
+}
+
+// VerifySpec asks for a verification pass after a flat file load or export: "count"
+// compares the rows of the source and the target, "checksum" also the sums of
+// their numeric columns. The lighter "sample" looks up SampleRows random rows of
+// the file, 100 by default, in the table: loaded rows in the target, exported
+// rows in the source; SampleSeed makes the draw repeatable.
+type VerifySpec struct {
+	Mode       string `json:"mode"`
+	SampleRows int    `json:"sampleRows,omitempty"`
+	SampleSeed int64  `json:"sampleSeed,omitempty"`
+}
+
+// Verification compares what a job wrote with its source. Loads count the rows
+// they added to the table; rows the job dropped on purpose are expected missing,
+// and leave column sums uncompared since they cannot match.
+type Verification struct {
+	Mode         string           `json:"mode"`
+	Passed       bool             `json:"passed"`
+	SourceRows   int64            `json:"sourceRows"`
+	DroppedRows  int64            `json:"droppedRows"`
+	ExpectedRows int64            `json:"expectedRows"`
+	TargetRows   int64            `json:"targetRows"`
+	Columns      []ColumnChecksum `json:"columns,omitempty"`
+	Mismatches   []string         `json:"mismatches,omitempty"`
+
+	// Why column sums were not compared, or the verification could not run
+	SumsSkipped string `json:"sumsSkipped,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	// Sample mode: the rows looked up, matched on SampleColumns, and the first of
+	// those not found. Rows the job dropped on purpose are among them.
+	SampledRows   int        `json:"sampledRows,omitempty"`
+	SampleColumns []string   `json:"sampleColumns,omitempty"`
+	MissingRows   int        `json:"missingRows,omitempty"`
+	Missing       []RowError `json:"missing,omitempty"`
+}
+
+// ColumnChecksum compares the sums of a numeric column in the source and the
+// target, equal within a relative 1e-6
+type ColumnChecksum struct {
+	Column string  `json:"column"`
+	Source float64 `json:"source"`
+	Target float64 `json:"target"`
+	Match  bool    `json:"match"`
+}
+
+// ParallelExport splits an export into Partitions ranges of a numeric, date or
+// time column, by default the first sorting key column of the exported table.
+// Each range is written to a part file; the parts are concatenated into the target
+// file, or kept as a sharded output set with Output "shards".
+type ParallelExport struct {
+	Partitions int    `json:"partitions"`
+	Column     string `json:"column,omitempty"`
+	Output     string `json:"output,omitempty"` // "concat" (default) or "shards"
+}
+
+// DistributedTable shards a table across a cluster: each node holds a local table
+// with the requested engine, and rows are written through a Distributed table
+// over them under the target table name
+type DistributedTable struct {
+	LocalTable  string `json:"localTable,omitempty"`  // defaults to <table>_local
+	ShardingKey string `json:"shardingKey,omitempty"` // column hashed to pick a shard; random when empty
+}
+
+// TTL ages data out an interval after the value of a Date or DateTime column
+type TTL struct {
+	DateColumn string `json:"dateColumn"`
+	Interval   int    `json:"interval"`
+	Unit       string `json:"unit"` // second, minute, hour, day, week, month, quarter or year
+}
+
+// ColumnStorage sets how a created column is stored: a chain of codecs such as
+// ["Delta", "ZSTD(3)"], specialized ones first, and whether it is LowCardinality
+type ColumnStorage struct {
+	Column         string   `json:"column"`
+	Codecs         []string `json:"codecs,omitempty"`
+	LowCardinality bool     `json:"lowCardinality,omitempty"`
+}
+
+// ColumnTTL resets the values of a column to its default once they expire
+type ColumnTTL struct {
+	Column string `json:"column"`
+	TTL    TTL    `json:"ttl"`
 }
 
 // JoinTableInfo contains info about a table in a join
 type JoinTableInfo struct {
-	Name            string   `json:"name"`
-	JoinType        string   `json:"joinType,omitempty"`
-	JoinCondition   string   `json:"joinCondition,omitempty"`
-	SelectedColumns []string `json:"selectedColumns"`
+	Name            string    `json:"name"`
+	JoinType        string    `json:"joinType,omitempty"`
+	JoinOn          []JoinKey `json:"joinOn,omitempty"`
+	SelectedColumns []string  `json:"selectedColumns"`
+
+	// Deprecated: raw SQL is rejected; use JoinOn
+	JoinCondition string `json:"joinCondition,omitempty"`
+}
+
+// JoinKey relates a column of an earlier table in a join to a column of the joined
+// table. Operators are =, <, <=, > and >=; ASOF joins need one inequality last.
+type JoinKey struct {
+	LeftTable   string `json:"leftTable,omitempty"` // defaults to the first table
+	LeftColumn  string `json:"leftColumn"`
+	Operator    string `json:"operator,omitempty"` // defaults to =
+	RightColumn string `json:"rightColumn"`
 }
 
 // JoinParams contains parameters for join operations
 type JoinParams struct {
-	Tables      []JoinTableInfo `json:"tables"`
-	WhereClause string          `json:"whereClause,omitempty"`
+	Tables  []JoinTableInfo `json:"tables"`
+	Filters []Filter        `json:"filters,omitempty"`
+
+	// Deprecated: raw SQL is rejected; use Filters
+	WhereClause string `json:"whereClause,omitempty"`
+}
+
+// Filter is a condition on one column whose value is bound as a query parameter.
+// Operators are =, !=, <, <=, >, >=, LIKE, NOT LIKE, ILIKE, IN and NOT IN, which
+// take a list, and IS NULL and IS NOT NULL, which take no value.
+type Filter struct {
+	Table    string      `json:"table,omitempty"` // qualifies the column in joins
+	Column   string      `json:"column"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// ColumnLineage traces an exported column back to its source table. Plain columns
+// name their SourceColumn; computed columns carry the Expression they were built from.
+type ColumnLineage struct {
+	Column       string `json:"column"`
+	SourceTable  string `json:"sourceTable"`
+	SourceColumn string `json:"sourceColumn,omitempty"`
+	Expression   string `json:"expression,omitempty"`
+}
+
+// ExportManifest is written next to an exported file to describe its contents
+type ExportManifest struct {
+	File      string          `json:"file"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Rows      int             `json:"rows"`
+	Query     string          `json:"query"`
+	QueryArgs []interface{}   `json:"queryArgs,omitempty"` // values bound to the query's ? parameters
+	Columns   []Column        `json:"columns"`
+	Lineage   []ColumnLineage `json:"lineage,omitempty"`
 }
 
 // ProgressUpdate represents a progress update during ingestion
 type ProgressUpdate struct {
-	Status    string `json:"status"`
-	Message   string `json:"message"`
-	Count     int    `json:"count"`
-	Completed bool   `json:"completed"`
+	JobID     string     `json:"jobId,omitempty"`
+	Status    string     `json:"status"`
+	Message   string     `json:"message"`
+	Count     int        `json:"count"`
+	Completed bool       `json:"completed"`
+	Warnings  []string   `json:"warnings,omitempty"`
+	Health    *JobHealth `json:"health,omitempty"`
+
+	// Bytes moved so far: on-disk for files, uncompressed values for ClickHouse
+	BytesRead    int64 `json:"bytesRead,omitempty"`
+	BytesWritten int64 `json:"bytesWritten,omitempty"`
+
+	// Size of the file being scanned, when known
+	BytesTotal int64 `json:"bytesTotal,omitempty"`
+
+	// The job's result, on its final update
+	Result *IngestionResult `json:"result,omitempty"`
+}
+
+// JobHealth reports source and target liveness in heartbeat updates
+type JobHealth struct {
+	SourceReachable      bool    `json:"sourceReachable"`
+	SourceError          string  `json:"sourceError,omitempty"`
+	TargetReachable      bool    `json:"targetReachable"`
+	TargetError          string  `json:"targetError,omitempty"`
+	LastProgressCount    int     `json:"lastProgressCount"`
+	SecondsSinceProgress float64 `json:"secondsSinceProgress"`
+}
+
+// BatchError describes an insert batch ClickHouse rejected. The batch was bisected
+// to find the offending rows; Code, Column and Value come from the first of them.
+type BatchError struct {
+	Batch        int    `json:"batch"` // 1-based
+	Rows         int    `json:"rows"`
+	RejectedRows int    `json:"rejectedRows"`
+	Code         int32  `json:"code,omitempty"` // ClickHouse exception code
+	Column       string `json:"column,omitempty"`
+	Value        string `json:"value,omitempty"`
+	Message      string `json:"message"`
 }
 
 // ToJSON converts ProgressUpdate to JSON string
@@ -79,5 +835,419 @@ func (p ProgressUpdate) ToJSON() string {
 
 // IngestionResult represents the result of an ingestion operation
 type IngestionResult struct {
-	TotalRecords int `json:"totalRecords"`
-}
\ No newline at end of file
+	TotalRecords     int      `json:"totalRecords"`
+	RejectedRecords  int      `json:"rejectedRecords"`
+	CoercedRecords   int      `json:"coercedRecords"`
+	CoercedValues    int      `json:"coercedValues"`
+	DuplicateRecords int      `json:"duplicateRecords"`
+
+	// RejectedRecords splits into rows skipped before reaching the target, because
+	// they could not be read or converted, and rows the target refused
+	SkippedRecords        int `json:"skippedRecords"`
+	TargetRejectedRecords int `json:"targetRejectedRecords"`
+
+	// The first bad rows of the job, whatever its error policy
+	ErrorSamples []RowError `json:"errorSamples,omitempty"`
+
+	// Rows each data-quality rule rejected
+	RuleViolations []RuleViolation `json:"ruleViolations,omitempty"`
+
+	// Outcome of the verification pass, if one was asked for
+	Verification *Verification `json:"verification,omitempty"`
+
+	BytesRead        int64    `json:"bytesRead"`
+	BytesWritten     int64    `json:"bytesWritten"`
+	Warnings         []string `json:"warnings,omitempty"`
+
+	// When the job ran, excluding any wait for quota, and its row throughput
+	StartedAt       time.Time `json:"startedAt"`
+	FinishedAt      time.Time `json:"finishedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	RowsPerSecond   float64   `json:"rowsPerSecond"`
+
+	// The table, file or table function written, and the query that read the rows
+	// from ClickHouse
+	Target string `json:"target,omitempty"`
+	Query  string `json:"query,omitempty"`
+
+	// Rejected flat file rows with their reasons, when a dead-letter directory is configured
+	DeadLetterFile string `json:"deadLetterFile,omitempty"`
+
+	// Insert batches ClickHouse rejected; their offending rows were skipped
+	BatchErrors []BatchError `json:"batchErrors,omitempty"`
+
+	// Bad rows dropped by a job with the collect error policy
+	RowErrors []RowError `json:"rowErrors,omitempty"`
+
+	// Differences found between the source and an existing target table
+	SchemaDiff []SchemaDifference `json:"schemaDiff,omitempty"`
+
+	// Largest value of the requested cursor column, in ClickHouse literal syntax
+	MaxCursor string `json:"maxCursor,omitempty"`
+
+	// Origin of each exported column and the manifest recording it, for join exports
+	Lineage      []ColumnLineage `json:"lineage,omitempty"`
+	ManifestFile string          `json:"manifestFile,omitempty"`
+
+	// Part files of a parallel export kept as a sharded output set
+	PartFiles []string `json:"partFiles,omitempty"`
+
+	// Objects written by ClickHouse for exports pushed down to a table function
+	Objects []ExportedObject `json:"objects,omitempty"`
+
+	// Columns found by a schema discovery job, and header names it made unique
+	Schema        []Column       `json:"schema,omitempty"`
+	HeaderRenames []HeaderRename `json:"headerRenames,omitempty"`
+
+	// How a schema discovery job inferred each column's type
+	Inference []ColumnInference `json:"inference,omitempty"`
+
+	// Fingerprint of the source file version read, for flat file sources
+	SchemaFingerprint string `json:"schemaFingerprint,omitempty"`
+
+	// Cost of the bytes the job moved, when unit costs are configured
+	Cost *CostEstimate `json:"cost,omitempty"`
+}
+
+// ColumnRule is a data-quality check on a source column: notNull, regex (String
+// values matching Pattern), range (numbers from Min to Max, either optional),
+// allowed (values in Values) or unique (no value repeated). Rules other than
+// notNull pass NULLs.
+type ColumnRule struct {
+	Column  string   `json:"column"`
+	Rule    string   `json:"rule"`
+	Pattern string   `json:"pattern,omitempty"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Values  []string `json:"values,omitempty"`
+}
+
+// RuleViolation counts the rows a data-quality rule rejected
+type RuleViolation struct {
+	Column string `json:"column"`
+	Rule   string `json:"rule"`
+	Rows   int    `json:"rows"`
+}
+
+// RowError is a row dropped because it could not be read, converted or inserted,
+// or broke a data-quality rule. Row numbers the data rows of a flat file source
+// from 1 and is 0 elsewhere.
+type RowError struct {
+	Row    int      `json:"row,omitempty"`
+	Reason string   `json:"reason"`
+	Values []string `json:"values,omitempty"`
+}
+
+// ExportedObject is an object ClickHouse wrote through a table function
+type ExportedObject struct {
+	Function string `json:"function"`
+	Location string `json:"location"`
+	Rows     int64  `json:"rows"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// IngestionValidation is the result of checking the columns a flat file load would
+// insert against the target table, or against the table it would create
+type IngestionValidation struct {
+	Table             string             `json:"table"`
+	TableExists       bool               `json:"tableExists"`
+	SchemaPolicy      string             `json:"schemaPolicy"`
+	Columns           []Column           `json:"columns"`
+	Differences       []SchemaDifference `json:"differences,omitempty"`
+	Incompatibilities []string           `json:"incompatibilities,omitempty"`
+	Compatible        bool               `json:"compatible"`
+
+	// Statements creating the table, when it does not exist yet; a distributed
+	// table is created after its local tables
+	CreateTable       string   `json:"createTable,omitempty"`
+	AlterTable        []string `json:"alterTable,omitempty"`
+	CreateDistributed string   `json:"createDistributed,omitempty"`
+}
+
+// SchemaDifference is a column on which the source and an existing target table disagree.
+// Kind is "missing" (not in the table), "type" (incompatible type) or "extra" (only in the table).
+type SchemaDifference struct {
+	Column     string `json:"column"`
+	Kind       string `json:"kind"`
+	SourceType string `json:"sourceType,omitempty"`
+	TargetType string `json:"targetType,omitempty"`
+}
+
+// JobKindSchemaDiscovery marks jobs that discover a flat file's schema
+const JobKindSchemaDiscovery = "schema_discovery"
+
+// Job represents a tracked ingestion job, or a long task such as schema discovery
+type Job struct {
+	ID             string          `json:"id"`
+	Kind           string          `json:"kind,omitempty"` // empty for ingestions
+	Status         string          `json:"status"`
+	Params         IngestionParams `json:"params"`
+	Result         IngestionResult `json:"result"`
+	Error          string          `json:"error,omitempty"`
+	StartedAt      time.Time       `json:"startedAt"`
+	FinishedAt     *time.Time      `json:"finishedAt,omitempty"`
+	LastProgressAt time.Time       `json:"lastProgressAt"`
+	LastCount      int             `json:"lastCount"`
+	Progress       *ProgressUpdate `json:"progress,omitempty"` // latest update of a background job
+	Stalled        bool            `json:"stalled"`
+	Diagnostics    *JobDiagnostics `json:"diagnostics,omitempty"`
+
+	// Jobs over a quota wait in the "queued" status until the quota window resets
+	QueuedUntil *time.Time `json:"queuedUntil,omitempty"`
+}
+
+// JobDiagnostics captures the state of a job flagged as stalled
+type JobDiagnostics struct {
+	DetectedAt     time.Time                `json:"detectedAt"`
+	StalledFor     string                   `json:"stalledFor"`
+	Goroutines     string                   `json:"goroutines,omitempty"`
+	RunningQueries []map[string]interface{} `json:"runningQueries,omitempty"`
+	QueryError     string                   `json:"queryError,omitempty"`
+	Action         string                   `json:"action"`
+}
+
+// JobSummary is a compact pass/fail verdict for a job, intended for CI pipelines
+type JobSummary struct {
+	JobID       string   `json:"jobId"`
+	Verdict     string   `json:"verdict"`
+	Status      string   `json:"status"`
+	Rows        int      `json:"rows"`
+	Rejects     int      `json:"rejects"`
+	RejectRatio float64  `json:"rejectRatio"`
+	DurationMs  int64    `json:"durationMs"`
+	Reasons     []string `json:"reasons,omitempty"`
+}
+
+// ReingestRequest optionally names a corrected copy of a job's dead-letter file
+type ReingestRequest struct {
+	FilePath string `json:"filePath,omitempty"`
+}
+
+// Schedule is a recurring ingestion triggered by a cron expression
+type Schedule struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Cron      string          `json:"cron"`
+	Params    IngestionParams `json:"params"`
+	Enabled   bool            `json:"enabled"`
+	NextRun   *time.Time      `json:"nextRun,omitempty"`
+	LastRun   *time.Time      `json:"lastRun,omitempty"`
+	LastJobID string          `json:"lastJobId,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Transient bool            `json:"-"`
+	Managed   bool            `json:"managed,omitempty"` // owned by declarative apply
+
+	// Firings skipped because the previous run had not finished
+	SkippedRuns int        `json:"skippedRuns,omitempty"`
+	LastSkipped *time.Time `json:"lastSkipped,omitempty"`
+
+	// LastPreflight is the most recent warm-up validation of the next run
+	LastPreflight *PreflightResult `json:"lastPreflight,omitempty"`
+}
+
+// PreflightResult is the outcome of validating a scheduled run ahead of its fire time
+type PreflightResult struct {
+	FireAt    time.Time `json:"fireAt"`
+	CheckedAt time.Time `json:"checkedAt"`
+	OK        bool      `json:"ok"`
+	Problems  []string  `json:"problems,omitempty"`
+}
+
+// ScheduleRunStats are the data quality figures of one run of a schedule.
+// Rates are fractions of all rows read, rejected rows included.
+type ScheduleRunStats struct {
+	JobID        string    `json:"jobId"`
+	Status       string    `json:"status"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	Rows         int       `json:"rows"`
+	Rejected     int       `json:"rejected"`
+	Coerced      int       `json:"coerced"`
+	RejectRate   float64   `json:"rejectRate"`
+	CoercionRate float64   `json:"coercionRate"`
+}
+
+// ScheduleStats aggregates data quality across the runs of a schedule.
+// Window rates cover the most recent runs and drive the alerts.
+type ScheduleStats struct {
+	ScheduleID         string             `json:"scheduleId"`
+	ScheduleName       string             `json:"scheduleName,omitempty"`
+	Runs               int                `json:"runs"`
+	TotalRows          int                `json:"totalRows"`
+	TotalRejected      int                `json:"totalRejected"`
+	TotalCoerced       int                `json:"totalCoerced"`
+	WindowRejectRate   float64            `json:"windowRejectRate"`
+	WindowCoercionRate float64            `json:"windowCoercionRate"`
+	Alerts             []string           `json:"alerts,omitempty"`
+	History            []ScheduleRunStats `json:"history"`
+}
+
+// ScheduleRequest contains parameters for creating a schedule
+type ScheduleRequest struct {
+	Name    string          `json:"name"`
+	Cron    string          `json:"cron"`
+	Params  IngestionParams `json:"params"`
+	Enabled *bool           `json:"enabled,omitempty"`
+
+	// Transient schedules are owned by another component and never persisted
+	Transient bool `json:"-"`
+
+	// Managed schedules are owned by declarative apply
+	Managed bool `json:"-"`
+}
+
+// SavedTemplate is a named, connection-less ingestion saved for re-running by hand.
+// Params hold the columns, filters and query but no session or credentials; a run
+// uses the caller's session.
+type SavedTemplate struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Params      IngestionParams `json:"params"`
+	CreatedBy   string          `json:"createdBy,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// SavedTemplateRequest contains parameters for saving a template
+type SavedTemplateRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Params      IngestionParams `json:"params"`
+}
+
+// Pipeline is a named ingestion declared in the pipelines file.
+// Columns and per-job options travel in Params exactly as in an ingest request.
+// Multi-step pipelines declare Steps instead of Params.
+type Pipeline struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schedule    string          `json:"schedule,omitempty"`
+	Template    string          `json:"template,omitempty"`
+	Params      IngestionParams `json:"params"`
+	Steps       []PipelineStep  `json:"steps,omitempty"`
+	ScheduleID  string          `json:"scheduleId,omitempty"`
+}
+
+// PipelineStep is one ingestion of a multi-step pipeline. String fields of its Params
+// may use template variables of earlier steps, e.g. {{ .steps.load.maxCursor }}.
+type PipelineStep struct {
+	Name     string          `json:"name"`
+	Template string          `json:"template,omitempty"`
+	Params   IngestionParams `json:"params"`
+}
+
+// PipelineRun tracks one execution of a pipeline
+type PipelineRun struct {
+	ID         string            `json:"id"`
+	Pipeline   string            `json:"pipeline"`
+	Status     string            `json:"status"` // running, success, error
+	Steps      []PipelineStepRun `json:"steps"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+}
+
+// PipelineStepRun is the job and outputs of one step of a pipeline run
+type PipelineStepRun struct {
+	Name    string                 `json:"name"`
+	JobID   string                 `json:"jobId,omitempty"`
+	Status  string                 `json:"status"` // pending, running, success, error, cancelled, skipped
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// Quota caps the rows and bytes moved per UTC day by the jobs of a user, or by the
+// jobs carrying every one of Labels. Zero limits are not enforced.
+type Quota struct {
+	User        string            `json:"user,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	RowsPerDay  int64             `json:"rowsPerDay,omitempty"`
+	BytesPerDay int64             `json:"bytesPerDay,omitempty"`
+}
+
+// QuotaFile is the top-level layout of the quotas file
+type QuotaFile struct {
+	Quotas []Quota `json:"quotas"`
+}
+
+// QuotaUsage is the consumption of a quota in the current window
+type QuotaUsage struct {
+	Quota       Quota     `json:"quota"`
+	Rows        int64     `json:"rows"`
+	Bytes       int64     `json:"bytes"`
+	WindowStart time.Time `json:"windowStart"`
+	ResetAt     time.Time `json:"resetAt"`
+	Exceeded    bool      `json:"exceeded"`
+}
+
+// PipelineFile is the top-level layout of the pipelines file
+type PipelineFile struct {
+	Pipelines []Pipeline `json:"pipelines"`
+}
+
+// ParamsTemplate is a named set of ingestion params that pipelines and schedules
+// of an apply bundle extend; their own non-empty params override the template's
+type ParamsTemplate struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Params      IngestionParams `json:"params"`
+}
+
+// ScheduleSpec declares a schedule in an apply bundle; schedules are matched by name
+type ScheduleSpec struct {
+	Name     string          `json:"name"`
+	Cron     string          `json:"cron"`
+	Enabled  *bool           `json:"enabled,omitempty"`
+	Template string          `json:"template,omitempty"`
+	Params   IngestionParams `json:"params"`
+}
+
+// ApplyBundle is a declarative set of templates, pipelines and schedules
+type ApplyBundle struct {
+	Templates []ParamsTemplate `json:"templates,omitempty"`
+	Pipelines []Pipeline       `json:"pipelines,omitempty"`
+	Schedules []ScheduleSpec   `json:"schedules,omitempty"`
+}
+
+// ApplyChange is the planned or applied change of one object
+type ApplyChange struct {
+	Kind   string        `json:"kind"` // template, pipeline, schedule
+	Name   string        `json:"name"`
+	Action string        `json:"action"` // create, update, delete, unchanged
+	Diff   []FieldChange `json:"diff,omitempty"`
+}
+
+// FieldChange is one differing field of an updated object, by dotted JSON path
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ApplyResult lists the changes of an apply, or those a plan would make
+type ApplyResult struct {
+	DryRun  bool          `json:"dryRun,omitempty"`
+	Summary ApplySummary  `json:"summary"`
+	Changes []ApplyChange `json:"changes"`
+}
+
+// ApplySummary counts the changes of an apply by action
+type ApplySummary struct {
+	Create    int `json:"create"`
+	Update    int `json:"update"`
+	Delete    int `json:"delete"`
+	Unchanged int `json:"unchanged"`
+}
+
+// DoctorCheck is the outcome of one self-check of the server configuration
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok, warn, fail, skip
+	Message string `json:"message,omitempty"`
+}
+
+// DoctorReport is the readiness report of ingestor doctor; the server is not ready
+// when any check failed
+type DoctorReport struct {
+	Ready     bool          `json:"ready"`
+	Checks    []DoctorCheck `json:"checks"`
+	CheckedAt time.Time     `json:"checkedAt"`
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -15,25 +16,83 @@ import (
 
 // SetupRouter configures the router
 func SetupRouter(cfg *config.Config, logger *logrus.Logger) *gin.Engine {
+	// Open the state store used to survive restarts
+	stateStore, err := service.NewStateStore(cfg.StateDir, cfg.StateEncryptionKey)
+	if err != nil {
+		logger.WithError(err).Error("Failed to open state store, persistence disabled")
+	}
+
 	// Create services
-	clickhouseService := service.NewClickHouseService(cfg, logger)
 	flatFileService := service.NewFlatFileService(cfg, logger)
-	ingestService := service.NewIngestService(clickhouseService, flatFileService, cfg, logger)
+	ingestService := service.NewIngestService(flatFileService, cfg, logger)
+	jobService := service.NewJobService(stateStore, cfg, logger)
+	notificationService := service.NewNotificationService(cfg, logger)
+	if !cfg.FeatureEnabled(config.FeatureNotifications) {
+		notificationService = service.NewDisabledNotificationService()
+	}
+	jobService.OnComplete(notificationService.NotifyJob)
+	sessionService := service.NewSessionService(stateStore, cfg, logger)
+	quotaService := service.NewQuotaService(jobService, cfg, logger)
+	if cfg.QuotasFile != "" {
+		if err := quotaService.Load(cfg.QuotasFile); err != nil {
+			logger.WithError(err).Error("Failed to load quotas")
+		}
+	}
+	jobService.OnComplete(quotaService.RecordJob)
+	jobRunner := service.NewJobRunner(ingestService, jobService, sessionService, quotaService, cfg, logger)
+	statsService := service.NewStatsService(notificationService, cfg, logger)
+	jobService.OnComplete(statsService.RecordJob)
+	schedulerService := service.NewSchedulerService(jobRunner, notificationService, statsService, stateStore, cfg, logger)
+	restoreState(sessionService, jobService, schedulerService, jobRunner, cfg, logger)
+	sessionService.Start()
+	// Disabled features keep their services so nothing is nil, but never start them
+	if cfg.FeatureEnabled(config.FeatureScheduler) {
+		schedulerService.Start()
+	}
+	pipelineService := service.NewPipelineService(jobRunner, schedulerService, cfg, logger)
+	if cfg.PipelinesFile != "" && cfg.FeatureEnabled(config.FeaturePipelines) {
+		if err := pipelineService.Load(cfg.PipelinesFile); err != nil {
+			logger.WithError(err).Error("Failed to load pipelines")
+		}
+	}
+	applyService := service.NewApplyService(pipelineService, schedulerService, stateStore, cfg, logger)
+	if cfg.FeatureEnabled(config.FeatureApply) {
+		if err := applyService.Restore(); err != nil {
+			logger.WithError(err).Error("Failed to restore applied configuration")
+		}
+	}
+	templateService := service.NewSavedTemplateService(stateStore, cfg, logger)
+	if cfg.FeatureEnabled(config.FeatureTemplates) {
+		if err := templateService.Restore(); err != nil {
+			logger.WithError(err).Error("Failed to restore saved templates")
+		}
+	}
+	watchdogService := service.NewWatchdogService(jobService, sessionService, cfg, logger)
+	watchdogService.Start()
 
 	// Create handlers
-	ingestHandler := handler.NewIngestHandler(clickhouseService, flatFileService, ingestService, cfg, logger)
-	joinHandler := handler.NewJoinHandler(clickhouseService, cfg, logger)
+	ingestHandler := handler.NewIngestHandler(flatFileService, ingestService, jobService, jobRunner, sessionService, quotaService, cfg, logger)
+	joinHandler := handler.NewJoinHandler(sessionService, cfg, logger)
+	jobHandler := handler.NewJobHandler(jobService, jobRunner, cfg, logger)
+	sdkHandler := handler.NewSDKHandler(cfg, logger)
+	scheduleHandler := handler.NewScheduleHandler(schedulerService, cfg, logger)
+	pipelineHandler := handler.NewPipelineHandler(pipelineService, cfg, logger)
+	statsHandler := handler.NewStatsHandler(statsService, quotaService, cfg, logger)
+	applyHandler := handler.NewApplyHandler(applyService, cfg, logger)
+	doctorHandler := handler.NewDoctorHandler(middleware.DoctorChecks(cfg), cfg, logger)
+	templateHandler := handler.NewTemplateHandler(templateService, jobRunner, sessionService, quotaService, cfg, logger)
 
 	// Create router
 	r := gin.New()
 	r.Use(gin.Recovery())
+	r.Use(middleware.RequestID())
 	r.Use(middleware.Logger(logger))
 	r.Use(middleware.ErrorHandler())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{cfg.AllowedOrigin},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.APIKeyHeader, middleware.RequestIDHeader, handler.SessionHeader},
+		ExposeHeaders:    []string{"Content-Length", "X-Job-ID", middleware.RequestIDHeader, handler.SessionHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -45,29 +104,156 @@ func SetupRouter(cfg *config.Config, logger *logrus.Logger) *gin.Engine {
 		})
 	})
 
+	// Prometheus metrics
+	r.GET("/metrics", statsHandler.GetMetrics)
+
+	// Optional features answer 501 when turned off
+	schedulerFeature := middleware.RequireFeature(cfg, config.FeatureScheduler)
+	templatesFeature := middleware.RequireFeature(cfg, config.FeatureTemplates)
+	pipelinesFeature := middleware.RequireFeature(cfg, config.FeaturePipelines)
+	applyFeature := middleware.RequireFeature(cfg, config.FeatureApply)
+	sdkFeature := middleware.RequireFeature(cfg, config.FeatureSDK)
+
 	// API v1
 	v1 := r.Group("/api/v1")
+	if cfg.JWKSURL != "" || cfg.APIKeys != "" || cfg.APIKeysFile != "" {
+		v1.Use(middleware.Authenticate(cfg, logger))
+	}
 	{
 		// ClickHouse endpoints
 		v1.POST("/clickhouse/connect", ingestHandler.ConnectToClickHouse)
+		v1.POST("/clickhouse/connect/test", ingestHandler.TestClickHouseConnection)
+		v1.POST("/clickhouse/disconnect", ingestHandler.DisconnectClickHouse)
+		v1.POST("/clickhouse/token", ingestHandler.RefreshClickHouseToken)
 		v1.GET("/clickhouse/tables/:tableName/columns", ingestHandler.GetTableColumns)
+		v1.GET("/clickhouse/tables/:tableName/ddl", ingestHandler.GetTableDDL)
 
 		// Flat file endpoints
 		v1.POST("/flatfile/schema", ingestHandler.DiscoverFlatFileSchema)
+		v1.PATCH("/flatfile/schema", ingestHandler.OverrideFlatFileSchema)
+		v1.POST("/flatfile/schema/jobs", jobHandler.StartSchemaDiscovery)
 
 		// Preview data
 		v1.POST("/preview", ingestHandler.PreviewData)
 
+		// Row counts
+		v1.POST("/count", ingestHandler.CountRows)
+
+		// Column statistics
+		v1.POST("/profile", ingestHandler.ProfileData)
+
 		// Join preview
 		v1.POST("/join/preview", joinHandler.BuildJoinPreview)
 
 		// Ingestion
 		v1.POST("/ingest", ingestHandler.StartIngestion)
+		v1.POST("/ingest/estimate", ingestHandler.EstimateCost)
+		v1.POST("/ingest/explain", ingestHandler.ExplainQuery)
+		v1.POST("/ingest/validate", ingestHandler.ValidateIngestion)
+		v1.POST("/query/validate", ingestHandler.ValidateQuery)
+
+		// Jobs
+		v1.GET("/jobs", jobHandler.ListJobs)
+		v1.GET("/jobs/:id", jobHandler.GetJob)
+		v1.GET("/jobs/:id/summary", jobHandler.GetJobSummary)
+		v1.GET("/jobs/:id/events", jobHandler.StreamJob)
+		v1.POST("/jobs/:id/cancel", jobHandler.CancelJob)
+		v1.POST("/jobs/:id/reingest", jobHandler.ReingestDeadLetter)
+
+		// Schedules
+		v1.POST("/schedules", schedulerFeature, scheduleHandler.CreateSchedule)
+		v1.GET("/schedules", schedulerFeature, scheduleHandler.ListSchedules)
+		v1.GET("/schedules/:id", schedulerFeature, scheduleHandler.GetSchedule)
+		v1.DELETE("/schedules/:id", schedulerFeature, scheduleHandler.DeleteSchedule)
+		v1.POST("/schedules/:id/enable", schedulerFeature, scheduleHandler.EnableSchedule)
+		v1.POST("/schedules/:id/disable", schedulerFeature, scheduleHandler.DisableSchedule)
+		v1.POST("/schedules/:id/preflight", schedulerFeature, scheduleHandler.PreflightSchedule)
+
+		// Saved templates
+		v1.POST("/templates", templatesFeature, templateHandler.CreateTemplate)
+		v1.GET("/templates", templatesFeature, templateHandler.ListTemplates)
+		v1.GET("/templates/:name", templatesFeature, templateHandler.GetTemplate)
+		v1.PUT("/templates/:name", templatesFeature, templateHandler.UpdateTemplate)
+		v1.DELETE("/templates/:name", templatesFeature, templateHandler.DeleteTemplate)
+		v1.POST("/templates/:name/run", templatesFeature, templateHandler.RunTemplate)
+
+		// Data quality stats
+		v1.GET("/stats/schedules", schedulerFeature, statsHandler.ListScheduleStats)
+		v1.GET("/stats/schedules/:id", schedulerFeature, statsHandler.GetScheduleStats)
+		v1.GET("/stats/quotas", statsHandler.ListQuotaUsage)
+
+		// Declared pipelines
+		v1.GET("/pipelines", pipelinesFeature, pipelineHandler.ListPipelines)
+		v1.GET("/pipelines/:name", pipelinesFeature, pipelineHandler.GetPipeline)
+		v1.POST("/pipelines/:name/run", pipelinesFeature, pipelineHandler.RunPipeline)
+		v1.GET("/pipelines/:name/runs/:id", pipelinesFeature, pipelineHandler.GetPipelineRun)
+
+		// Declarative configuration
+		v1.POST("/apply", applyFeature, applyHandler.Apply)
+		v1.POST("/plan", applyFeature, applyHandler.Plan)
+		v1.GET("/export", applyFeature, applyHandler.Export)
+
+		// Configuration self-check
+		v1.GET("/doctor", doctorHandler.GetDoctorReport)
+
+		// Generated clients
+		v1.GET("/sdk/typescript/types.ts", sdkFeature, sdkHandler.GetTypeScriptTypes)
+		v1.GET("/sdk/typescript/client.ts", sdkFeature, sdkHandler.GetTypeScriptClient)
 	}
 
 	return r
 }
 
+// restoreState re-establishes the ClickHouse sessions and reloads jobs and schedules
+// persisted before a restart
+func restoreState(
+	sessionService service.SessionService,
+	jobService service.JobService,
+	schedulerService service.SchedulerService,
+	jobRunner *service.JobRunner,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ClickHouseDialTimeout)
+	defer cancel()
+	if err := sessionService.Restore(ctx); err != nil {
+		// The keep-alive loop keeps retrying in the background
+		logger.WithError(err).Warn("Failed to restore ClickHouse sessions")
+	}
+
+	if cfg.FeatureEnabled(config.FeatureScheduler) {
+		if err := schedulerService.Restore(); err != nil {
+			logger.WithError(err).Error("Failed to restore schedules")
+		}
+	}
+
+	interrupted, err := jobService.Restore()
+	if err != nil {
+		logger.WithError(err).Error("Failed to restore jobs")
+		return
+	}
+	for _, job := range interrupted {
+		if job.Kind != "" {
+			// Only ingestions are resumed; other tasks are simply re-requested
+			continue
+		}
+		if !cfg.ResumeInterruptedJobs {
+			logger.WithField("jobId", job.ID).Warn("Job interrupted by restart")
+			continue
+		}
+		if job.Params.TargetConnection != nil && cfg.StateEncryptionKey == "" {
+			// Unencrypted state keeps no credentials, so the target would refuse the job
+			logger.WithField("jobId", job.ID).Warn("Job interrupted by restart not resumed: its target connection credentials were not persisted; set STATE_ENCRYPTION_KEY to resume such jobs")
+			continue
+		}
+		resumed, _ := jobRunner.Start(job.Params, logrus.Fields{"resumedFrom": job.ID})
+		logger.WithFields(logrus.Fields{
+			"jobId":       resumed.ID,
+			"resumedFrom": job.ID,
+		}).Info("Resubmitted interrupted job")
+	}
+}
+
 // SetupServer configures the HTTP server
 func SetupServer(r *gin.Engine, cfg *config.Config) *http.Server {
 	return &http.Server{
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Policies for exporting AggregateFunction columns
+const (
+	AggregatePolicyError    = "error"
+	AggregatePolicySkip     = "skip"
+	AggregatePolicyFinalize = "finalize"
+)
+
+// aggregatePlan is the SELECT list for exporting a table that may hold aggregate states
+type aggregatePlan struct {
+	columns    []model.Column // columns remaining in the export
+	selectList []string
+	groupBy    []string
+	skipped    []string
+}
+
+// planAggregateExport decides how AggregateFunction and SimpleAggregateFunction columns are exported.
+// Aggregate states cannot be scanned, so they are rejected, skipped, or merged with -Merge
+// combinators grouped by the remaining columns.
+func planAggregateExport(columns []model.Column, policy string) (aggregatePlan, error) {
+	switch policy {
+	case "", AggregatePolicyError, AggregatePolicySkip, AggregatePolicyFinalize:
+	default:
+		return aggregatePlan{}, fmt.Errorf("unsupported aggregate column policy %q: must be error, skip or finalize", policy)
+	}
+
+	var states, simple []string
+	for _, col := range columns {
+		switch aggregateKind(col.Type) {
+		case "AggregateFunction":
+			states = append(states, col.Name)
+		case "SimpleAggregateFunction":
+			simple = append(simple, col.Name)
+		}
+	}
+
+	// Column names are quoted in the SELECT list
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	quoted, err := quoteIdentifiers(names)
+	if err != nil {
+		return aggregatePlan{}, err
+	}
+
+	plan := aggregatePlan{}
+	switch {
+	case len(states) > 0 && (policy == "" || policy == AggregatePolicyError):
+		return aggregatePlan{}, fmt.Errorf(
+			"columns %s hold AggregateFunction states that cannot be exported; set aggregatePolicy to skip or finalize",
+			strings.Join(states, ", "),
+		)
+	case len(states) > 0 && policy == AggregatePolicySkip:
+		for i, col := range columns {
+			if aggregateKind(col.Type) == "AggregateFunction" {
+				plan.skipped = append(plan.skipped, col.Name)
+				continue
+			}
+			plan.columns = append(plan.columns, col)
+			plan.selectList = append(plan.selectList, quoted[i])
+		}
+		return plan, nil
+	case policy == AggregatePolicyFinalize && len(states)+len(simple) > 0:
+		plan.columns = columns
+		for i, col := range columns {
+			expr, err := mergeExpression(col, quoted[i])
+			if err != nil {
+				return aggregatePlan{}, err
+			}
+			if expr == "" {
+				plan.selectList = append(plan.selectList, quoted[i])
+				plan.groupBy = append(plan.groupBy, quoted[i])
+				continue
+			}
+			plan.selectList = append(plan.selectList, expr+" AS "+quoted[i])
+		}
+		return plan, nil
+	default:
+		plan.columns = columns
+		plan.selectList = quoted
+		return plan, nil
+	}
+}
+
+// aggregateKind returns "AggregateFunction", "SimpleAggregateFunction" or "" for a column type
+func aggregateKind(chType string) string {
+	t := baseType(chType)
+	switch {
+	case strings.HasPrefix(t, "AggregateFunction("):
+		return "AggregateFunction"
+	case strings.HasPrefix(t, "SimpleAggregateFunction("):
+		return "SimpleAggregateFunction"
+	default:
+		return ""
+	}
+}
+
+// mergeExpression returns the expression that finalizes an aggregate column, or "" for plain columns.
+// AggregateFunction(quantiles(0.5), Float64) becomes quantilesMerge(0.5)(col);
+// SimpleAggregateFunction(max, UInt64) becomes max(col). The column is referenced by its quoted name.
+func mergeExpression(col model.Column, quotedName string) (string, error) {
+	kind := aggregateKind(col.Type)
+	if kind == "" {
+		return "", nil
+	}
+
+	t := baseType(col.Type)
+	args := t[len(kind)+1 : len(t)-1]
+	function := strings.TrimSpace(firstTypeArgument(args))
+	if function == "" {
+		return "", fmt.Errorf("cannot parse aggregate function of column %s: %s", col.Name, col.Type)
+	}
+
+	if kind == "SimpleAggregateFunction" {
+		return fmt.Sprintf("%s(%s)", function, quotedName), nil
+	}
+
+	// Parametric functions keep their parameters after the combinator
+	name, params := function, ""
+	if i := strings.Index(function, "("); i >= 0 {
+		name, params = function[:i], function[i:]
+	}
+	return fmt.Sprintf("%sMerge%s(%s)", name, params, quotedName), nil
+}
+
+// firstTypeArgument returns the first top-level comma-separated argument of a type's argument list
+func firstTypeArgument(args string) string {
+	depth := 0
+	for i, r := range args {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				return args[:i]
+			}
+		}
+	}
+	return args
+}
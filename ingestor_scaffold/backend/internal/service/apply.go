@@ -0,0 +1,653 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Kinds of objects managed by declarative apply
+const (
+	KindTemplate = "template"
+	KindPipeline = "pipeline"
+	KindSchedule = "schedule"
+)
+
+// Apply actions
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionUnchanged = "unchanged"
+)
+
+// redactedValue replaces credentials in apply diffs
+const redactedValue = "[redacted]"
+
+// ApplyService reconciles declared templates, pipelines and schedules with the server
+type ApplyService interface {
+	Apply(bundle model.ApplyBundle, prune bool) (model.ApplyResult, error)
+	Plan(bundle model.ApplyBundle, prune bool) (model.ApplyResult, error)
+	Export() model.ApplyBundle
+	Restore() error
+}
+
+// ApplyServiceImpl implements ApplyService. Declared objects are kept as written,
+// template references included, so exports round-trip to the same YAML.
+type ApplyServiceImpl struct {
+	mu               sync.Mutex
+	templates        map[string]model.ParamsTemplate
+	pipelines        map[string]model.Pipeline
+	schedules        map[string]model.ScheduleSpec
+	pipelineService  PipelineService
+	schedulerService SchedulerService
+	store            *StateStore
+	config           *config.Config
+	logger           *logrus.Logger
+}
+
+// appliedState is the persisted form of everything applied
+type appliedState struct {
+	Templates []model.ParamsTemplate `json:"templates"`
+	Pipelines []model.Pipeline       `json:"pipelines"`
+	Schedules []model.ScheduleSpec   `json:"schedules"`
+}
+
+// NewApplyService creates a new apply service
+func NewApplyService(
+	pipelineService PipelineService,
+	schedulerService SchedulerService,
+	store *StateStore,
+	config *config.Config,
+	logger *logrus.Logger,
+) ApplyService {
+	return &ApplyServiceImpl{
+		templates:        make(map[string]model.ParamsTemplate),
+		pipelines:        make(map[string]model.Pipeline),
+		schedules:        make(map[string]model.ScheduleSpec),
+		pipelineService:  pipelineService,
+		schedulerService: schedulerService,
+		store:            store,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// applyPlan is a validated bundle with templates expanded, and the changes it makes
+type applyPlan struct {
+	changes   []model.ApplyChange
+	templates map[string]model.ParamsTemplate
+	pipelines map[string]model.Pipeline // expanded
+	schedules map[string]model.ScheduleRequest
+	existing  map[string]model.Schedule // by name
+	bundle    model.ApplyBundle
+}
+
+// Apply validates the whole bundle, then creates or updates every object in it.
+// With prune, managed objects missing from the bundle are deleted. Nothing is
+// changed when validation fails.
+func (s *ApplyServiceImpl) Apply(bundle model.ApplyBundle, prune bool) (model.ApplyResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, err := s.planLocked(bundle, prune)
+	if err != nil {
+		return model.ApplyResult{}, err
+	}
+
+	result := applyResult(plan.changes)
+	for i, change := range plan.changes {
+		if change.Action == ActionUnchanged {
+			continue
+		}
+		if err := s.executeLocked(plan, change); err != nil {
+			s.persistLocked()
+			result = applyResult(plan.changes[:i])
+			return result, fmt.Errorf("failed to %s %s %q: %w", change.Action, change.Kind, change.Name, err)
+		}
+	}
+	s.persistLocked()
+
+	s.logger.WithField("changes", countChanges(plan.changes)).Info("Applied declarative configuration")
+	return result, nil
+}
+
+// Plan reports the changes Apply would make for a bundle without making any
+func (s *ApplyServiceImpl) Plan(bundle model.ApplyBundle, prune bool) (model.ApplyResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, err := s.planLocked(bundle, prune)
+	if err != nil {
+		return model.ApplyResult{}, err
+	}
+
+	result := applyResult(plan.changes)
+	result.DryRun = true
+	return result, nil
+}
+
+// planLocked validates a bundle and computes its changes against the current state
+func (s *ApplyServiceImpl) planLocked(bundle model.ApplyBundle, prune bool) (*applyPlan, error) {
+	plan := &applyPlan{
+		templates: make(map[string]model.ParamsTemplate),
+		pipelines: make(map[string]model.Pipeline),
+		schedules: make(map[string]model.ScheduleRequest),
+		existing:  make(map[string]model.Schedule),
+		bundle:    bundle,
+	}
+
+	// Templates in the bundle, plus applied ones it keeps
+	for _, tmpl := range bundle.Templates {
+		if tmpl.Name == "" {
+			return nil, fmt.Errorf("template name is required")
+		}
+		if _, dup := plan.templates[tmpl.Name]; dup {
+			return nil, fmt.Errorf("duplicate template name %q", tmpl.Name)
+		}
+		plan.templates[tmpl.Name] = tmpl
+	}
+	available := make(map[string]model.ParamsTemplate, len(plan.templates))
+	for name, tmpl := range plan.templates {
+		available[name] = tmpl
+	}
+	if !prune {
+		for name, tmpl := range s.templates {
+			if _, ok := available[name]; !ok {
+				available[name] = tmpl
+			}
+		}
+	}
+
+	// Pipelines, expanded and validated
+	for _, pipeline := range bundle.Pipelines {
+		if _, dup := plan.pipelines[pipeline.Name]; dup {
+			return nil, fmt.Errorf("duplicate pipeline name %q", pipeline.Name)
+		}
+		if _, managed := s.pipelines[pipeline.Name]; !managed {
+			if _, err := s.pipelineService.GetPipeline(pipeline.Name); err == nil {
+				return nil, fmt.Errorf("pipeline %q is declared in the pipelines file and cannot be applied", pipeline.Name)
+			}
+		}
+		expanded, err := expandPipeline(pipeline, available)
+		if err != nil {
+			return nil, err
+		}
+		if err := validatePipeline(expanded); err != nil {
+			return nil, err
+		}
+		plan.pipelines[pipeline.Name] = expanded
+	}
+
+	// Schedules, expanded and validated, matched to existing ones by name
+	for _, schedule := range s.schedulerService.ListSchedules() {
+		if schedule.Transient {
+			continue
+		}
+		if _, dup := plan.existing[schedule.Name]; dup {
+			return nil, fmt.Errorf("several schedules are named %q; rename them before applying", schedule.Name)
+		}
+		plan.existing[schedule.Name] = schedule
+	}
+	for _, spec := range bundle.Schedules {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("schedule name is required")
+		}
+		if _, dup := plan.schedules[spec.Name]; dup {
+			return nil, fmt.Errorf("duplicate schedule name %q", spec.Name)
+		}
+		params, err := expandParams(spec.Template, spec.Params, available)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec.Name, err)
+		}
+		if _, err := cron.ParseStandard(spec.Cron); err != nil {
+			return nil, fmt.Errorf("schedule %q: invalid cron expression: %w", spec.Name, err)
+		}
+		if params.SourceType == "" || params.TargetType == "" {
+			return nil, fmt.Errorf("schedule %q: source and target types are required", spec.Name)
+		}
+		plan.schedules[spec.Name] = model.ScheduleRequest{
+			Name:    spec.Name,
+			Cron:    spec.Cron,
+			Enabled: spec.Enabled,
+			Params:  params,
+			Managed: true,
+		}
+	}
+
+	// Templates
+	for _, name := range sortedKeys(plan.templates) {
+		current, ok := s.templates[name]
+		plan.changes = append(plan.changes, objectChange(KindTemplate, name, current, plan.templates[name], ok))
+	}
+	if prune {
+		for _, name := range sortedKeys(s.templates) {
+			if _, keep := plan.templates[name]; !keep {
+				plan.changes = append(plan.changes, model.ApplyChange{Kind: KindTemplate, Name: name, Action: ActionDelete})
+			}
+		}
+	}
+
+	// Pipelines, compared in their expanded form so template edits show up
+	for _, name := range sortedKeys(plan.pipelines) {
+		current, err := s.pipelineService.GetPipeline(name)
+		current.ScheduleID = ""
+		plan.changes = append(plan.changes, objectChange(KindPipeline, name, current, plan.pipelines[name], err == nil))
+	}
+	if prune {
+		for _, name := range sortedKeys(s.pipelines) {
+			if _, keep := plan.pipelines[name]; !keep {
+				plan.changes = append(plan.changes, model.ApplyChange{Kind: KindPipeline, Name: name, Action: ActionDelete})
+			}
+		}
+	}
+
+	// Schedules
+	for _, name := range sortedKeys(plan.schedules) {
+		current, ok := plan.existing[name]
+		plan.changes = append(plan.changes, objectChange(KindSchedule, name, scheduleState(current), scheduleRequestState(plan.schedules[name]), ok))
+	}
+	if prune {
+		names := make([]string, 0, len(plan.existing))
+		for name, schedule := range plan.existing {
+			if _, keep := plan.schedules[name]; !keep && schedule.Managed {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			plan.changes = append(plan.changes, model.ApplyChange{Kind: KindSchedule, Name: name, Action: ActionDelete})
+		}
+	}
+
+	return plan, nil
+}
+
+// executeLocked carries out one planned change
+func (s *ApplyServiceImpl) executeLocked(plan *applyPlan, change model.ApplyChange) error {
+	switch change.Kind {
+	case KindTemplate:
+		if change.Action == ActionDelete {
+			delete(s.templates, change.Name)
+			return nil
+		}
+		s.templates[change.Name] = plan.templates[change.Name]
+		return nil
+
+	case KindPipeline:
+		if change.Action == ActionDelete {
+			delete(s.pipelines, change.Name)
+			return s.pipelineService.DeletePipeline(change.Name)
+		}
+		if _, err := s.pipelineService.PutPipeline(plan.pipelines[change.Name]); err != nil {
+			return err
+		}
+		for _, declared := range plan.bundle.Pipelines {
+			if declared.Name == change.Name {
+				s.pipelines[change.Name] = declared
+			}
+		}
+		return nil
+
+	case KindSchedule:
+		existing, exists := plan.existing[change.Name]
+		if change.Action == ActionDelete {
+			delete(s.schedules, change.Name)
+			return s.schedulerService.DeleteSchedule(existing.ID)
+		}
+		var err error
+		if exists {
+			_, err = s.schedulerService.UpdateSchedule(existing.ID, plan.schedules[change.Name])
+		} else {
+			_, err = s.schedulerService.CreateSchedule(plan.schedules[change.Name])
+		}
+		if err != nil {
+			return err
+		}
+		for _, declared := range plan.bundle.Schedules {
+			if declared.Name == change.Name {
+				s.schedules[change.Name] = declared
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown kind %q", change.Kind)
+	}
+}
+
+// Export returns the applied templates and pipelines as declared, and every
+// persistent schedule so existing ones can be adopted into configuration.
+// Connection credentials are left out.
+func (s *ApplyServiceImpl) Export() model.ApplyBundle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bundle model.ApplyBundle
+	for _, name := range sortedKeys(s.templates) {
+		tmpl := s.templates[name]
+		tmpl.Params = RedactParams(tmpl.Params)
+		bundle.Templates = append(bundle.Templates, tmpl)
+	}
+	for _, name := range sortedKeys(s.pipelines) {
+		bundle.Pipelines = append(bundle.Pipelines, RedactPipeline(s.pipelines[name]))
+	}
+	for _, schedule := range s.schedulerService.ListSchedules() {
+		if schedule.Transient {
+			continue
+		}
+		if declared, ok := s.schedules[schedule.Name]; ok && schedule.Managed {
+			declared.Params = RedactParams(declared.Params)
+			bundle.Schedules = append(bundle.Schedules, declared)
+			continue
+		}
+		enabled := schedule.Enabled
+		bundle.Schedules = append(bundle.Schedules, model.ScheduleSpec{
+			Name:    schedule.Name,
+			Cron:    schedule.Cron,
+			Enabled: &enabled,
+			Params:  RedactParams(schedule.Params),
+		})
+	}
+	return bundle
+}
+
+// Restore reloads applied templates and pipelines persisted before a restart.
+// Schedules are restored by the scheduler itself.
+func (s *ApplyServiceImpl) Restore() error {
+	var state appliedState
+	found, err := s.store.Load(stateAppliedFile, &state)
+	if err != nil || !found {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tmpl := range state.Templates {
+		s.templates[tmpl.Name] = tmpl
+	}
+	for _, spec := range state.Schedules {
+		s.schedules[spec.Name] = spec
+	}
+	for _, pipeline := range state.Pipelines {
+		expanded, err := expandPipeline(pipeline, s.templates)
+		if err == nil {
+			_, err = s.pipelineService.PutPipeline(expanded)
+		}
+		if err != nil {
+			s.logger.WithError(err).WithField("pipeline", pipeline.Name).Warn("Failed to restore applied pipeline")
+			continue
+		}
+		s.pipelines[pipeline.Name] = pipeline
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"templates": len(s.templates),
+		"pipelines": len(s.pipelines),
+	}).Info("Restored applied configuration")
+	return nil
+}
+
+// persistLocked writes the declared objects to the state store
+func (s *ApplyServiceImpl) persistLocked() {
+	if s.store == nil {
+		return
+	}
+
+	var state appliedState
+	for _, name := range sortedKeys(s.templates) {
+		tmpl := s.templates[name]
+		tmpl.Params = s.store.redactParams(tmpl.Params)
+		state.Templates = append(state.Templates, tmpl)
+	}
+	for _, name := range sortedKeys(s.pipelines) {
+		pipeline := s.pipelines[name]
+		pipeline.Params = s.store.redactParams(pipeline.Params)
+		state.Pipelines = append(state.Pipelines, pipeline)
+	}
+	for _, name := range sortedKeys(s.schedules) {
+		spec := s.schedules[name]
+		spec.Params = s.store.redactParams(spec.Params)
+		state.Schedules = append(state.Schedules, spec)
+	}
+
+	if err := s.store.Save(stateAppliedFile, state); err != nil {
+		s.logger.WithError(err).Warn("Failed to persist applied configuration")
+	}
+}
+
+// expandPipeline applies the templates referenced by a pipeline and its steps
+func expandPipeline(pipeline model.Pipeline, templates map[string]model.ParamsTemplate) (model.Pipeline, error) {
+	params, err := expandParams(pipeline.Template, pipeline.Params, templates)
+	if err != nil {
+		return pipeline, fmt.Errorf("pipeline %q: %w", pipeline.Name, err)
+	}
+	pipeline.Params = params
+
+	steps := make([]model.PipelineStep, len(pipeline.Steps))
+	for i, step := range pipeline.Steps {
+		if step.Params, err = expandParams(step.Template, step.Params, templates); err != nil {
+			return pipeline, fmt.Errorf("pipeline %q step %q: %w", pipeline.Name, step.Name, err)
+		}
+		steps[i] = step
+	}
+	if len(steps) > 0 {
+		pipeline.Steps = steps
+	}
+	return pipeline, nil
+}
+
+// expandParams overlays params on the named template; empty values keep the template's
+func expandParams(name string, params model.IngestionParams, templates map[string]model.ParamsTemplate) (model.IngestionParams, error) {
+	if name == "" {
+		return params, nil
+	}
+	tmpl, ok := templates[name]
+	if !ok {
+		return params, fmt.Errorf("unknown template %q", name)
+	}
+
+	base, err := toJSONTree(tmpl.Params)
+	if err != nil {
+		return params, err
+	}
+	overlay, err := toJSONTree(params)
+	if err != nil {
+		return params, err
+	}
+
+	data, err := json.Marshal(mergeJSON(base, overlay))
+	if err != nil {
+		return params, fmt.Errorf("failed to merge template %q: %w", name, err)
+	}
+	var merged model.IngestionParams
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return params, fmt.Errorf("failed to merge template %q: %w", name, err)
+	}
+	return merged, nil
+}
+
+// mergeJSON overlays decoded JSON: objects merge key by key, empty overlay values are ignored
+func mergeJSON(base, overlay interface{}) interface{} {
+	if isEmptyJSON(overlay) {
+		return base
+	}
+	baseMap, ok1 := base.(map[string]interface{})
+	overlayMap, ok2 := overlay.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return overlay
+	}
+	for key, value := range overlayMap {
+		baseMap[key] = mergeJSON(baseMap[key], value)
+	}
+	return baseMap
+}
+
+// isEmptyJSON reports whether a decoded JSON value is null or a zero value
+func isEmptyJSON(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		for _, item := range v {
+			if !isEmptyJSON(item) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// toJSONTree converts a value to its decoded JSON form
+func toJSONTree(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode: %w", err)
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+	return tree, nil
+}
+
+// objectChange compares the current and desired state of an object
+func objectChange(kind, name string, current, desired interface{}, exists bool) model.ApplyChange {
+	change := model.ApplyChange{Kind: kind, Name: name, Action: ActionCreate}
+	if !exists {
+		return change
+	}
+
+	oldTree, err1 := toJSONTree(current)
+	newTree, err2 := toJSONTree(desired)
+	if err1 != nil || err2 != nil {
+		change.Action = ActionUpdate
+		return change
+	}
+	change.Diff = diffJSON("", oldTree, newTree)
+	change.Action = ActionUnchanged
+	if len(change.Diff) > 0 {
+		change.Action = ActionUpdate
+	}
+	return change
+}
+
+// diffJSON lists the differing leaves of two decoded JSON values by dotted path
+func diffJSON(path string, old, new interface{}) []model.FieldChange {
+	oldMap, ok1 := old.(map[string]interface{})
+	newMap, ok2 := new.(map[string]interface{})
+	if ok1 && ok2 {
+		keys := make(map[string]bool, len(oldMap)+len(newMap))
+		for key := range oldMap {
+			keys[key] = true
+		}
+		for key := range newMap {
+			keys[key] = true
+		}
+		var changes []model.FieldChange
+		for _, key := range sortedKeys(keys) {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			changes = append(changes, diffJSON(child, oldMap[key], newMap[key])...)
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(old, new) {
+		return nil
+	}
+	if isSecretField(path) {
+		old, new = redactedValue, redactedValue
+	}
+	return []model.FieldChange{{Path: path, Old: old, New: new}}
+}
+
+// isSecretField reports whether a diff path names a credential
+func isSecretField(path string) bool {
+	for _, field := range []string{"token", "password", "clientKey"} {
+		if path == field || strings.HasSuffix(path, "."+field) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleState is the declared part of a schedule, as compared by apply
+func scheduleState(schedule model.Schedule) map[string]interface{} {
+	return map[string]interface{}{
+		"cron":    schedule.Cron,
+		"enabled": schedule.Enabled,
+		"managed": schedule.Managed,
+		"params":  schedule.Params,
+	}
+}
+
+// scheduleRequestState is the declared part of a schedule request, as compared by apply
+func scheduleRequestState(req model.ScheduleRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"cron":    req.Cron,
+		"enabled": req.Enabled == nil || *req.Enabled,
+		"managed": req.Managed,
+		"params":  req.Params,
+	}
+}
+
+// applyResult summarizes changes by action
+func applyResult(changes []model.ApplyChange) model.ApplyResult {
+	result := model.ApplyResult{Changes: changes}
+	for _, change := range changes {
+		switch change.Action {
+		case ActionCreate:
+			result.Summary.Create++
+		case ActionUpdate:
+			result.Summary.Update++
+		case ActionDelete:
+			result.Summary.Delete++
+		default:
+			result.Summary.Unchanged++
+		}
+	}
+	return result
+}
+
+// countChanges counts the changes that are not no-ops
+func countChanges(changes []model.ApplyChange) int {
+	n := 0
+	for _, change := range changes {
+		if change.Action != ActionUnchanged {
+			n++
+		}
+	}
+	return n
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryBudgetKey struct{}
+
+// MemoryBudget bounds the estimated bytes of the rows all running jobs hold in
+// insert batches. Reservations block while the budget is spent, so a sink slower
+// than its source stalls the source instead of buffering more rows.
+type MemoryBudget struct {
+	limit int64
+	mu    sync.Mutex
+	used  int64
+	freed chan struct{} // closed and replaced whenever bytes are released
+}
+
+// NewMemoryBudget creates a budget of limit bytes; it returns nil, which never
+// blocks, for a limit of 0
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &MemoryBudget{limit: limit, freed: make(chan struct{})}
+}
+
+// WithMemoryBudget returns a context carrying the given budget
+func WithMemoryBudget(ctx context.Context, b *MemoryBudget) context.Context {
+	return context.WithValue(ctx, memoryBudgetKey{}, b)
+}
+
+// MemoryBudgetFromContext returns the budget carried by ctx, or nil
+func MemoryBudgetFromContext(ctx context.Context) *MemoryBudget {
+	b, _ := ctx.Value(memoryBudgetKey{}).(*MemoryBudget)
+	return b
+}
+
+// TryReserve reserves n bytes if they fit in the budget. A reservation larger than
+// the whole budget fits once nothing else is held, so it cannot wait forever.
+func (b *MemoryBudget) TryReserve(n int64) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used > 0 && b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// Reserve waits until n bytes fit in the budget and reserves them, or returns
+// the error of ctx once it is done
+func (b *MemoryBudget) Reserve(ctx context.Context, n int64) error {
+	for !b.TryReserve(n) {
+		b.mu.Lock()
+		freed := b.freed
+		b.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Release returns n reserved bytes to the budget and wakes waiting reservations
+func (b *MemoryBudget) Release(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	close(b.freed)
+	b.freed = make(chan struct{})
+}
+
+// Used returns the bytes reserved so far
+func (b *MemoryBudget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// batchSender inserts batches on up to maxInFlight goroutines while the next batch
+// fills, blocking submissions while all are busy. With one in flight, batches are
+// inserted as they are submitted. The first error stops further submissions.
+type batchSender struct {
+	maxInFlight int
+	slots       chan struct{}
+	wg          sync.WaitGroup
+	insert      func(rows [][]interface{}, number int) (int, error)
+	done        func(bytes int64, err error) // called once per batch, when sent or dropped
+
+	mu       sync.Mutex
+	inserted int
+	err      error
+}
+
+// newBatchSender creates a sender inserting batches with insert
+func newBatchSender(maxInFlight int, insert func(rows [][]interface{}, number int) (int, error), done func(bytes int64, err error)) *batchSender {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	return &batchSender{
+		maxInFlight: maxInFlight,
+		slots:       make(chan struct{}, maxInFlight),
+		insert:      insert,
+		done:        done,
+	}
+}
+
+// submit inserts a batch of the given estimated size, waiting for a free sender
+func (b *batchSender) submit(ctx context.Context, rows [][]interface{}, number int, bytes int64) error {
+	if b.maxInFlight == 1 {
+		n, err := b.insert(rows, number)
+		b.record(n, err, bytes)
+		return err
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+	case <-ctx.Done():
+		b.done(bytes, ctx.Err())
+		return ctx.Err()
+	}
+	if _, err := b.result(); err != nil {
+		<-b.slots
+		b.done(bytes, err)
+		return err
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer func() { <-b.slots }()
+		n, err := b.insert(rows, number)
+		b.record(n, err, bytes)
+	}()
+	return nil
+}
+
+// record adds the outcome of one batch
+func (b *batchSender) record(n int, err error, bytes int64) {
+	b.done(bytes, err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inserted += n
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// result returns the rows inserted so far and the first error
+func (b *batchSender) result() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inserted, b.err
+}
+
+// wait waits for the batches in flight and returns the final result
+func (b *batchSender) wait() (int, error) {
+	b.wg.Wait()
+	return b.result()
+}
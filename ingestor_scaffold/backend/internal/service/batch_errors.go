@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBatchErrors bounds the number of failed batches described per job
+const maxBatchErrors = 100
+
+// maxBatchErrorValue bounds the offending value quoted in a batch error
+const maxBatchErrorValue = 200
+
+// dataErrorCodes are the ClickHouse exception codes caused by the inserted values
+// rather than the connection or the table, e.g. 6 CANNOT_PARSE_TEXT, 53 TYPE_MISMATCH
+// and 349 CANNOT_INSERT_NULL_IN_ORDINARY_COLUMN
+var dataErrorCodes = map[int32]bool{
+	6: true, 26: true, 27: true, 38: true, 41: true, 53: true, 69: true, 70: true,
+	72: true, 117: true, 131: true, 321: true, 349: true,
+}
+
+// exceptionColumnRe finds the column named in a ClickHouse exception message
+var exceptionColumnRe = regexp.MustCompile("(?i)column\\s+[`'\"]?([^`'\"\\s:,()]+)")
+
+type batchErrorsKey struct{}
+
+// BatchErrorLog describes the insert batches ClickHouse rejected during a job
+type BatchErrorLog struct {
+	mu     sync.Mutex
+	errors []model.BatchError
+}
+
+// NewBatchErrorLog creates an empty batch error log
+func NewBatchErrorLog() *BatchErrorLog {
+	return &BatchErrorLog{}
+}
+
+// WithBatchErrors returns a context carrying the given log
+func WithBatchErrors(ctx context.Context, l *BatchErrorLog) context.Context {
+	return context.WithValue(ctx, batchErrorsKey{}, l)
+}
+
+// BatchErrorsFromContext returns the log carried by ctx, or nil
+func BatchErrorsFromContext(ctx context.Context) *BatchErrorLog {
+	l, _ := ctx.Value(batchErrorsKey{}).(*BatchErrorLog)
+	return l
+}
+
+// Add records a rejected batch
+func (l *BatchErrorLog) Add(e model.BatchError) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.errors) < maxBatchErrors {
+		l.errors = append(l.errors, e)
+	}
+}
+
+// Snapshot returns the batch errors recorded so far
+func (l *BatchErrorLog) Snapshot() []model.BatchError {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]model.BatchError(nil), l.errors...)
+}
+
+// isDataError reports whether an insert failed because of the values inserted, so
+// retrying without the offending rows can succeed
+func isDataError(err error) bool {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		return dataErrorCodes[exception.Code]
+	}
+	var opErr *clickhouse.OpError
+	return errors.As(err, &opErr) && opErr.ColumnName != ""
+}
+
+// describeInsertError extracts the exception code and offending column of a failed insert
+func describeInsertError(err error) (code int32, column string) {
+	var opErr *clickhouse.OpError
+	if errors.As(err, &opErr) {
+		column = opErr.ColumnName
+	}
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		code = exception.Code
+		if column == "" {
+			if m := exceptionColumnRe.FindStringSubmatch(exception.Message); m != nil {
+				column = m[1]
+			}
+		}
+	}
+	return code, column
+}
+
+// sendBatch inserts rows with a prepared batch, appending their values column by
+// column, under the job's insert mode. Nothing is inserted when a value does not fit its column; the error
+// names the column, like those of row-wise appends.
+func (s *ClickHouseServiceImpl) sendBatch(ctx context.Context, query string, columns []model.Column, rows [][]interface{}) error {
+	batch, err := s.conn.PrepareBatch(insertContext(ctx), query)
+	if err != nil {
+		return err
+	}
+	for i, col := range columns {
+		column := batch.Column(i)
+		for _, row := range rows {
+			if err := column.AppendRow(row[i]); err != nil {
+				batch.Abort()
+				return &clickhouse.OpError{Op: "AppendRow", ColumnName: col.Name, Err: err}
+			}
+		}
+	}
+	return batch.Send()
+}
+
+// insertBatch inserts a batch of rows. When ClickHouse rejects the batch because of
+// its values, the batch is bisected down to the offending rows, which are routed to
+// the job's dead-letter file while every other row is inserted; the batch is then
+// described in the job's batch errors. Other failures, or more rejected rows than
+// InsertMaxRejectsPerBatch, fail the insert. It returns the number of rows inserted.
+func (s *ClickHouseServiceImpl) insertBatch(ctx context.Context, query string, columns []model.Column, batch [][]interface{}, number int) (int, error) {
+	err := s.sendBatch(ctx, query, columns, batch)
+	if err == nil {
+		return len(batch), nil
+	}
+	if !isDataError(err) || ctx.Err() != nil {
+		return 0, err
+	}
+
+	var rejected [][]interface{}
+	var rowErrs []error
+	var bisect func(rows [][]interface{}, err error) (int, error)
+	bisect = func(rows [][]interface{}, err error) (int, error) {
+		if len(rows) == 1 {
+			if limit := s.config.InsertMaxRejectsPerBatch; limit > 0 && len(rejected) >= limit {
+				return 0, fmt.Errorf("more than %d rejected rows in batch %d: %w", limit, number, err)
+			}
+			rejected = append(rejected, rows[0])
+			rowErrs = append(rowErrs, err)
+			return 0, nil
+		}
+
+		inserted := 0
+		half := len(rows) / 2
+		for _, part := range [][][]interface{}{rows[:half], rows[half:]} {
+			partErr := s.sendBatch(ctx, query, columns, part)
+			if partErr == nil {
+				inserted += len(part)
+				continue
+			}
+			if !isDataError(partErr) || ctx.Err() != nil {
+				return inserted, partErr
+			}
+			n, err := bisect(part, partErr)
+			inserted += n
+			if err != nil {
+				return inserted, err
+			}
+		}
+		return inserted, nil
+	}
+
+	inserted, bisectErr := bisect(batch, err)
+	if bisectErr != nil {
+		return inserted, bisectErr
+	}
+
+	// Route the offending rows to the dead-letter file with their own error; the
+	// job's error policy may stop it once the batch is reported
+	names := selectedColumnNames(columns)
+	deadLetter := DeadLetterFromContext(ctx)
+	rowErrors := RowErrorsFromContext(ctx)
+	var policyErr error
+	for i, row := range rejected {
+		reason := "rejected by ClickHouse: " + rowErrs[i].Error()
+		deadLetter.WriteValues(names, row, reason)
+		if err := rowErrors.Record(0, reason, rowStrings(row)); err != nil && policyErr == nil {
+			policyErr = err
+		}
+	}
+	WarningsFromContext(ctx).Count(targetRejectedWarning, len(rejected))
+
+	report := model.BatchError{
+		Batch:        number,
+		Rows:         len(batch),
+		RejectedRows: len(rejected),
+		Message:      err.Error(),
+	}
+	if len(rowErrs) > 0 {
+		report.Message = rowErrs[0].Error()
+		report.Code, report.Column = describeInsertError(rowErrs[0])
+		for i, name := range names {
+			if name == report.Column && i < len(rejected[0]) {
+				report.Value = truncateUTF8(fmt.Sprintf("%v", derefValue(rejected[0][i])), maxBatchErrorValue)
+			}
+		}
+	}
+	BatchErrorsFromContext(ctx).Add(report)
+	s.logger.WithFields(logrus.Fields{
+		"batch":    number,
+		"rejected": len(rejected),
+	}).Warn("Insert batch rejected; offending rows moved to the dead-letter file")
+
+	return inserted, policyErr
+}
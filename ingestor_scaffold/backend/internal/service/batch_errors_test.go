@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBatchConn prepares batches whose columns accept values of a single Go type
+// each, as AppendRow does for exact column types
+type fakeBatchConn struct {
+	driver.Conn
+	types   []reflect.Type
+	sent    [][]interface{}
+	aborted int
+}
+
+func (c *fakeBatchConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	batch := &fakeBatch{conn: c}
+	for _, t := range c.types {
+		batch.columns = append(batch.columns, &fakeBatchColumn{typ: t})
+	}
+	return batch, nil
+}
+
+type fakeBatch struct {
+	driver.Batch
+	conn    *fakeBatchConn
+	columns []*fakeBatchColumn
+}
+
+func (b *fakeBatch) Column(i int) driver.BatchColumn { return b.columns[i] }
+
+func (b *fakeBatch) Abort() error {
+	b.conn.aborted++
+	return nil
+}
+
+// Send records the appended values as rows, failing if the columns are ragged
+func (b *fakeBatch) Send() error {
+	for i := range b.columns[0].values {
+		row := make([]interface{}, len(b.columns))
+		for j, column := range b.columns {
+			if i >= len(column.values) {
+				return errors.New("columns have different lengths")
+			}
+			row[j] = column.values[i]
+		}
+		b.conn.sent = append(b.conn.sent, row)
+	}
+	return nil
+}
+
+type fakeBatchColumn struct {
+	typ    reflect.Type
+	values []interface{}
+}
+
+func (c *fakeBatchColumn) Append(v any) error { return c.AppendRow(v) }
+
+func (c *fakeBatchColumn) AppendRow(v any) error {
+	if v != nil && reflect.TypeOf(v) != c.typ {
+		return fmt.Errorf("converting %T to %s is unsupported", v, c.typ)
+	}
+	c.values = append(c.values, v)
+	return nil
+}
+
+func TestSendBatchColumnWise(t *testing.T) {
+	columns := []model.Column{{Name: "id", Type: "UInt32"}, {Name: "name", Type: "String"}}
+	types := []reflect.Type{reflect.TypeOf(uint32(0)), reflect.TypeOf("")}
+	tests := []struct {
+		name   string
+		rows   [][]interface{}
+		sent   int
+		column string
+	}{
+		{"valid", [][]interface{}{{uint32(1), "a"}, {uint32(2), "b"}, {uint32(3), "c"}}, 3, ""},
+		{"nulls", [][]interface{}{{uint32(1), nil}}, 1, ""},
+		{"narrowing", [][]interface{}{{uint32(1), "a"}, {int64(2), "b"}}, 0, "id"},
+		{"second column", [][]interface{}{{uint32(1), "a"}, {uint32(2), 3}}, 0, "name"},
+	}
+	for _, tt := range tests {
+		conn := &fakeBatchConn{types: types}
+		s := &ClickHouseServiceImpl{conn: conn, config: &config.Config{}}
+
+		err := s.sendBatch(context.Background(), "INSERT INTO t", columns, tt.rows)
+		assert.Len(t, conn.sent, tt.sent, tt.name)
+		if tt.column == "" {
+			assert.NoError(t, err, tt.name)
+			assert.Equal(t, tt.rows, conn.sent, tt.name)
+			assert.Zero(t, conn.aborted, tt.name)
+			continue
+		}
+		// The whole batch is abandoned and the error names the column for bisection
+		var opErr *clickhouse.OpError
+		if assert.ErrorAs(t, err, &opErr, tt.name) {
+			assert.Equal(t, tt.column, opErr.ColumnName, tt.name)
+		}
+		assert.True(t, isDataError(err), tt.name)
+		assert.Equal(t, 1, conn.aborted, tt.name)
+	}
+}
+
+func TestInsertBatchBisectsRejectedRows(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	columns := []model.Column{{Name: "id", Type: "UInt32"}}
+	tests := []struct {
+		name       string
+		batch      [][]interface{}
+		maxRejects int
+		inserted   int
+		rejected   int
+		fails      bool
+	}{
+		{"clean", [][]interface{}{{uint32(1)}, {uint32(2)}}, 0, 2, 0, false},
+		{"one bad row", [][]interface{}{{uint32(1)}, {uint32(2)}, {"three"}, {uint32(4)}, {uint32(5)}}, 0, 4, 1, false},
+		{"all bad", [][]interface{}{{"one"}, {"two"}}, 0, 0, 2, false},
+		{"over the limit", [][]interface{}{{"one"}, {uint32(2)}, {"three"}}, 1, 1, 0, true},
+	}
+	for _, tt := range tests {
+		conn := &fakeBatchConn{types: []reflect.Type{reflect.TypeOf(uint32(0))}}
+		s := &ClickHouseServiceImpl{conn: conn, config: &config.Config{InsertMaxRejectsPerBatch: tt.maxRejects}, logger: logger}
+
+		errs := NewBatchErrorLog()
+		inserted, err := s.insertBatch(WithBatchErrors(context.Background(), errs), "INSERT INTO t", columns, tt.batch, 7)
+		assert.Equal(t, tt.inserted, inserted, tt.name)
+		assert.Len(t, conn.sent, tt.inserted, tt.name)
+		if tt.fails {
+			assert.Error(t, err, tt.name)
+			assert.Empty(t, errs.Snapshot(), tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+
+		reports := errs.Snapshot()
+		if tt.rejected == 0 {
+			assert.Empty(t, reports, tt.name)
+			continue
+		}
+		if assert.Len(t, reports, 1, tt.name) {
+			assert.Equal(t, 7, reports[0].Batch, tt.name)
+			assert.Equal(t, tt.rejected, reports[0].RejectedRows, tt.name)
+			assert.Equal(t, "id", reports[0].Column, tt.name)
+		}
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"three", 200, "three"},
+		{"three", 5, "three"},
+		{"three", 3, "thr"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, truncateUTF8(tt.s, tt.max), "%q to %d", tt.s, tt.max)
+	}
+}
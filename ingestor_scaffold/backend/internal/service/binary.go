@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Text encodings for binary column values
+const (
+	BinaryEncodingHex    = "hex"
+	BinaryEncodingBase64 = "base64"
+)
+
+// ValidateBinaryEncoding checks that enc is empty (raw bytes) or a supported encoding
+func ValidateBinaryEncoding(enc string) error {
+	switch enc {
+	case "", BinaryEncodingHex, BinaryEncodingBase64:
+		return nil
+	default:
+		return fmt.Errorf("unsupported binary encoding %q: must be hex or base64", enc)
+	}
+}
+
+// baseType strips Nullable and LowCardinality wrappers from a ClickHouse type
+func baseType(chType string) string {
+	for {
+		switch {
+		case strings.HasPrefix(chType, "Nullable(") && strings.HasSuffix(chType, ")"):
+			chType = chType[len("Nullable(") : len(chType)-1]
+		case strings.HasPrefix(chType, "LowCardinality(") && strings.HasSuffix(chType, ")"):
+			chType = chType[len("LowCardinality(") : len(chType)-1]
+		default:
+			return chType
+		}
+	}
+}
+
+// isBinaryType reports whether a ClickHouse type holds raw bytes
+func isBinaryType(chType string) bool {
+	return strings.HasPrefix(baseType(chType), "FixedString(")
+}
+
+// binaryBytes returns the raw bytes of a string-like value
+func binaryBytes(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), true
+	case *string:
+		if v == nil {
+			return nil, false
+		}
+		return []byte(*v), true
+	case []byte:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// encodeBinary renders bytes as text in the given encoding
+func encodeBinary(b []byte, enc string) string {
+	if enc == BinaryEncodingHex {
+		return hex.EncodeToString(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// decodeBinary parses text produced by encodeBinary
+func decodeBinary(s, enc string) ([]byte, error) {
+	if enc == BinaryEncodingHex {
+		return hex.DecodeString(s)
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// EncodePreviewBinary rewrites binary values in preview rows as hex or base64 text.
+// FixedString columns are always encoded; other string values only when they are not
+// valid UTF-8. It returns the encoding applied to each affected column as a type hint.
+func EncodePreviewBinary(rows []map[string]interface{}, columns []model.Column, enc string) map[string]string {
+	if enc == "" {
+		enc = BinaryEncodingBase64
+	}
+
+	binary := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		if isBinaryType(col.Type) {
+			binary[col.Name] = true
+		}
+	}
+
+	hints := make(map[string]string)
+	for _, row := range rows {
+		for name, value := range row {
+			b, ok := binaryBytes(value)
+			if !ok || (!binary[name] && utf8.Valid(b)) {
+				continue
+			}
+			row[name] = encodeBinary(b, enc)
+			hints[name] = enc
+		}
+	}
+	return hints
+}
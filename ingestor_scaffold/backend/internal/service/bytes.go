@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+type byteCountersKey struct{}
+
+// ByteCounters tracks bytes read from the source and written to the target of a job.
+// File sides count bytes on disk; ClickHouse sides count uncompressed value sizes.
+type ByteCounters struct {
+	read    int64
+	written int64
+}
+
+// NewByteCounters creates zeroed byte counters
+func NewByteCounters() *ByteCounters {
+	return &ByteCounters{}
+}
+
+// WithByteCounters returns a context carrying the given counters
+func WithByteCounters(ctx context.Context, b *ByteCounters) context.Context {
+	return context.WithValue(ctx, byteCountersKey{}, b)
+}
+
+// ByteCountersFromContext returns the counters carried by ctx, or nil
+func ByteCountersFromContext(ctx context.Context) *ByteCounters {
+	b, _ := ctx.Value(byteCountersKey{}).(*ByteCounters)
+	return b
+}
+
+// AddRead records n bytes read from the source
+func (b *ByteCounters) AddRead(n int64) {
+	if b != nil {
+		atomic.AddInt64(&b.read, n)
+	}
+}
+
+// AddWritten records n bytes written to the target
+func (b *ByteCounters) AddWritten(n int64) {
+	if b != nil {
+		atomic.AddInt64(&b.written, n)
+	}
+}
+
+// Read returns the bytes read so far
+func (b *ByteCounters) Read() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.read)
+}
+
+// Written returns the bytes written so far
+func (b *ByteCounters) Written() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.written)
+}
+
+// countingReader counts bytes passing through an io.Reader
+type countingReader struct {
+	r   io.Reader
+	add func(int64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.add(int64(n))
+	return n, err
+}
+
+// countingWriter counts bytes passing through an io.Writer
+type countingWriter struct {
+	w   io.Writer
+	add func(int64)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.add(int64(n))
+	return n, err
+}
+
+// estimateRowBytes approximates the uncompressed size of a row of values
+func estimateRowBytes(values []interface{}) int64 {
+	var total int64
+	for _, v := range values {
+		total += estimateValueBytes(v)
+	}
+	return total
+}
+
+// estimateValueBytes approximates the uncompressed size of a single value
+func estimateValueBytes(v interface{}) int64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case *string:
+		if v == nil {
+			return 0
+		}
+		return int64(len(*v))
+	case []byte:
+		return int64(len(v))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, int64, uint, uint64, float64:
+		return 8
+	case time.Time:
+		return 8
+	default:
+		return int64(len(fmt.Sprint(v)))
+	}
+}
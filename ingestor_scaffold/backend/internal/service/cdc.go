@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Columns appended to rows loaded in CDC mode
+const (
+	cdcSignColumn       = "_sign"
+	cdcVersionColumn    = "_version"
+	cdcIngestedAtColumn = "_ingested_at"
+)
+
+// cdcColumns returns the bookkeeping columns appended in CDC mode.
+// The _version column is only added when no source version column is given.
+func cdcColumns(params model.IngestionParams) []model.Column {
+	columns := []model.Column{{Name: cdcSignColumn, Type: "Int8"}}
+	if params.VersionColumn == "" {
+		columns = append(columns, model.Column{Name: cdcVersionColumn, Type: "UInt64"})
+	}
+	return append(columns, model.Column{Name: cdcIngestedAtColumn, Type: "DateTime"})
+}
+
+// cdcTableOptions builds the collapsing engine for CDC mode
+func cdcTableOptions(params model.IngestionParams) model.TableOptions {
+	version := params.VersionColumn
+	if version == "" {
+		version = cdcVersionColumn
+	}
+
+	opts := model.TableOptions{
+		Engine:     "VersionedCollapsingMergeTree",
+		EngineArgs: []string{cdcSignColumn, version},
+		OrderBy:    params.UpsertKey,
+	}
+	if params.CDCEngine == "CollapsingMergeTree" {
+		opts.Engine = "CollapsingMergeTree"
+		opts.EngineArgs = []string{cdcSignColumn}
+	}
+	return opts
+}
+
+// cdcRows appends sign, version and ingestion time values to each row.
+// Rows whose delete marker column is set get a sign of -1.
+func (s *IngestServiceImpl) cdcRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	params model.IngestionParams,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	markerIdx := -1
+	for i, col := range params.Columns {
+		if col.Name == params.DeleteMarkerColumn {
+			markerIdx = i
+		}
+	}
+
+	// All rows of one load share a version
+	version := uint64(time.Now().UnixNano())
+	ingestedAt := time.Now().UTC().Truncate(time.Second)
+
+	go func() {
+		defer close(out)
+
+		for row := range in {
+			sign := int8(1)
+			if markerIdx >= 0 && isDeleteMarker(row[markerIdx]) {
+				sign = -1
+			}
+
+			row = append(row, sign)
+			if params.VersionColumn == "" {
+				row = append(row, version)
+			}
+			row = append(row, ingestedAt)
+
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// isDeleteMarker reports whether a delete marker value flags the row as deleted
+func isDeleteMarker(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case uint64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "1", "true", "yes", "y", "d", "delete", "deleted":
+			return true
+		}
+	}
+	if v, ok := toInt64(value); ok {
+		return v != 0
+	}
+	return false
+}
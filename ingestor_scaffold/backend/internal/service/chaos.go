@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+// ChaosConnector is the source and target type of the synthetic test connector
+const ChaosConnector = "chaos"
+
+// defaultChaosRows is the number of rows a chaos source generates when unset
+const defaultChaosRows = 1000
+
+// IngestChaos runs a job with the synthetic chaos connector on one or both ends.
+// The connector injects latency, errors and mid-stream truncation drawn from a seeded
+// generator, so retry and partial-failure handling can be exercised reproducibly.
+// It is refused in production.
+func (s *IngestServiceImpl) IngestChaos(
+	ctx context.Context,
+	params model.IngestionParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	if s.config.Environment == "production" {
+		return model.IngestionResult{}, fmt.Errorf("chaos connectors are disabled in production")
+	}
+	spec := model.ChaosSpec{}
+	if params.Chaos != nil {
+		spec = *params.Chaos
+	}
+	columns := params.Columns
+	if len(columns) == 0 {
+		return model.IngestionResult{}, fmt.Errorf("columns are required for chaos connectors")
+	}
+
+	// Stop reading if the write side fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	readErrCh := make(chan error, 1)
+	var dataCh <-chan []interface{}
+	switch params.SourceType {
+	case ChaosConnector:
+		dataCh = chaosSource(ctx, spec, columns, readErrCh)
+	case "clickhouse":
+		query := params.Query
+		if query == "" {
+			var err error
+			if query, err = selectColumnsQuery(params.TableName, columns); err != nil {
+				return model.IngestionResult{}, err
+			}
+		}
+		rowsCh := make(chan []interface{}, s.config.PipelineChannelSize)
+		go func() {
+			defer close(rowsCh)
+			readErrCh <- s.clickhouse(ctx).QueryRows(ctx, query, rowsCh)
+		}()
+		dataCh = rowsCh
+	case "flatfile":
+		rows, err := s.flatFileService.ReadData(ctx, params.FlatFileParams, columns, readErrCh)
+		if err != nil {
+			return model.IngestionResult{}, fmt.Errorf("failed to read data: %w", err)
+		}
+		dataCh = rows
+	default:
+		return model.IngestionResult{}, fmt.Errorf("invalid source or target type")
+	}
+
+	var count int
+	var err error
+	switch params.TargetType {
+	case ChaosConnector:
+		count, err = chaosTarget(ctx, spec, dataCh, progressCh, s.config.ProgressReportSize)
+	case "clickhouse":
+		if err := s.clickhouse(ctx).CreateTable(ctx, params.TableName, columns, model.TableOptions{}); err != nil {
+			return model.IngestionResult{}, fmt.Errorf("failed to create table: %w", err)
+		}
+		count, err = s.clickhouse(ctx).InsertData(ctx, params.TableName, columns, dataCh, progressCh)
+	case "flatfile":
+		count, err = s.flatFileService.WriteRows(ctx, params.FlatFileParams, columns, dataCh, progressCh)
+	default:
+		return model.IngestionResult{}, fmt.Errorf("invalid source or target type")
+	}
+	if err != nil {
+		cancel()
+		return model.IngestionResult{}, fmt.Errorf("failed to write data: %w", err)
+	}
+	if err := <-readErrCh; err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to read source: %w", err)
+	}
+
+	return model.IngestionResult{
+		TotalRecords: count,
+	}, nil
+}
+
+// chaosFaults draws the faults for one end of a chaos connection
+type chaosFaults struct {
+	faults model.ChaosFaults
+	rng    *rand.Rand
+}
+
+// newChaosFaults seeds a fault generator; each end gets its own stream
+func newChaosFaults(faults model.ChaosFaults, seed int64) *chaosFaults {
+	return &chaosFaults{faults: faults, rng: rand.New(rand.NewSource(seed))}
+}
+
+// next applies the latency for row n (1-based) and reports whether the stream should
+// be truncated silently or fail with an error
+func (f *chaosFaults) next(ctx context.Context, n int) (truncate bool, err error) {
+	if f.faults.LatencyMs > 0 {
+		select {
+		case <-time.After(time.Duration(f.faults.LatencyMs) * time.Millisecond):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	if f.faults.TruncateAfter > 0 && n > f.faults.TruncateAfter {
+		return true, nil
+	}
+	if f.faults.FailAfter > 0 && n > f.faults.FailAfter {
+		return false, fmt.Errorf("chaos: injected failure after %d rows", f.faults.FailAfter)
+	}
+	if f.faults.ErrorRate > 0 && f.rng.Float64() < f.faults.ErrorRate {
+		return false, fmt.Errorf("chaos: injected random failure at row %d", n)
+	}
+	return false, nil
+}
+
+// chaosSource generates deterministic rows for columns, applying the source faults.
+// The final read error, if any, is sent on errCh once the stream ends.
+func chaosSource(ctx context.Context, spec model.ChaosSpec, columns []model.Column, errCh chan<- error) <-chan []interface{} {
+	out := make(chan []interface{}, 100)
+	total := spec.Rows
+	if total <= 0 {
+		total = defaultChaosRows
+	}
+	faults := newChaosFaults(spec.Source, spec.Seed)
+	values := rand.New(rand.NewSource(spec.Seed ^ 0x5eed))
+
+	go func() {
+		defer close(out)
+
+		for n := 1; n <= total; n++ {
+			truncate, err := faults.next(ctx, n)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if truncate {
+				break
+			}
+
+			row := make([]interface{}, len(columns))
+			for i, col := range columns {
+				row[i] = chaosValue(values, col.Type, n)
+			}
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return
+			}
+		}
+		errCh <- nil
+	}()
+
+	return out
+}
+
+// chaosTarget consumes rows, applying the target faults. A truncated target discards
+// the rest of the stream and reports success with fewer rows, as a lossy sink would.
+func chaosTarget(
+	ctx context.Context,
+	spec model.ChaosSpec,
+	data <-chan []interface{},
+	progressCh chan<- model.ProgressUpdate,
+	reportSize int,
+) (int, error) {
+	faults := newChaosFaults(spec.Target, spec.Seed+1)
+
+	count := 0
+	for range data {
+		truncate, err := faults.next(ctx, count+1)
+		if err != nil {
+			return count, err
+		}
+		if truncate {
+			for range data {
+			}
+			return count, nil
+		}
+
+		count++
+		if reportSize > 0 && count%reportSize == 0 {
+			select {
+			case progressCh <- model.ProgressUpdate{
+				Status:    "processing",
+				Message:   fmt.Sprintf("Written %d rows", count),
+				Count:     count,
+				Completed: false,
+			}:
+			case <-ctx.Done():
+				return count, ctx.Err()
+			}
+		}
+	}
+	return count, ctx.Err()
+}
+
+// chaosValue produces a value of the given ClickHouse type for row n
+func chaosValue(rng *rand.Rand, chType string, n int) interface{} {
+	typ := baseType(chType)
+	switch {
+	case strings.HasPrefix(typ, "Int") || strings.HasPrefix(typ, "UInt"):
+		return int64(rng.Intn(1000000))
+	case strings.HasPrefix(typ, "Float") || strings.HasPrefix(typ, "Decimal"):
+		return rng.Float64() * 1000
+	case typ == "Bool":
+		return rng.Intn(2) == 1
+	case strings.HasPrefix(typ, "Date"):
+		return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(n) * time.Minute)
+	default:
+		return fmt.Sprintf("value-%d-%d", n, rng.Intn(1000))
+	}
+}
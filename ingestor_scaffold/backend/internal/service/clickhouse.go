@@ -3,8 +3,11 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -17,21 +20,55 @@ import (
 // ClickHouseService defines ClickHouse operations
 type ClickHouseService interface {
 	Connect(ctx context.Context, params model.ClickHouseConnectionParams, token string) error
+	Ping(ctx context.Context) error
 	ListTables(ctx context.Context) ([]string, error)
 	GetTableColumns(ctx context.Context, tableName string) ([]model.Column, error)
-	PreviewData(ctx context.Context, tableName string, columns []string, limit int) ([]map[string]interface{}, error)
-	BuildJoinQuery(params model.JoinParams) (string, error)
-	ExecuteJoinPreview(ctx context.Context, query string, limit int) ([]map[string]interface{}, error)
+	PreviewData(ctx context.Context, tableName string, columns []string, filters []model.Filter, offset, limit int) ([]map[string]interface{}, error)
+	BuildJoinQuery(params model.JoinParams) (string, []interface{}, error)
+	ExecuteJoinPreview(ctx context.Context, query string, args []interface{}, limit int) ([]map[string]interface{}, error)
+	CountRows(ctx context.Context, tableName string, filters []model.Filter, exact bool) (model.RowCount, error)
+	CountQuery(ctx context.Context, query string, args []interface{}) (model.RowCount, error)
+	TableSize(ctx context.Context, tableName string) (model.RowCount, error)
+	ProfileTable(ctx context.Context, tableName string, columns []string, sampleRows, topValues int) (model.DataProfile, error)
+	SortingKey(ctx context.Context, tableName string) ([]string, error)
+	ValueRange(ctx context.Context, query string, args []interface{}, column string) (int64, int64, bool, error)
+	ChecksumQuery(ctx context.Context, query string, args []interface{}, columns []string) (int64, map[string]float64, error)
+	RowExists(ctx context.Context, source string, args []interface{}, columns []string, values []interface{}) (bool, error)
+	ExplainQuery(ctx context.Context, query string, args []interface{}) ([]string, error)
+	EstimateQuery(ctx context.Context, query string, args []interface{}) ([]model.ReadEstimate, error)
+	ParseQuery(ctx context.Context, query string) error
+	DescribeSource(ctx context.Context, source string) ([]model.Column, error)
+	InsertSelect(ctx context.Context, tableName string, columns []model.Column, source string, progressCh chan<- model.ProgressUpdate) (int, error)
+	InsertIntoFunction(ctx context.Context, target, query string, args []interface{}, settings clickhouse.Settings, progressCh chan<- model.ProgressUpdate) (int64, int64, error)
 	ExecuteQuery(ctx context.Context, query string, progressCh chan<- model.ProgressUpdate) (int, error)
-	CreateTable(ctx context.Context, tableName string, columns []model.Column) error
+	QueryRows(ctx context.Context, query string, out chan<- []interface{}) error
+	Query(ctx context.Context, query string, args ...interface{}) (driver.Rows, error)
+	ExportFormatted(ctx context.Context, query, format string, settings map[string]string, w io.Writer) (int, error)
+	ShowCreateTable(ctx context.Context, tableName string) (string, error)
+	ExecDDL(ctx context.Context, ddl string) error
+	SetToken(token string) error
+	Cluster() string
+	Replicated() bool
+	RunningQueries(ctx context.Context) ([]map[string]interface{}, error)
+	Diagnose(ctx context.Context) (model.ConnectionDiagnostics, error)
+	CreateTable(ctx context.Context, tableName string, columns []model.Column, opts model.TableOptions) error
+	OptimizeTable(ctx context.Context, tableName string, cluster string) error
 	InsertData(ctx context.Context, tableName string, columns []model.Column, data <-chan []interface{}, progressCh chan<- model.ProgressUpdate) (int, error)
+	Close() error
 }
 
 // ClickHouseServiceImpl implements ClickHouseService
 type ClickHouseServiceImpl struct {
-	conn   driver.Conn
-	config *config.Config
-	logger *logrus.Logger
+	conn       driver.Conn
+	http       *httpEndpoint // HTTP interface of the same server
+	cluster    string
+	replicated bool
+	config     *config.Config
+	logger     *logrus.Logger
+
+	// JWT that new connections authenticate with; replaced when it is refreshed
+	tokenMu sync.RWMutex
+	token   string
 }
 
 // NewClickHouseService creates a new ClickHouse service
@@ -44,9 +81,63 @@ func NewClickHouseService(config *config.Config, logger *logrus.Logger) ClickHou
 
 // Connect establishes a connection to ClickHouse
 func (s *ClickHouseServiceImpl) Connect(ctx context.Context, params model.ClickHouseConnectionParams, token string) error {
-	// Create options with JWT token auth
+	params, err := applyPreset(params)
+	if err != nil {
+		return err
+	}
+
+	// Per-connection tuning overrides the configured defaults
+	compression, err := s.compressionOptions(params)
+	if err != nil {
+		return err
+	}
+	dialTimeout := s.config.ClickHouseDialTimeout
+	if params.DialTimeoutSeconds > 0 {
+		dialTimeout = time.Duration(params.DialTimeoutSeconds) * time.Second
+	}
+	readTimeout := s.config.ClickHouseReadTimeout
+	if params.ReadTimeoutSeconds > 0 {
+		readTimeout = time.Duration(params.ReadTimeoutSeconds) * time.Second
+	}
+	proxyURL := s.config.OutboundProxy
+	if params.Proxy != "" {
+		proxyURL = params.Proxy
+	}
+	dial, err := proxyDialer(proxyURL, dialTimeout)
+	if err != nil {
+		return err
+	}
+	tlsCfg, err := tlsConfig(params)
+	if err != nil {
+		return err
+	}
+	endpoint := newHTTPEndpoint(params, tlsCfg, dial)
+	if dial != nil && tlsCfg != nil {
+		dial = tlsDialer(dial, tlsCfg)
+	}
+	addrs, err := s.connectionAddrs(ctx, params)
+	if err != nil {
+		return err
+	}
+	strategy, err := s.openStrategy(params)
+	if err != nil {
+		return err
+	}
+
+	// A user authenticates with either a JWT token or a password, not both
+	if token != "" && params.Password != "" {
+		return fmt.Errorf("provide either a token or a password, not both")
+	}
+	if err := ValidateLabels(params.Labels); err != nil {
+		return err
+	}
+	if _, err := onCluster(params.Cluster); err != nil {
+		return err
+	}
+
+	// Create options
 	options := &clickhouse.Options{
-		Addr: []string{fmt.Sprintf("%s:%d", params.Host, params.Port)},
+		Addr: addrs,
 		Auth: clickhouse.Auth{
 			Database: params.Database,
 			Username: params.User,
@@ -54,18 +145,43 @@ func (s *ClickHouseServiceImpl) Connect(ctx context.Context, params model.ClickH
 		Settings: clickhouse.Settings{
 			"max_execution_time": 60,
 		},
-		DialTimeout:          10 * time.Second,
+		TLS:                  tlsCfg,
+		DialContext:          dial,
+		Compression:          compression,
+		DialTimeout:          dialTimeout,
+		ReadTimeout:          readTimeout,
 		MaxOpenConns:         5,
 		MaxIdleConns:         5,
 		ConnMaxLifetime:      time.Hour,
-		ConnOpenStrategy:     clickhouse.ConnOpenInOrder,
+		ConnOpenStrategy:     strategy,
 		BlockBufferSize:      10,
 		MaxCompressionBuffer: 10 * 1024 * 1024,
 	}
 
-	// If token is provided, configure JWT auth
+	if params.Protocol == "http" {
+		options.Protocol = clickhouse.HTTP
+	}
+	presetSettings(params, options.Settings)
+
+	// Connection labels tag every query in system.query_log; job labels override them
+	if comment := labelComment(params.Labels); comment != "" {
+		options.Settings["log_comment"] = comment
+	}
+
+	// If token is provided, configure JWT auth, read as each connection opens so a
+	// refreshed token takes over mid-job; otherwise use the password (empty for
+	// passwordless users)
 	if token != "" {
-		options.Auth.AccessToken = token
+		s.tokenMu.Lock()
+		s.token = token
+		s.tokenMu.Unlock()
+		options.GetJWT = func(ctx context.Context) (string, error) {
+			s.tokenMu.RLock()
+			defer s.tokenMu.RUnlock()
+			return s.token, nil
+		}
+	} else {
+		options.Auth.Password = params.Password
 	}
 
 	// Connect to ClickHouse
@@ -76,10 +192,100 @@ func (s *ClickHouseServiceImpl) Connect(ctx context.Context, params model.ClickH
 
 	// Test connection
 	if err := conn.Ping(ctx); err != nil {
+		conn.Close()
 		return fmt.Errorf("failed to ping ClickHouse: %w", err)
 	}
 
 	s.conn = conn
+	s.http = endpoint
+	s.cluster = params.Cluster
+	s.replicated = params.Replicated
+	return nil
+}
+
+// connectionAddrs lists the replica addresses to try, expanding DNS names if requested
+func (s *ClickHouseServiceImpl) connectionAddrs(ctx context.Context, params model.ClickHouseConnectionParams) ([]string, error) {
+	hosts := params.Hosts
+	if params.Host != "" {
+		hosts = append([]string{params.Host}, hosts...)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("at least one host is required")
+	}
+
+	var addrs []string
+	for _, host := range hosts {
+		// Hosts may carry their own port; bare IPv6 literals never do
+		name, port := host, strconv.Itoa(params.Port)
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			name, port = h, p
+		}
+		name = strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+
+		if !params.ExpandDNS || net.ParseIP(name) != nil {
+			addrs = append(addrs, net.JoinHostPort(name, port))
+			continue
+		}
+		ips, err := net.DefaultResolver.LookupHost(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, port))
+		}
+	}
+	return addrs, nil
+}
+
+// openStrategy resolves how replicas are chosen when opening connections
+func (s *ClickHouseServiceImpl) openStrategy(params model.ClickHouseConnectionParams) (clickhouse.ConnOpenStrategy, error) {
+	strategy := s.config.ClickHouseOpenStrategy
+	if params.OpenStrategy != "" {
+		strategy = params.OpenStrategy
+	}
+
+	switch strategy {
+	case "in_order":
+		return clickhouse.ConnOpenInOrder, nil
+	case "round_robin":
+		return clickhouse.ConnOpenRoundRobin, nil
+	default:
+		return 0, fmt.Errorf("unsupported open strategy %q: must be in_order or round_robin", strategy)
+	}
+}
+
+// compressionOptions resolves the wire compression for a connection
+func (s *ClickHouseServiceImpl) compressionOptions(params model.ClickHouseConnectionParams) (*clickhouse.Compression, error) {
+	method := s.config.ClickHouseCompression
+	if params.Compression != "" {
+		method = params.Compression
+	}
+	level := s.config.ClickHouseCompressionLevel
+	if params.CompressionLevel != 0 {
+		level = params.CompressionLevel
+	}
+
+	switch method {
+	case "none":
+		return nil, nil
+	case "lz4":
+		return &clickhouse.Compression{Method: clickhouse.CompressionLZ4, Level: level}, nil
+	case "zstd":
+		return &clickhouse.Compression{Method: clickhouse.CompressionZSTD, Level: level}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q: must be none, lz4 or zstd", method)
+	}
+}
+
+// Ping checks that the ClickHouse connection is alive
+func (s *ClickHouseServiceImpl) Ping(ctx context.Context) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected to ClickHouse")
+	}
+
+	if err := s.conn.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping ClickHouse: %w", err)
+	}
 	return nil
 }
 
@@ -90,7 +296,7 @@ func (s *ClickHouseServiceImpl) ListTables(ctx context.Context) ([]string, error
 	}
 
 	query := "SHOW TABLES"
-	rows, err := s.conn.Query(ctx, query)
+	rows, err := s.conn.Query(queryContext(ctx), query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -118,8 +324,12 @@ func (s *ClickHouseServiceImpl) GetTableColumns(ctx context.Context, tableName s
 		return nil, fmt.Errorf("not connected to ClickHouse")
 	}
 
-	query := fmt.Sprintf("DESCRIBE TABLE %s", tableName)
-	rows, err := s.conn.Query(ctx, query)
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("DESCRIBE TABLE %s", table)
+	rows, err := s.conn.Query(queryContext(ctx), query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -145,29 +355,41 @@ func (s *ClickHouseServiceImpl) GetTableColumns(ctx context.Context, tableName s
 	return columns, nil
 }
 
-// PreviewData returns a preview of the data
-func (s *ClickHouseServiceImpl) PreviewData(ctx context.Context, tableName string, columns []string, limit int) ([]map[string]interface{}, error) {
+// PreviewData returns a page of a table's rows, skipping the first offset. Pages
+// follow the order the server returns rows in, which is stable for unchanged parts.
+func (s *ClickHouseServiceImpl) PreviewData(ctx context.Context, tableName string, columns []string, filters []model.Filter, offset, limit int) ([]map[string]interface{}, error) {
 	if s.conn == nil {
 		return nil, fmt.Errorf("not connected to ClickHouse")
 	}
 
 	// Build query
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return nil, err
+	}
 	columnStr := "*"
 	if len(columns) > 0 {
-		columnStr = strings.Join(columns, ", ")
+		quoted, err := quoteIdentifiers(columns)
+		if err != nil {
+			return nil, err
+		}
+		columnStr = strings.Join(quoted, ", ")
 	}
-	query := fmt.Sprintf("SELECT %s FROM %s LIMIT %d", columnStr, tableName, limit)
+	where, args, err := buildWhere(filters)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s LIMIT %d OFFSET %d", columnStr, table, where, limit, offset)
 
 	// Execute query
-	rows, err := s.conn.Query(ctx, query)
+	rows, err := s.conn.Query(queryContext(ctx), query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 
 	// Get column names and types
-	columnNames := rows.ColumnNames()
-	columnTypes := rows.ColumnTypes()
+	columnNames := rows.Columns()
 
 	
 	// Prepare result
@@ -203,57 +425,14 @@ func (s *ClickHouseServiceImpl) PreviewData(ctx context.Context, tableName strin
 	return result, nil
 }
 
-// BuildJoinQuery builds a JOIN query from JoinParams
-func (s *ClickHouseServiceImpl) BuildJoinQuery(params model.JoinParams) (string, error) {
-	if len(params.Tables) < 2 {
-		return "", fmt.Errorf("at least two tables are required for a join")
-	}
-
-	// Main table
-	mainTable := params.Tables[0]
-	
-	// Build selected columns
-	allColumns := make([]string, 0)
-	for i, table := range params.Tables {
-		for _, col := range table.SelectedColumns {
-			// Add table prefix to avoid ambiguity
-			allColumns = append(allColumns, fmt.Sprintf("%s.%s", table.Name, col))
-		}
-	}
-	
-	if len(allColumns) == 0 {
-		return "", fmt.Errorf("no columns selected")
-	}
-	
-	// Start building query
-	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(allColumns, ", "), mainTable.Name)
-	
-	// Add joins
-	for i := 1; i < len(params.Tables); i++ {
-		joinTable := params.Tables[i]
-		joinType := "INNER JOIN"
-		if joinTable.JoinType != "" {
-			joinType = joinTable.JoinType
-		}
-		
-		// Get join condition
-		if joinTable.JoinCondition == "" {
-			return "", fmt.Errorf("join condition is required for table %s", joinTable.Name)
-		}
-		
-		query += fmt.Sprintf(" %s %s ON %s", joinType, joinTable.Name, joinTable.JoinCondition)
-	}
-	
-	// Add where clause if provided
-	if params.WhereClause != "" {
-		query += " WHERE " + params.WhereClause
-	}
-	
-	return query, nil
+// BuildJoinQuery builds a JOIN query from JoinParams, returning the values bound to its filters
+func (s *ClickHouseServiceImpl) BuildJoinQuery(params model.JoinParams) (string, []interface{}, error) {
+	query, args, _, err := joinSelect(params)
+	return query, args, err
 }
 
 // ExecuteJoinPreview executes a join query and returns preview data
-func (s *ClickHouseServiceImpl) ExecuteJoinPreview(ctx context.Context, query string, limit int) ([]map[string]interface{}, error) {
+func (s *ClickHouseServiceImpl) ExecuteJoinPreview(ctx context.Context, query string, args []interface{}, limit int) ([]map[string]interface{}, error) {
 	if s.conn == nil {
 		return nil, fmt.Errorf("not connected to ClickHouse")
 	}
@@ -262,14 +441,14 @@ func (s *ClickHouseServiceImpl) ExecuteJoinPreview(ctx context.Context, query st
 	query = query + fmt.Sprintf(" LIMIT %d", limit)
 	
 	// Execute query
-	rows, err := s.conn.Query(ctx, query)
+	rows, err := s.conn.Query(readOnlyContext(ctx), query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 	
 	// Get column names
-	columnNames := rows.ColumnNames()
+	columnNames := rows.Columns()
 	
 	// Prepare result
 	result := make([]map[string]interface{}, 0, limit)
@@ -311,18 +490,17 @@ func (s *ClickHouseServiceImpl) ExecuteQuery(ctx context.Context, query string,
 	}
 	
 	// Execute query
-	rows, err := s.conn.Query(ctx, query)
+	rows, err := s.conn.Query(queryContext(ctx), query)
 	if err != nil {
 		return 0, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 	
 	// Get column names
-	columnNames := rows.ColumnNames()
+	columnNames := rows.Columns()
 	
 	// Process rows
 	totalRows := 0
-	batchSize := s.config.BatchSize
 	progressReportSize := s.config.ProgressReportSize
 	
 	for rows.Next() {
@@ -362,28 +540,371 @@ func (s *ClickHouseServiceImpl) ExecuteQuery(ctx context.Context, query string,
 	return totalRows, nil
 }
 
+// QueryRows executes a query and sends each scanned row to out
+func (s *ClickHouseServiceImpl) QueryRows(ctx context.Context, query string, out chan<- []interface{}) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected to ClickHouse")
+	}
+
+	rows, err := s.conn.Query(readOnlyContext(ctx), query)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	columnNames := rows.Columns()
+	counters := ByteCountersFromContext(ctx)
+	for rows.Next() {
+		rowValues := make([]interface{}, len(columnNames))
+		rowPointers := make([]interface{}, len(columnNames))
+		for i := range rowValues {
+			rowPointers[i] = &rowValues[i]
+		}
+		if err := rows.Scan(rowPointers...); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		counters.AddRead(estimateRowBytes(rowValues))
+
+		select {
+		case out <- rowValues:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
+
+// Query runs a read-only query and returns its rows for the caller to read and close
+func (s *ClickHouseServiceImpl) Query(ctx context.Context, query string, args ...interface{}) (driver.Rows, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected to ClickHouse")
+	}
+	return s.conn.Query(readOnlyContext(ctx), query, args...)
+}
+
+// ShowCreateTable returns the CREATE TABLE statement of a table
+func (s *ClickHouseServiceImpl) ShowCreateTable(ctx context.Context, tableName string) (string, error) {
+	if s.conn == nil {
+		return "", fmt.Errorf("not connected to ClickHouse")
+	}
+
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return "", err
+	}
+
+	var ddl string
+	if err := s.conn.QueryRow(queryContext(ctx), fmt.Sprintf("SHOW CREATE TABLE %s", table)).Scan(&ddl); err != nil {
+		return "", fmt.Errorf("failed to show create table: %w", err)
+	}
+	return ddl, nil
+}
+
+// ExecDDL executes a DDL statement
+func (s *ClickHouseServiceImpl) ExecDDL(ctx context.Context, ddl string) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected to ClickHouse")
+	}
+
+	if err := s.conn.Exec(queryContext(ctx), ddl); err != nil {
+		return fmt.Errorf("failed to execute DDL: %w", err)
+	}
+	return nil
+}
+
+// SetToken replaces the JWT of a token-authenticated connection
+func (s *ClickHouseServiceImpl) SetToken(token string) error {
+	if token == "" {
+		return fmt.Errorf("token is required")
+	}
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+	if s.token == "" {
+		return fmt.Errorf("the connection does not authenticate with a token")
+	}
+	s.token = token
+	return nil
+}
+
+// Cluster returns the cluster DDL runs across, empty for a single node
+func (s *ClickHouseServiceImpl) Cluster() string {
+	return s.cluster
+}
+
+// Replicated reports whether created tables use Replicated engines
+func (s *ClickHouseServiceImpl) Replicated() bool {
+	return s.replicated
+}
+
+// Close closes the ClickHouse connection
+func (s *ClickHouseServiceImpl) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// RunningQueries returns the queries currently executing on the server
+func (s *ClickHouseServiceImpl) RunningQueries(ctx context.Context) ([]map[string]interface{}, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	query := "SELECT query_id, user, elapsed, read_rows, written_rows, memory_usage, query FROM system.processes"
+	rows, err := s.conn.Query(queryContext(ctx), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var result []map[string]interface{}
+	for rows.Next() {
+		var (
+			queryID, user, text   string
+			elapsed               float64
+			readRows, writtenRows uint64
+			memoryUsage           int64
+		)
+		if err := rows.Scan(&queryID, &user, &elapsed, &readRows, &writtenRows, &memoryUsage, &text); err != nil {
+			return nil, fmt.Errorf("failed to scan process: %w", err)
+		}
+		result = append(result, map[string]interface{}{
+			"queryId":     queryID,
+			"user":        user,
+			"elapsed":     elapsed,
+			"readRows":    readRows,
+			"writtenRows": writtenRows,
+			"memoryUsage": memoryUsage,
+			"query":       text,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, nil
+}
+
 // CreateTable creates a new table in ClickHouse
-func (s *ClickHouseServiceImpl) CreateTable(ctx context.Context, tableName string, columns []model.Column) error {
+func (s *ClickHouseServiceImpl) CreateTable(ctx context.Context, tableName string, columns []model.Column, opts model.TableOptions) error {
 	if s.conn == nil {
 		return fmt.Errorf("not connected to ClickHouse")
 	}
 	
-	// Build column definitions
+	if opts.Cluster == "" {
+		opts.Cluster = s.cluster
+	}
+	stmts, err := createTableStatements(tableName, columns, opts, s.config.DDLColumnChunk)
+	if err != nil {
+		return err
+	}
+	
+	// Execute query
+	if err := s.conn.Exec(queryContext(ctx), stmts.create); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+	for i, alter := range stmts.alters {
+		if err := s.conn.Exec(queryContext(ctx), alter); err != nil {
+			return fmt.Errorf("failed to add column chunk %d of %d: %w", i+1, len(stmts.alters), err)
+		}
+	}
+	if stmts.distributed != "" {
+		if err := s.conn.Exec(queryContext(ctx), stmts.distributed); err != nil {
+			return fmt.Errorf("failed to create distributed table: %w", err)
+		}
+	}
+	
+	return nil
+}
+
+// tableStatements are the DDL statements creating a table, run in order
+type tableStatements struct {
+	create      string   // CREATE TABLE of the table, or of its local tables when distributed
+	alters      []string // ALTER TABLE statements adding the columns beyond the first chunk
+	distributed string   // CREATE TABLE of the Distributed table over the local tables
+}
+
+// createTableStatements builds the statements creating a table: its CREATE TABLE,
+// the ALTER statements adding the columns beyond the first chunk and, when it is
+// distributed, the Distributed table over the local tables
+func createTableStatements(tableName string, columns []model.Column, opts model.TableOptions, chunk int) (tableStatements, error) {
+	if opts.Distributed != nil && opts.Cluster == "" {
+		return tableStatements{}, fmt.Errorf("distributed tables need a cluster; set cluster on the connection or the job")
+	}
+	table, err := QuoteTable(localTableName(tableName, opts.Distributed))
+	if err != nil {
+		return tableStatements{}, err
+	}
+	cluster, err := onCluster(opts.Cluster)
+	if err != nil {
+		return tableStatements{}, err
+	}
+
+	storage := make(map[string]model.ColumnStorage, len(opts.ColumnStorage))
+	for _, c := range opts.ColumnStorage {
+		if !containsColumn(columns, c.Column) {
+			return tableStatements{}, fmt.Errorf("storage settings name %s, which is not a table column", c.Column)
+		}
+		storage[c.Column] = c
+	}
+
+	// Build column definitions, with their storage settings
 	columnDefs := make([]string, len(columns))
 	for i, col := range columns {
-		columnDefs[i] = fmt.Sprintf("%s %s", col.Name, col.Type)
+		name, err := QuoteIdentifier(col.Name)
+		if err != nil {
+			return tableStatements{}, err
+		}
+		if err := validateColumnType(col.Type); err != nil {
+			return tableStatements{}, err
+		}
+		chType := col.Type
+		if storage[col.Name].LowCardinality {
+			if chType, err = lowCardinalityType(chType); err != nil {
+				return tableStatements{}, err
+			}
+		}
+		codec, err := codecClause(storage[col.Name].Codecs, col.Type)
+		if err != nil {
+			return tableStatements{}, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		columnDefs[i] = fmt.Sprintf("%s %s%s", name, chType, codec)
 	}
-	
-	// Build create table query
-	query := fmt.Sprintf(
-		"CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree() ORDER BY tuple()",
-		tableName,
-		strings.Join(columnDefs, ", "),
+
+	// Default to an unordered MergeTree
+	engineName, engine, err := lookupTableEngine(opts)
+	if err != nil {
+		return tableStatements{}, err
+	}
+	// Engine arguments and the sorting key are column names; several summed
+	// columns are passed as a tuple
+	engineArgs, err := quoteIdentifiers(opts.EngineArgs)
+	if err != nil {
+		return tableStatements{}, err
+	}
+	args := strings.Join(engineArgs, ", ")
+	if engineName == "SummingMergeTree" && len(engineArgs) > 1 {
+		args = "(" + args + ")"
+	}
+
+	// Replicated engines take their coordination path and replica name first
+	if opts.Replicated {
+		name, coordination, err := replicatedEngine(engineName, engine, opts)
+		if err != nil {
+			return tableStatements{}, err
+		}
+		engineName = name
+		if args != "" {
+			coordination = append(coordination, args)
+		}
+		args = strings.Join(coordination, ", ")
+	} else if opts.ZooKeeperPath != "" || opts.ReplicaName != "" {
+		return tableStatements{}, fmt.Errorf("zooKeeperPath and replicaName apply to replicated tables only")
+	}
+	clauses := ""
+	if engine.ordered {
+		clauses = " ORDER BY tuple()"
+		if orderBy := sortingKey(opts); len(orderBy) > 0 {
+			keys, err := quoteIdentifiers(orderBy)
+			if err != nil {
+				return tableStatements{}, err
+			}
+			clauses = " ORDER BY (" + strings.Join(keys, ", ") + ")"
+		}
+		if opts.PartitionBy != "" {
+			partition, err := partitionClause(opts.PartitionBy)
+			if err != nil {
+				return tableStatements{}, err
+			}
+			clauses += " PARTITION BY " + partition
+		}
+		if len(opts.PrimaryKey) > 0 {
+			keys, err := quoteIdentifiers(opts.PrimaryKey)
+			if err != nil {
+				return tableStatements{}, err
+			}
+			clauses += " PRIMARY KEY (" + strings.Join(keys, ", ") + ")"
+		}
+		if opts.TTL != nil {
+			ttl, err := ttlExpression(*opts.TTL, columns)
+			if err != nil {
+				return tableStatements{}, err
+			}
+			clauses += " TTL " + ttl
+		}
+	}
+
+	// Expiring columns carry their TTL in their definition
+	for _, c := range opts.ColumnTTLs {
+		ttl, err := ttlExpression(c.TTL, columns)
+		if err != nil {
+			return tableStatements{}, err
+		}
+		found := false
+		for i, col := range columns {
+			if col.Name == c.Column {
+				columnDefs[i] += " TTL " + ttl
+				found = true
+			}
+		}
+		if !found {
+			return tableStatements{}, fmt.Errorf("TTL column %s is not a table column", c.Column)
+		}
+	}
+
+	// Very wide tables are created in chunks to keep each statement small;
+	// TTL date columns come with the table
+	dates, _ := ttlColumns(opts)
+	keys := append(append(keyColumns(opts), opts.EngineArgs...), dates...)
+	if opts.Distributed != nil && opts.Distributed.ShardingKey != "" {
+		keys = append(keys, opts.Distributed.ShardingKey)
+	}
+	createDefs, alters := chunkColumns(table+cluster, columns, columnDefs, keys, chunk)
+
+	stmts := tableStatements{alters: alters}
+	stmts.create = fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s%s (%s) ENGINE = %s(%s)%s",
+		table,
+		cluster,
+		strings.Join(createDefs, ", "),
+		engineName,
+		args,
+		clauses,
 	)
+	if opts.Distributed != nil {
+		if stmts.distributed, err = distributedStatement(tableName, columns, opts); err != nil {
+			return tableStatements{}, err
+		}
+	}
+	return stmts, nil
+}
+
+// OptimizeTable forces a merge of all parts, collapsing replaced rows, across the
+// given cluster or else the connection's
+func (s *ClickHouseServiceImpl) OptimizeTable(ctx context.Context, tableName string, cluster string) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected to ClickHouse")
+	}
 	
-	// Execute query
-	if err := s.conn.Exec(ctx, query); err != nil {
-		return fmt.Errorf("failed to create table: %w", err)
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return err
+	}
+	if cluster == "" {
+		cluster = s.cluster
+	}
+	onClusterClause, err := onCluster(cluster)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("OPTIMIZE TABLE %s%s FINAL", table, onClusterClause)
+	if err := s.conn.Exec(queryContext(ctx), query); err != nil {
+		return fmt.Errorf("failed to optimize table: %w", err)
 	}
 	
 	return nil
@@ -402,38 +923,88 @@ func (s *ClickHouseServiceImpl) InsertData(
 	}
 	
 	// Get column names
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return 0, err
+	}
 	columnNames := make([]string, len(columns))
 	for i, col := range columns {
-		columnNames[i] = col.Name
+		name, err := QuoteIdentifier(col.Name)
+		if err != nil {
+			return 0, err
+		}
+		columnNames[i] = name
 	}
 	
 	// Prepare insert statement
 	query := fmt.Sprintf(
 		"INSERT INTO %s (%s) VALUES",
-		tableName,
+		table,
 		strings.Join(columnNames, ", "),
 	)
 	
-	// Insert data in batches
-	totalRows := 0
+	// Insert data in batches; rows ClickHouse rejects go to the dead-letter file.
+	// Up to INSERT_MAX_IN_FLIGHT_BATCHES batches are sent while the next one fills,
+	// their rows held against the memory budget until they are sent.
+	counters := ByteCountersFromContext(ctx)
+	budget := MemoryBudgetFromContext(ctx)
+	sender := newBatchSender(s.config.InsertMaxInFlightBatches, func(rows [][]interface{}, number int) (int, error) {
+		return s.insertBatch(ctx, query, columns, rows, number)
+	}, func(bytes int64, err error) {
+		budget.Release(bytes)
+		if err == nil {
+			counters.AddWritten(bytes)
+		}
+	})
+	
 	batch := make([][]interface{}, 0, s.config.BatchSize)
 	progressReportSize := s.config.ProgressReportSize
 	lastReportedCount := 0
+	var batchBytes int64
+	batchNumber := 0
+	
+	// Rows of a batch that is never sent give back their reservation
+	defer func() {
+		budget.Release(batchBytes)
+	}()
+	
+	// submit sends the filled batch and starts the next one
+	submit := func() error {
+		batchNumber++
+		rows, bytes := batch, batchBytes
+		batch = make([][]interface{}, 0, s.config.BatchSize)
+		batchBytes = 0
+		return sender.submit(ctx, rows, batchNumber, bytes)
+	}
 	
 	for rowData := range data {
+		// A spent budget sends the rows held so far before waiting for room, so a
+		// waiting job never holds memory that others wait for
+		rowBytes := estimateRowBytes(rowData)
+		if !budget.TryReserve(rowBytes) {
+			if len(batch) > 0 {
+				if err := submit(); err != nil {
+					inserted, _ := sender.wait()
+					return inserted, fmt.Errorf("failed to insert batch: %w", err)
+				}
+			}
+			if err := budget.Reserve(ctx, rowBytes); err != nil {
+				inserted, _ := sender.wait()
+				return inserted, err
+			}
+		}
 		batch = append(batch, rowData)
+		batchBytes += rowBytes
 		
 		// If batch is full, insert it
 		if len(batch) >= s.config.BatchSize {
-			// Insert batch
-			if err := s.conn.AsyncInsert(ctx, query, batch, false); err != nil {
-				return totalRows, fmt.Errorf("failed to insert batch: %w", err)
+			if err := submit(); err != nil {
+				inserted, _ := sender.wait()
+				return inserted, fmt.Errorf("failed to insert batch: %w", err)
 			}
 			
-			totalRows += len(batch)
-			batch = make([][]interface{}, 0, s.config.BatchSize)
-			
 			// Report progress if needed
+			totalRows, _ := sender.result()
 			if totalRows-lastReportedCount >= progressReportSize {
 				select {
 				case progressCh <- model.ProgressUpdate{
@@ -444,7 +1015,8 @@ func (s *ClickHouseServiceImpl) InsertData(
 				}:
 					lastReportedCount = totalRows
 				case <-ctx.Done():
-					return totalRows, ctx.Err()
+					inserted, _ := sender.wait()
+					return inserted, ctx.Err()
 				}
 			}
 		}
@@ -452,10 +1024,14 @@ func (s *ClickHouseServiceImpl) InsertData(
 	
 	// Insert any remaining rows
 	if len(batch) > 0 {
-		if err := s.conn.AsyncInsert(ctx, query, batch, false); err != nil {
-			return totalRows, fmt.Errorf("failed to insert final batch: %w", err)
+		if err := submit(); err != nil {
+			inserted, _ := sender.wait()
+			return inserted, fmt.Errorf("failed to insert final batch: %w", err)
 		}
-		totalRows += len(batch)
+	}
+	totalRows, err := sender.wait()
+	if err != nil {
+		return totalRows, fmt.Errorf("failed to insert batch: %w", err)
 	}
 	
 	return totalRows, nil
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// onCluster returns the ON CLUSTER clause of DDL run across a cluster, empty for a
+// single node
+func onCluster(cluster string) (string, error) {
+	if cluster == "" {
+		return "", nil
+	}
+	name, err := QuoteIdentifier(cluster)
+	if err != nil {
+		return "", fmt.Errorf("invalid cluster name %q", cluster)
+	}
+	return " ON CLUSTER " + name, nil
+}
+
+// localTableName returns the name of the table holding the rows of a table on
+// each node: its local table when distributed, or the table itself
+func localTableName(tableName string, spec *model.DistributedTable) string {
+	switch {
+	case spec == nil:
+		return tableName
+	case spec.LocalTable != "":
+		return spec.LocalTable
+	default:
+		return tableName + "_local"
+	}
+}
+
+// distributedStatement builds the CREATE TABLE of a Distributed table over the
+// local tables of a cluster, sharding rows by a hash of the sharding key
+func distributedStatement(tableName string, columns []model.Column, opts model.TableOptions) (string, error) {
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return "", err
+	}
+	localName := localTableName(tableName, opts.Distributed)
+	local, err := QuoteTable(localName)
+	if err != nil {
+		return "", err
+	}
+	cluster, err := onCluster(opts.Cluster)
+	if err != nil {
+		return "", err
+	}
+
+	// The Distributed engine names its database and table as strings
+	database := "currentDatabase()"
+	if db, name, qualified := strings.Cut(localName, "."); qualified {
+		database, localName = quoteString(db), name
+	}
+	shard := "rand()"
+	if key := opts.Distributed.ShardingKey; key != "" {
+		if !containsColumn(columns, key) {
+			return "", fmt.Errorf("sharding key %s is not a table column", key)
+		}
+		name, err := QuoteIdentifier(key)
+		if err != nil {
+			return "", err
+		}
+		shard = fmt.Sprintf("cityHash64(%s)", name)
+	}
+
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s%s AS %s ENGINE = Distributed(%s, %s, %s, %s)",
+		table, cluster, local, quoteString(opts.Cluster), database, quoteString(localName), shard,
+	), nil
+}
+
+// Default coordination path and replica name of replicated tables, expanded from
+// each node's macros
+const (
+	defaultZooKeeperPath = "/clickhouse/tables/{shard}/{database}/{table}"
+	defaultReplicaName   = "{replica}"
+)
+
+// jobCluster returns the cluster a job's DDL runs across: its own, or else the
+// connection's
+func jobCluster(params model.IngestionParams, conn ClickHouseService) string {
+	if params.Cluster != "" {
+		return params.Cluster
+	}
+	return conn.Cluster()
+}
+
+// clusterOptions sets the cluster and replication of a job's table options, the
+// job's own or else the connection's
+func clusterOptions(opts model.TableOptions, params model.IngestionParams, conn ClickHouseService) model.TableOptions {
+	opts.Cluster = jobCluster(params, conn)
+	opts.Replicated = conn.Replicated()
+	if params.Replicated != nil {
+		opts.Replicated = *params.Replicated
+	}
+	return opts
+}
+
+// replicatedEngine returns the Replicated variant of a MergeTree family engine and
+// its coordination arguments, which precede the engine's own
+func replicatedEngine(name string, engine tableEngine, opts model.TableOptions) (string, []string, error) {
+	if !engine.ordered {
+		return "", nil, fmt.Errorf("%s tables cannot be replicated", name)
+	}
+	path, replica := opts.ZooKeeperPath, opts.ReplicaName
+	if path == "" {
+		path = defaultZooKeeperPath
+	}
+	if replica == "" {
+		replica = defaultReplicaName
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", nil, fmt.Errorf("zooKeeperPath must be absolute")
+	}
+	return "Replicated" + name, []string{quoteString(path), quoteString(replica)}, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// columnCodec describes a compression codec a column may be created with
+type columnCodec struct {
+	minLevel, maxLevel int  // accepted argument; 0 takes none
+	specialized        bool // prepares values for a general codec that must follow
+	types              func(string) bool
+}
+
+// columnCodecs are the codecs of created columns; specialized ones only suit
+// numbers or dates
+var columnCodecs = map[string]columnCodec{
+	"NONE":        {},
+	"LZ4":         {},
+	"LZ4HC":       {minLevel: 1, maxLevel: 12},
+	"ZSTD":        {minLevel: 1, maxLevel: 22},
+	"Delta":       {minLevel: 1, maxLevel: 8, specialized: true, types: isSequenceType},
+	"DoubleDelta": {minLevel: 1, maxLevel: 8, specialized: true, types: isSequenceType},
+	"T64":         {specialized: true, types: isIntegerLikeType},
+	"Gorilla":     {minLevel: 1, maxLevel: 8, specialized: true, types: isSequenceType},
+}
+
+// codecRe matches a codec with an optional numeric argument, such as ZSTD(3)
+var codecRe = regexp.MustCompile(`^\s*(\w+)\s*(?:\(\s*(\d+)\s*\))?\s*$`)
+
+// isSequenceType reports whether delta and Gorilla codecs apply to a type
+func isSequenceType(chType string) bool {
+	return isNumericType(chType) || strings.HasPrefix(chType, "Date")
+}
+
+// isIntegerLikeType reports whether T64 applies to a type
+func isIntegerLikeType(chType string) bool {
+	return strings.HasPrefix(chType, "Int") || strings.HasPrefix(chType, "UInt") || strings.HasPrefix(chType, "Date")
+}
+
+// parseCodec splits a codec into its name and argument, 0 when it has none
+func parseCodec(codec string) (string, int, error) {
+	m := codecRe.FindStringSubmatch(codec)
+	if m == nil {
+		return "", 0, fmt.Errorf("invalid codec %q", codec)
+	}
+	spec, ok := columnCodecs[m[1]]
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported codec %s: must be NONE, LZ4, LZ4HC, ZSTD, Delta, DoubleDelta, T64 or Gorilla", m[1])
+	}
+	if m[2] == "" {
+		return m[1], 0, nil
+	}
+	level, err := strconv.Atoi(m[2])
+	if err != nil || level < spec.minLevel || level > spec.maxLevel || spec.maxLevel == 0 {
+		return "", 0, fmt.Errorf("invalid argument for codec %s", codec)
+	}
+	return m[1], level, nil
+}
+
+// checkColumnStorage validates per-column storage settings: known codecs, with
+// specialized ones before the one general codec that ends the chain
+func checkColumnStorage(storage []model.ColumnStorage) error {
+	seen := make(map[string]bool, len(storage))
+	for _, c := range storage {
+		if seen[c.Column] {
+			return fmt.Errorf("column %s has two storage settings", c.Column)
+		}
+		seen[c.Column] = true
+
+		general := false
+		for _, codec := range c.Codecs {
+			name, _, err := parseCodec(codec)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", c.Column, err)
+			}
+			if general {
+				return fmt.Errorf("column %s: codec %s follows a general compression codec", c.Column, name)
+			}
+			general = !columnCodecs[name].specialized
+		}
+		if len(c.Codecs) > 0 && !general {
+			return fmt.Errorf("column %s: specialized codecs must be followed by LZ4, LZ4HC or ZSTD", c.Column)
+		}
+	}
+	return nil
+}
+
+// codecClause renders the CODEC clause of a column of the given type
+func codecClause(codecs []string, chType string) (string, error) {
+	if len(codecs) == 0 {
+		return "", nil
+	}
+	rendered := make([]string, len(codecs))
+	for i, codec := range codecs {
+		name, level, err := parseCodec(codec)
+		if err != nil {
+			return "", err
+		}
+		if types := columnCodecs[name].types; types != nil && !types(baseType(chType)) {
+			return "", fmt.Errorf("codec %s does not apply to %s columns", name, chType)
+		}
+		rendered[i] = name
+		if level > 0 {
+			rendered[i] = fmt.Sprintf("%s(%d)", name, level)
+		}
+	}
+	return " CODEC(" + strings.Join(rendered, ", ") + ")", nil
+}
+
+// lowCardinalityType wraps a String, FixedString, number or date type in
+// LowCardinality, outside any Nullable
+func lowCardinalityType(t string) (string, error) {
+	t = strings.TrimSpace(t)
+	if strings.HasPrefix(t, "LowCardinality(") {
+		return t, nil
+	}
+	base := baseType(t)
+	if base != "String" && !strings.HasPrefix(base, "FixedString(") && !isNumericType(base) && !strings.HasPrefix(base, "Date") {
+		return "", fmt.Errorf("%s columns cannot be LowCardinality", t)
+	}
+	return "LowCardinality(" + t + ")", nil
+}
+
+// renameColumnStorage returns storage settings under the target column names
+func renameColumnStorage(storage []model.ColumnStorage, renames map[string]string) []model.ColumnStorage {
+	if len(renames) == 0 || storage == nil {
+		return storage
+	}
+	renamed := make([]model.ColumnStorage, len(storage))
+	for i, c := range storage {
+		if target, ok := renames[c.Column]; ok {
+			c.Column = target
+		}
+		renamed[i] = c
+	}
+	return renamed
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ingestor/internal/model"
+)
+
+// columnRenames validates column mappings against the source columns and returns
+// the target name of each mapped source column
+func columnRenames(mappings []model.ColumnMapping, columns []model.Column) (map[string]string, error) {
+	if len(mappings) == 0 {
+		return nil, nil
+	}
+
+	renames := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		if mapping.SourceColumn == "" || mapping.TargetColumn == "" {
+			return nil, fmt.Errorf("column mappings need a sourceColumn and a targetColumn")
+		}
+		if _, dup := renames[mapping.SourceColumn]; dup {
+			return nil, fmt.Errorf("column %s is mapped more than once", mapping.SourceColumn)
+		}
+		if !containsColumn(columns, mapping.SourceColumn) {
+			return nil, fmt.Errorf("mapped column %s is not selected", mapping.SourceColumn)
+		}
+		renames[mapping.SourceColumn] = mapping.TargetColumn
+	}
+
+	// The renamed columns must stay unique
+	seen := make(map[string]bool, len(columns))
+	for _, col := range renameColumns(columns, renames) {
+		if seen[col.Name] {
+			return nil, fmt.Errorf("column mappings produce duplicate target column %s", col.Name)
+		}
+		seen[col.Name] = true
+	}
+	return renames, nil
+}
+
+// containsColumn reports whether columns include one named name
+func containsColumn(columns []model.Column, name string) bool {
+	for _, col := range columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renameColumns returns a copy of columns under their target names
+func renameColumns(columns []model.Column, renames map[string]string) []model.Column {
+	if len(renames) == 0 {
+		return columns
+	}
+	renamed := make([]model.Column, len(columns))
+	for i, col := range columns {
+		if target, ok := renames[col.Name]; ok {
+			col.Name = target
+		}
+		renamed[i] = col
+	}
+	return renamed
+}
+
+// renameNames returns a copy of column names under their target names
+func renameNames(names []string, renames map[string]string) []string {
+	if len(renames) == 0 || names == nil {
+		return names
+	}
+	renamed := make([]string, len(names))
+	for i, name := range names {
+		if target, ok := renames[name]; ok {
+			name = target
+		}
+		renamed[i] = name
+	}
+	return renamed
+}
+
+// renameTableOptions points the key and engine columns of a table at target names
+func renameTableOptions(opts model.TableOptions, renames map[string]string) model.TableOptions {
+	opts.OrderBy = renameNames(opts.OrderBy, renames)
+	opts.EngineArgs = renameNames(opts.EngineArgs, renames)
+	opts.PrimaryKey = renameNames(opts.PrimaryKey, renames)
+	opts.PartitionBy = renamePartition(opts.PartitionBy, renames)
+	opts.ColumnStorage = renameColumnStorage(opts.ColumnStorage, renames)
+	if d := opts.Distributed; d != nil {
+		if target, ok := renames[d.ShardingKey]; ok {
+			opts.Distributed = &model.DistributedTable{LocalTable: d.LocalTable, ShardingKey: target}
+		}
+	}
+	return renameTTLs(opts, renames)
+}
+
+// renameLineage returns a copy of export lineage under the target column names
+func renameLineage(lineage []model.ColumnLineage, renames map[string]string) []model.ColumnLineage {
+	if len(renames) == 0 || lineage == nil {
+		return lineage
+	}
+	renamed := make([]model.ColumnLineage, len(lineage))
+	for i, entry := range lineage {
+		if target, ok := renames[entry.Column]; ok {
+			entry.Column = target
+		}
+		renamed[i] = entry
+	}
+	return renamed
+}
+
+// renameKeys moves the values of mapped columns in a keyed row to their target names
+func renameKeys(row map[string]interface{}, renames map[string]string) {
+	moved := make(map[string]interface{}, len(renames))
+	for source, target := range renames {
+		if value, ok := row[source]; ok {
+			moved[target] = value
+			delete(row, source)
+		}
+	}
+	for target, value := range moved {
+		row[target] = value
+	}
+}
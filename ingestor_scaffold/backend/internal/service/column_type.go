@@ -0,0 +1,271 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// typeArgs is the kind of arguments a ClickHouse type family takes
+type typeArgs int
+
+const (
+	argsNone      typeArgs = iota // no arguments
+	argsTypes                     // nested types: Array(String)
+	argsFields                    // nested types, optionally named: Tuple(a String, Int32)
+	argsLiterals                  // numbers and strings: Decimal(10, 2), DateTime('UTC')
+	argsEnum                      // 'name' = number entries
+	argsAggregate                 // a function, with literal parameters, then types
+	argsSettings                  // name = number settings: Dynamic(max_types = 8)
+)
+
+// maxColumnTypeLength bounds the column types accepted from requests
+const maxColumnTypeLength = 1024
+
+// columnTypeFamilies allowlists the ClickHouse type families a column may have
+var columnTypeFamilies = map[string]typeArgs{
+	"Int8": argsNone, "Int16": argsNone, "Int32": argsNone, "Int64": argsNone, "Int128": argsNone, "Int256": argsNone,
+	"UInt8": argsNone, "UInt16": argsNone, "UInt32": argsNone, "UInt64": argsNone, "UInt128": argsNone, "UInt256": argsNone,
+	"Float32": argsNone, "Float64": argsNone, "BFloat16": argsNone,
+	"Bool": argsNone, "String": argsNone, "UUID": argsNone, "IPv4": argsNone, "IPv6": argsNone,
+	"Date": argsNone, "Date32": argsNone, "Nothing": argsNone,
+	"Point": argsNone, "Ring": argsNone, "LineString": argsNone, "MultiLineString": argsNone, "Polygon": argsNone, "MultiPolygon": argsNone,
+
+	"FixedString": argsLiterals, "DateTime": argsLiterals, "DateTime64": argsLiterals, "Time": argsLiterals, "Time64": argsLiterals,
+	"Decimal": argsLiterals, "Decimal32": argsLiterals, "Decimal64": argsLiterals, "Decimal128": argsLiterals, "Decimal256": argsLiterals,
+
+	"Object": argsLiterals, "JSON": argsSettings, "Dynamic": argsSettings,
+
+	"Enum": argsEnum, "Enum8": argsEnum, "Enum16": argsEnum,
+
+	"Nullable": argsTypes, "LowCardinality": argsTypes, "Array": argsTypes, "Map": argsTypes, "Variant": argsTypes,
+	"Tuple": argsFields, "Nested": argsFields,
+
+	"AggregateFunction": argsAggregate, "SimpleAggregateFunction": argsAggregate,
+}
+
+// validateColumnType accepts a column type only if it parses as one ClickHouse type
+// built from allowlisted families, so it cannot end or extend a statement
+func validateColumnType(chType string) error {
+	if len(chType) > maxColumnTypeLength {
+		return fmt.Errorf("invalid column type %q: longer than %d characters", chType, maxColumnTypeLength)
+	}
+	p := &typeParser{input: chType}
+	if err := p.parseType(); err != nil {
+		return fmt.Errorf("invalid column type %q: %w", chType, err)
+	}
+	if tok := p.next(); tok != "" || p.err != nil {
+		return fmt.Errorf("invalid column type %q: %w", chType, p.unexpected(tok, "the end of the type"))
+	}
+	return nil
+}
+
+// typeParser reads a column type one token at a time. Tokens are identifiers, plain
+// or backquoted, unsigned numbers, single-quoted strings, and the punctuation
+// ( ) , = -. Quoted tokens cannot hold quotes or backslashes; anything else, such as
+// comments, fails to tokenize.
+type typeParser struct {
+	input  string
+	pos    int
+	peeked string
+	err    error
+}
+
+// next consumes and returns the next token, or "" at the end or on a bad character
+func (p *typeParser) next() string {
+	tok := p.peek()
+	p.peeked = ""
+	return tok
+}
+
+// peek returns the next token without consuming it
+func (p *typeParser) peek() string {
+	if p.peeked != "" || p.err != nil {
+		return p.peeked
+	}
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos == len(p.input) {
+		return ""
+	}
+
+	start := p.pos
+	c := p.input[p.pos]
+	switch {
+	case isTypeIdentByte(c) && !isDigit(c):
+		for p.pos < len(p.input) && isTypeIdentByte(p.input[p.pos]) {
+			p.pos++
+		}
+	case isDigit(c):
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+	case c == '\'', c == '`':
+		end := strings.IndexAny(p.input[p.pos+1:], "'`\\")
+		if end < 0 || p.input[p.pos+1+end] != c {
+			p.err = fmt.Errorf("unsupported quoted token at %d", start)
+			return ""
+		}
+		p.pos += end + 2
+	case strings.IndexByte("(),=-", c) >= 0:
+		p.pos++
+	default:
+		p.err = fmt.Errorf("unexpected %q at %d", c, start)
+		return ""
+	}
+	p.peeked = p.input[start:p.pos]
+	return p.peeked
+}
+
+// unexpected describes a token found where want was expected
+func (p *typeParser) unexpected(tok, want string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if tok == "" {
+		return fmt.Errorf("expected %s at the end", want)
+	}
+	return fmt.Errorf("expected %s, found %q", want, tok)
+}
+
+// parseType reads a type family and its arguments
+func (p *typeParser) parseType() error {
+	name := p.next()
+	kind, ok := columnTypeFamilies[name]
+	if !ok {
+		if p.err != nil {
+			return p.err
+		}
+		return fmt.Errorf("unknown type %q", name)
+	}
+	if p.peek() != "(" {
+		if kind == argsTypes || kind == argsFields || kind == argsEnum || kind == argsAggregate {
+			return fmt.Errorf("%s needs arguments", name)
+		}
+		return nil
+	}
+	if kind == argsNone {
+		return fmt.Errorf("%s takes no arguments", name)
+	}
+	p.next()
+
+	for i := 0; ; i++ {
+		var err error
+		switch {
+		case kind == argsAggregate && i == 0:
+			err = p.parseFunction()
+		case kind == argsTypes, kind == argsAggregate:
+			err = p.parseType()
+		case kind == argsFields:
+			err = p.parseField()
+		case kind == argsLiterals:
+			err = p.parseLiteral()
+		case kind == argsEnum:
+			err = p.parseEnumEntry()
+		case kind == argsSettings:
+			err = p.parseSetting()
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := p.next(); tok {
+		case ",":
+		case ")":
+			return nil
+		default:
+			return p.unexpected(tok, `"," or ")"`)
+		}
+	}
+}
+
+// parseField reads a tuple element: a type, or a name followed by a type
+func (p *typeParser) parseField() error {
+	tok := p.peek()
+	if _, family := columnTypeFamilies[tok]; !family && isTypeIdent(tok) || strings.HasPrefix(tok, "`") {
+		p.next()
+	}
+	return p.parseType()
+}
+
+// parseSetting reads name = number
+func (p *typeParser) parseSetting() error {
+	if tok := p.next(); !isTypeIdent(tok) {
+		return p.unexpected(tok, "a setting")
+	}
+	if tok := p.next(); tok != "=" {
+		return p.unexpected(tok, `"="`)
+	}
+	if tok := p.next(); tok == "" || !isDigit(tok[0]) {
+		return p.unexpected(tok, "a number")
+	}
+	return nil
+}
+
+// parseLiteral reads a number, possibly negative, or a string
+func (p *typeParser) parseLiteral() error {
+	tok := p.next()
+	if tok == "-" {
+		tok = p.next()
+		if tok == "" || !isDigit(tok[0]) {
+			return p.unexpected(tok, "a number")
+		}
+		return nil
+	}
+	if tok == "" || !isDigit(tok[0]) && tok[0] != '\'' {
+		return p.unexpected(tok, "a number or string")
+	}
+	return nil
+}
+
+// parseEnumEntry reads 'name' or 'name' = number
+func (p *typeParser) parseEnumEntry() error {
+	if tok := p.next(); tok == "" || tok[0] != '\'' {
+		return p.unexpected(tok, "an enum value")
+	}
+	if p.peek() != "=" {
+		return nil
+	}
+	p.next()
+	if tok := p.peek(); tok != "-" && (tok == "" || !isDigit(tok[0])) {
+		return p.unexpected(p.next(), "a number")
+	}
+	return p.parseLiteral()
+}
+
+// parseFunction reads the function of an aggregate type, with its parameters
+func (p *typeParser) parseFunction() error {
+	if tok := p.next(); !isTypeIdent(tok) {
+		return p.unexpected(tok, "an aggregate function")
+	}
+	if p.peek() != "(" {
+		return nil
+	}
+	p.next()
+	for {
+		if err := p.parseLiteral(); err != nil {
+			return err
+		}
+		switch tok := p.next(); tok {
+		case ",":
+		case ")":
+			return nil
+		default:
+			return p.unexpected(tok, `"," or ")"`)
+		}
+	}
+}
+
+// isTypeIdent reports whether a token is an identifier
+func isTypeIdent(tok string) bool {
+	return tok != "" && isTypeIdentByte(tok[0]) && !isDigit(tok[0])
+}
+
+// isTypeIdentByte reports whether c may appear in an identifier
+func isTypeIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c)
+}
+
+// isDigit reports whether c is a decimal digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+)
+
+// bytesPerGB is the unit of the configured costs
+const bytesPerGB = 1e9
+
+// CostsEnabled reports whether any unit cost is configured
+func CostsEnabled(cfg *config.Config) bool {
+	return cfg.CostPerGBScanned > 0 || cfg.CostPerGBTransferred > 0 || cfg.CostPerGBStored > 0
+}
+
+// priceCost applies the configured unit costs to a job's byte figures
+func priceCost(cfg *config.Config, cost model.CostEstimate) model.CostEstimate {
+	cost.Scanned = float64(cost.BytesScanned) / bytesPerGB * cfg.CostPerGBScanned
+	cost.Transferred = float64(cost.BytesTransferred) / bytesPerGB * cfg.CostPerGBTransferred
+	cost.Stored = float64(cost.BytesStored) / bytesPerGB * cfg.CostPerGBStored
+	cost.Total = cost.Scanned + cost.Transferred + cost.Stored
+	cost.Currency = cfg.CostCurrency
+	return cost
+}
+
+// JobCost prices the bytes a finished job moved: bytes read from a ClickHouse source
+// are scanned and transferred, bytes written to a ClickHouse target are transferred
+// and stored. It returns nil when no unit cost is configured.
+func JobCost(cfg *config.Config, params model.IngestionParams, result model.IngestionResult) *model.CostEstimate {
+	if !CostsEnabled(cfg) {
+		return nil
+	}
+
+	cost := model.CostEstimate{Rows: int64(result.TotalRecords), Exact: true}
+	if params.SourceType == "clickhouse" {
+		cost.BytesScanned = result.BytesRead
+		cost.BytesTransferred += result.BytesRead
+	}
+	if params.TargetType == "clickhouse" {
+		cost.BytesStored = result.BytesWritten
+		cost.BytesTransferred += result.BytesWritten
+	}
+	cost = priceCost(cfg, cost)
+	return &cost
+}
+
+// EstimateCost prices a job before it runs from the size of its source: the
+// uncompressed size of the source tables in their metadata, or the size of the
+// source file. Rows come from the same metadata, a count of a join, or a sample of
+// the file. Custom queries cannot be sized and are refused.
+func (s *IngestServiceImpl) EstimateCost(ctx context.Context, params model.IngestionParams) (model.CostEstimate, error) {
+	var cost model.CostEstimate
+	var sourceBytes int64
+
+	switch params.SourceType {
+	case "clickhouse":
+		if params.Query != "" || params.TableFunction != nil {
+			return model.CostEstimate{}, fmt.Errorf("the cost of a custom query or table function cannot be estimated")
+		}
+		conn := s.clickhouse(ctx)
+
+		tables := []string{params.TableName}
+		if params.Join != nil {
+			tables = tables[:0]
+			for _, table := range params.Join.Tables {
+				tables = append(tables, table.Name)
+			}
+		}
+		for _, table := range tables {
+			size, err := conn.TableSize(ctx, table)
+			if err != nil {
+				return model.CostEstimate{}, fmt.Errorf("failed to size table %s: %w", table, err)
+			}
+			cost.Rows = size.Rows
+			sourceBytes += size.Bytes
+		}
+
+		if params.Join != nil {
+			query, args, err := conn.BuildJoinQuery(*params.Join)
+			if err != nil {
+				return model.CostEstimate{}, fmt.Errorf("invalid join: %w", err)
+			}
+			count, err := conn.CountQuery(ctx, query, args)
+			if err != nil {
+				return model.CostEstimate{}, err
+			}
+			cost.Rows = count.Rows
+		}
+		cost.BytesScanned = sourceBytes
+		cost.BytesTransferred = sourceBytes
+	case "flatfile":
+		count, err := s.flatFileService.CountRows(ctx, params.FlatFileParams.FilePath, false)
+		if err != nil {
+			return model.CostEstimate{}, err
+		}
+		cost.Rows = count.Rows
+		sourceBytes = count.FileBytes
+	default:
+		return model.CostEstimate{}, fmt.Errorf("the cost of a %s source cannot be estimated", params.SourceType)
+	}
+
+	if params.TargetType == "clickhouse" {
+		cost.BytesStored = sourceBytes
+		cost.BytesTransferred += sourceBytes
+	}
+	return priceCost(s.config, cost), nil
+}
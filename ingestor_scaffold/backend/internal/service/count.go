@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Files up to countSampleChunks chunks of countSampleChunkBytes are counted in full;
+// larger files are estimated from that many evenly spaced chunks
+const (
+	countSampleChunks     = 8
+	countSampleChunkBytes = 256 * 1024
+)
+
+// CountRows counts the rows of a table that match the filters. Unless exact, the
+// count of an unfiltered table is read from its metadata, which MergeTree tables
+// keep without being scanned; other engines fall back to count().
+func (s *ClickHouseServiceImpl) CountRows(ctx context.Context, tableName string, filters []model.Filter, exact bool) (model.RowCount, error) {
+	if s.conn == nil {
+		return model.RowCount{}, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return model.RowCount{}, err
+	}
+
+	if !exact && len(filters) == 0 {
+		if size, err := s.TableSize(ctx, tableName); err == nil {
+			return size, nil
+		}
+	}
+
+	where, args, err := buildWhere(filters)
+	if err != nil {
+		return model.RowCount{}, err
+	}
+	rows, err := s.count(ctx, fmt.Sprintf("SELECT count() FROM %s%s", table, where), args)
+	if err != nil {
+		return model.RowCount{}, err
+	}
+	return model.RowCount{Rows: rows, Exact: true, Method: "count"}, nil
+}
+
+// TableSize returns the rows and uncompressed bytes of a table from system.tables
+// and system.parts. Tables whose engine keeps no row count return an error.
+func (s *ClickHouseServiceImpl) TableSize(ctx context.Context, tableName string) (model.RowCount, error) {
+	if s.conn == nil {
+		return model.RowCount{}, fmt.Errorf("not connected to ClickHouse")
+	}
+	if _, err := QuoteTable(tableName); err != nil {
+		return model.RowCount{}, err
+	}
+
+	database, args := "currentDatabase()", []interface{}{tableName}
+	if db, name, qualified := strings.Cut(tableName, "."); qualified {
+		database, args = "?", []interface{}{db, name}
+	}
+
+	var rows *uint64
+	query := fmt.Sprintf("SELECT total_rows FROM system.tables WHERE database = %s AND name = ?", database)
+	if err := s.conn.QueryRow(queryContext(ctx), query, args...).Scan(&rows); err != nil {
+		return model.RowCount{}, fmt.Errorf("failed to read table metadata: %w", err)
+	}
+	if rows == nil {
+		return model.RowCount{}, fmt.Errorf("table %s keeps no row count", tableName)
+	}
+
+	var uncompressed uint64
+	query = fmt.Sprintf("SELECT sum(data_uncompressed_bytes) FROM system.parts WHERE active AND database = %s AND table = ?", database)
+	if err := s.conn.QueryRow(queryContext(ctx), query, args...).Scan(&uncompressed); err != nil {
+		return model.RowCount{}, fmt.Errorf("failed to read table parts: %w", err)
+	}
+	return model.RowCount{Rows: int64(*rows), Bytes: int64(uncompressed), Method: "metadata"}, nil
+}
+
+// CountQuery counts the rows a query returns. Joins have no cheap estimate, so
+// they are always counted.
+func (s *ClickHouseServiceImpl) CountQuery(ctx context.Context, query string, args []interface{}) (model.RowCount, error) {
+	if s.conn == nil {
+		return model.RowCount{}, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	rows, err := s.count(ctx, fmt.Sprintf("SELECT count() FROM (%s)", query), args)
+	if err != nil {
+		return model.RowCount{}, err
+	}
+	return model.RowCount{Rows: rows, Exact: true, Method: "count"}, nil
+}
+
+// count runs a query returning a single count
+func (s *ClickHouseServiceImpl) count(ctx context.Context, query string, args []interface{}) (int64, error) {
+	var rows uint64
+	if err := s.conn.QueryRow(readOnlyContext(ctx), query, args...).Scan(&rows); err != nil {
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return int64(rows), nil
+}
+
+// CountRows counts the data rows of a flat file by its lines, less the header. Unless
+// exact, a large file is estimated from the line density of evenly spaced chunks.
+// Quoted fields spanning lines are counted once per line.
+func (s *FlatFileServiceImpl) CountRows(ctx context.Context, filePath string, exact bool) (model.RowCount, error) {
+	filePath, err := s.sandbox.Resolve(filePath)
+	if err != nil {
+		return model.RowCount{}, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return model.RowCount{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return model.RowCount{}, fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := info.Size()
+
+	if !exact && size > countSampleChunks*countSampleChunkBytes {
+		var sampled, newlines int64
+		stride := size / countSampleChunks
+		buf := make([]byte, countSampleChunkBytes)
+		for i := int64(0); i < countSampleChunks; i++ {
+			if err := ctx.Err(); err != nil {
+				return model.RowCount{}, err
+			}
+			n, err := file.ReadAt(buf, i*stride)
+			if err != nil && err != io.EOF {
+				return model.RowCount{}, fmt.Errorf("failed to read file: %w", err)
+			}
+			sampled += int64(n)
+			newlines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		}
+
+		// Lines longer than a chunk leave nothing to extrapolate from
+		if newlines > 0 {
+			lines := int64(math.Round(float64(size) * float64(newlines) / float64(sampled)))
+			return model.RowCount{Rows: dataRows(lines), Method: "sample", FileBytes: size, SampledBytes: sampled}, nil
+		}
+	}
+
+	lines, err := countLines(ctx, file)
+	if err != nil {
+		return model.RowCount{}, err
+	}
+	return model.RowCount{Rows: dataRows(lines), Exact: true, Method: "lines", FileBytes: size, SampledBytes: size}, nil
+}
+
+// countLines counts the lines of r, including a last line without a newline
+func countLines(ctx context.Context, r io.Reader) (int64, error) {
+	buf := make([]byte, 64*1024)
+	var lines int64
+	var last byte = '\n'
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
+
+// dataRows is the number of rows in a file of the given lines, less its header
+func dataRows(lines int64) int64 {
+	if lines <= 1 {
+		return 0
+	}
+	return lines - 1
+}
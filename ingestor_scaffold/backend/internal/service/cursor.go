@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// CursorTracker keeps the largest value seen in a cursor column, such as an
+// auto-increment ID or an update timestamp, so later runs can resume after it
+type CursorTracker struct {
+	mu  sync.Mutex
+	max interface{}
+}
+
+// NewCursorTracker creates an empty tracker
+func NewCursorTracker() *CursorTracker {
+	return &CursorTracker{}
+}
+
+// Observe records a value of the cursor column; NULLs are ignored
+func (t *CursorTracker) Observe(value interface{}) {
+	if t == nil {
+		return
+	}
+	value = derefValue(value)
+	if value == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.max == nil || compareCursor(value, t.max) > 0 {
+		t.max = value
+	}
+}
+
+// Value renders the largest value seen in ClickHouse literal syntax, or "" when none was
+func (t *CursorTracker) Value() string {
+	if t == nil {
+		return ""
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch v := t.max.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if v.Nanosecond() != 0 {
+			return v.Format("2006-01-02 15:04:05.000000")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// cursorRows forwards rows from in, observing the value at index
+func (s *IngestServiceImpl) cursorRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	index int,
+	cursor *CursorTracker,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	go func() {
+		defer close(out)
+
+		for row := range in {
+			if index < len(row) {
+				cursor.Observe(row[index])
+			}
+
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// cursorIndex returns the position of the cursor column among the given columns
+func cursorIndex(column string, names []string) (int, error) {
+	for i, name := range names {
+		if name == column {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("cursor column %s is not selected", column)
+}
+
+// derefValue unwraps the pointers ClickHouse uses for Nullable values
+func derefValue(value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Ptr {
+		return value
+	}
+	if rv.IsNil() {
+		return nil
+	}
+	return rv.Elem().Interface()
+}
+
+// compareCursor orders two cursor values of the same column. Integers compare
+// exactly, other numbers as floats, times chronologically and anything else as text.
+func compareCursor(a, b interface{}) int {
+	if ai, ok := toInt64(a); ok {
+		if bi, ok := toInt64(b); ok {
+			return compareOrdered(ai, bi)
+		}
+	}
+	if au, ok := toUint64(a); ok {
+		if bu, ok := toUint64(b); ok {
+			return compareOrdered(au, bu)
+		}
+	}
+	if af, ok := toFloat64(a); ok {
+		if bf, ok := toFloat64(b); ok {
+			return compareOrdered(af, bf)
+		}
+	}
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			switch {
+			case at.Before(bt):
+				return -1
+			case at.After(bt):
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return compareOrdered(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// compareOrdered returns -1, 0 or 1
+func compareOrdered[T int64 | uint64 | float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// toInt64 converts signed integers, and unsigned ones that fit
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// toUint64 converts non-negative integers
+func toUint64(value interface{}) (uint64, bool) {
+	if v, ok := value.(uint64); ok {
+		return v, true
+	}
+	if v, ok := toInt64(value); ok && v >= 0 {
+		return uint64(v), true
+	}
+	return 0, false
+}
+
+// toFloat64 converts any numeric value
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case uint64:
+		return float64(v), true
+	}
+	if v, ok := toInt64(value); ok {
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+// strptimeDirectives maps strptime directives to Go layout elements
+var strptimeDirectives = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'j': "002",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'f': "000000", 'p': "PM",
+	'b': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday", 'z': "-0700", 'Z': "MST",
+	'%': "%",
+}
+
+// dateLayout converts a column format to a Go layout: formats with % directives
+// are strptime-style, e.g. "%d-%b-%Y %H:%M", others Go layouts already
+func dateLayout(format string) (string, error) {
+	if !strings.Contains(format, "%") {
+		return format, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		if i+1 == len(format) {
+			return "", fmt.Errorf("format %q ends with a lone %%", format)
+		}
+		i++
+		element, ok := strptimeDirectives[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive %%%c in format %q", format[i], format)
+		}
+		b.WriteString(element)
+	}
+	return b.String(), nil
+}
+
+// dateLayouts resolves the layout of each column with a format; columns without
+// one keep an empty layout and are parsed from the common formats
+func dateLayouts(columns []model.Column) ([]string, error) {
+	layouts := make([]string, len(columns))
+	for i, col := range columns {
+		if col.Format == "" {
+			continue
+		}
+		if !strings.HasPrefix(baseType(col.Type), "Date") {
+			return nil, fmt.Errorf("format given for %s, which is %s rather than a date type", col.Name, col.Type)
+		}
+		layout, err := dateLayout(col.Format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format for %s: %w", col.Name, err)
+		}
+		layouts[i] = layout
+	}
+	return layouts, nil
+}
+
+// parseDate parses a date value with a column's layout, reporting false when it
+// does not match and the zero time is used; empty values of Nullable columns are NULL
+func parseDate(value, dataType, layout string) (interface{}, bool) {
+	if value == "" && strings.HasPrefix(dataType, "Nullable(") {
+		return nil, true
+	}
+	t, err := time.Parse(layout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+var (
+	createTableRe = regexp.MustCompile(`^\s*CREATE\s+TABLE\s+(IF\s+NOT\s+EXISTS\s+)?\S+`)
+	engineRe      = regexp.MustCompile(`ENGINE\s*=\s*\w+(\([^)]*\))?`)
+)
+
+// rewriteDDL adapts a source CREATE TABLE statement for replay on the target:
+// it renames the table, makes creation idempotent and applies optional engine and cluster rewrites
+func rewriteDDL(ddl, targetTable string, rewrite *model.DDLRewrite) (string, error) {
+	if !createTableRe.MatchString(ddl) {
+		return "", fmt.Errorf("only CREATE TABLE statements can be replayed")
+	}
+
+	header := "CREATE TABLE IF NOT EXISTS " + targetTable
+	if rewrite != nil && rewrite.Cluster != "" {
+		header += " ON CLUSTER " + rewrite.Cluster
+	}
+	ddl = createTableRe.ReplaceAllLiteralString(ddl, header)
+
+	if rewrite != nil && rewrite.Engine != "" {
+		if !engineRe.MatchString(ddl) {
+			return "", fmt.Errorf("source DDL has no ENGINE clause to rewrite")
+		}
+		// The first ENGINE clause is the table's own
+		loc := engineRe.FindStringIndex(ddl)
+		ddl = ddl[:loc[0]] + "ENGINE = " + rewrite.Engine + ddl[loc[1]:]
+	}
+
+	return strings.TrimSpace(ddl), nil
+}
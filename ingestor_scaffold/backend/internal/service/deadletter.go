@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ingestor/internal/config"
+)
+
+// RejectReasonColumn is the extra column of a dead-letter file naming why each row was rejected
+const RejectReasonColumn = "_reject_reason"
+
+type deadLetterKey struct{}
+
+// DeadLetterWriter collects rows rejected while reading a flat file into a CSV with the
+// source's header and delimiter plus RejectReasonColumn, so the file can be corrected
+// and re-ingested with the original mapping. The file is only created once a row is rejected.
+type DeadLetterWriter struct {
+	mu     sync.Mutex
+	path   string
+	header []string
+	delim  rune
+	file   *os.File
+	w      *csv.Writer
+	rows   int
+	err    error
+}
+
+// NewDeadLetterWriter creates a writer for the dead-letter file at path
+func NewDeadLetterWriter(path string) *DeadLetterWriter {
+	return &DeadLetterWriter{path: path, delim: ','}
+}
+
+// NewJobDeadLetter returns the dead-letter writer for a job, or nil when no
+// dead-letter directory is configured
+func NewJobDeadLetter(cfg *config.Config, jobID string) *DeadLetterWriter {
+	if cfg.DeadLetterDir == "" {
+		return nil
+	}
+	return NewDeadLetterWriter(filepath.Join(cfg.DeadLetterDir, jobID+".csv"))
+}
+
+// WithDeadLetter returns a context carrying the given dead-letter writer
+func WithDeadLetter(ctx context.Context, d *DeadLetterWriter) context.Context {
+	return context.WithValue(ctx, deadLetterKey{}, d)
+}
+
+// DeadLetterFromContext returns the dead-letter writer carried by ctx, or nil
+func DeadLetterFromContext(ctx context.Context) *DeadLetterWriter {
+	d, _ := ctx.Value(deadLetterKey{}).(*DeadLetterWriter)
+	return d
+}
+
+// SetLayout records the header and delimiter of the source file
+func (d *DeadLetterWriter) SetLayout(header []string, delim rune) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.header = append([]string(nil), header...)
+	d.delim = delim
+}
+
+// Write appends a rejected record with its reason. Records are padded or cut to the
+// header width so the dead-letter file itself always parses.
+func (d *DeadLetterWriter) Write(record []string, reason string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err != nil {
+		return
+	}
+	if d.w == nil {
+		if d.err = d.openLocked(); d.err != nil {
+			return
+		}
+	}
+
+	row := make([]string, len(d.header)+1)
+	copy(row, record)
+	row[len(d.header)] = reason
+	if d.err = d.w.Write(row); d.err == nil {
+		d.rows++
+	}
+}
+
+// WriteValues appends a rejected row given as values of the named columns, placed
+// under the matching header columns. Without a source layout the columns become
+// the header.
+func (d *DeadLetterWriter) WriteValues(columns []string, values []interface{}, reason string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	if len(d.header) == 0 && d.w == nil {
+		d.header = append([]string(nil), columns...)
+	}
+	position := make(map[string]int, len(d.header))
+	for i, name := range d.header {
+		position[name] = i
+	}
+	record := make([]string, len(d.header))
+	d.mu.Unlock()
+
+	for i, name := range columns {
+		idx, ok := position[name]
+		if !ok || i >= len(values) {
+			continue
+		}
+		if value := derefValue(values[i]); value != nil {
+			record[idx] = fmt.Sprintf("%v", value)
+		}
+	}
+	d.Write(record, reason)
+}
+
+// openLocked creates the file and writes the header
+func (d *DeadLetterWriter) openLocked() error {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	file, err := os.Create(d.path)
+	if err != nil {
+		return fmt.Errorf("failed to create dead-letter file: %w", err)
+	}
+
+	d.file = file
+	d.w = csv.NewWriter(file)
+	d.w.Comma = d.delim
+	return d.w.Write(append(append([]string(nil), d.header...), RejectReasonColumn))
+}
+
+// Close flushes and closes the file, returning the first write error
+func (d *DeadLetterWriter) Close() error {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.w == nil {
+		return d.err
+	}
+	d.w.Flush()
+	if err := d.w.Error(); err != nil && d.err == nil {
+		d.err = err
+	}
+	if err := d.file.Close(); err != nil && d.err == nil {
+		d.err = err
+	}
+	d.w = nil
+	return d.err
+}
+
+// Path returns the dead-letter file path, or "" when no row was rejected
+func (d *DeadLetterWriter) Path() string {
+	if d == nil {
+		return ""
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.rows == 0 {
+		return ""
+	}
+	return d.path
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Deduplicator drops rows whose key was already seen within a job.
+// It remembers at most maxKeys keys, evicting the oldest first.
+type Deduplicator struct {
+	mu         sync.Mutex
+	seen       map[uint64]struct{}
+	order      []uint64
+	next       int
+	maxKeys    int
+	duplicates int
+}
+
+// NewDeduplicator creates a deduplicator bounded to maxKeys keys
+func NewDeduplicator(maxKeys int) *Deduplicator {
+	if maxKeys <= 0 {
+		maxKeys = 1
+	}
+	return &Deduplicator{
+		seen:    make(map[uint64]struct{}),
+		order:   make([]uint64, 0, maxKeys),
+		maxKeys: maxKeys,
+	}
+}
+
+// IsDuplicate records the key values and reports whether they were already seen
+func (d *Deduplicator) IsDuplicate(values []interface{}) bool {
+	h := fnv.New64a()
+	for _, v := range values {
+		fmt.Fprintf(h, "%v\x1f", v)
+	}
+	key := h.Sum64()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.seen[key]; ok {
+		d.duplicates++
+		return true
+	}
+
+	// Evict the oldest key once the set is full
+	if len(d.order) < d.maxKeys {
+		d.order = append(d.order, key)
+	} else {
+		delete(d.seen, d.order[d.next])
+		d.order[d.next] = key
+		d.next = (d.next + 1) % d.maxKeys
+	}
+	d.seen[key] = struct{}{}
+	return false
+}
+
+// Duplicates returns the number of duplicate rows dropped so far
+func (d *Deduplicator) Duplicates() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.duplicates
+}
+
+// dedupKeyIndexes resolves dedup key column names to positions in columns
+func dedupKeyIndexes(keys []string, columns []string) ([]int, error) {
+	positions := make(map[string]int, len(columns))
+	for i, name := range columns {
+		positions[name] = i
+	}
+
+	indexes := make([]int, len(keys))
+	for i, key := range keys {
+		idx, ok := positions[key]
+		if !ok {
+			return nil, fmt.Errorf("dedup key column %s is not selected", key)
+		}
+		indexes[i] = idx
+	}
+	return indexes, nil
+}
+
+// dedupRows forwards rows from in, dropping rows whose key columns repeat
+func (s *IngestServiceImpl) dedupRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	keyIndexes []int,
+	dedup *Deduplicator,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	warnings := WarningsFromContext(ctx)
+
+	go func() {
+		defer close(out)
+
+		key := make([]interface{}, len(keyIndexes))
+		for row := range in {
+			for i, idx := range keyIndexes {
+				key[i] = row[idx]
+			}
+			if dedup.IsDuplicate(key) {
+				warnings.Count("duplicate rows dropped", 1)
+				continue
+			}
+
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
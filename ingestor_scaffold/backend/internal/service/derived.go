@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/ingestor/internal/model"
+)
+
+// derivedColumn is a derived or defaulted column with its compiled expression; a
+// column without one takes the constant value
+type derivedColumn struct {
+	column  model.Column
+	program *vm.Program
+	value   interface{}
+}
+
+// exprOptions configure the expression language of derived columns; concat joins
+// its arguments as strings instead of concatenating arrays
+var exprOptions = []expr.Option{
+	expr.DisableBuiltin("concat"),
+	expr.Function("concat", func(params ...interface{}) (interface{}, error) {
+		var b strings.Builder
+		for _, param := range params {
+			if param != nil {
+				fmt.Fprint(&b, param)
+			}
+		}
+		return b.String(), nil
+	}),
+}
+
+// compileDerivedColumns compiles the expressions of derived columns against the
+// source columns, which they reference by their source names
+func compileDerivedColumns(derived []model.DerivedColumn, columns []model.Column, renames map[string]string) ([]derivedColumn, error) {
+	if len(derived) == 0 {
+		return nil, nil
+	}
+
+	options := expressionOptions(columns)
+	compiled := make([]derivedColumn, 0, len(derived))
+	for _, d := range derived {
+		if d.Name == "" || d.Type == "" || d.Expression == "" {
+			return nil, fmt.Errorf("derived columns need a name, type and expression")
+		}
+		if err := validateColumnType(d.Type); err != nil {
+			return nil, fmt.Errorf("derived column %s: %w", d.Name, err)
+		}
+		if containsColumn(columns, d.Name) || containsColumn(renameColumns(columns, renames), d.Name) {
+			return nil, fmt.Errorf("derived column %s is also a source column", d.Name)
+		}
+		program, err := expr.Compile(d.Expression, options...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression for derived column %s: %w", d.Name, err)
+		}
+		compiled = append(compiled, derivedColumn{
+			column:  model.Column{Name: d.Name, Type: d.Type},
+			program: program,
+		})
+	}
+	return compiled, nil
+}
+
+// compileColumnDefaults compiles the defaults of target table columns missing from
+// the source, given the differences between the loaded columns and the table. The
+// remaining table-only columns are reported as left to the table's defaults.
+func compileColumnDefaults(ctx context.Context, defaults []model.ColumnDefault, columns []model.Column, diff []model.SchemaDifference) ([]derivedColumn, error) {
+	tableOnly := make(map[string]string)
+	for _, d := range diff {
+		if d.Kind == "extra" {
+			tableOnly[d.Column] = d.TargetType
+		}
+	}
+
+	var options []expr.Option
+	compiled := make([]derivedColumn, 0, len(defaults))
+	for _, d := range defaults {
+		chType, ok := tableOnly[d.Column]
+		if !ok {
+			return nil, fmt.Errorf("default for %s: not a column of the existing target table missing from the source", d.Column)
+		}
+		delete(tableOnly, d.Column)
+
+		column := derivedColumn{column: model.Column{Name: d.Column, Type: chType}}
+		switch {
+		case d.Expression != "":
+			if options == nil {
+				options = expressionOptions(columns)
+			}
+			program, err := expr.Compile(d.Expression, options...)
+			if err != nil {
+				return nil, fmt.Errorf("invalid default expression for %s: %w", d.Column, err)
+			}
+			column.program = program
+		case d.Value != nil:
+			value, err := coerceValue(d.Value, chType)
+			if err != nil {
+				return nil, fmt.Errorf("invalid default for %s: %w", d.Column, err)
+			}
+			column.value = value
+		default:
+			return nil, fmt.Errorf("default for %s needs a value or expression", d.Column)
+		}
+		compiled = append(compiled, column)
+	}
+
+	warnings := WarningsFromContext(ctx)
+	for name, chType := range tableOnly {
+		warnings.Add("column %s %s is not in the source and gets the table default; set columnDefaults to fill it", name, chType)
+	}
+	return compiled, nil
+}
+
+// expressionOptions declare the source columns as the variables of expressions
+func expressionOptions(columns []model.Column) []expr.Option {
+	env := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		env[col.Name] = nil
+	}
+	return append([]expr.Option{expr.Env(env)}, exprOptions...)
+}
+
+// derivedTargetColumns returns the target columns of derived columns
+func derivedTargetColumns(derived []derivedColumn) []model.Column {
+	columns := make([]model.Column, len(derived))
+	for i, d := range derived {
+		columns[i] = d.column
+	}
+	return columns
+}
+
+// deriveRows appends the values of derived or defaulted columns to each row, whose
+// leading values are the source columns; rows whose expressions fail or return a
+// value of the wrong type are dropped and counted
+func (s *IngestServiceImpl) deriveRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	columns []model.Column,
+	derived []derivedColumn,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	warnings := WarningsFromContext(ctx)
+	rowErrors := RowErrorsFromContext(ctx)
+
+	go func() {
+		defer close(out)
+
+		env := make(map[string]interface{}, len(columns))
+	rows:
+		for row := range in {
+			// Drain the rows once the error policy stops the job
+			if rowErrors.Err() != nil {
+				continue
+			}
+			for i, col := range columns {
+				env[col.Name] = row[i]
+			}
+
+			for _, d := range derived {
+				value := d.value
+				if d.program != nil {
+					var err error
+					value, err = expr.Run(d.program, env)
+					if err == nil {
+						value, err = coerceValue(value, d.column.Type)
+					}
+					if err != nil {
+						warnings.Count(fmt.Sprintf("rows skipped (column %s could not be computed)", d.column.Name), 1)
+						rowErrors.Record(0, fmt.Sprintf("column %s could not be computed: %v", d.column.Name, err), rowStrings(row))
+						continue rows
+					}
+				}
+				row = append(row, value)
+			}
+
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// coerceValue converts an expression result to the value inserted into a column
+// of a ClickHouse type
+func coerceValue(value interface{}, chType string) (interface{}, error) {
+	if value == nil {
+		if strings.HasPrefix(chType, "Nullable(") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("null for %s", chType)
+	}
+
+	text, isText := value.(string)
+	switch t := baseType(chType); {
+	case strings.HasPrefix(t, "Int") || strings.HasPrefix(t, "UInt"):
+		if isText {
+			return parseInteger(strings.TrimSpace(text), t)
+		}
+		if v, ok := toInt64(value); ok {
+			return parseInteger(strconv.FormatInt(v, 10), t)
+		}
+		if v, ok := value.(uint64); ok {
+			return parseInteger(strconv.FormatUint(v, 10), t)
+		}
+		if v, ok := toFloat64(value); ok {
+			return parseInteger(strconv.FormatFloat(math.Round(v), 'f', -1, 64), t)
+		}
+	case strings.HasPrefix(t, "Float"):
+		if isText {
+			return strconv.ParseFloat(strings.TrimSpace(text), 64)
+		}
+		if v, ok := toFloat64(value); ok {
+			return v, nil
+		}
+	case t == "Bool":
+		if isText {
+			return strconv.ParseBool(text)
+		}
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case strings.HasPrefix(t, "Date"):
+		if v, ok := value.(time.Time); ok {
+			return v, nil
+		}
+		if isText {
+			for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339} {
+				if v, err := time.Parse(layout, text); err == nil {
+					return v, nil
+				}
+			}
+		}
+	default:
+		return fmt.Sprint(value), nil
+	}
+	return nil, fmt.Errorf("%v is not a %s", value, chType)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/model"
+)
+
+// Diagnose reports the server version, timezone, databases, grants and round-trip
+// latency of the connection. Listing databases and grants is best effort; what
+// could not be read is noted in the warnings.
+func (s *ClickHouseServiceImpl) Diagnose(ctx context.Context) (model.ConnectionDiagnostics, error) {
+	var diagnostics model.ConnectionDiagnostics
+	if s.conn == nil {
+		return diagnostics, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	// Round trip of the cheapest query
+	start := time.Now()
+	if err := s.conn.Ping(ctx); err != nil {
+		return diagnostics, fmt.Errorf("failed to ping ClickHouse: %w", err)
+	}
+	diagnostics.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+
+	row := s.conn.QueryRow(queryContext(ctx), "SELECT version(), timezone(), currentUser(), currentDatabase()")
+	if err := row.Scan(&diagnostics.ServerVersion, &diagnostics.Timezone, &diagnostics.User, &diagnostics.Database); err != nil {
+		return diagnostics, fmt.Errorf("failed to read server info: %w", err)
+	}
+
+	var err error
+	if diagnostics.Databases, err = s.queryStrings(ctx, "SHOW DATABASES"); err != nil {
+		diagnostics.Warnings = append(diagnostics.Warnings, "cannot list databases: "+err.Error())
+	}
+	if diagnostics.Grants, err = s.queryStrings(ctx, "SHOW GRANTS"); err != nil {
+		diagnostics.Warnings = append(diagnostics.Warnings, "cannot read grants: "+err.Error())
+	}
+	return diagnostics, nil
+}
+
+// queryStrings runs a query returning one string column
+func (s *ClickHouseServiceImpl) queryStrings(ctx context.Context, query string) ([]string, error) {
+	rows, err := s.conn.Query(queryContext(ctx), query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// ConnectionHint suggests the likely misconfiguration behind a failed connection
+func ConnectionHint(err error, params model.ClickHouseConnectionParams) string {
+	if err == nil {
+		return ""
+	}
+	msg := strings.ToLower(err.Error())
+
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		switch exception.Code {
+		case 516:
+			return "Authentication failed: check the user and its password or token"
+		case 81:
+			return fmt.Sprintf("Database %q does not exist or the user cannot see it", params.Database)
+		case 497:
+			return "The user lacks a grant needed for this operation"
+		}
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("Host %q does not resolve: check the host name", dnsErr.Name)
+	case strings.Contains(msg, "connection refused"):
+		return fmt.Sprintf("Nothing is listening on port %d: the native protocol uses 9000, or 9440 with TLS", params.Port)
+	case strings.Contains(msg, "first record does not look like a tls handshake"):
+		return "The port does not speak TLS: disable secure or use the server's TLS port (usually 9440)"
+	case strings.Contains(msg, "certificate"):
+		return "The server certificate could not be verified: provide caCert or set tlsServerName to a name it is issued for"
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded"):
+		return "The connection timed out: check the host, port, firewall and proxy"
+	case strings.Contains(msg, "unexpected packet") || (strings.Contains(msg, "eof") && (params.Port == 8123 || params.Port == 8443)):
+		return "The port looks like ClickHouse's HTTP interface; connect to the native protocol port (9000, or 9440 with TLS)"
+	case strings.Contains(msg, "eof") && !params.Secure:
+		return "The server closed the connection: it may require TLS on this port"
+	}
+	return ""
+}
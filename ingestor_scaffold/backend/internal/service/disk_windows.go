@@ -0,0 +1,6 @@
+package service
+
+// freeDiskBytes is not implemented on Windows; the free disk check is skipped
+func freeDiskBytes(dir string) (uint64, bool) {
+	return 0, false
+}
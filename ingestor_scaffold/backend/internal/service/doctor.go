@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Doctor check statuses
+const (
+	DoctorOK   = "ok"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+	DoctorSkip = "skip"
+)
+
+// DoctorCheckFunc runs one self-check
+type DoctorCheckFunc func(ctx context.Context) model.DoctorCheck
+
+// Doctor validates the effective configuration before users rely on it: the state
+// directory and its key, the ClickHouse connections persisted there, the dead-letter
+// and allowed file directories, the pipelines and quotas files and the notification
+// channels, followed by any extra checks. notify sends a test Slack message instead
+// of only reaching the webhook.
+func Doctor(ctx context.Context, cfg *config.Config, logger *logrus.Logger, notify bool, extra ...DoctorCheckFunc) model.DoctorReport {
+	var sessions []persistedSession
+	checks := []DoctorCheckFunc{
+		func(ctx context.Context) model.DoctorCheck {
+			check, stored := checkStateDir(cfg)
+			sessions = stored
+			return check
+		},
+		func(ctx context.Context) model.DoctorCheck { return checkSessions(ctx, cfg, logger, sessions) },
+		func(ctx context.Context) model.DoctorCheck { return checkDeadLetterDir(cfg) },
+		func(ctx context.Context) model.DoctorCheck { return checkFileRoots(cfg) },
+		func(ctx context.Context) model.DoctorCheck { return checkPipelinesFile(cfg) },
+		func(ctx context.Context) model.DoctorCheck { return checkQuotasFile(cfg, logger) },
+		func(ctx context.Context) model.DoctorCheck { return checkSlack(ctx, cfg, logger, notify) },
+		func(ctx context.Context) model.DoctorCheck { return checkSMTP(cfg) },
+	}
+
+	report := model.DoctorReport{Ready: true, CheckedAt: time.Now()}
+	for _, check := range append(checks, extra...) {
+		result := check(ctx)
+		if result.Status == DoctorFail {
+			report.Ready = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// checkStateDir opens the state store, proves the directory writable and the
+// encryption key able to read the persisted sessions, which it returns
+func checkStateDir(cfg *config.Config) (model.DoctorCheck, []persistedSession) {
+	check := model.DoctorCheck{Name: "state_dir"}
+	if cfg.StateDir == "" {
+		check.Status, check.Message = DoctorSkip, "STATE_DIR is not set; sessions, jobs and schedules are lost on restart"
+		return check, nil
+	}
+
+	store, err := NewStateStore(cfg.StateDir, cfg.StateEncryptionKey)
+	if err != nil {
+		check.Status, check.Message = DoctorFail, err.Error()
+		return check, nil
+	}
+	if err := checkWritable(cfg.StateDir); err != nil {
+		check.Status, check.Message = DoctorFail, err.Error()
+		return check, nil
+	}
+	var sessions []persistedSession
+	if _, err := store.Load(stateSessionsFile, &sessions); err != nil {
+		check.Status, check.Message = DoctorFail, fmt.Sprintf("persisted state cannot be read, check STATE_ENCRYPTION_KEY: %v", err)
+		return check, nil
+	}
+
+	check.Status, check.Message = DoctorOK, fmt.Sprintf("%s is writable", cfg.StateDir)
+	if !store.Encrypted() {
+		check.Status, check.Message = DoctorWarn, check.Message+"; without STATE_ENCRYPTION_KEY credentials are not persisted"
+	}
+	return check, sessions
+}
+
+// checkSessions connects to every persisted ClickHouse connection, the only
+// connections configured server-side
+func checkSessions(ctx context.Context, cfg *config.Config, logger *logrus.Logger, sessions []persistedSession) model.DoctorCheck {
+	check := model.DoctorCheck{Name: "clickhouse"}
+	if len(sessions) == 0 {
+		check.Status, check.Message = DoctorSkip, "no persisted ClickHouse sessions; clients connect through /clickhouse/connect"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.ClickHouseDialTimeout)
+	defer cancel()
+
+	diagnoser := NewSessionService(nil, cfg, logger)
+	var failures []string
+	for _, session := range sessions {
+		diagnostics := diagnoser.Diagnose(ctx, session.Params)
+		if !diagnostics.Connected {
+			failures = append(failures, fmt.Sprintf("%s (session %s): %s", hostPort(session.Params.Host, session.Params.Port), session.ID, diagnostics.Error))
+		}
+	}
+	if len(failures) > 0 {
+		check.Status, check.Message = DoctorFail, "unreachable: "+strings.Join(failures, "; ")
+		return check
+	}
+	check.Status, check.Message = DoctorOK, fmt.Sprintf("%d persisted sessions reachable", len(sessions))
+	return check
+}
+
+// checkDeadLetterDir checks that rejected rows can be written
+func checkDeadLetterDir(cfg *config.Config) model.DoctorCheck {
+	check := model.DoctorCheck{Name: "dead_letter_dir"}
+	if cfg.DeadLetterDir == "" {
+		check.Status, check.Message = DoctorSkip, "DEAD_LETTER_DIR is not set; rejected rows are only counted"
+		return check
+	}
+	if err := checkWritable(cfg.DeadLetterDir); err != nil {
+		check.Status, check.Message = DoctorFail, err.Error()
+		return check
+	}
+	check.Status, check.Message = DoctorOK, fmt.Sprintf("%s is writable", cfg.DeadLetterDir)
+	return check
+}
+
+// checkFileRoots checks that every allowed file root exists; roots that cannot be
+// written only serve as sources
+func checkFileRoots(cfg *config.Config) model.DoctorCheck {
+	check := model.DoctorCheck{Name: "allowed_file_roots"}
+	var roots []string
+	for _, root := range strings.Split(cfg.AllowedFileRoots, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			roots = append(roots, root)
+		}
+	}
+	if len(roots) == 0 {
+		check.Status, check.Message = DoctorWarn, "ALLOWED_FILE_ROOTS is not set; any path on the server can be read and written"
+		return check
+	}
+
+	var missing, readOnly []string
+	for _, root := range roots {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			missing = append(missing, root)
+		} else if err := checkWritable(root); err != nil {
+			readOnly = append(readOnly, root)
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		check.Status, check.Message = DoctorFail, "not a directory: "+strings.Join(missing, ", ")
+	case len(readOnly) > 0:
+		check.Status, check.Message = DoctorWarn, "not writable, exports there will fail: "+strings.Join(readOnly, ", ")
+	default:
+		check.Status, check.Message = DoctorOK, fmt.Sprintf("%d roots exist and are writable", len(roots))
+	}
+	return check
+}
+
+// checkWritable creates and removes a file in dir
+func checkWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkPipelinesFile parses and validates the pipelines file
+func checkPipelinesFile(cfg *config.Config) model.DoctorCheck {
+	check := model.DoctorCheck{Name: "pipelines_file"}
+	if cfg.PipelinesFile == "" || !cfg.FeatureEnabled(config.FeaturePipelines) {
+		check.Status = DoctorSkip
+		return check
+	}
+	pipelines, err := ReadPipelinesFile(cfg.PipelinesFile)
+	if err != nil {
+		check.Status, check.Message = DoctorFail, err.Error()
+		return check
+	}
+	check.Status, check.Message = DoctorOK, fmt.Sprintf("%d pipelines", len(pipelines))
+	return check
+}
+
+// checkQuotasFile parses and validates the quotas file
+func checkQuotasFile(cfg *config.Config, logger *logrus.Logger) model.DoctorCheck {
+	check := model.DoctorCheck{Name: "quotas_file"}
+	if cfg.QuotasFile == "" {
+		check.Status = DoctorSkip
+		return check
+	}
+	if err := NewQuotaService(nil, cfg, logger).Load(cfg.QuotasFile); err != nil {
+		check.Status, check.Message = DoctorFail, err.Error()
+		return check
+	}
+	check.Status = DoctorOK
+	return check
+}
+
+// checkSlack reaches the Slack webhook or, with notify, posts a test message to it
+func checkSlack(ctx context.Context, cfg *config.Config, logger *logrus.Logger, notify bool) model.DoctorCheck {
+	check := model.DoctorCheck{Name: "slack"}
+	if cfg.SlackWebhookURL == "" || !cfg.FeatureEnabled(config.FeatureNotifications) {
+		check.Status = DoctorSkip
+		return check
+	}
+
+	if notify {
+		if err := NewNotificationService(cfg, logger).SendSlack(ctx, "Ingestor doctor: test notification"); err != nil {
+			check.Status, check.Message = DoctorFail, err.Error()
+			return check
+		}
+		check.Status, check.Message = DoctorOK, "test message sent"
+		return check
+	}
+
+	// Any HTTP answer proves the webhook reachable through the proxy, if any
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, cfg.SlackWebhookURL, nil)
+	if err != nil {
+		check.Status, check.Message = DoctorFail, fmt.Sprintf("invalid SLACK_WEBHOOK_URL: %v", err)
+		return check
+	}
+	resp, err := NewHTTPClient(cfg.OutboundProxy, 10*time.Second).Do(req)
+	if err != nil {
+		check.Status, check.Message = DoctorFail, fmt.Sprintf("webhook unreachable: %v", err)
+		return check
+	}
+	resp.Body.Close()
+	check.Status, check.Message = DoctorOK, "webhook reachable"
+	return check
+}
+
+// checkSMTP connects to the mail server and, with SMTP_USER set, authenticates
+func checkSMTP(cfg *config.Config) model.DoctorCheck {
+	check := model.DoctorCheck{Name: "smtp"}
+	if cfg.SMTPHost == "" || !cfg.FeatureEnabled(config.FeatureNotifications) {
+		check.Status = DoctorSkip
+		return check
+	}
+	if cfg.SMTPFrom == "" {
+		check.Status, check.Message = DoctorFail, "SMTP_FROM is not set"
+		return check
+	}
+
+	client, err := smtp.Dial(hostPort(cfg.SMTPHost, cfg.SMTPPort))
+	if err != nil {
+		check.Status, check.Message = DoctorFail, fmt.Sprintf("mail server unreachable: %v", err)
+		return check
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.SMTPHost}); err != nil {
+			check.Status, check.Message = DoctorFail, fmt.Sprintf("STARTTLS failed: %v", err)
+			return check
+		}
+	}
+	if cfg.SMTPUser != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)); err != nil {
+			check.Status, check.Message = DoctorFail, fmt.Sprintf("authentication failed: %v", err)
+			return check
+		}
+	}
+	client.Quit()
+	check.Status, check.Message = DoctorOK, "mail server reachable"
+	return check
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ingestor/internal/model"
+)
+
+// tableEngine describes a table engine the ingestor may create
+type tableEngine struct {
+	minArgs, maxArgs int  // column arguments; maxArgs -1 takes any number
+	ordered          bool // MergeTree family, created with an ORDER BY clause
+	selectable       bool // may be chosen for append loads
+}
+
+// tableEngines are the engines of created tables: those selectable for append
+// loads and the ones upsert and cdc modes pick
+var tableEngines = map[string]tableEngine{
+	"MergeTree":                    {maxArgs: 0, ordered: true, selectable: true},
+	"ReplacingMergeTree":           {maxArgs: 2, ordered: true, selectable: true},
+	"SummingMergeTree":             {maxArgs: -1, ordered: true, selectable: true},
+	"Log":                          {maxArgs: 0, selectable: true},
+	"CollapsingMergeTree":          {minArgs: 1, maxArgs: 1, ordered: true},
+	"VersionedCollapsingMergeTree": {minArgs: 2, maxArgs: 2, ordered: true},
+}
+
+// lookupTableEngine validates table options against their engine, MergeTree by default
+func lookupTableEngine(opts model.TableOptions) (string, tableEngine, error) {
+	name := opts.Engine
+	if name == "" {
+		name = "MergeTree"
+	}
+	engine, ok := tableEngines[name]
+	if !ok {
+		return "", tableEngine{}, fmt.Errorf("unsupported table engine %q", name)
+	}
+	if len(opts.EngineArgs) < engine.minArgs || engine.maxArgs >= 0 && len(opts.EngineArgs) > engine.maxArgs {
+		return "", tableEngine{}, fmt.Errorf("%s takes %d to %d engine arguments, got %d", name, engine.minArgs, engine.maxArgs, len(opts.EngineArgs))
+	}
+	if !engine.ordered && (len(opts.OrderBy) > 0 || len(opts.PrimaryKey) > 0 || opts.PartitionBy != "") {
+		return "", tableEngine{}, fmt.Errorf("%s tables have no sorting, primary or partition key", name)
+	}
+	if !engine.ordered && (opts.TTL != nil || len(opts.ColumnTTLs) > 0) {
+		return "", tableEngine{}, fmt.Errorf("%s tables do not support TTL", name)
+	}
+	if err := checkTableKeys(opts); err != nil {
+		return "", tableEngine{}, err
+	}
+	if err := checkTTLs(opts); err != nil {
+		return "", tableEngine{}, err
+	}
+	if err := checkColumnStorage(opts.ColumnStorage); err != nil {
+		return "", tableEngine{}, err
+	}
+	return name, engine, nil
+}
+
+// appendTableOptions returns the engine chosen for an append load: MergeTree,
+// ReplacingMergeTree, SummingMergeTree or Log, with column arguments
+func appendTableOptions(params model.IngestionParams) (model.TableOptions, error) {
+	opts := model.TableOptions{Engine: params.Engine, EngineArgs: params.EngineArgs}
+	name, engine, err := lookupTableEngine(opts)
+	if err != nil {
+		return model.TableOptions{}, err
+	}
+	if !engine.selectable {
+		return model.TableOptions{}, fmt.Errorf("%s tables are created by cdc mode; choose MergeTree, ReplacingMergeTree, SummingMergeTree or Log", name)
+	}
+
+	// Arguments name ingested columns; discovered columns are checked on creation
+	if len(params.Columns) > 0 {
+		for _, arg := range opts.EngineArgs {
+			if !containsColumn(params.Columns, arg) {
+				return model.TableOptions{}, fmt.Errorf("engine argument %s is not a selected column", arg)
+			}
+		}
+	}
+	return opts, nil
+}
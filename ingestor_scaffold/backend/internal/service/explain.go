@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Explain returns ClickHouse's plan and read estimate for the query a ClickHouse
+// export would run, without running it. Joins whose condition does not relate the
+// joined table to an earlier one are flagged as likely cartesian products.
+func (s *IngestServiceImpl) Explain(ctx context.Context, params model.IngestionParams) (model.QueryPlan, error) {
+	if params.SourceType != "clickhouse" {
+		return model.QueryPlan{}, fmt.Errorf("only ClickHouse sources can be explained")
+	}
+
+	warnings := NewWarningCollector()
+	query, args, _, _, err := s.exportQuery(params, warnings)
+	if err != nil {
+		return model.QueryPlan{}, err
+	}
+
+	conn := s.clickhouse(ctx)
+	plan, err := conn.ExplainQuery(ctx, query, args)
+	if err != nil {
+		return model.QueryPlan{}, err
+	}
+
+	// Only MergeTree tables can be estimated; the plan stands without it
+	estimate, err := conn.EstimateQuery(ctx, query, args)
+	if err != nil {
+		warnings.Add("no read estimate: %s", err)
+	}
+
+	if params.Join != nil && params.Query == "" {
+		for _, warning := range crossJoinWarnings(*params.Join) {
+			warnings.Add("%s", warning)
+		}
+	}
+
+	return model.QueryPlan{
+		Query:    query,
+		Args:     args,
+		Plan:     plan,
+		Estimate: estimate,
+		Warnings: warnings.Snapshot(),
+	}, nil
+}
+
+// ExplainQuery returns the lines of EXPLAIN for a query
+func (s *ClickHouseServiceImpl) ExplainQuery(ctx context.Context, query string, args []interface{}) ([]string, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	rows, err := s.conn.Query(readOnlyContext(ctx), "EXPLAIN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return plan, nil
+}
+
+// EstimateQuery returns what EXPLAIN ESTIMATE expects a query to read from each table
+func (s *ClickHouseServiceImpl) EstimateQuery(ctx context.Context, query string, args []interface{}) ([]model.ReadEstimate, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	rows, err := s.conn.Query(readOnlyContext(ctx), "EXPLAIN ESTIMATE "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate query: %w", err)
+	}
+	defer rows.Close()
+
+	var estimate []model.ReadEstimate
+	for rows.Next() {
+		var e model.ReadEstimate
+		if err := rows.Scan(&e.Database, &e.Table, &e.Parts, &e.Rows, &e.Marks); err != nil {
+			return nil, fmt.Errorf("failed to scan estimate: %w", err)
+		}
+		estimate = append(estimate, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return estimate, nil
+}
+
+// crossJoinWarnings flags CROSS JOINs, which pair every row with every row of the
+// tables before them
+func crossJoinWarnings(join model.JoinParams) []string {
+	var warnings []string
+	for i, table := range join.Tables {
+		if i > 0 && strings.EqualFold(strings.Join(strings.Fields(table.JoinType), " "), "CROSS JOIN") {
+			warnings = append(warnings, fmt.Sprintf("%s is cross joined: every row pairs with every row of the tables before it", table.Name))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,13 @@
+package service
+
+import "fmt"
+
+// FeatureDisabledError is returned when work needs an optional feature that is
+// turned off in the configuration or the build
+type FeatureDisabledError struct {
+	Feature string
+}
+
+func (e *FeatureDisabledError) Error() string {
+	return fmt.Sprintf("the %s feature is disabled on this server", e.Feature)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// filterOperators maps accepted filter operators to their SQL spelling
+var filterOperators = map[string]string{
+	"=":           "=",
+	"!=":          "!=",
+	"<>":          "!=",
+	"<":           "<",
+	"<=":          "<=",
+	">":           ">",
+	">=":          ">=",
+	"LIKE":        "LIKE",
+	"NOT LIKE":    "NOT LIKE",
+	"ILIKE":       "ILIKE",
+	"IN":          "IN",
+	"NOT IN":      "NOT IN",
+	"IS NULL":     "IS NULL",
+	"IS NOT NULL": "IS NOT NULL",
+}
+
+// buildWhere renders filters as a WHERE clause, ANDed together, whose values are
+// bound as ? parameters. It returns "" and no arguments when there are no filters.
+func buildWhere(filters []model.Filter) (string, []interface{}, error) {
+	if len(filters) == 0 {
+		return "", nil, nil
+	}
+
+	conditions := make([]string, len(filters))
+	var args []interface{}
+	for i, filter := range filters {
+		column, err := QuoteIdentifier(filter.Column)
+		if err != nil {
+			return "", nil, fmt.Errorf("filter %d: %w", i+1, err)
+		}
+		if filter.Table != "" {
+			table, err := QuoteTable(filter.Table)
+			if err != nil {
+				return "", nil, fmt.Errorf("filter %d: %w", i+1, err)
+			}
+			column = table + "." + column
+		}
+
+		op, ok := filterOperators[strings.ToUpper(strings.Join(strings.Fields(filter.Operator), " "))]
+		if !ok {
+			return "", nil, fmt.Errorf("filter %d: unsupported operator %q", i+1, filter.Operator)
+		}
+
+		switch op {
+		case "IS NULL", "IS NOT NULL":
+			conditions[i] = column + " " + op
+		case "IN", "NOT IN":
+			values, ok := filter.Value.([]interface{})
+			if !ok || len(values) == 0 {
+				return "", nil, fmt.Errorf("filter %d: %s needs a non-empty list of values", i+1, op)
+			}
+			conditions[i] = column + " " + op + " (?)"
+			args = append(args, values)
+		default:
+			if filter.Value == nil {
+				return "", nil, fmt.Errorf("filter %d: %s needs a value", i+1, op)
+			}
+			if _, isList := filter.Value.([]interface{}); isList {
+				return "", nil, fmt.Errorf("filter %d: %s takes a single value", i+1, op)
+			}
+			conditions[i] = column + " " + op + " ?"
+			args = append(args, filter.Value)
+		}
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
@@ -3,7 +3,10 @@ package service
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -14,40 +17,134 @@ import (
 
 	"github.com/ingestor/internal/config"
 	"github.com/ingestor/internal/model"
+	"github.com/paulmach/orb"
 	"github.com/sirupsen/logrus"
 )
 
 // FlatFileService defines operations for flat files
 type FlatFileService interface {
-	DiscoverSchema(ctx context.Context, filePath, delimiter string) ([]model.Column, error)
-	PreviewData(ctx context.Context, filePath, delimiter string, columns []model.Column, limit int) ([]map[string]interface{}, error)
-	ReadData(ctx context.Context, filePath, delimiter string, columns []model.Column) (<-chan []interface{}, error)
-	WriteData(ctx context.Context, filePath, delimiter string, columns []model.Column, data <-chan map[string]interface{}, progressCh chan<- model.ProgressUpdate) (int, error)
+	DiscoverSchema(ctx context.Context, params model.FlatFileParams, progressCh chan<- model.ProgressUpdate) (model.FileSchema, error)
+	PreviewData(ctx context.Context, filePath, delimiter string, columns []model.Column, offset, limit int) ([]map[string]interface{}, error)
+	CountRows(ctx context.Context, filePath string, exact bool) (model.RowCount, error)
+	ChecksumFile(ctx context.Context, filePath, delimiter string, columns []string) (int64, map[string]float64, error)
+	SampleRows(ctx context.Context, params model.FlatFileParams, columns []model.Column, n int, seed int64) ([][]interface{}, error)
+	ProfileFile(ctx context.Context, filePath, delimiter string, columns []string, sampleRows, topValues int) (model.DataProfile, error)
+	OverrideSchema(ctx context.Context, req model.SchemaOverrideRequest) (model.SchemaOverrideResult, error)
+	ReadData(ctx context.Context, params model.FlatFileParams, columns []model.Column, errCh chan<- error) (<-chan []interface{}, error)
+	WriteData(ctx context.Context, params model.FlatFileParams, columns []model.Column, data <-chan map[string]interface{}, progressCh chan<- model.ProgressUpdate) (int, error)
+	WriteRows(ctx context.Context, params model.FlatFileParams, columns []model.Column, data <-chan []interface{}, progressCh chan<- model.ProgressUpdate) (int, error)
+	WriteStream(ctx context.Context, params model.FlatFileParams, write func(w io.Writer) (int, error)) (int, error)
+	CheckReadable(filePath string) error
+	CheckWritable(filePath string) error
+	ResolvePath(filePath string) (string, error)
 }
 
 // FlatFileServiceImpl implements FlatFileService
 type FlatFileServiceImpl struct {
-	config *config.Config
-	logger *logrus.Logger
+	schemaCache *SchemaCache
+	sandbox     *PathSandbox
+	boolTokens  map[string]bool
+	config      *config.Config
+	logger      *logrus.Logger
 }
 
 // NewFlatFileService creates a new flat file service
 func NewFlatFileService(config *config.Config, logger *logrus.Logger) FlatFileService {
 	return &FlatFileServiceImpl{
-		config: config,
-		logger: logger,
+		schemaCache: NewSchemaCache(config.SchemaCacheSize),
+		sandbox:     newFileSandbox(config),
+		boolTokens:  newBoolTokens(config),
+		config:      config,
+		logger:      logger,
 	}
 }
 
-// DiscoverSchema discovers the schema of a flat file
-func (s *FlatFileServiceImpl) DiscoverSchema(ctx context.Context, filePath, delimiter string) ([]model.Column, error) {
+// newBoolTokens maps the lower-cased BOOL_TRUE_TOKENS and BOOL_FALSE_TOKENS to
+// their values
+func newBoolTokens(config *config.Config) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, list := range []struct {
+		tokens string
+		value  bool
+	}{{config.BoolTrueTokens, true}, {config.BoolFalseTokens, false}} {
+		for _, token := range strings.Split(list.tokens, ",") {
+			if token = strings.ToLower(strings.TrimSpace(token)); token != "" {
+				tokens[token] = list.value
+			}
+		}
+	}
+	return tokens
+}
+
+// parseBool reads a configured boolean token
+func (s *FlatFileServiceImpl) parseBool(value string) (bool, bool) {
+	b, ok := s.boolTokens[strings.ToLower(strings.TrimSpace(value))]
+	return b, ok
+}
+
+// discoveryProgressInterval is how often schema discovery reports progress
+const discoveryProgressInterval = 500 * time.Millisecond
+
+// DiscoverSchema discovers the schema of a flat file, with the file's fingerprint
+// and the header names renamed to be unique. Unchanged files are served from the
+// schema cache unless params.RefreshSchema is set; schemas are cached per sample,
+// except random samples without a seed. When progressCh is not nil, bytes scanned
+// and rows sampled are reported on it.
+func (s *FlatFileServiceImpl) DiscoverSchema(ctx context.Context, params model.FlatFileParams, progressCh chan<- model.ProgressUpdate) (model.FileSchema, error) {
+	filePath, err := s.sandbox.Resolve(params.FilePath)
+	if err != nil {
+		return model.FileSchema{}, err
+	}
+	if err := s.checkSourceSize(filePath); err != nil {
+		return model.FileSchema{}, err
+	}
+	fingerprint, err := FileFingerprint(filePath, params.Delimiter)
+	if err != nil {
+		return model.FileSchema{}, err
+	}
+	sampler, err := newRowSampler(params)
+	if err != nil {
+		return model.FileSchema{}, err
+	}
+	suffix, cacheable := sampler.cacheKey()
+	cacheKey := fingerprint + suffix
+	if !params.RefreshSchema && cacheable {
+		if schema, ok := s.schemaCache.Get(cacheKey); ok {
+			s.logger.WithField("file", params.FilePath).Debug("Schema served from cache")
+			return schema, nil
+		}
+	}
+
+	schema, err := s.scanSchema(ctx, filePath, params.Delimiter, sampler, progressCh)
+	if err != nil {
+		return model.FileSchema{}, err
+	}
+	schema.Fingerprint = fingerprint
+	if cacheable {
+		s.schemaCache.Put(cacheKey, schema)
+	}
+	return schema, nil
+}
+
+// scanSchema infers column types from the header and the rows picked by sampler,
+// explaining each choice
+func (s *FlatFileServiceImpl) scanSchema(ctx context.Context, filePath, delimiter string, sampler *rowSampler, progressCh chan<- model.ProgressUpdate) (model.FileSchema, error) {
 	// Open file
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return model.FileSchema{}, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
+	var total int64
+	if info, err := file.Stat(); err == nil {
+		total = info.Size()
+	}
+	counters := ByteCountersFromContext(ctx)
+	if counters == nil {
+		counters = NewByteCounters()
+	}
+
 	// Create CSV reader
 	var delim rune = ','
 	if delimiter != "" {
@@ -56,7 +153,7 @@ func (s *FlatFileServiceImpl) DiscoverSchema(ctx context.Context, filePath, deli
 			delim = delims[0]
 		}
 	}
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(s.bufferedReader(&countingReader{r: file, add: counters.AddRead}))
 	reader.Comma = delim
 	reader.LazyQuotes = true
 	reader.TrimLeadingSpace = true
@@ -64,31 +161,33 @@ func (s *FlatFileServiceImpl) DiscoverSchema(ctx context.Context, filePath, deli
 	// Read header
 	header, err := reader.Read()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read header: %w", err)
+		return model.FileSchema{}, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	// Create columns with empty types
+	// Create columns with empty types, under unique names
+	names, renames := normalizeHeader(header)
 	columns := make([]model.Column, len(header))
-	for i, name := range header {
+	for i, name := range names {
 		columns[i] = model.Column{
 			Name: name,
 			Type: "",
 		}
 	}
 
-	// Read sample rows to infer types
-	sampleSize := 100
-	types := make([][]string, len(header))
+	// Count the types inferred for each column's values
+	types := make([]*typeSamples, len(header))
 	for i := range types {
-		types[i] = make([]string, 0, sampleSize)
+		types[i] = newTypeSamples()
 	}
 
-	// Read up to sampleSize rows
-	for i := 0; i < sampleSize; i++ {
+	// Read rows until the sample is complete
+	scanned := 0
+	lastReport := time.Now()
+	for !sampler.Done() {
 		// Check context for cancellation
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return model.FileSchema{}, ctx.Err()
 		default:
 		}
 
@@ -107,19 +206,45 @@ func (s *FlatFileServiceImpl) DiscoverSchema(ctx context.Context, filePath, deli
 		}
 
 		// Try to infer types
+		if sampler.Offer(record) {
+			for j, value := range record {
+				types[j].add(s.inferType(value), value)
+			}
+		}
+		scanned++
+
+		// Report progress periodically
+		if progressCh != nil && time.Since(lastReport) >= discoveryProgressInterval {
+			select {
+			case progressCh <- model.ProgressUpdate{
+				Status:     "processing",
+				Message:    fmt.Sprintf("Scanned %d rows", scanned),
+				Count:      scanned,
+				BytesRead:  counters.Read(),
+				BytesTotal: total,
+			}:
+				lastReport = time.Now()
+			case <-ctx.Done():
+				return model.FileSchema{}, ctx.Err()
+			}
+		}
+	}
+
+	for _, record := range sampler.Reservoir() {
 		for j, value := range record {
-			valueType := s.inferType(value)
-			types[j] = append(types[j], valueType)
+			types[j].add(s.inferType(value), value)
 		}
 	}
 
 	// Determine dominant type for each column
+	inference := make([]model.ColumnInference, len(types))
 	for i, colTypes := range types {
-		dominantType := s.getDominantType(colTypes)
+		dominantType := s.getDominantType(colTypes.counts)
 		columns[i].Type = dominantType
+		inference[i] = colTypes.inference(columns[i].Name, dominantType)
 	}
 
-	return columns, nil
+	return model.FileSchema{Columns: columns, Renames: renames, Inference: inference}, nil
 }
 
 // inferType infers the data type of a value
@@ -139,6 +264,11 @@ func (s *FlatFileServiceImpl) inferType(value string) string {
 		return "Float64"
 	}
 
+	// Boolean tokens such as yes/no; numeric ones like 1/0 infer as integers above
+	if _, ok := s.parseBool(value); ok {
+		return "Bool"
+	}
+
 	// Try common date formats
 	dateFormats := []string{
 		"2006-01-02",
@@ -160,18 +290,12 @@ func (s *FlatFileServiceImpl) inferType(value string) string {
 	return "String"
 }
 
-// getDominantType determines the dominant type from a list of types
-func (s *FlatFileServiceImpl) getDominantType(types []string) string {
-	if len(types) == 0 {
+// getDominantType determines the dominant type from the counts of inferred types
+func (s *FlatFileServiceImpl) getDominantType(counts map[string]int) string {
+	if len(counts) == 0 {
 		return "String" // Default to string if no samples
 	}
 
-	// Count occurrences of each type
-	counts := make(map[string]int)
-	for _, t := range types {
-		counts[t]++
-	}
-
 	// Find the most common type
 	maxCount := 0
 	dominantType := "String" // Default to string
@@ -195,13 +319,18 @@ func (s *FlatFileServiceImpl) getDominantType(types []string) string {
 	return dominantType
 }
 
-// PreviewData returns a preview of the data
+// PreviewData returns a page of a file's rows, skipping the first offset
 func (s *FlatFileServiceImpl) PreviewData(
 	ctx context.Context,
 	filePath, delimiter string,
 	columns []model.Column,
-	limit int,
+	offset, limit int,
 ) ([]map[string]interface{}, error) {
+	filePath, err := s.sandbox.Resolve(filePath)
+	if err != nil {
+		return nil, err
+	}
+
 	// Open file
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -217,7 +346,7 @@ func (s *FlatFileServiceImpl) PreviewData(
 			delim = delims[0]
 		}
 	}
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(s.bufferedReader(file))
 	reader.Comma = delim
 	reader.LazyQuotes = true
 	reader.TrimLeadingSpace = true
@@ -228,9 +357,10 @@ func (s *FlatFileServiceImpl) PreviewData(
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	// Create column name to index map
+	// Create column name to index map, under the names schema discovery reports
+	names, _ := normalizeHeader(header)
 	colNameToIndex := make(map[string]int)
-	for i, name := range header {
+	for i, name := range names {
 		colNameToIndex[name] = i
 	}
 
@@ -239,7 +369,7 @@ func (s *FlatFileServiceImpl) PreviewData(
 	if len(selectedColumns) == 0 {
 		// If no columns specified, use all
 		selectedColumns = make([]model.Column, len(header))
-		for i, name := range header {
+		for i, name := range names {
 			selectedColumns[i] = model.Column{
 				Name: name,
 				Type: "String", // Default type
@@ -247,6 +377,29 @@ func (s *FlatFileServiceImpl) PreviewData(
 		}
 	}
 
+	layouts, err := dateLayouts(selectedColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the rows of earlier pages without keeping them; they count as the rows
+	// previewed below do, so malformed rows are not counted
+	for skipped := 0; skipped < offset; {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			return []map[string]interface{}{}, nil
+		}
+		if err == nil && len(record) == len(header) {
+			skipped++
+		}
+	}
+
 	// Create result array
 	result := make([]map[string]interface{}, 0, limit)
 
@@ -275,7 +428,7 @@ func (s *FlatFileServiceImpl) PreviewData(
 
 		// Create row map
 		row := make(map[string]interface{})
-		for _, col := range selectedColumns {
+		for i, col := range selectedColumns {
 			idx, ok := colNameToIndex[col.Name]
 			if !ok || idx >= len(record) {
 				continue
@@ -283,7 +436,11 @@ func (s *FlatFileServiceImpl) PreviewData(
 
 			// Convert value based on type
 			value := record[idx]
-			row[col.Name] = s.convertValue(value, col.Type)
+			if layouts[i] != "" {
+				row[col.Name], _ = parseDate(value, col.Type, layouts[i])
+			} else {
+				row[col.Name] = s.convertValue(value, col.Type)
+			}
 		}
 
 		result = append(result, row)
@@ -292,12 +449,29 @@ func (s *FlatFileServiceImpl) PreviewData(
 	return result, nil
 }
 
-// ReadData reads data from a flat file and returns a channel of rows
+// ReadData reads data from a flat file and returns a channel of rows. The final read
+// error, if any, is sent on errCh once the stream ends; reading stops with
+// ErrSourceTooLarge past MaxSourceRows data rows.
 func (s *FlatFileServiceImpl) ReadData(
 	ctx context.Context,
-	filePath, delimiter string,
+	params model.FlatFileParams,
 	columns []model.Column,
+	errCh chan<- error,
 ) (<-chan []interface{}, error) {
+	filePath, err := s.sandbox.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSourceSize(filePath); err != nil {
+		return nil, err
+	}
+	delimiter := params.Delimiter
+	warnings := WarningsFromContext(ctx)
+	conv, err := s.newRecordConverter(params, columns, warnings)
+	if err != nil {
+		return nil, err
+	}
+
 	// Open file
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -312,7 +486,7 @@ func (s *FlatFileServiceImpl) ReadData(
 			delim = delims[0]
 		}
 	}
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(s.bufferedReader(&countingReader{r: file, add: ByteCountersFromContext(ctx).AddRead}))
 	reader.Comma = delim
 	reader.LazyQuotes = true
 	reader.TrimLeadingSpace = true
@@ -324,24 +498,46 @@ func (s *FlatFileServiceImpl) ReadData(
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	// Create column name to index map
-	colNameToIndex := make(map[string]int)
-	for i, name := range header {
-		colNameToIndex[name] = i
-	}
+	// Cells are parsed into fresh values, so the reader may reuse record slices
+	// unless parallel workers hold them
+	workers := parseWorkers(params, s.config)
+	reader.ReuseRecord = workers <= 1
+
+	// Find columns under the names schema discovery reports
+	names, _ := normalizeHeader(header)
+	conv.index(names)
 
 	// Create output channel
-	out := make(chan []interface{}, 100)
+	out := make(chan []interface{}, s.config.PipelineChannelSize)
+
+	// Rejected rows are counted and kept in the job's dead-letter file, if any,
+	// until the job's error policy stops it
+	deadLetter := DeadLetterFromContext(ctx)
+	deadLetter.SetLayout(header, delim)
+	rowErrors := RowErrorsFromContext(ctx)
+	reject := func(row int, record []string, reason string) error {
+		warnings.Count("rows skipped ("+reason+")", 1)
+		deadLetter.Write(record, reason)
+		return rowErrors.Record(row, reason, record)
+	}
+
+	// Rows are converted inline, or by parallel workers in chunks of records
+	pipeline := newParsePipeline(ctx, conv, reject, out, errCh, workers, s.config.ParseChunkRows, !params.UnorderedParse)
 
 	// Start goroutine to read data
 	go func() {
+		var readErr error
+		defer func() {
+			pipeline.finish(readErr)
+		}()
 		defer file.Close()
-		defer close(out)
 
+		rows := 0
 		for {
 			// Check context for cancellation
 			select {
 			case <-ctx.Done():
+				readErr = ctx.Err()
 				return
 			default:
 			}
@@ -350,34 +546,30 @@ func (s *FlatFileServiceImpl) ReadData(
 			if err == io.EOF {
 				break
 			}
+
+			// Refuse sources past the row limit rather than load them partly
+			if rows++; s.config.MaxSourceRows > 0 && rows > s.config.MaxSourceRows {
+				readErr = fmt.Errorf("%s has more than %d rows (MAX_SOURCE_ROWS): %w", params.FilePath, s.config.MaxSourceRows, ErrSourceTooLarge)
+				return
+			}
 			if err != nil {
 				s.logger.WithError(err).Warn("Error reading row, skipping")
+				if readErr = reject(rows, record, "malformed CSV"); readErr != nil {
+					return
+				}
 				continue
 			}
 
 			// Skip rows with different number of columns
 			if len(record) != len(header) {
-				continue
-			}
-
-			// Create row slice
-			row := make([]interface{}, len(columns))
-			for i, col := range columns {
-				idx, ok := colNameToIndex[col.Name]
-				if !ok || idx >= len(record) {
-					row[i] = nil
-					continue
+				if readErr = reject(rows, record, "column count does not match header"); readErr != nil {
+					return
 				}
-
-				// Convert value based on type
-				value := record[idx]
-				row[i] = s.convertValue(value, col.Type)
+				continue
 			}
 
-			// Send row to channel
-			select {
-			case out <- row:
-			case <-ctx.Done():
+			// Convert the row and send it on
+			if readErr = pipeline.add(rows, record); readErr != nil {
 				return
 			}
 		}
@@ -386,36 +578,153 @@ func (s *FlatFileServiceImpl) ReadData(
 	return out, nil
 }
 
-// WriteData writes data to a flat file
+// WriteData writes keyed rows to a flat file
 func (s *FlatFileServiceImpl) WriteData(
 	ctx context.Context,
-	filePath, delimiter string,
+	params model.FlatFileParams,
 	columns []model.Column,
 	data <-chan map[string]interface{},
 	progressCh chan<- model.ProgressUpdate,
 ) (int, error) {
+	return s.WriteRows(ctx, params, columns, rowSlices(ctx, data, columns), progressCh)
+}
+
+// WriteRows writes positional rows, ordered as columns, to a flat file
+func (s *FlatFileServiceImpl) WriteRows(
+	ctx context.Context,
+	params model.FlatFileParams,
+	columns []model.Column,
+	data <-chan []interface{},
+	progressCh chan<- model.ProgressUpdate,
+) (int, error) {
+	delimiter := params.Delimiter
+	if err := ValidateBinaryEncoding(params.BinaryEncoding); err != nil {
+		return 0, err
+	}
+	if err := ValidateGeoFormat(params.GeoFormat); err != nil {
+		return 0, err
+	}
+
+	// Create CSV writer
+	var delim rune = ','
+	if delimiter != "" {
+		delims := []rune(delimiter)
+		if len(delims) > 0 {
+			delim = delims[0]
+		}
+	}
+	return s.WriteStream(ctx, params, func(out io.Writer) (int, error) {
+		return s.writeRecords(ctx, params, columns, data, delim, out, progressCh)
+	})
+}
+
+// WriteStream writes a flat file with the content write produces, returning the
+// row count write reports. The file is written next to its path and renamed into
+// place, so readers never see a partial export, then synced and verified as
+// params request.
+func (s *FlatFileServiceImpl) WriteStream(
+	ctx context.Context,
+	params model.FlatFileParams,
+	write func(w io.Writer) (int, error),
+) (int, error) {
+	filePath, err := s.sandbox.Resolve(params.FilePath)
+	if err != nil {
+		return 0, err
+	}
+
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return 0, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Create file
-	file, err := os.Create(filePath)
+	// Write to a temporary file in the same directory so readers never see a partial export
+	file, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".*.tmp")
 	if err != nil {
 		return 0, fmt.Errorf("failed to create file: %w", err)
 	}
-	defer file.Close()
+	tmpPath := file.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			file.Close()
+			os.Remove(tmpPath)
+		}
+	}()
 
-	// Create CSV writer
-	var delim rune = ','
-	if delimiter != "" {
-		delims := []rune(delimiter)
-		if len(delims) > 0 {
-			delim = delims[0]
+	// Hash the output as it is written if verification is requested
+	var out io.Writer = file
+	hasher := sha256.New()
+	if params.VerifyChecksum {
+		out = io.MultiWriter(file, hasher)
+	}
+	out = &countingWriter{w: out, add: ByteCountersFromContext(ctx).AddWritten}
+
+	// Write to the file in large chunks; write may flush early through the buffer
+	buffered := bufio.NewWriterSize(out, s.config.FileWriteBufferBytes)
+	totalRows, err := write(buffered)
+	if err != nil {
+		return totalRows, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return totalRows, fmt.Errorf("failed to write file: %w", err)
+	}
+
+	// Publish the export atomically; temp files are created owner-only
+	if err := file.Chmod(0644); err != nil {
+		return totalRows, fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if params.Fsync {
+		if err := file.Sync(); err != nil {
+			return totalRows, fmt.Errorf("failed to sync file: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return totalRows, fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return totalRows, fmt.Errorf("failed to rename file: %w", err)
+	}
+	committed = true
+
+	// Make the rename itself durable
+	if params.Fsync {
+		if err := syncDir(dir); err != nil {
+			return totalRows, err
+		}
+	}
+
+	// Re-read the published file and compare checksums
+	if params.VerifyChecksum {
+		expected := hex.EncodeToString(hasher.Sum(nil))
+		actual, err := fileChecksum(filePath)
+		if err != nil {
+			return totalRows, err
+		}
+		if actual != expected {
+			return totalRows, fmt.Errorf("checksum mismatch for %s: wrote %s, read back %s", filePath, expected, actual)
 		}
+		s.logger.WithFields(logrus.Fields{
+			"path":   filePath,
+			"sha256": actual,
+		}).Info("Verified export checksum")
 	}
-	writer := csv.NewWriter(file)
+
+	return totalRows, nil
+}
+
+// writeRecords writes a header and positional rows as CSV, reporting progress
+func (s *FlatFileServiceImpl) writeRecords(
+	ctx context.Context,
+	params model.FlatFileParams,
+	columns []model.Column,
+	data <-chan []interface{},
+	delim rune,
+	out io.Writer,
+	progressCh chan<- model.ProgressUpdate,
+) (int, error) {
+	writer := csv.NewWriter(out)
 	writer.Comma = delim
 
 	// Write header
@@ -428,10 +737,13 @@ func (s *FlatFileServiceImpl) WriteData(
 	}
 	writer.Flush()
 
-	// Write data
+	// Write data; the writer does not keep records, so one is reused for every row
 	totalRows := 0
 	progressReportSize := s.config.ProgressReportSize
 	lastReportedCount := 0
+	record := make([]string, len(columns))
+	flushRows, flushInterval := s.config.ExportFlushRows, s.config.ExportFlushInterval
+	lastFlush := time.Now()
 
 	for row := range data {
 		// Check context for cancellation
@@ -441,15 +753,45 @@ func (s *FlatFileServiceImpl) WriteData(
 		default:
 		}
 
-		// Create record
-		record := make([]string, len(columns))
+		// Fill record
 		for i, col := range columns {
-			value, ok := row[col.Name]
-			if !ok {
+			if i >= len(row) {
+				record[i] = ""
+				continue
+			}
+			value := row[i]
+			if _, missing := value.(missingCell); missing {
 				record[i] = ""
 				continue
 			}
 
+			// Encode binary columns as text if requested
+			if params.BinaryEncoding != "" && isBinaryType(col.Type) {
+				if b, ok := binaryBytes(value); ok {
+					record[i] = encodeBinary(b, params.BinaryEncoding)
+					continue
+				}
+			}
+
+			// Render geo values as WKT or GeoJSON
+			if g, ok := value.(orb.Geometry); ok {
+				text, err := formatGeo(g, params.GeoFormat)
+				if err != nil {
+					return totalRows, err
+				}
+				record[i] = text
+				continue
+			}
+
+			// Write structured JSON values as JSON text
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				if b, err := json.Marshal(value); err == nil {
+					record[i] = string(b)
+					continue
+				}
+			}
+
 			// Convert value to string
 			record[i] = fmt.Sprintf("%v", value)
 		}
@@ -461,13 +803,22 @@ func (s *FlatFileServiceImpl) WriteData(
 
 		totalRows++
 
-		// Flush periodically
-		if totalRows%1000 == 0 {
+		// Flush periodically, and through to the file once the flush interval passes
+		toFile := flushInterval > 0 && time.Since(lastFlush) >= flushInterval
+		if totalRows%flushRows == 0 || toFile {
 			writer.Flush()
 			if err := writer.Error(); err != nil {
 				return totalRows, fmt.Errorf("writer error: %w", err)
 			}
 		}
+		if toFile {
+			if f, ok := out.(interface{ Flush() error }); ok {
+				if err := f.Flush(); err != nil {
+					return totalRows, fmt.Errorf("failed to flush file: %w", err)
+				}
+			}
+			lastFlush = time.Now()
+		}
 
 		// Report progress if needed
 		if totalRows-lastReportedCount >= progressReportSize {
@@ -494,35 +845,201 @@ func (s *FlatFileServiceImpl) WriteData(
 	return totalRows, nil
 }
 
+// missingCell stands for a column absent from a row; it is written as an empty field
+type missingCell struct{}
+
+// rowSlices converts keyed rows to the positional rows written by WriteRows
+func rowSlices(ctx context.Context, in <-chan map[string]interface{}, columns []model.Column) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	go func() {
+		defer close(out)
+
+		for m := range in {
+			row := make([]interface{}, len(columns))
+			for i, col := range columns {
+				value, ok := m[col.Name]
+				if !ok {
+					value = missingCell{}
+				}
+				row[i] = value
+			}
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// syncDir fsyncs a directory so entries created or renamed in it are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 of a file's contents
+func fileChecksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for verification: %w", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to read file for verification: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// CheckReadable verifies that a file exists, is within the size limit and can be
+// opened for reading
+func (s *FlatFileServiceImpl) CheckReadable(filePath string) error {
+	filePath, err := s.sandbox.Resolve(filePath)
+	if err != nil {
+		return err
+	}
+	if err := s.checkSourceSize(filePath); err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("file is not readable: %w", err)
+	}
+	return file.Close()
+}
+
+// CheckWritable verifies that the directory of a file accepts new files
+func (s *FlatFileServiceImpl) CheckWritable(filePath string) error {
+	filePath, err := s.sandbox.Resolve(filePath)
+	if err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(filepath.Dir(filePath), ".ingestor-probe-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// bufferedReader reads a flat file in FILE_READ_BUFFER_BYTES chunks. CSV readers
+// use it as their own buffer.
+func (s *FlatFileServiceImpl) bufferedReader(r io.Reader) *bufio.Reader {
+	return bufio.NewReaderSize(r, s.config.FileReadBufferBytes)
+}
+
+// ResolvePath checks that a path lies inside the allowed directories, returning its
+// resolved form
+func (s *FlatFileServiceImpl) ResolvePath(filePath string) (string, error) {
+	return s.sandbox.Resolve(filePath)
+}
+
+// Policies for flat file values that do not parse as their column's type. Lenient
+// loads NULL into Nullable columns and rejects the row, as strict does, for others.
+const (
+	CoercionStrict  = "strict"
+	CoercionLenient = "lenient"
+	CoercionLegacy  = "legacy"
+)
+
+// integerBits is the size of each ClickHouse integer type
+var integerBits = map[string]int{
+	"Int8": 8, "Int16": 16, "Int32": 32, "Int64": 64,
+	"UInt8": 8, "UInt16": 16, "UInt32": 32, "UInt64": 64,
+}
+
+// parseInteger parses a value as the Go type of a ClickHouse integer column, since
+// the insert would silently wrap a wider one. Values out of the column's range fail,
+// returning the type's zero value.
+func parseInteger(value, dataType string) (interface{}, error) {
+	bits, ok := integerBits[dataType]
+	if !ok {
+		return strconv.ParseInt(value, 10, 64)
+	}
+	if strings.HasPrefix(dataType, "U") {
+		u, err := strconv.ParseUint(value, 10, bits)
+		if err != nil {
+			u = 0
+		}
+		switch bits {
+		case 8:
+			return uint8(u), err
+		case 16:
+			return uint16(u), err
+		case 32:
+			return uint32(u), err
+		}
+		return u, err
+	}
+	i, err := strconv.ParseInt(value, 10, bits)
+	if err != nil {
+		i = 0
+	}
+	switch bits {
+	case 8:
+		return int8(i), err
+	case 16:
+		return int16(i), err
+	case 32:
+		return int32(i), err
+	}
+	return i, err
+}
+
+// isNullableType reports whether a column type takes NULL, directly or inside
+// LowCardinality
+func isNullableType(dataType string) bool {
+	dataType = strings.TrimPrefix(strings.TrimSpace(dataType), "LowCardinality(")
+	return strings.HasPrefix(dataType, "Nullable(")
+}
+
 // convertValue converts a string value to the appropriate type
 func (s *FlatFileServiceImpl) convertValue(value string, dataType string) interface{} {
+	converted, _ := s.parseValue(value, dataType)
+	return converted
+}
+
+// parseValue converts a string value to the appropriate type, reporting false when
+// the value could not be parsed and was coerced to the type's zero value
+func (s *FlatFileServiceImpl) parseValue(value string, dataType string) (interface{}, bool) {
 	// Handle nullable types
 	if strings.HasPrefix(dataType, "Nullable(") && strings.HasSuffix(dataType, ")") {
 		if value == "" {
-			return nil
+			return nil, true
 		}
 		innerType := dataType[9 : len(dataType)-1]
-		return s.convertValue(value, innerType)
+		return s.parseValue(value, innerType)
 	}
 
 	switch dataType {
 	case "Int8", "Int16", "Int32", "Int64", "UInt8", "UInt16", "UInt32", "UInt64":
-		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
-			return i
-		}
-		return 0
+		i, err := parseInteger(value, dataType)
+		return i, err == nil
 
 	case "Float32", "Float64":
 		if f, err := strconv.ParseFloat(value, 64); err == nil {
-			return f
+			return f, true
 		}
-		return 0.0
+		return 0.0, false
 
 	case "Bool":
-		if b, err := strconv.ParseBool(value); err == nil {
-			return b
+		if b, ok := s.parseBool(value); ok {
+			return b, true
 		}
-		return false
+		return false, false
 
 	case "Date", "DateTime":
 		// Try common date formats
@@ -538,12 +1055,12 @@ func (s *FlatFileServiceImpl) convertValue(value string, dataType string) interf
 
 		for _, format := range dateFormats {
 			if t, err := time.Parse(format, value); err == nil {
-				return t
+				return t, true
 			}
 		}
-		return time.Time{}
+		return time.Time{}, false
 
 	default:
-		return value
+		return value, true
 	}
 }
\ No newline at end of file
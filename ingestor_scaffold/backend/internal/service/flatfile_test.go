@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValueIntegers(t *testing.T) {
+	s := NewFlatFileService(&config.Config{}, nil).(*FlatFileServiceImpl)
+	tests := []struct {
+		value, dataType string
+		want            interface{}
+		ok              bool
+	}{
+		{"127", "Int8", int8(127), true},
+		{"-128", "Int8", int8(-128), true},
+		{"128", "Int8", int8(0), false},
+		{"-32769", "Int16", int16(0), false},
+		{"2147483647", "Int32", int32(2147483647), true},
+		{"-9223372036854775808", "Int64", int64(-9223372036854775808), true},
+		{"9223372036854775808", "Int64", int64(0), false},
+		{"255", "UInt8", uint8(255), true},
+		{"300", "UInt8", uint8(0), false},
+		{"65536", "UInt16", uint16(0), false},
+		{"-1", "UInt32", uint32(0), false},
+		{"4294967295", "UInt32", uint32(4294967295), true},
+		{"18446744073709551615", "UInt64", uint64(18446744073709551615), true},
+		{"18446744073709551616", "UInt64", uint64(0), false},
+		{"1.5", "Int32", int32(0), false},
+		{"", "Nullable(UInt8)", nil, true},
+		{"200", "Nullable(UInt8)", uint8(200), true},
+		{"-5", "Nullable(UInt8)", uint8(0), false},
+	}
+	for _, tt := range tests {
+		got, ok := s.parseValue(tt.value, tt.dataType)
+		assert.Equal(t, tt.ok, ok, "%s as %s", tt.value, tt.dataType)
+		assert.Equal(t, tt.want, got, "%s as %s", tt.value, tt.dataType)
+	}
+}
+
+func TestCoerceValueIntegers(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		chType string
+		want   interface{}
+		ok     bool
+	}{
+		{int64(42), "UInt8", uint8(42), true},
+		{int64(300), "UInt8", nil, false},
+		{int64(-1), "UInt32", nil, false},
+		{" 7 ", "Nullable(Int16)", int16(7), true},
+		{2.6, "Int32", int32(3), true},
+		{1e20, "Int64", nil, false},
+		{uint64(18446744073709551615), "UInt64", uint64(18446744073709551615), true},
+		{uint64(18446744073709551615), "Int64", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := coerceValue(tt.value, tt.chType)
+		if !tt.ok {
+			assert.Error(t, err, "%v as %s", tt.value, tt.chType)
+			continue
+		}
+		assert.NoError(t, err, "%v as %s", tt.value, tt.chType)
+		assert.Equal(t, tt.want, got, "%v as %s", tt.value, tt.chType)
+	}
+}
+
+// benchmarkBufferSizes brackets the 256 KiB FILE_*_BUFFER_BYTES defaults. On local
+// disk throughput is flat from 64 KiB, since parsing and formatting dominate, and
+// reads slow down again at 1 MiB once the buffer outgrows the CPU cache; 256 KiB
+// keeps the syscall count low for network mounts without that cost.
+var benchmarkBufferSizes = []int{4 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+var benchmarkColumns = []model.Column{
+	{Name: "id", Type: "UInt64"},
+	{Name: "name", Type: "String"},
+	{Name: "score", Type: "Float64"},
+	{Name: "created", Type: "String"},
+}
+
+const benchmarkRows = 100000
+
+// benchmarkFlatFileService uses the default settings with both file buffers sized bufferBytes
+func benchmarkFlatFileService(bufferBytes int) *FlatFileServiceImpl {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{
+		FileReadBufferBytes:  bufferBytes,
+		FileWriteBufferBytes: bufferBytes,
+		ParseWorkers:         1,
+		MaxParseWorkers:      1,
+		ParseChunkRows:       1000,
+		PipelineChannelSize:  1000,
+		ProgressReportSize:   5000,
+		ExportFlushRows:      1000,
+		CoercionPolicy:       CoercionLegacy,
+	}
+	return NewFlatFileService(cfg, logger).(*FlatFileServiceImpl)
+}
+
+func BenchmarkWriteData(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "out.csv")
+	for _, size := range benchmarkBufferSizes {
+		b.Run(strconv.Itoa(size>>10)+"KiB", func(b *testing.B) {
+			s := benchmarkFlatFileService(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data := make(chan map[string]interface{}, 1024)
+				go func() {
+					defer close(data)
+					for row := 0; row < benchmarkRows; row++ {
+						data <- map[string]interface{}{
+							"id":      uint64(row),
+							"name":    "customer-" + strconv.Itoa(row),
+							"score":   float64(row) / 7,
+							"created": "2024-01-02 03:04:05",
+						}
+					}
+				}()
+				progress := make(chan model.ProgressUpdate)
+				go func() {
+					for range progress {
+					}
+				}()
+				_, err := s.WriteData(context.Background(), model.FlatFileParams{FilePath: path}, benchmarkColumns, data, progress)
+				close(progress)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			if info, err := os.Stat(path); err == nil {
+				b.SetBytes(info.Size())
+			}
+		})
+	}
+}
+
+func BenchmarkReadData(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "in.csv")
+	file, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fmt.Fprintln(file, "id,name,score,created")
+	for row := 0; row < benchmarkRows; row++ {
+		fmt.Fprintf(file, "%d,customer-%d,%g,2024-01-02 03:04:05\n", row, row, float64(row)/7)
+	}
+	file.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, size := range benchmarkBufferSizes {
+		b.Run(strconv.Itoa(size>>10)+"KiB", func(b *testing.B) {
+			s := benchmarkFlatFileService(size)
+			b.SetBytes(info.Size())
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				errCh := make(chan error, 1)
+				rows, err := s.ReadData(context.Background(), model.FlatFileParams{FilePath: path}, benchmarkColumns, errCh)
+				if err != nil {
+					b.Fatal(err)
+				}
+				read := 0
+				for range rows {
+					read++
+				}
+				if err := <-errCh; err != nil {
+					b.Fatal(err)
+				}
+				if read != benchmarkRows {
+					b.Fatalf("read %d rows, want %d", read, benchmarkRows)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteStreamReplacesAtomically(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := NewFlatFileService(&config.Config{FileWriteBufferBytes: 4096}, logger).(*FlatFileServiceImpl)
+	failed := errors.New("source failed")
+	tests := []struct {
+		name   string
+		params model.FlatFileParams
+		write  func(w io.Writer) (int, error)
+		want   string
+		err    error
+	}{
+		{"written", model.FlatFileParams{}, func(w io.Writer) (int, error) {
+			_, err := io.WriteString(w, "id\n1\n")
+			return 1, err
+		}, "id\n1\n", nil},
+		{"synced and verified", model.FlatFileParams{Fsync: true, VerifyChecksum: true}, func(w io.Writer) (int, error) {
+			_, err := io.WriteString(w, "id\n2\n")
+			return 1, err
+		}, "id\n2\n", nil},
+		{"fails before flushing", model.FlatFileParams{}, func(w io.Writer) (int, error) {
+			io.WriteString(w, "id\n3\n")
+			return 1, failed
+		}, "old\n", failed},
+		{"fails after flushing", model.FlatFileParams{}, func(w io.Writer) (int, error) {
+			io.WriteString(w, strings.Repeat("partial\n", 4096))
+			return 4096, failed
+		}, "old\n", failed},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "export.csv")
+		assert.NoError(t, os.WriteFile(path, []byte("old\n"), 0644))
+
+		tt.params.FilePath = path
+		_, err := s.WriteStream(context.Background(), tt.params, tt.write)
+		assert.ErrorIs(t, err, tt.err, tt.name)
+
+		// The destination holds either the old or the complete new content, and
+		// no temporary file is left behind
+		content, _ := os.ReadFile(path)
+		assert.Equal(t, tt.want, string(content), tt.name)
+		entries, _ := os.ReadDir(dir)
+		assert.Len(t, entries, 1, tt.name)
+		if info, err := os.Stat(path); assert.NoError(t, err, tt.name) {
+			assert.Equal(t, os.FileMode(0644), info.Mode().Perm(), tt.name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ingestor/internal/model"
+)
+
+// checkServerFormat validates a server-formatted export. Rows never pass through
+// this service, so options that process them do not apply.
+func checkServerFormat(params model.IngestionParams) error {
+	if !serverFormats[params.ServerFormat] {
+		return fmt.Errorf("unsupported server format %q: must be CSVWithNames, TSVWithNames or Parquet", params.ServerFormat)
+	}
+	if params.SourceType != "clickhouse" || params.TargetType != "flatfile" {
+		return fmt.Errorf("serverFormat applies to ClickHouse to flat file exports")
+	}
+	ff := params.FlatFileParams
+	switch {
+	case len(params.DedupKey) > 0, params.CursorColumn != "", len(params.JSONPaths) > 0,
+		len(params.ColumnMappings) > 0, params.MaxRowsPerSecond > 0:
+		return fmt.Errorf("serverFormat exports cannot deduplicate, track cursors, extract JSON paths, map columns or throttle rows")
+	case ff.BinaryEncoding != "", ff.GeoFormat != "":
+		return fmt.Errorf("serverFormat exports write values as ClickHouse formats them; unset binaryEncoding and geoFormat")
+	case params.ServerFormat != "CSVWithNames" && ff.Delimiter != "" && ff.Delimiter != ",":
+		return fmt.Errorf("delimiter only applies to the CSVWithNames server format")
+	}
+	return nil
+}
+
+// exportFormatted streams an export ClickHouse formats itself into the target file
+func (s *IngestServiceImpl) exportFormatted(
+	ctx context.Context,
+	params model.IngestionParams,
+	query string,
+	args []interface{},
+	columns []model.Column,
+	lineage []model.ColumnLineage,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	if len(args) > 0 {
+		return model.IngestionResult{}, fmt.Errorf("serverFormat exports do not support filtered joins")
+	}
+
+	settings := map[string]string{}
+	if d := params.FlatFileParams.Delimiter; d != "" && d != "," {
+		settings["format_csv_delimiter"] = d
+	}
+
+	select {
+	case progressCh <- model.ProgressUpdate{
+		Status:  "processing",
+		Message: fmt.Sprintf("Streaming %s export formatted by ClickHouse", params.ServerFormat),
+	}:
+	case <-ctx.Done():
+		return model.IngestionResult{}, ctx.Err()
+	}
+
+	conn := s.clickhouse(ctx)
+	count, err := s.flatFileService.WriteStream(ctx, params.FlatFileParams, func(w io.Writer) (int, error) {
+		return conn.ExportFormatted(ctx, query, params.ServerFormat, settings, w)
+	})
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+
+	result := model.IngestionResult{TotalRecords: count, Lineage: lineage}
+	if lineage != nil {
+		s.attachManifest(&result, params.FlatFileParams.FilePath, query, args, columns, WarningsFromContext(ctx))
+	}
+	return result, nil
+}
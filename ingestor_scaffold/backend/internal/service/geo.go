@@ -0,0 +1,132 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkt"
+	"github.com/paulmach/orb/geojson"
+)
+
+// Text formats for geo column values
+const (
+	GeoFormatWKT     = "wkt"
+	GeoFormatGeoJSON = "geojson"
+)
+
+// ValidateGeoFormat checks that format is empty (WKT) or a supported geo format
+func ValidateGeoFormat(format string) error {
+	switch format {
+	case "", GeoFormatWKT, GeoFormatGeoJSON:
+		return nil
+	default:
+		return fmt.Errorf("unsupported geo format %q: must be wkt or geojson", format)
+	}
+}
+
+// geoType returns the geo type name of a ClickHouse column type, or "" if it is not a geo type
+func geoType(chType string) string {
+	switch t := baseType(chType); t {
+	case "Point", "Ring", "Polygon", "MultiPolygon":
+		return t
+	default:
+		return ""
+	}
+}
+
+// formatGeo renders a geometry as WKT or GeoJSON text.
+// Rings have no WKT or GeoJSON form of their own and are written as single-ring polygons.
+func formatGeo(g orb.Geometry, format string) (string, error) {
+	if ring, ok := g.(orb.Ring); ok {
+		g = orb.Polygon{ring}
+	}
+
+	if format == GeoFormatGeoJSON {
+		b, err := json.Marshal(geojson.NewGeometry(g))
+		if err != nil {
+			return "", fmt.Errorf("failed to encode GeoJSON: %w", err)
+		}
+		return string(b), nil
+	}
+	return wkt.MarshalString(g), nil
+}
+
+// parseGeo parses WKT or GeoJSON text into the geometry expected by a geo column type
+func parseGeo(value, typ string) (orb.Geometry, error) {
+	var g orb.Geometry
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		geometry, err := geojson.UnmarshalGeometry([]byte(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+		}
+		g = geometry.Geometry()
+	} else {
+		geometry, err := wkt.Unmarshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WKT: %w", err)
+		}
+		g = geometry
+	}
+
+	// Coerce compatible shapes into the column's geometry type
+	switch typ {
+	case "Point":
+		if p, ok := g.(orb.Point); ok {
+			return p, nil
+		}
+	case "Ring":
+		switch v := g.(type) {
+		case orb.Ring:
+			return v, nil
+		case orb.LineString:
+			return orb.Ring(v), nil
+		case orb.Polygon:
+			if len(v) == 1 {
+				return v[0], nil
+			}
+		}
+	case "Polygon":
+		if p, ok := g.(orb.Polygon); ok {
+			return p, nil
+		}
+	case "MultiPolygon":
+		switch v := g.(type) {
+		case orb.MultiPolygon:
+			return v, nil
+		case orb.Polygon:
+			return orb.MultiPolygon{v}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s value cannot be stored in a %s column", g.GeoJSONType(), typ)
+}
+
+// RenderPreviewGeo rewrites geo values in preview rows as WKT strings or GeoJSON objects.
+// It returns the format applied to each affected column as a type hint.
+func RenderPreviewGeo(rows []map[string]interface{}, format string) map[string]string {
+	if format == "" {
+		format = GeoFormatWKT
+	}
+
+	hints := make(map[string]string)
+	for _, row := range rows {
+		for name, value := range row {
+			g, ok := value.(orb.Geometry)
+			if !ok {
+				continue
+			}
+			text, err := formatGeo(g, format)
+			if err != nil {
+				continue
+			}
+			if format == GeoFormatGeoJSON {
+				row[name] = json.RawMessage(text)
+			} else {
+				row[name] = text
+			}
+			hints[name] = format
+		}
+	}
+	return hints
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Reasons a header name was renamed
+const (
+	RenameBlank         = "blank"
+	RenameDuplicate     = "duplicate"
+	RenameCaseDuplicate = "case_duplicate"
+)
+
+// normalizeHeader makes CSV header names unique so every column is addressable by
+// name. Blank names become column_<position>; later repeats of a name, compared
+// case-insensitively, get the first free _<n> suffix. The first occurrence of a
+// name always keeps it, so the result only depends on the header.
+func normalizeHeader(header []string) ([]string, []model.HeaderRename) {
+	// Original names are reserved so generated ones never take a later column's name
+	reserved := make(map[string]bool, len(header))
+	for _, name := range header {
+		reserved[strings.ToLower(name)] = true
+	}
+
+	names := make([]string, len(header))
+	used := make(map[string]string, len(header)) // lowercased -> name as used
+	var renames []model.HeaderRename
+	for i, original := range header {
+		name, reason := original, ""
+		prior, seen := used[strings.ToLower(original)]
+		switch {
+		case strings.TrimSpace(original) == "":
+			reason = RenameBlank
+		case seen && prior == original:
+			reason = RenameDuplicate
+		case seen:
+			reason = RenameCaseDuplicate
+		}
+
+		if reason != "" {
+			name = uniqueName(original, i, reason, used, reserved)
+			renames = append(renames, model.HeaderRename{
+				Position: i,
+				Original: original,
+				Name:     name,
+				Reason:   reason,
+			})
+		}
+		used[strings.ToLower(name)] = name
+		names[i] = name
+	}
+	return names, renames
+}
+
+// uniqueName picks the first name for a renamed column that is neither used nor reserved
+func uniqueName(original string, position int, reason string, used map[string]string, reserved map[string]bool) string {
+	free := func(name string) bool {
+		_, taken := used[strings.ToLower(name)]
+		return !taken && !reserved[strings.ToLower(name)]
+	}
+
+	base := original
+	if reason == RenameBlank {
+		base = fmt.Sprintf("column_%d", position+1)
+		if free(base) {
+			return base
+		}
+	}
+	for n := 2; ; n++ {
+		if name := fmt.Sprintf("%s_%d", base, n); free(name) {
+			return name
+		}
+	}
+}
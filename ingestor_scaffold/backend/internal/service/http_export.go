@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ingestor/internal/model"
+)
+
+// maxHTTPErrorBody bounds the error text read from a failed HTTP query
+const maxHTTPErrorBody = 4096
+
+// serverFormats are the formats ClickHouse may render exports in
+var serverFormats = map[string]bool{
+	"CSVWithNames": true,
+	"TSVWithNames": true,
+	"Parquet":      true,
+}
+
+// httpEndpoint reaches the HTTP interface of the connected server, which streams
+// results in formats ClickHouse renders itself
+type httpEndpoint struct {
+	client   *http.Client
+	url      string
+	user     string
+	password string
+	database string
+}
+
+// newHTTPEndpoint derives the HTTP interface of a connection: its own port over
+// the HTTP protocol, or else HTTPPort, by default 8123, or 8443 with TLS
+func newHTTPEndpoint(params model.ClickHouseConnectionParams, tlsCfg *tls.Config, dial dialFunc) *httpEndpoint {
+	host := params.Host
+	if host == "" && len(params.Hosts) > 0 {
+		host = params.Hosts[0]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	scheme, port := "http", 8123
+	if tlsCfg != nil {
+		scheme, port = "https", 8443
+	}
+	switch {
+	case params.Protocol == "http" && params.Port != 0:
+		port = params.Port
+	case params.HTTPPort != 0:
+		port = params.HTTPPort
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsCfg}
+	if dial != nil {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dial(ctx, addr)
+		}
+	}
+	return &httpEndpoint{
+		client:   &http.Client{Transport: transport},
+		url:      fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(host, strconv.Itoa(port))),
+		user:     params.User,
+		password: params.Password,
+		database: params.Database,
+	}
+}
+
+// ExportFormatted runs a query through the HTTP interface with its result in a
+// ClickHouse format, copying the bytes to w unread, and returns the number of rows
+// in the result. The server finishes the query before streaming it, so failures
+// surface before anything is written.
+func (s *ClickHouseServiceImpl) ExportFormatted(ctx context.Context, query, format string, settings map[string]string, w io.Writer) (int, error) {
+	if s.conn == nil || s.http == nil {
+		return 0, fmt.Errorf("not connected to ClickHouse")
+	}
+	if !serverFormats[format] {
+		return 0, fmt.Errorf("unsupported server format %q: must be CSVWithNames, TSVWithNames or Parquet", format)
+	}
+
+	values := url.Values{}
+	for name, value := range settings {
+		values.Set(name, value)
+	}
+	values.Set("wait_end_of_query", "1")
+	values.Set("readonly", "1")
+	if id := RequestIDFromContext(ctx); id != "" {
+		values.Set("query_id", fmt.Sprintf("%s-%d", id, atomic.AddUint64(&querySeq, 1)))
+	}
+	if comment := labelComment(LabelsFromContext(ctx)); comment != "" {
+		values.Set("log_comment", comment)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.http.url+"?"+values.Encode(), strings.NewReader(query+" FORMAT "+format))
+	if err != nil {
+		return 0, err
+	}
+	if s.http.database != "" {
+		req.Header.Set("X-ClickHouse-Database", s.http.database)
+	}
+	s.tokenMu.RLock()
+	token := s.token
+	s.tokenMu.RUnlock()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Set("X-ClickHouse-User", s.http.user)
+		req.Header.Set("X-ClickHouse-Key", s.http.password)
+	}
+
+	resp, err := s.http.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach the ClickHouse HTTP interface: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBody))
+		return 0, fmt.Errorf("ClickHouse HTTP interface returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	// The summary counts the result rows; its numbers are strings
+	var summary struct {
+		ResultRows string `json:"result_rows"`
+	}
+	_ = json.Unmarshal([]byte(resp.Header.Get("X-ClickHouse-Summary")), &summary)
+	rows, _ := strconv.Atoi(summary.ResultRows)
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return rows, fmt.Errorf("failed to stream export: %w", err)
+	}
+	return rows, nil
+}
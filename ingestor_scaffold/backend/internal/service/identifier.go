@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+var (
+	// safeIdentifierRe allowlists the characters of table and column names: letters,
+	// digits and underscores, plus spaces, dots, dashes and dollar signs after the first
+	safeIdentifierRe = regexp.MustCompile(`^[\p{L}\p{N}_][\p{L}\p{N}_ .$-]*$`)
+)
+
+// maxIdentifierLength bounds table and column names
+const maxIdentifierLength = 255
+
+// QuoteIdentifier validates a column name, or one part of a table name, and quotes it
+// with backticks so it cannot be read as SQL
+func QuoteIdentifier(name string) (string, error) {
+	if len(name) > maxIdentifierLength || !safeIdentifierRe.MatchString(name) {
+		return "", fmt.Errorf("invalid identifier %q", name)
+	}
+	return "`" + name + "`", nil
+}
+
+// QuoteTable validates and quotes a table name, optionally qualified as database.table
+func QuoteTable(name string) (string, error) {
+	database, table, qualified := strings.Cut(name, ".")
+	if !qualified {
+		return QuoteIdentifier(name)
+	}
+
+	quotedDatabase, err := QuoteIdentifier(database)
+	if err != nil {
+		return "", fmt.Errorf("invalid table name %q", name)
+	}
+	quotedTable, err := QuoteIdentifier(table)
+	if err != nil {
+		return "", fmt.Errorf("invalid table name %q", name)
+	}
+	return quotedDatabase + "." + quotedTable, nil
+}
+
+// quoteIdentifiers quotes each of the given column names
+func quoteIdentifiers(names []string) ([]string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		q, err := QuoteIdentifier(name)
+		if err != nil {
+			return nil, err
+		}
+		quoted[i] = q
+	}
+	return quoted, nil
+}
+
+// selectColumnsQuery builds the query reading the given columns of a table
+func selectColumnsQuery(tableName string, columns []model.Column) (string, error) {
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return "", err
+	}
+	quoted, err := quoteIdentifiers(selectedColumnNames(columns))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), table), nil
+}
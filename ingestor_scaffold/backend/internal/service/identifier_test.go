@@ -0,0 +1,140 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name   string
+		quoted string
+	}{
+		{"id", "`id`"},
+		{"_ingested_at", "`_ingested_at`"},
+		{"order total", "`order total`"},
+		{"price-usd$", "`price-usd$`"},
+		{"données", "`données`"},
+		{"1st", "`1st`"},
+		{"", ""},
+		{" id", ""},
+		{"-id", ""},
+		{"id`", ""},
+		{"id` FROM users --", ""},
+		{"id; DROP TABLE users", ""},
+		{"id\\", ""},
+		{"id'", ""},
+		{"id)", ""},
+		{"id\n", ""},
+		{strings.Repeat("a", maxIdentifierLength), "`" + strings.Repeat("a", maxIdentifierLength) + "`"},
+		{strings.Repeat("a", maxIdentifierLength+1), ""},
+	}
+	for _, tt := range tests {
+		quoted, err := QuoteIdentifier(tt.name)
+		if tt.quoted == "" {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.quoted, quoted)
+	}
+}
+
+func TestQuoteTable(t *testing.T) {
+	tests := []struct {
+		name   string
+		quoted string
+	}{
+		{"events", "`events`"},
+		{"analytics.events", "`analytics`.`events`"},
+		{"analytics.events.v2", "`analytics`.`events.v2`"},
+		{".events", ""},
+		{"analytics.", ""},
+		{"analytics.`events`", ""},
+		{"events; DROP TABLE users", ""},
+		{"events FINAL", "`events FINAL`"},
+		{"system.users UNION SELECT 1", "`system`.`users UNION SELECT 1`"},
+		{"events)", ""},
+	}
+	for _, tt := range tests {
+		quoted, err := QuoteTable(tt.name)
+		if tt.quoted == "" {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.quoted, quoted)
+	}
+}
+
+func TestValidateColumnType(t *testing.T) {
+	valid := []string{
+		"String",
+		"UInt64",
+		"Nullable(Float64)",
+		"LowCardinality(Nullable(String))",
+		"Array(Array(Int32))",
+		"Map(String, UInt64)",
+		"Decimal(38, 10)",
+		"FixedString(16)",
+		"DateTime",
+		"DateTime('Europe/Berlin')",
+		"DateTime64(3, 'UTC')",
+		"Enum8('a' = 1, 'b' = -2)",
+		"Enum('draft', 'sent')",
+		"Tuple(String, Int32)",
+		"Tuple(name String, tags Array(String))",
+		"Tuple(`first name` String)",
+		"Nested(id UInt32, value Float64)",
+		"AggregateFunction(uniq, UInt64)",
+		"AggregateFunction(quantiles(0.5, 0.9), Float64)",
+		"SimpleAggregateFunction(sum, UInt64)",
+		"Variant(String, UInt64)",
+		"Dynamic(max_types = 8)",
+		"JSON",
+		"Object('json')",
+	}
+	for _, chType := range valid {
+		assert.NoError(t, validateColumnType(chType), chType)
+	}
+
+	invalid := []string{
+		"",
+		" ",
+		"Text",
+		"string",
+		"Int32, evil String) ENGINE = Memory #",
+		"Int32) ENGINE = Memory AS SELECT * FROM system.users --",
+		"String; DROP TABLE users",
+		"String -- comment",
+		"String /* comment */",
+		"String # comment",
+		"String DEFAULT 'x'",
+		"String CODEC(ZSTD)",
+		"String, evil String",
+		"Nullable(String",
+		"Nullable(String))",
+		"Nullable()",
+		"Nullable",
+		"Array(String",
+		"String(1)",
+		"Map(String UInt64)",
+		"Decimal(10, name)",
+		"DateTime('UTC'')",
+		"DateTime('UTC\\')",
+		"DateTime('UTC' || currentUser())",
+		"Enum8('a' = 1, 'b' = x)",
+		"Enum8('a' + 1)",
+		"Tuple(`a`` String)",
+		"Tuple(`a\\` String)",
+		"AggregateFunction(uniq(x), UInt64)",
+		"Dynamic(max_types = 'x')",
+		"Nullable(String)\nENGINE = Memory",
+		strings.Repeat("Array(", 200) + "String" + strings.Repeat(")", 200),
+	}
+	for _, chType := range invalid {
+		assert.Error(t, validateColumnType(chType), chType)
+	}
+}
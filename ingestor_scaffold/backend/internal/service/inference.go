@@ -0,0 +1,73 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/ingestor/internal/model"
+)
+
+// maxInferenceExamples bounds the example values kept per column and type
+const maxInferenceExamples = 3
+
+// typeSamples counts the types inferred for a column's sampled values, keeping a
+// few example values of each
+type typeSamples struct {
+	counts   map[string]int
+	examples map[string][]string
+	total    int
+}
+
+// newTypeSamples creates empty type counts
+func newTypeSamples() *typeSamples {
+	return &typeSamples{
+		counts:   make(map[string]int),
+		examples: make(map[string][]string),
+	}
+}
+
+// add records the type inferred for a value
+func (t *typeSamples) add(typ, value string) {
+	t.counts[typ]++
+	t.total++
+	if len(t.examples[typ]) < maxInferenceExamples {
+		t.examples[typ] = append(t.examples[typ], value)
+	}
+}
+
+// inference reports the candidate types of a column and the sampled values that
+// would not load into the chosen type as they are
+func (t *typeSamples) inference(column, chosen string) model.ColumnInference {
+	info := model.ColumnInference{Column: column, Type: chosen}
+	for typ, count := range t.counts {
+		info.Candidates = append(info.Candidates, model.TypeCandidate{
+			Type:    typ,
+			Count:   count,
+			Percent: 100 * float64(count) / float64(t.total),
+		})
+	}
+	sort.Slice(info.Candidates, func(i, j int) bool {
+		a, b := info.Candidates[i], info.Candidates[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Type < b.Type
+	})
+
+	for _, candidate := range info.Candidates {
+		if conflictsWith(candidate.Type, chosen) {
+			info.Conflicts = append(info.Conflicts, t.examples[candidate.Type]...)
+		}
+	}
+	info.Ambiguous = len(info.Conflicts) > 0
+	return info
+}
+
+// conflictsWith reports whether values inferred as typ fail to parse as the chosen
+// column type. Empty values always load, as NULL or an empty string.
+func conflictsWith(typ, chosen string) bool {
+	base := baseType(chosen)
+	switch {
+	case typ == "Nullable(String)", typ == base, base == "String":
+		return false
+	case typ == "Int64" && base == "Float64":
+		return false
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ingestor/internal/config"
 	"github.com/ingestor/internal/model"
@@ -12,76 +13,369 @@ import (
 
 // IngestService defines ingestion operations
 type IngestService interface {
+	Run(
+		ctx context.Context,
+		params model.IngestionParams,
+		progressCh chan<- model.ProgressUpdate,
+	) (model.IngestionResult, error)
+	
+	DiscoverSchema(
+		ctx context.Context,
+		params model.FlatFileParams,
+		progressCh chan<- model.ProgressUpdate,
+	) (model.FileSchema, error)
+	
 	IngestClickHouseToFlatFile(
 		ctx context.Context,
-		tableName string,
-		columns []model.Column,
-		flatFileParams model.FlatFileParams,
-		query string,
+		params model.IngestionParams,
 		progressCh chan<- model.ProgressUpdate,
 	) (model.IngestionResult, error)
 	
 	IngestFlatFileToClickHouse(
 		ctx context.Context,
-		flatFileParams model.FlatFileParams,
-		tableName string,
-		columns []model.Column,
+		params model.IngestionParams,
+		progressCh chan<- model.ProgressUpdate,
+	) (model.IngestionResult, error)
+	
+	IngestClickHouseToClickHouse(
+		ctx context.Context,
+		params model.IngestionParams,
 		progressCh chan<- model.ProgressUpdate,
 	) (model.IngestionResult, error)
+	
+	IngestTableFunction(
+		ctx context.Context,
+		params model.IngestionParams,
+		progressCh chan<- model.ProgressUpdate,
+	) (model.IngestionResult, error)
+	
+	ExportToTableFunction(
+		ctx context.Context,
+		params model.IngestionParams,
+		progressCh chan<- model.ProgressUpdate,
+	) (model.IngestionResult, error)
+	
+	CheckHealth(ctx context.Context, params model.IngestionParams) model.JobHealth
+	Preflight(ctx context.Context, params model.IngestionParams) []string
+	EstimateCost(ctx context.Context, params model.IngestionParams) (model.CostEstimate, error)
+	Explain(ctx context.Context, params model.IngestionParams) (model.QueryPlan, error)
+	ValidateQuery(ctx context.Context, query string) model.QueryValidation
+	ValidateIngestion(ctx context.Context, params model.IngestionParams) (model.IngestionValidation, error)
 }
 
 // IngestServiceImpl implements IngestService
 type IngestServiceImpl struct {
-	clickhouseService ClickHouseService
-	flatFileService   FlatFileService
-	config            *config.Config
-	logger            *logrus.Logger
+	flatFileService FlatFileService
+	memory          *MemoryBudget // shared by the insert batches of all jobs
+	config          *config.Config
+	logger          *logrus.Logger
 }
 
 // NewIngestService creates a new ingest service
 func NewIngestService(
-	clickhouseService ClickHouseService,
 	flatFileService FlatFileService,
 	config *config.Config,
 	logger *logrus.Logger,
 ) IngestService {
 	return &IngestServiceImpl{
-		clickhouseService: clickhouseService,
-		flatFileService:   flatFileService,
-		config:            config,
-		logger:            logger,
+		flatFileService: flatFileService,
+		memory:          NewMemoryBudget(int64(config.MaxPipelineMemoryBytes)),
+		config:          config,
+		logger:          logger,
+	}
+}
+
+// clickhouse returns the ClickHouse connection of the session carried by ctx.
+// Without a session it returns an unconnected service whose calls fail.
+func (s *IngestServiceImpl) clickhouse(ctx context.Context) ClickHouseService {
+	if conn := ClickHouseFromContext(ctx); conn != nil {
+		return conn
 	}
+	return NewClickHouseService(s.config, s.logger)
+}
+
+// Run dispatches an ingestion to the direction given by its source and target types
+func (s *IngestServiceImpl) Run(
+	ctx context.Context,
+	params model.IngestionParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	if (len(params.Transforms) > 0 || len(params.DerivedColumns) > 0 || len(params.ColumnDefaults) > 0 || len(params.Rules) > 0) && (params.SourceType != "flatfile" || params.TargetType != "clickhouse") {
+		return model.IngestionResult{}, fmt.Errorf("transforms, derived columns, column defaults and rules are only supported for flat file to ClickHouse ingestion")
+	}
+	if err := checkInsertPolicy(params); err != nil {
+		return model.IngestionResult{}, err
+	}
+	if err := checkErrorPolicy(params); err != nil {
+		return model.IngestionResult{}, err
+	}
+	if err := checkParseWorkers(params.FlatFileParams, s.config); err != nil {
+		return model.IngestionResult{}, err
+	}
+	if params.Verify != nil {
+		if err := checkVerify(params); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	ctx = WithMemoryBudget(WithInsertPolicy(ctx, params), s.memory)
+	if params.ServerFormat != "" {
+		if err := checkServerFormat(params); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	if params.Parallel != nil {
+		if err := checkParallelExport(params); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+
+	// Loads are verified by what they add to the target table
+	var baseline checksum
+	if params.Verify != nil {
+		var err error
+		if baseline, err = s.verifyBaseline(ctx, params); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+
+	// Bad rows are dropped, counted or fail the job as its error policy says
+	rowErrors := NewRowErrorLog(params, s.config.RowErrorSamples)
+	startedAt := time.Now()
+	result, err := s.dispatch(WithRowErrors(ctx, rowErrors), params, progressCh)
+	if policyErr := rowErrors.Err(); policyErr != nil {
+		result, err = model.IngestionResult{}, policyErr
+	}
+	result.ErrorSamples = rowErrors.Samples()
+	if err == nil {
+		result.RowErrors = rowErrors.Collected()
+	}
+	describeRun(&result, params, startedAt, time.Now())
+
+	// Verification runs once the job's rows are written
+	if err == nil && params.Verify != nil {
+		result.Verification = s.verify(ctx, params, result, baseline)
+	}
+	return result, err
+}
+
+// dispatch runs an ingestion in the direction given by its source and target types
+func (s *IngestServiceImpl) dispatch(
+	ctx context.Context,
+	params model.IngestionParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	switch {
+	case params.SourceType == "clickhouse" && params.TargetType == "flatfile":
+		// ClickHouse to Flat File
+		return s.IngestClickHouseToFlatFile(ctx, params, progressCh)
+	case params.SourceType == "flatfile" && params.TargetType == "clickhouse":
+		// Flat File to ClickHouse
+		return s.IngestFlatFileToClickHouse(ctx, params, progressCh)
+	case params.SourceType == "clickhouse" && params.TargetType == TableFunctionConnector:
+		// Export pushed down to ClickHouse
+		return s.ExportToTableFunction(ctx, params, progressCh)
+	case params.SourceType == "clickhouse" && params.TargetType == "clickhouse" && params.TableFunction != nil:
+		// Table function loaded server-side
+		return s.IngestTableFunction(ctx, params, progressCh)
+	case params.SourceType == "clickhouse" && params.TargetType == "clickhouse":
+		// ClickHouse to another ClickHouse instance
+		return s.IngestClickHouseToClickHouse(ctx, params, progressCh)
+	case params.SourceType == ChaosConnector || params.TargetType == ChaosConnector:
+		// Synthetic connector for failure testing
+		return s.IngestChaos(ctx, params, progressCh)
+	default:
+		return model.IngestionResult{}, fmt.Errorf("invalid source or target type")
+	}
+}
+
+// describeRun records when a job ran, its throughput and what it wrote to
+func describeRun(result *model.IngestionResult, params model.IngestionParams, startedAt, finishedAt time.Time) {
+	result.StartedAt = startedAt
+	result.FinishedAt = finishedAt
+	result.DurationSeconds = finishedAt.Sub(startedAt).Seconds()
+	if result.DurationSeconds > 0 {
+		result.RowsPerSecond = float64(result.TotalRecords) / result.DurationSeconds
+	}
+
+	switch {
+	case params.TargetType == "flatfile":
+		result.Target = params.FlatFileParams.FilePath
+	case params.TargetType == TableFunctionConnector && params.TargetFunction != nil:
+		// Table function arguments may hold credentials
+		result.Target = params.TargetFunction.Name
+	case params.TargetType == "clickhouse" && params.TargetTableName != "":
+		result.Target = params.TargetTableName
+	case params.TargetType == "clickhouse":
+		result.Target = params.TableName
+	}
+}
+
+// DiscoverSchema infers the columns of a flat file, reporting progress on progressCh
+func (s *IngestServiceImpl) DiscoverSchema(
+	ctx context.Context,
+	params model.FlatFileParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.FileSchema, error) {
+	return s.flatFileService.DiscoverSchema(ctx, params, progressCh)
+}
+
+// exportQuery builds the query a ClickHouse export runs: the given query, the join
+// with the lineage of its columns, or a select of the table whose aggregate states
+// are handled by the aggregate policy
+func (s *IngestServiceImpl) exportQuery(params model.IngestionParams, warnings *WarningCollector) (string, []interface{}, []model.Column, []model.ColumnLineage, error) {
+	query, columns := params.Query, params.Columns
+
+	// Custom queries run as given, so they must not change data
+	if query != "" {
+		if err := CheckReadOnlyQuery(query); err != nil {
+			return "", nil, nil, nil, err
+		}
+	}
+
+	var lineage []model.ColumnLineage
+	var args []interface{}
+	if params.Join != nil && query == "" {
+		var err error
+		query, args, lineage, err = joinSelect(*params.Join)
+		if err != nil {
+			return "", nil, nil, nil, fmt.Errorf("invalid join: %w", err)
+		}
+		if len(columns) == 0 {
+			columns = lineageColumns(lineage)
+		}
+	}
+	if query != "" {
+		return query, args, columns, lineage, nil
+	}
+
+	// Aggregate states cannot be scanned; reject, skip or merge them
+	plan, err := planAggregateExport(columns, params.AggregatePolicy)
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+	if len(plan.skipped) > 0 {
+		warnings.Add("skipped AggregateFunction columns: %s", strings.Join(plan.skipped, ", "))
+	}
+
+	var table string
+	if params.TableFunction != nil {
+		table, err = TableFunctionSource(*params.TableFunction, s.config)
+	} else {
+		table, err = QuoteTable(params.TableName)
+	}
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+	selectList := strings.Join(plan.selectList, ", ")
+	if selectList == "" {
+		selectList = "*"
+	}
+	query = fmt.Sprintf("SELECT %s FROM %s", selectList, table)
+	if len(plan.groupBy) > 0 {
+		query += " GROUP BY " + strings.Join(plan.groupBy, ", ")
+	}
+	return query, nil, plan.columns, nil, nil
 }
 
 // IngestClickHouseToFlatFile ingests data from ClickHouse to a flat file
 func (s *IngestServiceImpl) IngestClickHouseToFlatFile(
 	ctx context.Context,
-	tableName string,
-	columns []model.Column,
-	flatFileParams model.FlatFileParams,
+	params model.IngestionParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	// Joins export their selected columns and record where each one came from
+	query, queryArgs, columns, lineage, err := s.exportQuery(params, WarningsFromContext(ctx))
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+
+	// Large exports may be split into ranges exported concurrently
+	var result model.IngestionResult
+	if params.Parallel != nil {
+		result, err = s.exportParallel(ctx, params, query, queryArgs, columns, lineage, progressCh)
+	} else {
+		result, err = s.exportToFile(ctx, params, query, queryArgs, columns, lineage, progressCh)
+	}
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	result.Query = query
+	return result, nil
+}
+
+// exportToFile writes the result of an export query to the target flat file
+func (s *IngestServiceImpl) exportToFile(
+	ctx context.Context,
+	params model.IngestionParams,
 	query string,
+	queryArgs []interface{},
+	columns []model.Column,
+	lineage []model.ColumnLineage,
 	progressCh chan<- model.ProgressUpdate,
 ) (model.IngestionResult, error) {
-	// Build query if not provided
-	if query == "" {
-		// Extract column names
-		columnNames := make([]string, len(columns))
-		for i, col := range columns {
-			columnNames[i] = col.Name
+	// ClickHouse formats the export itself when asked, and rows are never read here
+	if params.ServerFormat != "" {
+		return s.exportFormatted(ctx, params, query, queryArgs, columns, lineage, progressCh)
+	}
+
+	flatFileParams := params.FlatFileParams
+
+	// Throttle reads if a row rate is configured
+	limiter := NewRateLimiter(effectiveRowRate(params.MaxRowsPerSecond, s.config.MaxRowsPerSecond))
+
+	// Drop repeated keys if deduplication is requested
+	var dedup *Deduplicator
+	if len(params.DedupKey) > 0 {
+		dedup = NewDeduplicator(s.config.DedupMaxKeys)
+	}
+
+	warnings := WarningsFromContext(ctx)
+	
+	// Track the largest cursor value exported, if requested
+	var cursor *CursorTracker
+	if params.CursorColumn != "" {
+		cursor = NewCursorTracker()
+	}
+	
+	// Exported JSON paths become extra columns after the selected ones
+	exportColumns := columns
+	if len(params.JSONPaths) > 0 {
+		if err := validateJSONPaths(params.JSONPaths, columns); err != nil {
+			return model.IngestionResult{}, err
 		}
-		
-		query = fmt.Sprintf("SELECT %s FROM %s", strings.Join(columnNames, ", "), tableName)
+		exportColumns = append(append([]model.Column{}, columns...), jsonPathColumns(params.JSONPaths)...)
 	}
 	
-	// Channel for intermediate data
-	dataCh := make(chan map[string]interface{}, 100)
+	// Wide tables skip the per-row map; JSON paths need it
+	if params.WideTable && len(params.JSONPaths) > 0 {
+		return model.IngestionResult{}, fmt.Errorf("jsonPaths are not supported in wide-table mode")
+	}
+	wide := s.wideTableMode(params, exportColumns)
+
+	// Mapped columns are written under their target names
+	renames, err := columnRenames(params.ColumnMappings, exportColumns)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	outputColumns := renameColumns(exportColumns, renames)
+
+	// Channel for intermediate data; wide tables use rowCh
+	dataCh := make(chan map[string]interface{}, s.config.PipelineChannelSize)
+	rowCh := make(chan []interface{}, s.config.PipelineChannelSize)
+	counters := ByteCountersFromContext(ctx)
+	
+	// Rows the error policy cannot drop cancel the export, so no partial file is published
+	rowErrors := RowErrorsFromContext(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	
 	// Start goroutine to fetch data from ClickHouse
 	go func() {
 		defer close(dataCh)
+		defer close(rowCh)
 		
 		// Execute query
-		rows, err := s.clickhouseService.conn.Query(ctx, query)
+		rows, err := s.clickhouse(ctx).Query(ctx, query, queryArgs...)
 		if err != nil {
 			s.logger.WithError(err).Error("Failed to execute query")
 			progressCh <- model.ProgressUpdate{
@@ -95,11 +389,45 @@ func (s *IngestServiceImpl) IngestClickHouseToFlatFile(
 		defer rows.Close()
 		
 		// Get column names
-		columnNames := rows.ColumnNames()
+		columnNames := rows.Columns()
+		
+		// Resolve dedup key positions in the result set
+		var keyIndexes []int
+		if dedup != nil {
+			keyIndexes, err = dedupKeyIndexes(params.DedupKey, columnNames)
+			if err != nil {
+				progressCh <- model.ProgressUpdate{
+					Status:    "error",
+					Message:   err.Error(),
+					Count:     0,
+					Completed: true,
+				}
+				return
+			}
+		}
+		cursorIdx := -1
+		if cursor != nil {
+			if cursorIdx, err = cursorIndex(params.CursorColumn, columnNames); err != nil {
+				progressCh <- model.ProgressUpdate{
+					Status:    "error",
+					Message:   err.Error(),
+					Count:     0,
+					Completed: true,
+				}
+				return
+			}
+		}
+		
+		// Wide rows are reordered only when the result differs from the export columns
+		var positions []int
+		if wide {
+			positions = rowPositions(exportColumns, columnNames)
+		}
 		
 		// Process rows
 		totalRows := 0
 		progressReportSize := s.config.ProgressReportSize
+		rowPointers := make([]interface{}, len(columnNames))
 		
 		for rows.Next() {
 			// Check context for cancellation
@@ -109,9 +437,13 @@ func (s *IngestServiceImpl) IngestClickHouseToFlatFile(
 			default:
 			}
 			
-			// Create a slice for row values
+			// Pace reads to the configured row rate
+			if err := limiter.Wait(ctx, 1); err != nil {
+				return
+			}
+			
+			// Create a slice for row values; the pointer slice is reused
 			rowValues := make([]interface{}, len(columnNames))
-			rowPointers := make([]interface{}, len(columnNames))
 			for i := range rowValues {
 				rowPointers[i] = &rowValues[i]
 			}
@@ -119,20 +451,69 @@ func (s *IngestServiceImpl) IngestClickHouseToFlatFile(
 			// Scan row into slice
 			if err := rows.Scan(rowPointers...); err != nil {
 				s.logger.WithError(err).Error("Failed to scan row")
+				warnings.Count("rows skipped (scan failed)", 1)
+				if rowErrors.Record(0, "scan failed: "+err.Error(), nil) != nil {
+					cancel()
+					return
+				}
 				continue
 			}
+			counters.AddRead(estimateRowBytes(rowValues))
 			
-			// Create map for row
-			rowMap := make(map[string]interface{})
-			for i, colName := range columnNames {
-				rowMap[colName] = rowValues[i]
+			// Skip rows whose key was already exported
+			if dedup != nil {
+				key := make([]interface{}, len(keyIndexes))
+				for i, idx := range keyIndexes {
+					key[i] = rowValues[idx]
+				}
+				if dedup.IsDuplicate(key) {
+					warnings.Count("duplicate rows dropped", 1)
+					continue
+				}
 			}
 			
-			// Send row to channel
-			select {
-			case dataCh <- rowMap:
-			case <-ctx.Done():
-				return
+			if wide {
+				if cursorIdx >= 0 {
+					cursor.Observe(rowValues[cursorIdx])
+				}
+				
+				// Send positional row to channel
+				select {
+				case rowCh <- reorderRow(rowValues, positions):
+				case <-ctx.Done():
+					return
+				}
+			} else {
+				// Create map for row
+				rowMap := make(map[string]interface{})
+				for i, colName := range columnNames {
+					rowMap[colName] = rowValues[i]
+				}
+				
+				// Extract requested JSON paths
+				if len(params.JSONPaths) > 0 {
+					if err := applyJSONPaths(rowMap, params.JSONPaths); err != nil {
+						s.logger.WithError(err).Warn("Failed to extract JSON paths")
+						warnings.Count("rows skipped (JSON path extraction failed)", 1)
+						if rowErrors.Record(0, "JSON path extraction failed: "+err.Error(), rowStrings(rowValues)) != nil {
+							cancel()
+							return
+						}
+						continue
+					}
+				}
+				
+				cursor.Observe(rowMap[params.CursorColumn])
+				if renames != nil {
+					renameKeys(rowMap, renames)
+				}
+				
+				// Send row to channel
+				select {
+				case dataCh <- rowMap:
+				case <-ctx.Done():
+					return
+				}
 			}
 			
 			totalRows++
@@ -165,53 +546,222 @@ func (s *IngestServiceImpl) IngestClickHouseToFlatFile(
 	}()
 	
 	// Write data to flat file
-	count, err := s.flatFileService.WriteData(
-		ctx,
-		flatFileParams.FilePath,
-		flatFileParams.Delimiter,
-		columns,
-		dataCh,
-		progressCh,
-	)
+	var count int
+	if wide {
+		count, err = s.flatFileService.WriteRows(ctx, flatFileParams, outputColumns, rowCh, progressCh)
+	} else {
+		count, err = s.flatFileService.WriteData(ctx, flatFileParams, outputColumns, dataCh, progressCh)
+	}
 	
 	if err != nil {
 		return model.IngestionResult{}, err
 	}
 	
-	return model.IngestionResult{
+	result := model.IngestionResult{
 		TotalRecords: count,
-	}, nil
+		MaxCursor:    cursor.Value(),
+	}
+	if dedup != nil {
+		result.DuplicateRecords = dedup.Duplicates()
+	}
+	if lineage != nil {
+		result.Lineage = renameLineage(lineage, renames)
+		s.attachManifest(&result, flatFileParams.FilePath, query, queryArgs, outputColumns, warnings)
+	}
+	return result, nil
+}
+
+// attachManifest writes the manifest of an export with lineage next to its file
+// and names it in the result
+func (s *IngestServiceImpl) attachManifest(result *model.IngestionResult, filePath, query string, args []interface{}, columns []model.Column, warnings *WarningCollector) {
+	manifest := newExportManifest(filePath, query, args, result.TotalRecords, columns, result.Lineage)
+	if err := writeManifest(manifest); err != nil {
+		s.logger.WithError(err).Warn("Failed to write export manifest")
+		warnings.Add("export manifest not written: %v", err)
+	} else {
+		result.ManifestFile = manifest.File + manifestSuffix
+	}
 }
 
 // IngestFlatFileToClickHouse ingests data from a flat file to ClickHouse
 func (s *IngestServiceImpl) IngestFlatFileToClickHouse(
 	ctx context.Context,
-	flatFileParams model.FlatFileParams,
-	tableName string,
-	columns []model.Column,
+	params model.IngestionParams,
 	progressCh chan<- model.ProgressUpdate,
 ) (model.IngestionResult, error) {
+	flatFileParams := params.FlatFileParams
+	tableName := params.TableName
+	columns := params.Columns
+
+	// Resolve table engine from the ingestion mode
+	tableOpts, err := buildTableOptions(params)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+
+	// Ingest every column when none are given, using the cached schema of an
+	// unchanged file; the fingerprint records which version of the file was read
+	var fingerprint string
+	if len(columns) == 0 {
+		schema, err := s.flatFileService.DiscoverSchema(ctx, flatFileParams, nil)
+		if err != nil {
+			return model.IngestionResult{}, fmt.Errorf("failed to discover schema: %w", err)
+		}
+		columns, fingerprint = schema.Columns, schema.Fingerprint
+		for _, rename := range schema.Renames {
+			WarningsFromContext(ctx).Add("header %q at position %d renamed to %q (%s)", rename.Original, rename.Position, rename.Name, rename.Reason)
+		}
+	} else {
+		filePath, err := s.flatFileService.ResolvePath(flatFileParams.FilePath)
+		if err != nil {
+			return model.IngestionResult{}, err
+		}
+		if fingerprint, err = FileFingerprint(filePath, flatFileParams.Delimiter); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	
+	// Mapped columns land under their target names, keys included
+	renames, err := columnRenames(params.ColumnMappings, columns)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	tableOpts = renameTableOptions(tableOpts, renames)
+	
+	// Cleanse values between read and insert
+	transforms, err := compileTransforms(params.Transforms, columns)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	derived, err := compileDerivedColumns(params.DerivedColumns, columns, renames)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	rules, err := compileRules(params.Rules, columns, s.config.DedupMaxKeys)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	
+	// Resolve dedup key positions in the ingested columns
+	var keyIndexes []int
+	if len(params.DedupKey) > 0 {
+		keyIndexes, err = dedupKeyIndexes(params.DedupKey, selectedColumnNames(columns))
+		if err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	
+	// Resolve the cursor column position, if one is tracked
+	cursorIdx := -1
+	if params.CursorColumn != "" {
+		cursorIdx, err = cursorIndex(params.CursorColumn, selectedColumnNames(columns))
+		if err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	
+	// CDC loads carry sign, version and ingestion time columns
+	targetColumns := append(append([]model.Column{}, renameColumns(columns, renames)...), derivedTargetColumns(derived)...)
+	if params.Mode == "cdc" {
+		targetColumns = append(append([]model.Column{}, targetColumns...), cdcColumns(params)...)
+	}
+	
+	// Reconcile with an existing table according to the schema policy
+	targetSchema, err := s.planTargetSchema(ctx, params, tableName, targetColumns)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	
+	// Fill the table's columns missing from the source; their values follow the
+	// target columns in each row
+	defaults, err := compileColumnDefaults(ctx, params.ColumnDefaults, columns, targetSchema.diff)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	if len(defaults) > 0 {
+		defaultColumns := derivedTargetColumns(defaults)
+		targetSchema.columns = append(append([]model.Column{}, targetSchema.columns...), defaultColumns...)
+		for i := range defaults {
+			if targetSchema.positions != nil {
+				targetSchema.positions = append(targetSchema.positions, len(targetColumns)+i)
+			}
+		}
+
+		// Defaulted columns are loaded, so they no longer count as drift
+		var diff []model.SchemaDifference
+		for _, d := range targetSchema.diff {
+			if d.Kind != "extra" || !containsColumn(defaultColumns, d.Column) {
+				diff = append(diff, d)
+			}
+		}
+		targetSchema.diff = diff
+	}
+	
 	// Create table if it doesn't exist
-	if err := s.clickhouseService.CreateTable(ctx, tableName, columns); err != nil {
+	tableOpts = clusterOptions(tableOpts, params, s.clickhouse(ctx))
+	if err := s.clickhouse(ctx).CreateTable(ctx, tableName, targetColumns, tableOpts); err != nil {
 		return model.IngestionResult{}, fmt.Errorf("failed to create table: %w", err)
 	}
 	
 	// Read data from flat file
+	readErrCh := make(chan error, 1)
 	dataCh, err := s.flatFileService.ReadData(
 		ctx,
-		flatFileParams.FilePath,
-		flatFileParams.Delimiter,
+		flatFileParams,
 		columns,
+		readErrCh,
 	)
 	if err != nil {
 		return model.IngestionResult{}, fmt.Errorf("failed to read data: %w", err)
 	}
+	if len(transforms) > 0 {
+		dataCh = s.transformRows(ctx, dataCh, transforms)
+	}
+	if rules != nil {
+		dataCh = s.checkRows(ctx, dataCh, rules)
+	}
+	if len(derived) > 0 {
+		dataCh = s.deriveRows(ctx, dataCh, columns, derived)
+	}
+	
+	// Throttle reads if a row rate is configured
+	limiter := NewRateLimiter(effectiveRowRate(params.MaxRowsPerSecond, s.config.MaxRowsPerSecond))
+	if limiter != nil {
+		dataCh = s.throttleRows(ctx, dataCh, limiter)
+	}
+	
+	// Drop repeated keys if deduplication is requested
+	var dedup *Deduplicator
+	if len(keyIndexes) > 0 {
+		dedup = NewDeduplicator(s.config.DedupMaxKeys)
+		dataCh = s.dedupRows(ctx, dataCh, keyIndexes, dedup)
+	}
+	
+	// Track the largest cursor value loaded
+	var cursor *CursorTracker
+	if cursorIdx >= 0 {
+		cursor = NewCursorTracker()
+		dataCh = s.cursorRows(ctx, dataCh, cursorIdx, cursor)
+	}
+	
+	// Append CDC bookkeeping values
+	if params.Mode == "cdc" {
+		dataCh = s.cdcRows(ctx, dataCh, params)
+	}
+	if len(defaults) > 0 {
+		dataCh = s.deriveRows(ctx, dataCh, columns, defaults)
+	}
+	
+	// Drop the columns a mapped schema leaves out
+	if targetSchema.positions != nil {
+		dataCh = s.projectRows(ctx, dataCh, targetSchema.positions)
+	}
 	
 	// Insert data into ClickHouse
-	count, err := s.clickhouseService.InsertData(
+	count, err := s.clickhouse(ctx).InsertData(
 		ctx,
 		tableName,
-		columns,
+		targetSchema.columns,
 		dataCh,
 		progressCh,
 	)
@@ -219,8 +769,297 @@ func (s *IngestServiceImpl) IngestFlatFileToClickHouse(
 	if err != nil {
 		return model.IngestionResult{}, fmt.Errorf("failed to insert data: %w", err)
 	}
+	if err := <-readErrCh; err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to read data: %w", err)
+	}
+	
+	// Collapse replaced or cancelled rows so readers see one version per key
+	if (params.Mode == "upsert" || params.Mode == "cdc") && params.OptimizeFinal {
+		if err := s.clickhouse(ctx).OptimizeTable(ctx, localTableName(tableName, params.Distributed), params.Cluster); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	
+	result := model.IngestionResult{
+		TotalRecords:      count,
+		MaxCursor:         cursor.Value(),
+		SchemaFingerprint: fingerprint,
+		SchemaDiff:        targetSchema.diff,
+		RuleViolations:    rules.Violations(),
+	}
+	if dedup != nil {
+		result.DuplicateRecords = dedup.Duplicates()
+	}
+	return result, nil
+}
+
+// IngestClickHouseToClickHouse copies a table to another ClickHouse instance,
+// recreating it from the source's own DDL
+func (s *IngestServiceImpl) IngestClickHouseToClickHouse(
+	ctx context.Context,
+	params model.IngestionParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	if params.TargetConnection == nil {
+		return model.IngestionResult{}, fmt.Errorf("targetConnection is required for ClickHouse to ClickHouse copies")
+	}
+	if len(params.ColumnMappings) > 0 {
+		return model.IngestionResult{}, fmt.Errorf("columnMappings are not supported for ClickHouse to ClickHouse copies, which replay the source DDL")
+	}
+	targetTable := params.TargetTableName
+	if targetTable == "" {
+		targetTable = params.TableName
+	}
+	
+	// Replay the source table definition on the target
+	ddl, err := s.clickhouse(ctx).ShowCreateTable(ctx, params.TableName)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	ddl, err = rewriteDDL(ddl, targetTable, params.DDLRewrite)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	
+	target := NewClickHouseService(s.config, s.logger)
+	if err := target.Connect(ctx, *params.TargetConnection, params.TargetConnection.Token); err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to connect to target: %w", err)
+	}
+	defer target.Close()
+	
+	if err := target.ExecDDL(ctx, ddl); err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to create target table: %w", err)
+	}
+	
+	// Copy every column unless a selection was given
+	columns := params.Columns
+	if len(columns) == 0 {
+		columns, err = s.clickhouse(ctx).GetTableColumns(ctx, params.TableName)
+		if err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	query := params.Query
+	if query == "" {
+		query, err = selectColumnsQuery(params.TableName, columns)
+		if err != nil {
+			return model.IngestionResult{}, err
+		}
+	} else if err := CheckReadOnlyQuery(query); err != nil {
+		return model.IngestionResult{}, err
+	}
+	cursorIdx := -1
+	if params.CursorColumn != "" {
+		cursorIdx, err = cursorIndex(params.CursorColumn, selectedColumnNames(columns))
+		if err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	
+	// Stream rows from the source; stop reading if the insert fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	rowsCh := make(chan []interface{}, s.config.PipelineChannelSize)
+	readErrCh := make(chan error, 1)
+	go func() {
+		defer close(rowsCh)
+		readErrCh <- s.clickhouse(ctx).QueryRows(ctx, query, rowsCh)
+	}()
+	
+	var dataCh <-chan []interface{} = rowsCh
+	limiter := NewRateLimiter(effectiveRowRate(params.MaxRowsPerSecond, s.config.MaxRowsPerSecond))
+	if limiter != nil {
+		dataCh = s.throttleRows(ctx, dataCh, limiter)
+	}
+	var cursor *CursorTracker
+	if cursorIdx >= 0 {
+		cursor = NewCursorTracker()
+		dataCh = s.cursorRows(ctx, dataCh, cursorIdx, cursor)
+	}
+	
+	count, err := target.InsertData(ctx, targetTable, columns, dataCh, progressCh)
+	if err != nil {
+		cancel()
+		return model.IngestionResult{}, fmt.Errorf("failed to insert data: %w", err)
+	}
+	if err := <-readErrCh; err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to read source: %w", err)
+	}
 	
 	return model.IngestionResult{
 		TotalRecords: count,
+		MaxCursor:    cursor.Value(),
+		Query:        query,
 	}, nil
+}
+
+// CheckHealth probes the source and target of a running job
+func (s *IngestServiceImpl) CheckHealth(ctx context.Context, params model.IngestionParams) model.JobHealth {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	
+	var sourceErr, targetErr error
+	switch {
+	case params.SourceType == "clickhouse" && params.TargetType == "clickhouse":
+		// The target connection is owned by the running job and not probed here
+		sourceErr = s.clickhouse(ctx).Ping(ctx)
+	case params.SourceType == "clickhouse":
+		sourceErr = s.clickhouse(ctx).Ping(ctx)
+		targetErr = s.flatFileService.CheckWritable(params.FlatFileParams.FilePath)
+	case params.SourceType == "flatfile":
+		sourceErr = s.flatFileService.CheckReadable(params.FlatFileParams.FilePath)
+		targetErr = s.clickhouse(ctx).Ping(ctx)
+	}
+	
+	health := model.JobHealth{
+		SourceReachable: sourceErr == nil,
+		TargetReachable: targetErr == nil,
+	}
+	if sourceErr != nil {
+		health.SourceError = sourceErr.Error()
+	}
+	if targetErr != nil {
+		health.TargetError = targetErr.Error()
+	}
+	return health
+}
+
+// throttleRows forwards rows from in, pacing them with the given limiter
+func (s *IngestServiceImpl) throttleRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	limiter *RateLimiter,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+	
+	go func() {
+		defer close(out)
+		
+		for row := range in {
+			if err := limiter.Wait(ctx, 1); err != nil {
+				return
+			}
+			
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	
+	return out
+}
+
+// buildTableOptions derives the target table engine from the ingestion mode, with
+// the requested keys, TTLs, column storage and sharding
+func buildTableOptions(params model.IngestionParams) (model.TableOptions, error) {
+	if (params.Engine != "" || len(params.EngineArgs) > 0) && params.Mode != "" && params.Mode != "append" {
+		return model.TableOptions{}, fmt.Errorf("engine can only be chosen for append loads; %s mode picks its own", params.Mode)
+	}
+	if len(params.OrderBy) > 0 && params.Mode != "" && params.Mode != "append" {
+		return model.TableOptions{}, fmt.Errorf("orderBy can only be set for append loads; %s mode sorts by upsertKey", params.Mode)
+	}
+
+	opts, err := modeTableOptions(params)
+	if err != nil {
+		return model.TableOptions{}, err
+	}
+	if len(params.OrderBy) > 0 {
+		opts.OrderBy = params.OrderBy
+	}
+	opts.PrimaryKey = params.PrimaryKey
+	opts.PartitionBy = params.PartitionBy
+	opts.TTL = params.TTL
+	opts.ColumnTTLs = params.ColumnTTLs
+	opts.ColumnStorage = params.ColumnStorage
+	opts.Distributed = params.Distributed
+	opts.ZooKeeperPath = params.ZooKeeperPath
+	opts.ReplicaName = params.ReplicaName
+	if _, _, err := lookupTableEngine(opts); err != nil {
+		return model.TableOptions{}, err
+	}
+
+	// Keys and TTLs name ingested columns; discovered columns are checked on creation
+	if len(params.Columns) > 0 {
+		for _, key := range keyColumns(opts) {
+			if !containsColumn(params.Columns, key) {
+				return model.TableOptions{}, fmt.Errorf("key column %s is not a selected column", key)
+			}
+		}
+		dates, expiring := ttlColumns(opts)
+		for _, name := range append(dates, expiring...) {
+			if !containsColumn(params.Columns, name) {
+				return model.TableOptions{}, fmt.Errorf("TTL column %s is not a selected column", name)
+			}
+		}
+		for _, c := range opts.ColumnStorage {
+			if !containsColumn(params.Columns, c.Column) {
+				return model.TableOptions{}, fmt.Errorf("storage settings name %s, which is not a selected column", c.Column)
+			}
+		}
+		if d := opts.Distributed; d != nil && d.ShardingKey != "" && !containsColumn(params.Columns, d.ShardingKey) {
+			return model.TableOptions{}, fmt.Errorf("sharding key %s is not a selected column", d.ShardingKey)
+		}
+	}
+	return opts, nil
+}
+
+// modeTableOptions returns the engine and sorting key of the ingestion mode
+func modeTableOptions(params model.IngestionParams) (model.TableOptions, error) {
+	switch params.Mode {
+	case "", "append":
+		return appendTableOptions(params)
+	case "upsert", "cdc":
+		if len(params.UpsertKey) == 0 {
+			return model.TableOptions{}, fmt.Errorf("%s mode requires at least one key column", params.Mode)
+		}
+		
+		// Key, version and marker columns must be part of the ingested columns
+		known := make(map[string]bool, len(params.Columns))
+		for _, col := range params.Columns {
+			known[col.Name] = true
+		}
+		for _, key := range params.UpsertKey {
+			if !known[key] {
+				return model.TableOptions{}, fmt.Errorf("key column %s is not selected", key)
+			}
+		}
+		if params.VersionColumn != "" && !known[params.VersionColumn] {
+			return model.TableOptions{}, fmt.Errorf("version column %s is not selected", params.VersionColumn)
+		}
+		
+		if params.Mode == "cdc" {
+			if params.DeleteMarkerColumn != "" && !known[params.DeleteMarkerColumn] {
+				return model.TableOptions{}, fmt.Errorf("delete marker column %s is not selected", params.DeleteMarkerColumn)
+			}
+			switch params.CDCEngine {
+			case "", "VersionedCollapsingMergeTree", "CollapsingMergeTree":
+			default:
+				return model.TableOptions{}, fmt.Errorf("unsupported CDC engine: %s", params.CDCEngine)
+			}
+			return cdcTableOptions(params), nil
+		}
+		
+		opts := model.TableOptions{
+			Engine:  "ReplacingMergeTree",
+			OrderBy: params.UpsertKey,
+		}
+		if params.VersionColumn != "" {
+			opts.EngineArgs = []string{params.VersionColumn}
+		}
+		return opts, nil
+	default:
+		return model.TableOptions{}, fmt.Errorf("unsupported ingestion mode: %s", params.Mode)
+	}
+}
+
+// selectedColumnNames extracts the names of the given columns
+func selectedColumnNames(columns []model.Column) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return names
 }
\ No newline at end of file
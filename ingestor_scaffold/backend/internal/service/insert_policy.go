@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/model"
+)
+
+// Insert modes: prepared batches the server writes before acknowledging, or
+// batches the server buffers and flushes with other inserts
+const (
+	InsertSync  = "sync"
+	InsertAsync = "async"
+)
+
+type insertSettingsKey struct{}
+
+// checkInsertPolicy validates the insert mode of a job. Async inserts only apply to
+// rows this service sends to ClickHouse.
+func checkInsertPolicy(params model.IngestionParams) error {
+	switch params.InsertMode {
+	case "", InsertSync:
+		if params.AsyncInsert != nil {
+			return fmt.Errorf("asyncInsert settings require insertMode async")
+		}
+		return nil
+	case InsertAsync:
+	default:
+		return fmt.Errorf("unsupported insert mode %q: must be sync or async", params.InsertMode)
+	}
+
+	if params.TargetType != "clickhouse" || params.TableFunction != nil {
+		return fmt.Errorf("async inserts apply to rows sent to a ClickHouse table")
+	}
+	if spec := params.AsyncInsert; spec != nil {
+		if spec.WaitTimeoutSeconds < 0 || spec.BusyTimeoutMs < 0 || spec.MaxDataSize < 0 {
+			return fmt.Errorf("asyncInsert settings must not be negative")
+		}
+		if spec.Wait != nil && !*spec.Wait && spec.WaitTimeoutSeconds > 0 {
+			return fmt.Errorf("waitTimeoutSeconds requires waiting for async inserts")
+		}
+	}
+	return nil
+}
+
+// WithInsertPolicy returns a context whose inserts follow the insert mode of a job.
+// Async inserts wait for their flush unless told otherwise, so rows are durable
+// when the job reports them.
+func WithInsertPolicy(ctx context.Context, params model.IngestionParams) context.Context {
+	if params.InsertMode != InsertAsync {
+		return ctx
+	}
+
+	settings := clickhouse.Settings{
+		"async_insert":          1,
+		"wait_for_async_insert": 1,
+	}
+	if spec := params.AsyncInsert; spec != nil {
+		if spec.Wait != nil && !*spec.Wait {
+			settings["wait_for_async_insert"] = 0
+		}
+		if spec.WaitTimeoutSeconds > 0 {
+			settings["wait_for_async_insert_timeout"] = spec.WaitTimeoutSeconds
+		}
+		if spec.BusyTimeoutMs > 0 {
+			settings["async_insert_busy_timeout_ms"] = spec.BusyTimeoutMs
+		}
+		if spec.MaxDataSize > 0 {
+			settings["async_insert_max_data_size"] = spec.MaxDataSize
+		}
+	}
+	return context.WithValue(ctx, insertSettingsKey{}, settings)
+}
+
+// insertContext is queryContext for inserts, with the settings of the job's
+// insert mode
+func insertContext(ctx context.Context) context.Context {
+	settings, _ := ctx.Value(insertSettingsKey{}).(clickhouse.Settings)
+	return settingsContext(ctx, settings)
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// JobService defines operations for tracking ingestion jobs
+type JobService interface {
+	CreateJob(params model.IngestionParams) model.Job
+	CreateTask(kind string, params model.IngestionParams) model.Job
+	GetJob(id string) (model.Job, error)
+	ListRunningJobs() []model.Job
+	ListJobs(status string, labels map[string]string) []model.Job
+	RecordProgress(id string, count int)
+	RecordUpdate(id string, update model.ProgressUpdate)
+	AttachCancel(id string, cancel context.CancelFunc)
+	CancelJob(id, reason string) error
+	MarkStalled(id string, diagnostics model.JobDiagnostics)
+	MarkQueued(id string, until time.Time)
+	MarkRunning(id string)
+	CompleteJob(id string, result model.IngestionResult, jobErr error)
+	Summarize(id string, warnRejectRatio, failRejectRatio float64) (model.JobSummary, error)
+	OnComplete(hook func(job model.Job))
+	Restore() ([]model.Job, error)
+}
+
+// JobServiceImpl implements JobService with an in-memory store
+type JobServiceImpl struct {
+	mu            sync.RWMutex
+	jobs          map[string]*model.Job
+	cancels       map[string]context.CancelFunc
+	cancelReasons map[string]string
+	hooks         []func(job model.Job)
+	persistMu     sync.Mutex
+	store         *StateStore
+	config        *config.Config
+	logger        *logrus.Logger
+}
+
+// NewJobService creates a new job service
+func NewJobService(store *StateStore, config *config.Config, logger *logrus.Logger) JobService {
+	return &JobServiceImpl{
+		jobs:          make(map[string]*model.Job),
+		cancels:       make(map[string]context.CancelFunc),
+		cancelReasons: make(map[string]string),
+		store:         store,
+		config:        config,
+		logger:        logger,
+	}
+}
+
+// CreateJob registers a new running ingestion job
+func (s *JobServiceImpl) CreateJob(params model.IngestionParams) model.Job {
+	return s.CreateTask("", params)
+}
+
+// CreateTask registers a new running job of the given kind
+func (s *JobServiceImpl) CreateTask(kind string, params model.IngestionParams) model.Job {
+	now := time.Now()
+	job := &model.Job{
+		ID:             newJobID(),
+		Kind:           kind,
+		Status:         "running",
+		Params:         params,
+		StartedAt:      now,
+		LastProgressAt: now,
+	}
+
+	s.mu.Lock()
+	s.jobs[job.ID] = job
+	s.mu.Unlock()
+
+	s.persist()
+	return *job
+}
+
+// GetJob returns a job by ID
+func (s *JobServiceImpl) GetJob(id string) (model.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return model.Job{}, fmt.Errorf("job %s not found", id)
+	}
+	return *job, nil
+}
+
+// ListRunningJobs returns all jobs that have not completed
+func (s *JobServiceImpl) ListRunningJobs() []model.Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var jobs []model.Job
+	for _, job := range s.jobs {
+		if job.Status == "running" {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// ListJobs returns the job history, newest first, optionally restricted to a status
+// and to the jobs carrying every given label
+func (s *JobServiceImpl) ListJobs(status string, labels map[string]string) []model.Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := []model.Job{}
+	for _, job := range s.jobs {
+		if status != "" && job.Status != status {
+			continue
+		}
+		if !MatchLabels(job.Params.Labels, labels) {
+			continue
+		}
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.After(jobs[j].StartedAt)
+	})
+	return jobs
+}
+
+// RecordProgress notes that a job made progress, clearing any stalled flag
+func (s *JobServiceImpl) RecordProgress(id string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.LastProgressAt = time.Now()
+		job.LastCount = count
+		job.Stalled = false
+	}
+}
+
+// RecordUpdate notes a progress update of a background job, keeping it for
+// clients following the job
+func (s *JobServiceImpl) RecordUpdate(id string, update model.ProgressUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.LastProgressAt = time.Now()
+		job.LastCount = update.Count
+		job.Progress = &update
+		job.Stalled = false
+	}
+}
+
+// AttachCancel registers the function that stops a running job
+func (s *JobServiceImpl) AttachCancel(id string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancels[id] = cancel
+}
+
+// CancelJob stops a running job, recording why
+func (s *JobServiceImpl) CancelJob(id, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	if job.Status != "running" && job.Status != "queued" {
+		return fmt.Errorf("job %s is not running", id)
+	}
+	cancel, ok := s.cancels[id]
+	if !ok {
+		return fmt.Errorf("job %s cannot be cancelled", id)
+	}
+
+	s.cancelReasons[id] = reason
+	cancel()
+	return nil
+}
+
+// MarkStalled flags a job as stalled and stores its diagnostics
+func (s *JobServiceImpl) MarkStalled(id string, diagnostics model.JobDiagnostics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.Stalled = true
+		job.Diagnostics = &diagnostics
+	}
+}
+
+// MarkQueued moves a job to the queue until the given time
+func (s *JobServiceImpl) MarkQueued(id string, until time.Time) {
+	s.mu.Lock()
+	if job, ok := s.jobs[id]; ok {
+		job.Status = "queued"
+		job.QueuedUntil = &until
+	}
+	s.mu.Unlock()
+
+	s.persist()
+}
+
+// MarkRunning moves a queued job back to running
+func (s *JobServiceImpl) MarkRunning(id string) {
+	s.mu.Lock()
+	if job, ok := s.jobs[id]; ok && job.Status == "queued" {
+		now := time.Now()
+		job.Status = "running"
+		job.QueuedUntil = nil
+		job.LastProgressAt = now
+	}
+	s.mu.Unlock()
+
+	s.persist()
+}
+
+// CompleteJob records the outcome of a job
+func (s *JobServiceImpl) CompleteJob(id string, result model.IngestionResult, jobErr error) {
+	s.mu.Lock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		s.logger.WithField("jobId", id).Warn("Completing unknown job")
+		return
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if job.Kind == "" {
+		result.Cost = JobCost(s.config, job.Params, result)
+	}
+	job.Result = result
+	if reason, cancelled := s.cancelReasons[id]; cancelled && jobErr != nil {
+		job.Status = "cancelled"
+		job.Error = reason
+	} else if jobErr != nil {
+		job.Status = "error"
+		job.Error = jobErr.Error()
+	} else {
+		job.Status = "success"
+	}
+	delete(s.cancels, id)
+	delete(s.cancelReasons, id)
+
+	completed := *job
+	hooks := s.hooks
+	s.mu.Unlock()
+
+	s.persist()
+
+	// Run completion hooks outside the lock
+	for _, hook := range hooks {
+		go hook(completed)
+	}
+}
+
+// OnComplete registers a hook called with each job once it finishes
+func (s *JobServiceImpl) OnComplete(hook func(job model.Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hooks = append(s.hooks, hook)
+}
+
+// Restore loads persisted jobs. Jobs that were still running when the server
+// stopped are marked as interrupted and returned so they can be resubmitted.
+func (s *JobServiceImpl) Restore() ([]model.Job, error) {
+	var jobs []model.Job
+	found, err := s.store.Load(stateJobsFile, &jobs)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var interrupted []model.Job
+	now := time.Now()
+
+	s.mu.Lock()
+	for i := range jobs {
+		job := jobs[i]
+		if _, exists := s.jobs[job.ID]; exists {
+			continue
+		}
+		if job.Status == "running" || job.Status == "queued" {
+			job.Status = "error"
+			job.Error = "interrupted by server restart"
+			job.FinishedAt = &now
+			interrupted = append(interrupted, job)
+		}
+		s.jobs[job.ID] = &job
+	}
+	s.mu.Unlock()
+
+	s.persist()
+	return interrupted, nil
+}
+
+// pruneJobs drops finished jobs beyond JOB_HISTORY_SIZE or older than
+// JOB_HISTORY_MAX_AGE; running and queued jobs are always kept. Callers hold s.mu.
+func (s *JobServiceImpl) pruneJobs(now time.Time) int {
+	var finished []*model.Job
+	pruned := 0
+	for id, job := range s.jobs {
+		if job.FinishedAt == nil {
+			continue
+		}
+		if s.config.JobHistoryMaxAge > 0 && now.Sub(*job.FinishedAt) > s.config.JobHistoryMaxAge {
+			delete(s.jobs, id)
+			pruned++
+			continue
+		}
+		finished = append(finished, job)
+	}
+
+	if limit := s.config.JobHistorySize; limit > 0 && len(finished) > limit {
+		sort.Slice(finished, func(i, j int) bool {
+			return finished[i].FinishedAt.After(*finished[j].FinishedAt)
+		})
+		for _, job := range finished[limit:] {
+			delete(s.jobs, job.ID)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// persist prunes the job history and writes the remaining jobs to the state store
+func (s *JobServiceImpl) persist() {
+	s.mu.Lock()
+	if pruned := s.pruneJobs(time.Now()); pruned > 0 {
+		s.logger.WithField("jobs", pruned).Debug("Pruned job history")
+	}
+	s.mu.Unlock()
+
+	if s.store == nil {
+		return
+	}
+
+	// Serialize writers so the newest snapshot always lands last
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	s.mu.RLock()
+	jobs := make([]model.Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		snapshot := *job
+		snapshot.Params = s.store.redactParams(snapshot.Params)
+		jobs = append(jobs, snapshot)
+	}
+	s.mu.RUnlock()
+
+	if err := s.store.Save(stateJobsFile, jobs); err != nil {
+		s.logger.WithError(err).Warn("Failed to persist jobs")
+	}
+}
+
+// Summarize evaluates a job against reject-ratio gates and returns a verdict
+func (s *JobServiceImpl) Summarize(id string, warnRejectRatio, failRejectRatio float64) (model.JobSummary, error) {
+	job, err := s.GetJob(id)
+	if err != nil {
+		return model.JobSummary{}, err
+	}
+
+	summary := model.JobSummary{
+		JobID:   job.ID,
+		Verdict: "ok",
+		Status:  job.Status,
+		Rows:    job.Result.TotalRecords,
+		Rejects: job.Result.RejectedRecords,
+	}
+
+	// Ratio of rejects to all rows seen
+	seen := job.Result.TotalRecords + job.Result.RejectedRecords
+	if seen > 0 {
+		summary.RejectRatio = float64(job.Result.RejectedRecords) / float64(seen)
+	}
+
+	end := time.Now()
+	if job.FinishedAt != nil {
+		end = *job.FinishedAt
+	}
+	summary.DurationMs = end.Sub(job.StartedAt).Milliseconds()
+
+	switch {
+	case job.Status == "running" || job.Status == "queued":
+		summary.Verdict = "pending"
+		summary.Reasons = append(summary.Reasons, "job is still "+job.Status)
+	case job.Status == "error" || job.Status == "cancelled":
+		summary.Verdict = "fail"
+		summary.Reasons = append(summary.Reasons, "job "+job.Status+": "+job.Error)
+	case summary.RejectRatio > failRejectRatio:
+		summary.Verdict = "fail"
+		summary.Reasons = append(summary.Reasons, fmt.Sprintf("reject ratio %.4f exceeds fail threshold %.4f", summary.RejectRatio, failRejectRatio))
+	case summary.RejectRatio > warnRejectRatio:
+		summary.Verdict = "warn"
+		summary.Reasons = append(summary.Reasons, fmt.Sprintf("reject ratio %.4f exceeds warn threshold %.4f", summary.RejectRatio, warnRejectRatio))
+	}
+
+	// Repeat loads that succeeded despite schema drift still deserve a look
+	if drift := describeDrift(job.Result.SchemaDiff); drift != "" && summary.Verdict == "ok" {
+		summary.Verdict = "warn"
+		summary.Reasons = append(summary.Reasons, drift)
+	}
+
+	return summary, nil
+}
+
+// describeDrift counts the source columns added to, removed from and retyped
+// against the target table, or returns "" without drift
+func describeDrift(diff []model.SchemaDifference) string {
+	var added, removed, retyped int
+	for _, d := range diff {
+		switch d.Kind {
+		case "missing":
+			added++
+		case "extra":
+			removed++
+		case "type":
+			retyped++
+		}
+	}
+	if added+removed+retyped == 0 {
+		return ""
+	}
+	return fmt.Sprintf("schema drift against the target table: %d columns added, %d removed, %d retyped", added, removed, retyped)
+}
+
+// newJobID generates a random job identifier
+func newJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
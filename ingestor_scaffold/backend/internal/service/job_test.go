@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestJobService(t *testing.T, cfg *config.Config) (*JobServiceImpl, *StateStore) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store, err := NewStateStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewJobService(store, cfg, logger).(*JobServiceImpl), store
+}
+
+func TestJobHistorySize(t *testing.T) {
+	s, store := newTestJobService(t, &config.Config{JobHistorySize: 2})
+
+	running := s.CreateJob(model.IngestionParams{})
+	var finished []model.Job
+	for i := 0; i < 4; i++ {
+		job := s.CreateJob(model.IngestionParams{})
+		s.CompleteJob(job.ID, model.IngestionResult{}, errors.New("failed"))
+		finished = append(finished, job)
+	}
+
+	// The two newest finished jobs and the running one remain
+	jobs := s.ListJobs("", nil)
+	assert.Len(t, jobs, 3)
+	_, err := s.GetJob(running.ID)
+	assert.NoError(t, err)
+	_, err = s.GetJob(finished[0].ID)
+	assert.Error(t, err)
+	_, err = s.GetJob(finished[3].ID)
+	assert.NoError(t, err)
+
+	var persisted []model.Job
+	found, err := store.Load(stateJobsFile, &persisted)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Len(t, persisted, 3)
+}
+
+func TestJobHistoryMaxAge(t *testing.T) {
+	s, _ := newTestJobService(t, &config.Config{JobHistoryMaxAge: time.Hour})
+
+	old := s.CreateJob(model.IngestionParams{})
+	s.CompleteJob(old.ID, model.IngestionResult{}, nil)
+	recent := s.CreateJob(model.IngestionParams{})
+	s.CompleteJob(recent.ID, model.IngestionResult{}, nil)
+
+	s.mu.Lock()
+	finishedAt := time.Now().Add(-2 * time.Hour)
+	s.jobs[old.ID].FinishedAt = &finishedAt
+	s.mu.Unlock()
+
+	s.persist()
+	_, err := s.GetJob(old.ID)
+	assert.Error(t, err)
+	_, err = s.GetJob(recent.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// isJSONType reports whether a ClickHouse type holds JSON documents
+func isJSONType(chType string) bool {
+	t := baseType(chType)
+	return t == "JSON" || strings.HasPrefix(t, "JSON(") || strings.HasPrefix(t, "Object(")
+}
+
+// RenderPreviewJSON turns JSON text in JSON-typed columns into structured preview values
+func RenderPreviewJSON(rows []map[string]interface{}, columns []model.Column) {
+	jsonColumns := make(map[string]bool)
+	for _, col := range columns {
+		if isJSONType(col.Type) {
+			jsonColumns[col.Name] = true
+		}
+	}
+	if len(jsonColumns) == 0 {
+		return
+	}
+
+	for _, row := range rows {
+		for name := range jsonColumns {
+			if text, ok := row[name].(string); ok && json.Valid([]byte(text)) {
+				row[name] = json.RawMessage(text)
+			}
+		}
+	}
+}
+
+// jsonDocument returns a JSON column value as decoded Go data
+func jsonDocument(value interface{}) (interface{}, error) {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	case json.RawMessage:
+		raw = v
+	default:
+		// Structured values from the driver round-trip through JSON so paths see plain maps
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// jsonPathStep is one segment of a JSON path: an object key followed by array indexes
+type jsonPathStep struct {
+	key     string
+	indexes []int
+}
+
+// parseJSONPath parses a dotted path with optional [n] indexes, e.g. "user.tags[0]"
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+	for _, segment := range strings.Split(path, ".") {
+		step := jsonPathStep{key: segment}
+		if i := strings.Index(segment, "["); i >= 0 {
+			step.key = segment[:i]
+			for _, part := range strings.Split(segment[i+1:], "[") {
+				n, err := strconv.Atoi(strings.TrimSuffix(part, "]"))
+				if err != nil || !strings.HasSuffix(part, "]") {
+					return nil, fmt.Errorf("invalid index in JSON path %q", path)
+				}
+				step.indexes = append(step.indexes, n)
+			}
+		}
+		if step.key == "" && len(step.indexes) == 0 {
+			return nil, fmt.Errorf("empty segment in JSON path %q", path)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// extractJSONPath follows parsed path steps through a decoded document; missing values yield nil
+func extractJSONPath(doc interface{}, steps []jsonPathStep) interface{} {
+	current := doc
+	for _, step := range steps {
+		if step.key != "" {
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			current = obj[step.key]
+		}
+		for _, n := range step.indexes {
+			arr, ok := current.([]interface{})
+			if !ok || n < 0 || n >= len(arr) {
+				return nil
+			}
+			current = arr[n]
+		}
+	}
+	return current
+}
+
+// jsonPathColumns returns the export columns added by JSON path extraction
+func jsonPathColumns(paths []model.JSONPathColumn) []model.Column {
+	columns := make([]model.Column, len(paths))
+	for i, path := range paths {
+		columns[i] = model.Column{Name: path.Name, Type: "String"}
+	}
+	return columns
+}
+
+// validateJSONPaths checks that every path reads from a selected column
+func validateJSONPaths(paths []model.JSONPathColumn, columns []model.Column) error {
+	selected := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		selected[col.Name] = true
+	}
+	for _, path := range paths {
+		if path.Name == "" || path.Path == "" {
+			return fmt.Errorf("JSON path columns require a name and a path")
+		}
+		if !selected[path.Column] {
+			return fmt.Errorf("JSON path %s reads from unselected column %s", path.Name, path.Column)
+		}
+		if selected[path.Name] {
+			return fmt.Errorf("JSON path column %s clashes with a selected column", path.Name)
+		}
+		if _, err := parseJSONPath(path.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyJSONPaths adds the extracted path values to an exported row.
+// Scalars are exported as-is; objects and arrays as JSON text.
+func applyJSONPaths(row map[string]interface{}, paths []model.JSONPathColumn) error {
+	for _, path := range paths {
+		doc, err := jsonDocument(row[path.Column])
+		if err != nil {
+			return fmt.Errorf("column %s is not valid JSON: %w", path.Column, err)
+		}
+		steps, err := parseJSONPath(path.Path)
+		if err != nil {
+			return err
+		}
+		value := extractJSONPath(doc, steps)
+
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			row[path.Name] = string(b)
+		case nil:
+			row[path.Name] = ""
+		default:
+			row[path.Name] = value
+		}
+	}
+	return nil
+}
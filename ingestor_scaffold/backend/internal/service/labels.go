@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxLabels bounds the labels on a connection or a job
+const maxLabels = 20
+
+// maxLabelValueLength bounds a label value in bytes
+const maxLabelValueLength = 128
+
+// labelKeyRe accepts label keys that are also valid Prometheus label values and
+// JSON keys readable with JSONExtractString
+var labelKeyRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,62}$`)
+
+type labelsKey struct{}
+
+// ValidateLabels checks the keys, values and number of labels
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("too many labels: %d, at most %d are allowed", len(labels), maxLabels)
+	}
+	for key, value := range labels {
+		if !labelKeyRe.MatchString(key) {
+			return fmt.Errorf("invalid label key %q: use letters, digits and underscores", key)
+		}
+		if len(value) > maxLabelValueLength {
+			return fmt.Errorf("label %s is longer than %d bytes", key, maxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// MergeLabels returns the connection's labels overridden by the job's, or nil
+func MergeLabels(connection, job map[string]string) map[string]string {
+	if len(connection)+len(job) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(connection)+len(job))
+	for key, value := range connection {
+		merged[key] = value
+	}
+	for key, value := range job {
+		merged[key] = value
+	}
+	return merged
+}
+
+// WithLabels returns a context whose ClickHouse queries are tagged with the labels
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	if len(labels) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, labelsKey{}, labels)
+}
+
+// LabelsFromContext returns the labels carried by ctx, or nil
+func LabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
+
+// labelComment renders labels as the log_comment of ClickHouse queries: a JSON
+// object with sorted keys, so system.query_log can be grouped with
+// JSONExtractString(log_comment, 'team')
+func labelComment(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	comment, err := json.Marshal(labels)
+	if err != nil {
+		return ""
+	}
+	return string(comment)
+}
+
+// MatchLabels reports whether labels hold every key and value of filter
+func MatchLabels(labels, filter map[string]string) bool {
+	for key, value := range filter {
+		if got, ok := labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabelFilters parses "key=value" (or "key:value") selectors, as given in
+// repeated label query parameters
+func ParseLabelFilters(selectors []string) (map[string]string, error) {
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+	filter := make(map[string]string, len(selectors))
+	for _, selector := range selectors {
+		key, value, ok := strings.Cut(selector, "=")
+		if !ok {
+			key, value, ok = strings.Cut(selector, ":")
+		}
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label selector %q: expected key=value", selector)
+		}
+		filter[key] = value
+	}
+	return filter, nil
+}
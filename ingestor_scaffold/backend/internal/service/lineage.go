@@ -0,0 +1,222 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+var (
+	// aliasRe splits "expression AS alias" entries of a join's selected columns
+	aliasRe = regexp.MustCompile(`(?is)^(.+?)\s+AS\s+([A-Za-z_][A-Za-z0-9_]*)$`)
+
+	// identifierRe matches a plain column name
+	identifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// joinTypes are the accepted join kinds
+	joinTypes = map[string]bool{
+		"INNER JOIN":       true,
+		"LEFT JOIN":        true,
+		"LEFT OUTER JOIN":  true,
+		"RIGHT JOIN":       true,
+		"RIGHT OUTER JOIN": true,
+		"FULL JOIN":        true,
+		"FULL OUTER JOIN":  true,
+		"CROSS JOIN":       true,
+		"LEFT SEMI JOIN":   true,
+		"LEFT ANTI JOIN":   true,
+		"ANY LEFT JOIN":    true,
+		"ANY INNER JOIN":   true,
+		"ASOF JOIN":        true,
+		"ASOF LEFT JOIN":   true,
+	}
+
+	// joinOperators compare the columns of a join key
+	joinOperators = map[string]bool{"=": true, "<": true, "<=": true, ">": true, ">=": true}
+)
+
+// manifestSuffix is appended to an export's path to name its manifest
+const manifestSuffix = ".manifest.json"
+
+// joinSelect builds the query of a join, the values bound to its filter parameters
+// and the lineage of each output column.
+// Selected columns are plain names, "column AS alias" or "expression AS alias";
+// plain names are qualified with their table and exported as "table.column".
+func joinSelect(params model.JoinParams) (string, []interface{}, []model.ColumnLineage, error) {
+	if len(params.Tables) < 2 {
+		return "", nil, nil, fmt.Errorf("at least two tables are required for a join")
+	}
+	if params.WhereClause != "" {
+		return "", nil, nil, fmt.Errorf("whereClause is no longer supported, use filters")
+	}
+
+	var selectList []string
+	var lineage []model.ColumnLineage
+	seen := make(map[string]bool)
+	quotedTables := make([]string, len(params.Tables))
+	for i, table := range params.Tables {
+		quotedTable, err := QuoteTable(table.Name)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		quotedTables[i] = quotedTable
+
+		for _, entry := range table.SelectedColumns {
+			entry = strings.TrimSpace(entry)
+			col := model.ColumnLineage{SourceTable: table.Name}
+			expr := entry
+
+			if m := aliasRe.FindStringSubmatch(entry); m != nil {
+				expr, col.Column = strings.TrimSpace(m[1]), m[2]
+			}
+			switch {
+			case identifierRe.MatchString(expr):
+				col.SourceColumn = expr
+				expr = quotedTable + ".`" + expr + "`"
+				if col.Column == "" {
+					col.Column = table.Name + "." + col.SourceColumn
+				}
+			case col.Column == "":
+				return "", nil, nil, fmt.Errorf("expression %q in table %s needs an alias", entry, table.Name)
+			default:
+				col.Expression = expr
+			}
+
+			if seen[col.Column] {
+				return "", nil, nil, fmt.Errorf("duplicate output column %s", col.Column)
+			}
+			seen[col.Column] = true
+			selectList = append(selectList, fmt.Sprintf("%s AS `%s`", expr, col.Column))
+			lineage = append(lineage, col)
+		}
+	}
+	if len(selectList) == 0 {
+		return "", nil, nil, fmt.Errorf("no columns selected")
+	}
+
+	// Start building query
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectList, ", "), quotedTables[0])
+
+	// Add joins
+	for i, joinTable := range params.Tables[1:] {
+		joinType := "INNER JOIN"
+		if joinTable.JoinType != "" {
+			joinType = strings.ToUpper(strings.Join(strings.Fields(joinTable.JoinType), " "))
+			if !joinTypes[joinType] {
+				return "", nil, nil, fmt.Errorf("unsupported join type %q for table %s", joinTable.JoinType, joinTable.Name)
+			}
+		}
+		if joinTable.JoinCondition != "" {
+			return "", nil, nil, fmt.Errorf("joinCondition is no longer supported for table %s, use joinOn", joinTable.Name)
+		}
+		query += fmt.Sprintf(" %s %s", joinType, quotedTables[i+1])
+		if joinType == "CROSS JOIN" {
+			if len(joinTable.JoinOn) > 0 {
+				return "", nil, nil, fmt.Errorf("cross joined table %s takes no joinOn", joinTable.Name)
+			}
+			continue
+		}
+		on, err := joinOn(joinTable, params.Tables[:i+1], quotedTables[:i+1], quotedTables[i+1])
+		if err != nil {
+			return "", nil, nil, err
+		}
+		query += " ON " + on
+	}
+
+	// Filter values are bound, never spliced into the query
+	where, args, err := buildWhere(params.Filters)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	query += where
+
+	return query, args, lineage, nil
+}
+
+// joinOn builds the ON clause of a joined table from its keys; left columns belong
+// to one of the earlier tables, right columns to the joined one
+func joinOn(table model.JoinTableInfo, earlier []model.JoinTableInfo, quotedEarlier []string, quotedTable string) (string, error) {
+	if len(table.JoinOn) == 0 {
+		return "", fmt.Errorf("joinOn is required for table %s", table.Name)
+	}
+
+	conditions := make([]string, len(table.JoinOn))
+	for i, key := range table.JoinOn {
+		left := quotedEarlier[0]
+		if key.LeftTable != "" {
+			left = ""
+			for j, t := range earlier {
+				if t.Name == key.LeftTable {
+					left = quotedEarlier[j]
+					break
+				}
+			}
+			if left == "" {
+				return "", fmt.Errorf("join key of %s refers to %s, which is not joined before it", table.Name, key.LeftTable)
+			}
+		}
+		op := key.Operator
+		if op == "" {
+			op = "="
+		}
+		if !joinOperators[op] {
+			return "", fmt.Errorf("unsupported join operator %q for table %s", key.Operator, table.Name)
+		}
+		leftColumn, err := QuoteIdentifier(key.LeftColumn)
+		if err != nil {
+			return "", err
+		}
+		rightColumn, err := QuoteIdentifier(key.RightColumn)
+		if err != nil {
+			return "", err
+		}
+		conditions[i] = fmt.Sprintf("%s.%s %s %s.%s", left, leftColumn, op, quotedTable, rightColumn)
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// lineageColumns returns the export columns of a join; their types are left to the writer
+func lineageColumns(lineage []model.ColumnLineage) []model.Column {
+	columns := make([]model.Column, len(lineage))
+	for i, col := range lineage {
+		columns[i] = model.Column{Name: col.Column}
+	}
+	return columns
+}
+
+// writeManifest writes the manifest describing an export next to the exported file
+func writeManifest(manifest model.ExportManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	path := manifest.File + manifestSuffix
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// newExportManifest describes a finished export
+func newExportManifest(file, query string, args []interface{}, rows int, columns []model.Column, lineage []model.ColumnLineage) model.ExportManifest {
+	return model.ExportManifest{
+		File:      file,
+		CreatedAt: time.Now().UTC(),
+		Rows:      rows,
+		Query:     query,
+		QueryArgs: args,
+		Columns:   columns,
+		Lineage:   lineage,
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ingestor/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func joinTables(joined model.JoinTableInfo) []model.JoinTableInfo {
+	return []model.JoinTableInfo{
+		{Name: "db.orders", SelectedColumns: []string{"id"}},
+		{Name: "customers", JoinOn: []model.JoinKey{{LeftColumn: "customer_id", RightColumn: "id"}}, SelectedColumns: []string{"name"}},
+		joined,
+	}
+}
+
+func TestBuildJoinQueryKeys(t *testing.T) {
+	s := &ClickHouseServiceImpl{}
+	query, _, err := s.BuildJoinQuery(model.JoinParams{Tables: joinTables(model.JoinTableInfo{
+		Name:     "events",
+		JoinType: "asof left join",
+		JoinOn: []model.JoinKey{
+			{LeftTable: "customers", LeftColumn: "id", RightColumn: "customer_id"},
+			{LeftColumn: "created_at", Operator: ">=", RightColumn: "time"},
+		},
+	})})
+	assert.NoError(t, err)
+	assert.Contains(t, query, " ASOF LEFT JOIN `events` ON `customers`.`id` = `events`.`customer_id` AND `db`.`orders`.`created_at` >= `events`.`time`")
+}
+
+func TestBuildJoinQueryRejectsInjection(t *testing.T) {
+	s := &ClickHouseServiceImpl{}
+	injection := "1=1) UNION SELECT name, password FROM system.users --"
+
+	tests := []struct {
+		name   string
+		joined model.JoinTableInfo
+	}{
+		{"raw condition", model.JoinTableInfo{Name: "events", JoinCondition: injection}},
+		{"raw condition with keys", model.JoinTableInfo{
+			Name:          "events",
+			JoinCondition: injection,
+			JoinOn:        []model.JoinKey{{LeftColumn: "id", RightColumn: "order_id"}},
+		}},
+		{"left column", model.JoinTableInfo{Name: "events", JoinOn: []model.JoinKey{{LeftColumn: injection, RightColumn: "order_id"}}}},
+		{"right column", model.JoinTableInfo{Name: "events", JoinOn: []model.JoinKey{{LeftColumn: "id", RightColumn: injection}}}},
+		{"left table", model.JoinTableInfo{Name: "events", JoinOn: []model.JoinKey{{LeftTable: injection, LeftColumn: "id", RightColumn: "order_id"}}}},
+		{"operator", model.JoinTableInfo{Name: "events", JoinOn: []model.JoinKey{{LeftColumn: "id", Operator: "= 1 OR", RightColumn: "order_id"}}}},
+		{"later table", model.JoinTableInfo{Name: "events", JoinOn: []model.JoinKey{{LeftTable: "events", LeftColumn: "id", RightColumn: "order_id"}}}},
+		{"no keys", model.JoinTableInfo{Name: "events"}},
+		{"cross join with keys", model.JoinTableInfo{Name: "events", JoinType: "CROSS JOIN", JoinOn: []model.JoinKey{{LeftColumn: "id", RightColumn: "order_id"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := s.BuildJoinQuery(model.JoinParams{Tables: joinTables(tt.joined)})
+			assert.Error(t, err)
+			assert.Empty(t, query)
+		})
+	}
+}
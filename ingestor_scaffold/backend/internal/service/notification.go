@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationService sends human-readable job summaries to Slack and email
+type NotificationService interface {
+	NotifyJob(job model.Job)
+	NotifyPreflight(schedule model.Schedule, result model.PreflightResult)
+	NotifyDataQuality(schedule model.Schedule, alerts []string)
+	SendSlack(ctx context.Context, text string) error
+	SendEmail(to []string, subject, body string) error
+}
+
+// NotificationServiceImpl implements NotificationService
+type NotificationServiceImpl struct {
+	httpClient *http.Client
+	config     *config.Config
+	logger     *logrus.Logger
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(config *config.Config, logger *logrus.Logger) NotificationService {
+	return &NotificationServiceImpl{
+		httpClient: NewHTTPClient(config.OutboundProxy, 10*time.Second),
+		config:     config,
+		logger:     logger,
+	}
+}
+
+// NotifyJob sends the notifications requested by a finished job
+func (s *NotificationServiceImpl) NotifyJob(job model.Job) {
+	spec := job.Params.Notifications
+	if spec == nil {
+		return
+	}
+
+	succeeded := job.Status == "success"
+	if (succeeded && !spec.OnSuccess) || (!succeeded && !spec.OnFailure) {
+		return
+	}
+
+	subject, body := formatJobNotification(job)
+	s.send(*spec, subject, body, s.logger.WithField("jobId", job.ID))
+}
+
+// NotifyPreflight warns the failure recipients of a schedule that its next run is expected to fail
+func (s *NotificationServiceImpl) NotifyPreflight(schedule model.Schedule, result model.PreflightResult) {
+	spec := schedule.Params.Notifications
+	if spec == nil || !spec.OnFailure || result.OK {
+		return
+	}
+
+	subject := fmt.Sprintf("Schedule %s is expected to fail at %s", scheduleLabel(schedule), result.FireAt.Format(time.RFC3339))
+	var b strings.Builder
+	fmt.Fprintf(&b, "Direction: %s -> %s\n", schedule.Params.SourceType, schedule.Params.TargetType)
+	for _, problem := range result.Problems {
+		fmt.Fprintf(&b, "Problem: %s\n", problem)
+	}
+	s.send(*spec, subject, b.String(), s.logger.WithField("scheduleId", schedule.ID))
+}
+
+// NotifyDataQuality warns the failure recipients of a schedule that its data quality is degrading
+func (s *NotificationServiceImpl) NotifyDataQuality(schedule model.Schedule, alerts []string) {
+	spec := schedule.Params.Notifications
+	if spec == nil || !spec.OnFailure {
+		return
+	}
+
+	subject := fmt.Sprintf("Schedule %s data quality is degrading", scheduleLabel(schedule))
+	var b strings.Builder
+	fmt.Fprintf(&b, "Direction: %s -> %s\n", schedule.Params.SourceType, schedule.Params.TargetType)
+	for _, alert := range alerts {
+		fmt.Fprintf(&b, "Alert: %s\n", alert)
+	}
+	s.send(*spec, subject, b.String(), s.logger.WithField("scheduleId", schedule.ID))
+}
+
+// send delivers a message to the Slack and email recipients of a notification spec
+func (s *NotificationServiceImpl) send(spec model.NotificationSpec, subject, body string, logger *logrus.Entry) {
+	if spec.Slack {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := s.SendSlack(ctx, subject+"\n"+body); err != nil {
+			logger.WithError(err).Warn("Failed to send Slack notification")
+		}
+		cancel()
+	}
+	if len(spec.Emails) > 0 {
+		if err := s.SendEmail(spec.Emails, subject, body); err != nil {
+			logger.WithError(err).Warn("Failed to send email notification")
+		}
+	}
+}
+
+// SendSlack posts a message to the configured Slack webhook
+func (s *NotificationServiceImpl) SendSlack(ctx context.Context, text string) error {
+	if s.config.SlackWebhookURL == "" {
+		return fmt.Errorf("Slack webhook is not configured")
+	}
+
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.SlackWebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Slack message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("Slack webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SendEmail sends a plain-text email through the configured SMTP server
+func (s *NotificationServiceImpl) SendEmail(to []string, subject, body string) error {
+	if s.config.SMTPHost == "" || s.config.SMTPFrom == "" {
+		return fmt.Errorf("SMTP is not configured")
+	}
+
+	var auth smtp.Auth
+	if s.config.SMTPUser != "" {
+		auth = smtp.PlainAuth("", s.config.SMTPUser, s.config.SMTPPassword, s.config.SMTPHost)
+	}
+
+	msg := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		s.config.SMTPFrom,
+		strings.Join(to, ", "),
+		subject,
+		body,
+	)
+
+	addr := hostPort(s.config.SMTPHost, s.config.SMTPPort)
+	if err := smtp.SendMail(addr, auth, s.config.SMTPFrom, to, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// scheduleLabel names a schedule by its name, falling back to its ID
+func scheduleLabel(schedule model.Schedule) string {
+	if schedule.Name != "" {
+		return schedule.Name
+	}
+	return schedule.ID
+}
+
+// formatJobNotification renders a subject line and body describing a finished job
+func formatJobNotification(job model.Job) (string, string) {
+	target := job.Params.TableName
+	if job.Params.TargetType == "flatfile" {
+		target = job.Params.FlatFileParams.FilePath
+	}
+
+	subject := fmt.Sprintf("Ingestion job %s %s", job.ID, job.Status)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Direction: %s -> %s\n", job.Params.SourceType, job.Params.TargetType)
+	fmt.Fprintf(&b, "Target: %s\n", target)
+	fmt.Fprintf(&b, "Rows: %d\n", job.Result.TotalRecords)
+	if job.Result.RejectedRecords > 0 {
+		fmt.Fprintf(&b, "Rejected: %d\n", job.Result.RejectedRecords)
+	}
+	if job.Result.DuplicateRecords > 0 {
+		fmt.Fprintf(&b, "Duplicates dropped: %d\n", job.Result.DuplicateRecords)
+	}
+	if job.FinishedAt != nil {
+		fmt.Fprintf(&b, "Duration: %s\n", job.FinishedAt.Sub(job.StartedAt).Round(time.Second))
+	}
+	if job.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n", job.Error)
+	}
+	for _, warning := range job.Result.Warnings {
+		fmt.Fprintf(&b, "Warning: %s\n", warning)
+	}
+
+	return subject, b.String()
+}
+
+// disabledNotificationService drops job notifications and refuses direct sends
+type disabledNotificationService struct{}
+
+// NewDisabledNotificationService creates the notification service used when
+// notifications are turned off
+func NewDisabledNotificationService() NotificationService {
+	return disabledNotificationService{}
+}
+
+func (disabledNotificationService) NotifyJob(model.Job) {}
+
+func (disabledNotificationService) NotifyPreflight(model.Schedule, model.PreflightResult) {}
+
+func (disabledNotificationService) NotifyDataQuality(model.Schedule, []string) {}
+
+func (disabledNotificationService) SendSlack(context.Context, string) error {
+	return &FeatureDisabledError{Feature: config.FeatureNotifications}
+}
+
+func (disabledNotificationService) SendEmail([]string, string, string) error {
+	return &FeatureDisabledError{Feature: config.FeatureNotifications}
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Parallel export outputs
+const (
+	ParallelConcat = "concat"
+	ParallelShards = "shards"
+)
+
+// Parallel export limits; at most maxExportWorkers ranges are read at once, which
+// leaves a connection of the pool free for other queries
+const (
+	maxExportPartitions = 64
+	maxExportWorkers    = 4
+)
+
+// checkParallelExport validates the parallel settings of an export. Deduplication
+// and cursors span the whole export, so they cannot be split.
+func checkParallelExport(params model.IngestionParams) error {
+	p := params.Parallel
+	switch {
+	case params.SourceType != "clickhouse" || params.TargetType != "flatfile":
+		return fmt.Errorf("parallel applies to ClickHouse to flat file exports")
+	case p.Partitions < 2 || p.Partitions > maxExportPartitions:
+		return fmt.Errorf("parallel exports need between 2 and %d partitions", maxExportPartitions)
+	case p.Output != "" && p.Output != ParallelConcat && p.Output != ParallelShards:
+		return fmt.Errorf("unsupported parallel output %q: must be concat or shards", p.Output)
+	case len(params.DedupKey) > 0 || params.CursorColumn != "":
+		return fmt.Errorf("dedupKey and cursorColumn are not supported for parallel exports")
+	case params.ServerFormat == "Parquet" && p.Output != ParallelShards:
+		return fmt.Errorf("Parquet parts cannot be concatenated; use the shards output")
+	case p.Column == "" && (params.Query != "" || params.Join != nil || params.TableFunction != nil):
+		return fmt.Errorf("parallel exports of queries, joins and table functions need a range column")
+	}
+	return nil
+}
+
+// partFilePath names part i of an export, before the file extension
+func partFilePath(filePath string, i int) string {
+	ext := filepath.Ext(filePath)
+	return fmt.Sprintf("%s.part%04d%s", strings.TrimSuffix(filePath, ext), i+1, ext)
+}
+
+// exportRange is one range of a parallel export, from lo inclusive to hi exclusive.
+// The first and last ranges are open so rows outside the planned bounds are kept.
+type exportRange struct {
+	lo, hi      int64
+	first, last bool
+}
+
+// splitRange divides the values from lo to hi into n ranges of equal width
+func splitRange(lo, hi int64, n int) []exportRange {
+	step := (uint64(hi)-uint64(lo))/uint64(n) + 1
+	ranges := make([]exportRange, n)
+	for i := range ranges {
+		ranges[i] = exportRange{
+			lo:    lo + int64(step*uint64(i)),
+			hi:    lo + int64(step*uint64(i+1)),
+			first: i == 0,
+			last:  i == n-1,
+		}
+	}
+	return ranges
+}
+
+// condition renders the range as a WHERE condition on a quoted column. The bounds
+// are integers and written inline, since server-formatted exports take no query
+// arguments. Rows whose value is NULL fall in the first range.
+func (r exportRange) condition(column string) string {
+	value := "toInt64(" + column + ")"
+	switch {
+	case r.first && r.last:
+		return "1"
+	case r.first:
+		return fmt.Sprintf("(%s < %d OR %s IS NULL)", value, r.hi, column)
+	case r.last:
+		return fmt.Sprintf("%s >= %d", value, r.lo)
+	}
+	return fmt.Sprintf("%s >= %d AND %s < %d", value, r.lo, value, r.hi)
+}
+
+// SortingKey returns the expressions of a table's sorting key, in order
+func (s *ClickHouseServiceImpl) SortingKey(ctx context.Context, tableName string) ([]string, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected to ClickHouse")
+	}
+	if _, err := QuoteTable(tableName); err != nil {
+		return nil, err
+	}
+
+	database, args := "currentDatabase()", []interface{}{tableName}
+	if db, name, qualified := strings.Cut(tableName, "."); qualified {
+		database, args = "?", []interface{}{db, name}
+	}
+
+	var key string
+	query := fmt.Sprintf("SELECT sorting_key FROM system.tables WHERE database = %s AND name = ?", database)
+	if err := s.conn.QueryRow(queryContext(ctx), query, args...).Scan(&key); err != nil {
+		return nil, fmt.Errorf("failed to read table metadata: %w", err)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	parts := strings.Split(key, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts, nil
+}
+
+// ValueRange returns the smallest and largest value of a numeric, date or time
+// column in the result of a query, as integers: dates count days and times seconds
+// since the epoch. ok is false when the column holds no values.
+func (s *ClickHouseServiceImpl) ValueRange(ctx context.Context, query string, args []interface{}, column string) (lo, hi int64, ok bool, err error) {
+	if s.conn == nil {
+		return 0, 0, false, fmt.Errorf("not connected to ClickHouse")
+	}
+	quoted, err := QuoteIdentifier(column)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	var values uint64
+	query = fmt.Sprintf("SELECT min(toInt64(%[1]s)), max(toInt64(%[1]s)), count(%[1]s) FROM (%[2]s)", quoted, query)
+	if err := s.conn.QueryRow(readOnlyContext(ctx), query, args...).Scan(&lo, &hi, &values); err != nil {
+		return 0, 0, false, fmt.Errorf("failed to read the range of %s: %w", column, err)
+	}
+	return lo, hi, values > 0, nil
+}
+
+// rangeColumn picks the column a parallel export is split on: the requested one,
+// or the first sorting key column of the exported table
+func (s *IngestServiceImpl) rangeColumn(ctx context.Context, params model.IngestionParams, columns []model.Column) (string, error) {
+	column := params.Parallel.Column
+	if column == "" {
+		key, err := s.clickhouse(ctx).SortingKey(ctx, params.TableName)
+		if err != nil {
+			return "", err
+		}
+		if len(key) == 0 {
+			return "", fmt.Errorf("table %s has no sorting key; set the range column", params.TableName)
+		}
+		column = key[0]
+	}
+	if _, err := QuoteIdentifier(column); err != nil {
+		return "", fmt.Errorf("range column must be a column: %w", err)
+	}
+
+	// Ranges filter the export's result, so the column must be in it
+	if len(columns) > 0 && !hasColumn(columns, column) {
+		return "", fmt.Errorf("range column %s must be exported", column)
+	}
+	return column, nil
+}
+
+// hasColumn reports whether columns include the named one
+func hasColumn(columns []model.Column, name string) bool {
+	for _, col := range columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// exportProgress reports the rows of all ranges of a parallel export as one count
+type exportProgress struct {
+	mu     sync.Mutex
+	counts []int
+	out    chan<- model.ProgressUpdate
+}
+
+// relay forwards the progress of range i until ctx is done. Ranges return their
+// failures, so only progress is forwarded.
+func (p *exportProgress) relay(ctx context.Context, i int, in <-chan model.ProgressUpdate) {
+	for {
+		select {
+		case update := <-in:
+			if update.Status != "processing" {
+				continue
+			}
+			p.mu.Lock()
+			p.counts[i] = update.Count
+			total := 0
+			for _, count := range p.counts {
+				total += count
+			}
+			p.mu.Unlock()
+
+			select {
+			case p.out <- model.ProgressUpdate{
+				Status:  "processing",
+				Message: fmt.Sprintf("Exported %d rows in %d ranges", total, len(p.counts)),
+				Count:   total,
+			}:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// exportParallel splits an export into ranges of one column and exports them
+// concurrently to part files, which are concatenated into the target file or
+// returned as a sharded output set
+func (s *IngestServiceImpl) exportParallel(
+	ctx context.Context,
+	params model.IngestionParams,
+	query string,
+	args []interface{},
+	columns []model.Column,
+	lineage []model.ColumnLineage,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	if err := checkParallelExport(params); err != nil {
+		return model.IngestionResult{}, err
+	}
+	column, err := s.rangeColumn(ctx, params, columns)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	lo, hi, ok, err := s.clickhouse(ctx).ValueRange(ctx, query, args, column)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+
+	// An export without values to split is written whole
+	if !ok {
+		sequential := params
+		sequential.Parallel = nil
+		return s.exportToFile(ctx, sequential, query, args, columns, lineage, progressCh)
+	}
+
+	shards := params.Parallel.Output == ParallelShards
+	ranges := splitRange(lo, hi, params.Parallel.Partitions)
+	quoted, _ := QuoteIdentifier(column)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	progress := &exportProgress{counts: make([]int, len(ranges)), out: progressCh}
+
+	parts := make([]string, len(ranges))
+	results := make([]model.IngestionResult, len(ranges))
+	errs := make([]error, len(ranges))
+	workers := make(chan struct{}, maxExportWorkers)
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		part := params
+		part.Parallel = nil
+		part.FlatFileParams.FilePath = partFilePath(params.FlatFileParams.FilePath, i)
+		if !shards {
+			// Only the concatenated file is published durably
+			part.FlatFileParams.Fsync = false
+			part.FlatFileParams.VerifyChecksum = false
+		}
+		parts[i] = part.FlatFileParams.FilePath
+
+		partQuery := fmt.Sprintf("SELECT * FROM (%s) WHERE %s", query, r.condition(quoted))
+
+		partCh := make(chan model.ProgressUpdate, 10)
+		go progress.relay(ctx, i, partCh)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-workers }()
+
+			results[i], errs[i] = s.exportToFile(ctx, part, partQuery, args, columns, lineage, partCh)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil && err != context.Canceled {
+			s.removeParts(parts)
+			return model.IngestionResult{}, fmt.Errorf("range %d of %d failed: %w", i+1, len(ranges), err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		s.removeParts(parts)
+		return model.IngestionResult{}, err
+	}
+
+	result := model.IngestionResult{Lineage: results[0].Lineage}
+	for _, r := range results {
+		result.TotalRecords += r.TotalRecords
+	}
+	if shards {
+		result.PartFiles = parts
+		return result, nil
+	}
+
+	// The concatenated bytes were counted as the parts were written
+	if err := s.concatParts(WithByteCounters(ctx, nil), params.FlatFileParams, parts, result.TotalRecords); err != nil {
+		s.removeParts(parts)
+		return model.IngestionResult{}, err
+	}
+	s.removeParts(parts)
+	if lineage != nil {
+		s.attachManifest(&result, params.FlatFileParams.FilePath, query, args, columns, WarningsFromContext(ctx))
+	}
+	return result, nil
+}
+
+// concatParts writes the part files of an export to the target file in order,
+// keeping only the header of the first
+func (s *IngestServiceImpl) concatParts(ctx context.Context, params model.FlatFileParams, parts []string, rows int) error {
+	_, err := s.flatFileService.WriteStream(ctx, params, func(w io.Writer) (int, error) {
+		for i, part := range parts {
+			if err := s.copyPart(w, part, i > 0); err != nil {
+				return 0, err
+			}
+		}
+		return rows, nil
+	})
+	return err
+}
+
+// copyPart copies one part file to w, optionally without its header line
+func (s *IngestServiceImpl) copyPart(w io.Writer, part string, skipHeader bool) error {
+	path, err := s.flatFileService.ResolvePath(part)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open part file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if skipHeader {
+		if _, err := reader.ReadString('\n'); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read part header: %w", err)
+		}
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("failed to copy part file: %w", err)
+	}
+	return nil
+}
+
+// removeParts deletes the part files of an export and their manifests
+func (s *IngestServiceImpl) removeParts(parts []string) {
+	for _, part := range parts {
+		os.Remove(part + manifestSuffix)
+		path, err := s.flatFileService.ResolvePath(part)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.logger.WithError(err).WithField("file", part).Warn("Failed to remove part file")
+		}
+	}
+}
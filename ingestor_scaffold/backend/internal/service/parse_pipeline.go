@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+)
+
+// recordConverter turns the CSV records of a flat file into typed rows. It is safe
+// for concurrent use.
+type recordConverter struct {
+	service        *FlatFileServiceImpl
+	columns        []model.Column
+	colNameToIndex map[string]int
+	layouts        []string
+	geoTypes       []string
+	binaryEncoding string
+	policy         string
+	warnings       *WarningCollector
+}
+
+// newRecordConverter prepares the conversion of records into rows of columns under
+// the coercion policy and binary encoding of params; index must then locate the
+// columns in the file's header
+func (s *FlatFileServiceImpl) newRecordConverter(params model.FlatFileParams, columns []model.Column, warnings *WarningCollector) (*recordConverter, error) {
+	if err := ValidateBinaryEncoding(params.BinaryEncoding); err != nil {
+		return nil, err
+	}
+	policy := params.CoercionPolicy
+	if policy == "" {
+		policy = s.config.CoercionPolicy
+	}
+	switch policy {
+	case CoercionStrict, CoercionLenient, CoercionLegacy:
+	default:
+		return nil, fmt.Errorf("unsupported coercion policy %q: must be strict, lenient or legacy", policy)
+	}
+
+	layouts, err := dateLayouts(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve geo column types once; their cells are parsed from WKT or GeoJSON
+	geoTypes := make([]string, len(columns))
+	for i, col := range columns {
+		geoTypes[i] = geoType(col.Type)
+	}
+
+	return &recordConverter{
+		service:        s,
+		columns:        columns,
+		layouts:        layouts,
+		geoTypes:       geoTypes,
+		binaryEncoding: params.BinaryEncoding,
+		policy:         policy,
+		warnings:       warnings,
+	}, nil
+}
+
+// index locates the columns by the normalized names of the file's header
+func (c *recordConverter) index(names []string) {
+	c.colNameToIndex = make(map[string]int, len(names))
+	for i, name := range names {
+		c.colNameToIndex[name] = i
+	}
+}
+
+// convert parses a record into a row ordered as the columns. If a cell cannot be
+// decoded it returns why the record must be skipped.
+func (c *recordConverter) convert(record []string) ([]interface{}, string) {
+	row := make([]interface{}, len(c.columns))
+	coerced := false
+	for i, col := range c.columns {
+		idx, ok := c.colNameToIndex[col.Name]
+		if !ok || idx >= len(record) {
+			row[i] = nil
+			continue
+		}
+
+		// Decode text-encoded binary columns back to raw bytes
+		value := record[idx]
+		if c.binaryEncoding != "" && isBinaryType(col.Type) && value != "" {
+			decoded, err := decodeBinary(value, c.binaryEncoding)
+			if err != nil {
+				return nil, "invalid " + c.binaryEncoding + " binary value"
+			}
+			value = string(decoded)
+		}
+
+		// Pass JSON documents through as text once validated
+		if isJSONType(col.Type) && value != "" && !json.Valid([]byte(value)) {
+			return nil, "invalid JSON value"
+		}
+
+		// Parse geo values from WKT or GeoJSON
+		if c.geoTypes[i] != "" {
+			g, err := parseGeo(value, c.geoTypes[i])
+			if err != nil {
+				return nil, "invalid geo value"
+			}
+			row[i] = g
+			continue
+		}
+
+		// Convert value based on type, applying the coercion policy to
+		// values that do not parse
+		if c.layouts[i] != "" {
+			row[i], ok = parseDate(value, col.Type, c.layouts[i])
+		} else {
+			row[i], ok = c.service.parseValue(value, col.Type)
+		}
+		if ok {
+			continue
+		}
+		if c.policy == CoercionStrict {
+			return nil, "invalid " + col.Type + " value"
+		}
+		if c.policy == CoercionLenient {
+			// Other columns would take the zero value from the insert, as under legacy
+			if !isNullableType(col.Type) {
+				return nil, "invalid " + col.Type + " value"
+			}
+			row[i] = nil
+		}
+		c.warnings.Count("values coerced in "+col.Name, 1)
+		coerced = true
+	}
+	if coerced {
+		c.warnings.Count("rows coerced", 1)
+	}
+	return row, ""
+}
+
+// parseWorkers returns how many workers convert the records of a flat file: the
+// file's own setting, at most MAX_PARSE_WORKERS, or PARSE_WORKERS
+func parseWorkers(params model.FlatFileParams, cfg *config.Config) int {
+	if params.ParseWorkers > cfg.MaxParseWorkers {
+		return cfg.MaxParseWorkers
+	}
+	if params.ParseWorkers > 0 {
+		return params.ParseWorkers
+	}
+	return cfg.ParseWorkers
+}
+
+// checkParseWorkers rejects a worker count beyond the server's MAX_PARSE_WORKERS
+func checkParseWorkers(params model.FlatFileParams, cfg *config.Config) error {
+	if params.ParseWorkers < 0 || params.ParseWorkers > cfg.MaxParseWorkers {
+		return fmt.Errorf("invalid parseWorkers %d: must be between 1 and %d", params.ParseWorkers, cfg.MaxParseWorkers)
+	}
+	return nil
+}
+
+// recordChunk is a run of records converted by one worker, with their row
+// numbers; seq orders the chunks
+type recordChunk struct {
+	seq     int
+	rows    []int
+	records [][]string
+}
+
+// rowChunk holds the rows converted from the records of one chunk
+type rowChunk struct {
+	seq  int
+	rows [][]interface{}
+}
+
+// parsePipeline converts the records a reader delivers into rows on out: inline,
+// or in chunks by parallel workers. Parallel rows keep the file's order unless it
+// is relaxed, which lets a slow chunk be overtaken. Records that reject fails for
+// stop the pipeline with its error.
+type parsePipeline struct {
+	ctx       context.Context
+	conv      *recordConverter
+	reject    func(row int, record []string, reason string) error
+	out       chan []interface{}
+	errCh     chan<- error
+	chunkRows int
+	ordered   bool
+
+	// Set for parallel conversion only; window bounds the chunks read but not yet
+	// sent, which ordered rows may hold back behind a slow one
+	chunk   recordChunk
+	seq     int
+	chunks  chan recordChunk
+	window  chan struct{}
+	readErr chan error
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	err     error
+}
+
+// newParsePipeline starts a pipeline writing to out. With more than one worker,
+// records are converted by workers started here.
+func newParsePipeline(
+	ctx context.Context,
+	conv *recordConverter,
+	reject func(row int, record []string, reason string) error,
+	out chan []interface{},
+	errCh chan<- error,
+	workers, chunkRows int,
+	ordered bool,
+) *parsePipeline {
+	p := &parsePipeline{
+		ctx:       ctx,
+		conv:      conv,
+		reject:    reject,
+		out:       out,
+		errCh:     errCh,
+		chunkRows: chunkRows,
+		ordered:   ordered,
+	}
+	if workers > 1 {
+		if p.chunkRows <= 0 {
+			p.chunkRows = 1
+		}
+		p.ctx, p.cancel = context.WithCancel(ctx)
+		p.chunks = make(chan recordChunk, workers)
+		p.window = make(chan struct{}, 2*workers)
+		p.readErr = make(chan error, 1)
+		p.start(p.ctx, workers)
+	}
+	return p
+}
+
+// add delivers record number row, returning an error once the pipeline stops.
+// Records are kept by parallel workers, so the reader must not reuse them.
+func (p *parsePipeline) add(row int, record []string) error {
+	if p.chunks == nil {
+		values, skip := p.conv.convert(record)
+		if skip != "" {
+			return p.reject(row, record, skip)
+		}
+		select {
+		case p.out <- values:
+			return nil
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+	}
+
+	p.chunk.rows = append(p.chunk.rows, row)
+	p.chunk.records = append(p.chunk.records, record)
+	if len(p.chunk.records) < p.chunkRows {
+		return nil
+	}
+	return p.flush()
+}
+
+// flush hands the records gathered so far to the workers
+func (p *parsePipeline) flush() error {
+	if len(p.chunk.records) == 0 {
+		return nil
+	}
+	chunk := p.chunk
+	chunk.seq = p.seq
+	p.seq++
+	p.chunk = recordChunk{}
+	select {
+	case p.window <- struct{}{}:
+	case <-p.ctx.Done():
+		return p.stopped()
+	}
+	select {
+	case p.chunks <- chunk:
+		return nil
+	case <-p.ctx.Done():
+		return p.stopped()
+	}
+}
+
+// fail stops parallel conversion with the first error a worker met
+func (p *parsePipeline) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// stopped returns why parallel conversion stopped: a worker's error or the
+// reader's context ending
+func (p *parsePipeline) stopped() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	return p.ctx.Err()
+}
+
+// finish ends the stream with the reader's error. Inline conversion reports it
+// at once; parallel conversion once the workers' rows have been sent.
+func (p *parsePipeline) finish(readErr error) {
+	if p.chunks == nil {
+		close(p.out)
+		if p.errCh != nil {
+			p.errCh <- readErr
+		}
+		return
+	}
+	if readErr == nil {
+		readErr = p.flush()
+	}
+	close(p.chunks)
+	p.readErr <- readErr
+}
+
+// start runs the conversion workers and the stage sending their rows to out
+func (p *parsePipeline) start(ctx context.Context, workers int) {
+	results := make(chan rowChunk, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range p.chunks {
+				rows := make([][]interface{}, 0, len(chunk.records))
+				for i, record := range chunk.records {
+					row, skip := p.conv.convert(record)
+					if skip == "" {
+						rows = append(rows, row)
+						continue
+					}
+					if err := p.reject(chunk.rows[i], record, skip); err != nil {
+						p.fail(err)
+						return
+					}
+				}
+				select {
+				case results <- rowChunk{seq: chunk.seq, rows: rows}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	go p.emit(ctx, results)
+}
+
+// emit sends converted rows to out, in file order unless it is relaxed, then
+// closes out and reports the first error
+func (p *parsePipeline) emit(ctx context.Context, results <-chan rowChunk) {
+	var err error
+	defer func() {
+		close(p.out)
+		readErr := <-p.readErr
+		p.mu.Lock()
+		switch {
+		case p.err != nil:
+			err = p.err
+		case readErr != nil:
+			err = readErr
+		}
+		p.mu.Unlock()
+		p.cancel()
+		if p.errCh != nil {
+			p.errCh <- err
+		}
+	}()
+
+	pending := make(map[int][][]interface{})
+	next := 0
+	for chunk := range results {
+		if !p.ordered {
+			if !p.send(ctx, chunk.rows) {
+				err = ctx.Err()
+				return
+			}
+			continue
+		}
+
+		// Hold chunks that finished early until those before them are sent
+		pending[chunk.seq] = chunk.rows
+		for rows, ok := pending[next]; ok; rows, ok = pending[next] {
+			delete(pending, next)
+			next++
+			if !p.send(ctx, rows) {
+				err = ctx.Err()
+				return
+			}
+		}
+	}
+	err = ctx.Err()
+}
+
+// send writes the rows of a chunk to out and makes room for another chunk,
+// returning false once ctx is done
+func (p *parsePipeline) send(ctx context.Context, rows [][]interface{}) bool {
+	for _, row := range rows {
+		select {
+		case p.out <- row:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	<-p.window
+	return true
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWorkers(t *testing.T) {
+	cfg := &config.Config{ParseWorkers: 2, MaxParseWorkers: 8}
+	tests := []struct {
+		requested, want int
+		valid           bool
+	}{
+		{0, 2, true},
+		{1, 1, true},
+		{8, 8, true},
+		{9, 8, false},
+		{1 << 20, 8, false},
+		{-1, 2, false},
+	}
+	for _, tt := range tests {
+		params := model.FlatFileParams{ParseWorkers: tt.requested}
+		assert.Equal(t, tt.want, parseWorkers(params, cfg), "requested %d", tt.requested)
+		if tt.valid {
+			assert.NoError(t, checkParseWorkers(params, cfg), "requested %d", tt.requested)
+		} else {
+			assert.Error(t, checkParseWorkers(params, cfg), "requested %d", tt.requested)
+		}
+	}
+}
+
+func TestRecordConverterCoercion(t *testing.T) {
+	s := NewFlatFileService(&config.Config{}, nil).(*FlatFileServiceImpl)
+	columns := []model.Column{{Name: "id", Type: "Int32"}, {Name: "score", Type: "Nullable(Int32)"}}
+	tests := []struct {
+		policy  string
+		record  []string
+		row     []interface{}
+		reason  string
+		coerced int
+	}{
+		{CoercionStrict, []string{"1", "2"}, []interface{}{int32(1), int32(2)}, "", 0},
+		{CoercionStrict, []string{"1", ""}, []interface{}{int32(1), nil}, "", 0},
+		{CoercionStrict, []string{"1", "x"}, nil, "invalid Nullable(Int32) value", 0},
+		{CoercionStrict, []string{"x", "2"}, nil, "invalid Int32 value", 0},
+		{CoercionLenient, []string{"1", "x"}, []interface{}{int32(1), nil}, "", 1},
+		{CoercionLenient, []string{"x", "2"}, nil, "invalid Int32 value", 0},
+		{CoercionLegacy, []string{"1", "x"}, []interface{}{int32(1), int32(0)}, "", 1},
+		{CoercionLegacy, []string{"x", "2"}, []interface{}{int32(0), int32(2)}, "", 1},
+	}
+	for _, tt := range tests {
+		warnings := NewWarningCollector()
+		conv, err := s.newRecordConverter(model.FlatFileParams{CoercionPolicy: tt.policy}, columns, warnings)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		conv.index([]string{"id", "score"})
+
+		row, reason := conv.convert(tt.record)
+		assert.Equal(t, tt.reason, reason, "%s %v", tt.policy, tt.record)
+		assert.Equal(t, tt.row, row, "%s %v", tt.policy, tt.record)
+		assert.Equal(t, tt.coerced, warnings.Total("rows coerced"), "%s %v", tt.policy, tt.record)
+	}
+}
+
+func TestReadDataParallel(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	// Every 100th row fails to parse under the strict policy
+	var csv strings.Builder
+	csv.WriteString("id,name\n")
+	var want []int32
+	for i := 0; i < 5000; i++ {
+		if i%100 == 99 {
+			fmt.Fprintf(&csv, "bad%d,row\n", i)
+			continue
+		}
+		fmt.Fprintf(&csv, "%d,name-%d\n", i, i)
+		want = append(want, int32(i))
+	}
+	path := filepath.Join(t.TempDir(), "in.csv")
+	assert.NoError(t, os.WriteFile(path, []byte(csv.String()), 0644))
+
+	columns := []model.Column{{Name: "id", Type: "Int32"}, {Name: "name", Type: "String"}}
+	tests := []struct {
+		name      string
+		workers   int
+		unordered bool
+	}{
+		{"inline", 1, false},
+		{"ordered workers", 4, false},
+		{"unordered workers", 4, true},
+	}
+	for _, tt := range tests {
+		s := NewFlatFileService(&config.Config{
+			FileReadBufferBytes: 4096,
+			MaxParseWorkers:     8,
+			ParseChunkRows:      64,
+			PipelineChannelSize: 16,
+			CoercionPolicy:      CoercionStrict,
+		}, logger).(*FlatFileServiceImpl)
+		warnings := NewWarningCollector()
+		ctx := WithWarnings(context.Background(), warnings)
+		params := model.FlatFileParams{FilePath: path, ParseWorkers: tt.workers, UnorderedParse: tt.unordered}
+
+		errCh := make(chan error, 1)
+		rows, err := s.ReadData(ctx, params, columns, errCh)
+		if !assert.NoError(t, err, tt.name) {
+			continue
+		}
+		var got []int32
+		for row := range rows {
+			got = append(got, row[0].(int32))
+		}
+		assert.NoError(t, <-errCh, tt.name)
+
+		if tt.unordered {
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		}
+		assert.Equal(t, want, got, tt.name)
+		assert.Equal(t, 50, warnings.Total("rows skipped (invalid Int32 value)"), tt.name)
+	}
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// PipelineService defines operations for named, file-declared pipelines
+type PipelineService interface {
+	Load(path string) error
+	ListPipelines() []model.Pipeline
+	GetPipeline(name string) (model.Pipeline, error)
+	PutPipeline(pipeline model.Pipeline) (model.Pipeline, error)
+	DeletePipeline(name string) error
+	RunPipeline(name string) (model.PipelineRun, error)
+	GetRun(name, id string) (model.PipelineRun, error)
+}
+
+// PipelineServiceImpl implements PipelineService
+type PipelineServiceImpl struct {
+	mu               sync.RWMutex
+	pipelines        map[string]model.Pipeline
+	runs             map[string]*model.PipelineRun
+	runner           *JobRunner
+	schedulerService SchedulerService
+	config           *config.Config
+	logger           *logrus.Logger
+}
+
+// NewPipelineService creates a new pipeline service
+func NewPipelineService(
+	runner *JobRunner,
+	schedulerService SchedulerService,
+	config *config.Config,
+	logger *logrus.Logger,
+) PipelineService {
+	return &PipelineServiceImpl{
+		pipelines:        make(map[string]model.Pipeline),
+		runs:             make(map[string]*model.PipelineRun),
+		runner:           runner,
+		schedulerService: schedulerService,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// Load reads pipeline definitions from a YAML file and registers their schedules
+func (s *PipelineServiceImpl) Load(path string) error {
+	pipelines, err := ReadPipelinesFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Register schedules only once the whole file is valid
+	for name, pipeline := range pipelines {
+		if pipeline.Schedule == "" {
+			continue
+		}
+		scheduled, err := s.schedule(pipeline)
+		if err != nil {
+			return err
+		}
+		pipelines[name] = scheduled
+	}
+
+	s.mu.Lock()
+	for name, pipeline := range pipelines {
+		s.pipelines[name] = pipeline
+	}
+	s.mu.Unlock()
+
+	s.logger.WithFields(logrus.Fields{
+		"path":      path,
+		"pipelines": len(pipelines),
+	}).Info("Loaded pipeline definitions")
+
+	return nil
+}
+
+// ReadPipelinesFile reads and validates a YAML pipelines file without loading it
+func ReadPipelinesFile(path string) (map[string]model.Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipelines file: %w", err)
+	}
+
+	// YAML keys follow the JSON field names used by the API
+	var file model.PipelineFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse pipelines file: %w", err)
+	}
+
+	pipelines := make(map[string]model.Pipeline, len(file.Pipelines))
+	for _, pipeline := range file.Pipelines {
+		if err := validatePipeline(pipeline); err != nil {
+			return nil, err
+		}
+		if _, exists := pipelines[pipeline.Name]; exists {
+			return nil, fmt.Errorf("duplicate pipeline name %q", pipeline.Name)
+		}
+		pipelines[pipeline.Name] = pipeline
+	}
+	return pipelines, nil
+}
+
+// ListPipelines returns all pipelines ordered by name
+func (s *PipelineServiceImpl) ListPipelines() []model.Pipeline {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pipelines := make([]model.Pipeline, 0, len(s.pipelines))
+	for _, pipeline := range s.pipelines {
+		pipelines = append(pipelines, pipeline)
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].Name < pipelines[j].Name
+	})
+	return pipelines
+}
+
+// GetPipeline returns a pipeline by name
+func (s *PipelineServiceImpl) GetPipeline(name string) (model.Pipeline, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pipeline, ok := s.pipelines[name]
+	if !ok {
+		return model.Pipeline{}, fmt.Errorf("pipeline %s not found", name)
+	}
+	return pipeline, nil
+}
+
+// PutPipeline validates and registers a pipeline, replacing one of the same name
+// along with its schedule
+func (s *PipelineServiceImpl) PutPipeline(pipeline model.Pipeline) (model.Pipeline, error) {
+	if err := validatePipeline(pipeline); err != nil {
+		return model.Pipeline{}, err
+	}
+	pipeline.ScheduleID = ""
+	pipeline, err := s.schedule(pipeline)
+	if err != nil {
+		return model.Pipeline{}, err
+	}
+
+	s.mu.Lock()
+	previous, exists := s.pipelines[pipeline.Name]
+	s.pipelines[pipeline.Name] = pipeline
+	s.mu.Unlock()
+
+	if exists {
+		s.unschedule(previous)
+	}
+	return pipeline, nil
+}
+
+// DeletePipeline removes a pipeline and its schedule
+func (s *PipelineServiceImpl) DeletePipeline(name string) error {
+	s.mu.Lock()
+	pipeline, ok := s.pipelines[name]
+	delete(s.pipelines, name)
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("pipeline %s not found", name)
+	}
+	s.unschedule(pipeline)
+	return nil
+}
+
+// schedule registers the schedule of a pipeline that declares one
+func (s *PipelineServiceImpl) schedule(pipeline model.Pipeline) (model.Pipeline, error) {
+	if pipeline.Schedule == "" {
+		return pipeline, nil
+	}
+	if !s.config.FeatureEnabled(config.FeatureScheduler) {
+		return pipeline, fmt.Errorf("failed to schedule pipeline %q: %w", pipeline.Name, &FeatureDisabledError{Feature: config.FeatureScheduler})
+	}
+	schedule, err := s.schedulerService.CreateSchedule(model.ScheduleRequest{
+		Name:      "pipeline:" + pipeline.Name,
+		Cron:      pipeline.Schedule,
+		Params:    pipeline.Params,
+		Transient: true,
+	})
+	if err != nil {
+		return pipeline, fmt.Errorf("failed to schedule pipeline %q: %w", pipeline.Name, err)
+	}
+	pipeline.ScheduleID = schedule.ID
+	return pipeline, nil
+}
+
+// unschedule removes the schedule registered for a pipeline, if any
+func (s *PipelineServiceImpl) unschedule(pipeline model.Pipeline) {
+	if pipeline.ScheduleID == "" {
+		return
+	}
+	if err := s.schedulerService.DeleteSchedule(pipeline.ScheduleID); err != nil {
+		s.logger.WithError(err).WithField("pipeline", pipeline.Name).Warn("Failed to remove pipeline schedule")
+	}
+}
+
+// RunPipeline starts a pipeline in the background. Steps run one after another as
+// separate jobs; the first is started before returning so its job ID is known.
+func (s *PipelineServiceImpl) RunPipeline(name string) (model.PipelineRun, error) {
+	pipeline, err := s.GetPipeline(name)
+	if err != nil {
+		return model.PipelineRun{}, err
+	}
+
+	steps := pipelineSteps(pipeline)
+	run := &model.PipelineRun{
+		ID:        newJobID(),
+		Pipeline:  name,
+		Status:    "running",
+		StartedAt: time.Now(),
+	}
+	for _, step := range steps {
+		run.Steps = append(run.Steps, model.PipelineStepRun{Name: step.Name, Status: "pending"})
+	}
+
+	s.mu.Lock()
+	s.runs[run.ID] = run
+	s.mu.Unlock()
+
+	outputs := make(map[string]map[string]interface{}, len(steps))
+	finished, err := s.startStep(run, steps, 0, outputs)
+	if err != nil {
+		s.finishRun(run, 0, err)
+		return s.GetRun(name, run.ID)
+	}
+	go s.execute(run, steps, finished, outputs)
+
+	return s.GetRun(name, run.ID)
+}
+
+// GetRun returns a run of a pipeline
+func (s *PipelineServiceImpl) GetRun(name, id string) (model.PipelineRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	run, ok := s.runs[id]
+	if !ok || run.Pipeline != name {
+		return model.PipelineRun{}, fmt.Errorf("run %s of pipeline %s not found", id, name)
+	}
+	snapshot := *run
+	snapshot.Steps = append([]model.PipelineStepRun(nil), run.Steps...)
+	return snapshot, nil
+}
+
+// execute waits for each step and starts the next one with the outputs gathered so far
+func (s *PipelineServiceImpl) execute(run *model.PipelineRun, steps []model.PipelineStep, finished <-chan struct{}, outputs map[string]map[string]interface{}) {
+	for i := range steps {
+		<-finished
+
+		s.mu.RLock()
+		jobID := run.Steps[i].JobID
+		s.mu.RUnlock()
+		job, err := s.runner.jobService.GetJob(jobID)
+		if err != nil {
+			s.finishRun(run, i, err)
+			return
+		}
+
+		s.mu.Lock()
+		run.Steps[i].Status = job.Status
+		if job.Status == "success" {
+			run.Steps[i].Outputs = stepOutputs(job)
+			outputs[steps[i].Name] = run.Steps[i].Outputs
+		}
+		s.mu.Unlock()
+
+		if job.Status != "success" {
+			s.finishRun(run, i+1, fmt.Errorf("step %s ended with status %s: %s", steps[i].Name, job.Status, job.Error))
+			return
+		}
+
+		if i+1 == len(steps) {
+			break
+		}
+		if finished, err = s.startStep(run, steps, i+1, outputs); err != nil {
+			s.finishRun(run, i+1, err)
+			return
+		}
+	}
+	s.finishRun(run, len(steps), nil)
+}
+
+// startStep renders a step's params with the outputs of earlier steps and starts its job
+func (s *PipelineServiceImpl) startStep(run *model.PipelineRun, steps []model.PipelineStep, i int, outputs map[string]map[string]interface{}) (<-chan struct{}, error) {
+	step := steps[i]
+	params, err := renderStepParams(step.Params, outputs)
+	if err != nil {
+		return nil, fmt.Errorf("step %s: %w", step.Name, err)
+	}
+
+	job, finished := s.runner.Start(params, logrus.Fields{
+		"pipeline":    run.Pipeline,
+		"pipelineRun": run.ID,
+		"step":        step.Name,
+	})
+
+	s.mu.Lock()
+	run.Steps[i].JobID = job.ID
+	run.Steps[i].Status = "running"
+	s.mu.Unlock()
+	return finished, nil
+}
+
+// finishRun records the outcome of a run; steps from next on were never started
+func (s *PipelineServiceImpl) finishRun(run *model.PipelineRun, next int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Status = "success"
+	if err != nil {
+		run.Status = "error"
+		run.Error = err.Error()
+		for i := next; i < len(run.Steps); i++ {
+			if run.Steps[i].Status == "pending" {
+				run.Steps[i].Status = "skipped"
+			}
+		}
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"pipeline":    run.Pipeline,
+			"pipelineRun": run.ID,
+		}).Error("Pipeline run failed")
+	}
+}
+
+// pipelineSteps returns the steps of a pipeline; a single-step pipeline runs its Params
+func pipelineSteps(pipeline model.Pipeline) []model.PipelineStep {
+	if len(pipeline.Steps) > 0 {
+		return pipeline.Steps
+	}
+	return []model.PipelineStep{{Name: pipeline.Name, Params: pipeline.Params}}
+}
+
+// validatePipeline checks that a pipeline definition is runnable
+func validatePipeline(pipeline model.Pipeline) error {
+	if pipeline.Name == "" {
+		return fmt.Errorf("pipeline name is required")
+	}
+	if len(pipeline.Steps) == 0 {
+		return validateStep(pipeline.Name, pipeline.Params)
+	}
+
+	// Scheduled runs execute a single ingestion
+	if pipeline.Schedule != "" {
+		return fmt.Errorf("pipeline %q: multi-step pipelines cannot be scheduled", pipeline.Name)
+	}
+	names := make(map[string]bool, len(pipeline.Steps))
+	for _, step := range pipeline.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("pipeline %q: step name is required", pipeline.Name)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("pipeline %q: duplicate step name %q", pipeline.Name, step.Name)
+		}
+		names[step.Name] = true
+		if err := validateStep(pipeline.Name+"/"+step.Name, step.Params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateStep checks that the params of one ingestion are runnable
+func validateStep(name string, params model.IngestionParams) error {
+	if params.SourceType == "" || params.TargetType == "" {
+		return fmt.Errorf("pipeline %q: source and target types are required", name)
+	}
+	if len(params.Columns) == 0 {
+		return fmt.Errorf("pipeline %q: at least one column is required", name)
+	}
+	if err := validateTemplates(params); err != nil {
+		return fmt.Errorf("pipeline %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Preflight validates that a job could run now: connections, source availability
+// and free disk on the target. It returns the problems found, if any.
+func (s *IngestServiceImpl) Preflight(ctx context.Context, params model.IngestionParams) []string {
+	ctx, cancel := context.WithTimeout(ctx, s.config.ClickHouseDialTimeout+5*time.Second)
+	defer cancel()
+
+	var problems []string
+	switch params.SourceType {
+	case "clickhouse":
+		if err := s.clickhouse(ctx).Ping(ctx); err != nil {
+			problems = append(problems, "source connection: "+err.Error())
+		} else if params.Query == "" && params.TableName != "" {
+			if _, err := s.clickhouse(ctx).GetTableColumns(ctx, params.TableName); err != nil {
+				problems = append(problems, fmt.Sprintf("source table %s: %s", params.TableName, err))
+			}
+		}
+	case "flatfile":
+		if err := s.flatFileService.CheckReadable(params.FlatFileParams.FilePath); err != nil {
+			problems = append(problems, "source file: "+err.Error())
+		}
+	}
+
+	switch {
+	case params.TargetType == "flatfile":
+		path := params.FlatFileParams.FilePath
+		if err := s.flatFileService.CheckWritable(path); err != nil {
+			problems = append(problems, "target file: "+err.Error())
+		} else if free, ok := freeDiskBytes(filepath.Dir(path)); ok && free < uint64(s.config.PreflightMinFreeBytes) {
+			problems = append(problems, fmt.Sprintf("target disk has %d bytes free, below the %d byte minimum", free, s.config.PreflightMinFreeBytes))
+		}
+	case params.TargetType == "clickhouse" && params.SourceType == "clickhouse":
+		if params.TargetConnection == nil {
+			problems = append(problems, "target connection: targetConnection is required")
+			break
+		}
+		target := NewClickHouseService(s.config, s.logger)
+		if err := target.Connect(ctx, *params.TargetConnection, params.TargetConnection.Token); err != nil {
+			problems = append(problems, "target connection: "+err.Error())
+			break
+		}
+		target.Close()
+	case params.TargetType == "clickhouse":
+		if err := s.clickhouse(ctx).Ping(ctx); err != nil {
+			problems = append(problems, "target connection: "+err.Error())
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/model"
+)
+
+// Connection presets
+const PresetCloud = "cloud"
+
+// ClickHouse Cloud services sleep when idle and take a while to wake on connect
+const cloudDialTimeout = 30 * time.Second
+
+// applyPreset fills in the connection parameters a preset implies, keeping any
+// that are given
+func applyPreset(params model.ClickHouseConnectionParams) (model.ClickHouseConnectionParams, error) {
+	switch params.Protocol {
+	case "", "native", "http":
+	default:
+		return params, fmt.Errorf("unsupported protocol %q: must be native or http", params.Protocol)
+	}
+
+	switch params.Preset {
+	case "":
+		return params, nil
+	case PresetCloud:
+		// Cloud replicates every table itself and only accepts TLS
+		if params.Replicated {
+			return params, fmt.Errorf("ClickHouse Cloud replicates tables itself; unset replicated")
+		}
+		params.Secure = true
+		if params.Port == 0 {
+			params.Port = 9440
+			if params.Protocol == "http" {
+				params.Port = 8443
+			}
+		}
+		if params.DialTimeoutSeconds == 0 {
+			params.DialTimeoutSeconds = int(cloudDialTimeout / time.Second)
+		}
+		return params, nil
+	default:
+		return params, fmt.Errorf("unsupported connection preset %q: must be cloud", params.Preset)
+	}
+}
+
+// presetSettings adds the settings a preset tunes to a connection's
+func presetSettings(params model.ClickHouseConnectionParams, settings clickhouse.Settings) {
+	if params.Preset == PresetCloud {
+		// Reads right after a load see its rows on whichever replica serves them
+		settings["select_sequential_consistency"] = 1
+	}
+}
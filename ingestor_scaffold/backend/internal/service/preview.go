@@ -0,0 +1,214 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+)
+
+// PreviewColumns caps a requested column list at the configured maximum so
+// wide tables are never fetched in full; PreviewLimiter reports the cut
+func PreviewColumns(columns []string, cfg *config.Config) []string {
+	if cfg.MaxPreviewColumns <= 0 || len(columns) <= cfg.MaxPreviewColumns {
+		return columns
+	}
+	return columns[:cfg.MaxPreviewColumns]
+}
+
+// PreviewLimiter enforces the column, cell size and response size caps on preview
+// rows one row at a time, so a response can be written as its rows are encoded
+type PreviewLimiter struct {
+	cfg        *config.Config
+	columns    []string
+	truncation model.PreviewTruncation
+	total      int
+	written    int
+	skipped    int
+	size       int
+	full       bool
+}
+
+// NewPreviewLimiter decides which columns of rows survive the column cap.
+// order gives the preferred column order; columns not listed follow alphabetically.
+func NewPreviewLimiter(rows []map[string]interface{}, order []string, cfg *config.Config) *PreviewLimiter {
+	l := &PreviewLimiter{
+		cfg:   cfg,
+		total: len(rows),
+		truncation: model.PreviewTruncation{
+			MaxRows:      cfg.MaxPreviewRows,
+			MaxColumns:   cfg.MaxPreviewColumns,
+			MaxCellBytes: cfg.MaxPreviewCellBytes,
+			MaxBytes:     cfg.MaxPreviewBytes,
+		},
+	}
+	if len(rows) == 0 {
+		return l
+	}
+
+	l.columns = previewColumnOrder(rows, order)
+	if cfg.MaxPreviewColumns > 0 && len(l.columns) > cfg.MaxPreviewColumns {
+		l.truncation.OmittedColumns = append(l.truncation.OmittedColumns, l.columns[cfg.MaxPreviewColumns:]...)
+		l.columns = l.columns[:cfg.MaxPreviewColumns]
+		l.truncation.Reasons = append(l.truncation.Reasons, fmt.Sprintf("only the first %d columns are previewed", cfg.MaxPreviewColumns))
+	}
+	return l
+}
+
+// Encode applies the caps to a row and returns it as JSON, or nil for a row that
+// cannot be encoded, such as one holding NaN. It returns false once the byte budget
+// is spent; that row and every later one are omitted.
+func (l *PreviewLimiter) Encode(row map[string]interface{}) ([]byte, bool) {
+	if l.full {
+		return nil, false
+	}
+
+	limited := make(map[string]interface{}, len(l.columns))
+	for _, col := range l.columns {
+		value, ok := row[col]
+		if !ok {
+			continue
+		}
+		if cut, truncated := truncateCell(value, l.cfg.MaxPreviewCellBytes); truncated {
+			value = cut
+			l.truncation.TruncatedCells++
+		}
+		limited[col] = value
+	}
+
+	encoded, err := json.Marshal(limited)
+	if err != nil {
+		l.skipped++
+		return nil, true
+	}
+
+	// Stop before the response grows past the byte budget
+	if l.cfg.MaxPreviewBytes > 0 && l.size+len(encoded) > l.cfg.MaxPreviewBytes {
+		l.full = true
+		l.truncation.OmittedRows = l.total - l.written - l.skipped
+		l.truncation.Reasons = append(l.truncation.Reasons, fmt.Sprintf("response size limit of %d bytes reached", l.cfg.MaxPreviewBytes))
+		return nil, false
+	}
+	l.size += len(encoded)
+	l.written++
+	return encoded, true
+}
+
+// Written returns the number of rows encoded so far
+func (l *PreviewLimiter) Written() int {
+	return l.written
+}
+
+// Consumed returns the number of rows the limiter has gone through, encoded or not;
+// the next page of a preview starts after them
+func (l *PreviewLimiter) Consumed() int {
+	return l.written + l.skipped
+}
+
+// Truncation reports what the caps cut from the rows
+func (l *PreviewLimiter) Truncation() model.PreviewTruncation {
+	truncation := l.truncation
+	truncation.Reasons = append([]string(nil), l.truncation.Reasons...)
+	if l.skipped > 0 {
+		truncation.OmittedRows += l.skipped
+		truncation.Reasons = append(truncation.Reasons, fmt.Sprintf("%d rows could not be encoded as JSON", l.skipped))
+	}
+	if truncation.TruncatedCells > 0 {
+		truncation.Reasons = append(truncation.Reasons, fmt.Sprintf("%d cells longer than %d bytes were shortened", truncation.TruncatedCells, l.cfg.MaxPreviewCellBytes))
+	}
+	if l.total >= l.cfg.MaxPreviewRows && l.cfg.MaxPreviewRows > 0 {
+		truncation.Reasons = append(truncation.Reasons, fmt.Sprintf("row limit of %d reached", l.cfg.MaxPreviewRows))
+	}
+	truncation.Truncated = len(truncation.Reasons) > 0
+	return truncation
+}
+
+// PreviewCursor is the position of the next page of a preview. Flat file cursors
+// carry the file's fingerprint so a page is never read from a changed file.
+type PreviewCursor struct {
+	Offset      int    `json:"offset"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// EncodePreviewCursor renders a cursor as an opaque token
+func EncodePreviewCursor(cursor PreviewCursor) string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// DecodePreviewCursor parses a token made by EncodePreviewCursor
+func DecodePreviewCursor(token string) (PreviewCursor, error) {
+	var cursor PreviewCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(decoded, &cursor) != nil || cursor.Offset < 0 {
+		return PreviewCursor{}, fmt.Errorf("invalid preview cursor")
+	}
+	return cursor, nil
+}
+
+// previewColumnOrder lists every column in the rows, honoring the preferred order first
+func previewColumnOrder(rows []map[string]interface{}, order []string) []string {
+	seen := make(map[string]bool)
+	columns := make([]string, 0, len(order))
+	for _, col := range order {
+		if !seen[col] {
+			seen[col] = true
+			columns = append(columns, col)
+		}
+	}
+
+	var extra []string
+	for _, row := range rows {
+		for col := range row {
+			if !seen[col] {
+				seen[col] = true
+				extra = append(extra, col)
+			}
+		}
+	}
+	sort.Strings(extra)
+
+	return append(columns, extra...)
+}
+
+// truncateCell shortens string and byte values longer than max bytes
+func truncateCell(value interface{}, max int) (interface{}, bool) {
+	if max <= 0 {
+		return value, false
+	}
+
+	switch v := value.(type) {
+	case string:
+		if len(v) > max {
+			return truncateUTF8(v, max), true
+		}
+	case *string:
+		if v != nil && len(*v) > max {
+			return truncateUTF8(*v, max), true
+		}
+	case []byte:
+		if len(v) > max {
+			return truncateUTF8(string(v), max), true
+		}
+	}
+	return value, false
+}
+
+// truncateUTF8 cuts s to at most max bytes without splitting a rune
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && max < len(s) && !isRuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// isRuneStart reports whether b begins a UTF-8 encoded rune
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
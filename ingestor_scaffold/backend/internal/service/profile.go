@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Top values reported per profiled column by default, and at most
+const (
+	defaultProfileTopValues = 5
+	maxProfileTopValues     = 100
+)
+
+// CheckProfileParams checks the sample and top value settings of params and fills
+// in their defaults
+func CheckProfileParams(params model.ProfileParams, maxSampleRows int) (model.ProfileParams, error) {
+	switch {
+	case params.SampleRows < 0:
+		return params, fmt.Errorf("sampleRows must not be negative")
+	case params.SampleRows > maxSampleRows:
+		return params, fmt.Errorf("profiles may sample at most %d rows", maxSampleRows)
+	case params.TopValues < 0 || params.TopValues > maxProfileTopValues:
+		return params, fmt.Errorf("topValues must be between 0 and %d", maxProfileTopValues)
+	}
+	if params.TopValues == 0 {
+		params.TopValues = defaultProfileTopValues
+	}
+	return params, nil
+}
+
+// ProfileTable computes the statistics of the columns of a table, or of its first
+// sampleRows rows if set. The aggregates of every column are read in one pass;
+// top values take a query per column.
+func (s *ClickHouseServiceImpl) ProfileTable(ctx context.Context, tableName string, columns []string, sampleRows, topValues int) (model.DataProfile, error) {
+	if s.conn == nil {
+		return model.DataProfile{}, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return model.DataProfile{}, err
+	}
+	tableColumns, err := s.GetTableColumns(ctx, tableName)
+	if err != nil {
+		return model.DataProfile{}, err
+	}
+	selected, err := profileColumns(tableColumns, columns)
+	if err != nil {
+		return model.DataProfile{}, err
+	}
+	source := table
+	if sampleRows > 0 {
+		source = fmt.Sprintf("(SELECT * FROM %s LIMIT %d)", table, sampleRows)
+	}
+
+	// Scan each aggregate straight into the profile of its column
+	var rows uint64
+	nulls, distinct := make([]uint64, len(selected)), make([]uint64, len(selected))
+	profile := model.DataProfile{Sampled: sampleRows > 0, Columns: make([]model.ColumnProfile, len(selected))}
+	selectList := []string{"count()"}
+	dest := []interface{}{&rows}
+	for i, col := range selected {
+		quoted, _ := QuoteIdentifier(col.Name)
+		profile.Columns[i] = model.ColumnProfile{Name: col.Name, Type: col.Type}
+		selectList = append(selectList, fmt.Sprintf("countIf(%s IS NULL)", quoted))
+		dest = append(dest, &nulls[i])
+		if aggregateKind(col.Type) == "AggregateFunction" {
+			continue
+		}
+		selectList = append(selectList, fmt.Sprintf("uniq(%s)", quoted))
+		dest = append(dest, &distinct[i])
+		if orderableType(col.Type) {
+			selectList = append(selectList, fmt.Sprintf("ifNull(toString(min(%s)), '')", quoted), fmt.Sprintf("ifNull(toString(max(%s)), '')", quoted))
+			dest = append(dest, &profile.Columns[i].Min, &profile.Columns[i].Max)
+		}
+		if numericType(col.Type) {
+			selectList = append(selectList, fmt.Sprintf("avgOrNull(toFloat64(%s))", quoted))
+			dest = append(dest, &profile.Columns[i].Mean)
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selectList, ", "), source)
+	if err := s.conn.QueryRow(queryContext(ctx), query).Scan(dest...); err != nil {
+		return model.DataProfile{}, fmt.Errorf("failed to profile table: %w", err)
+	}
+
+	profile.Rows = int64(rows)
+	for i, col := range selected {
+		p := &profile.Columns[i]
+		p.Nulls, p.Distinct = int64(nulls[i]), int64(distinct[i])
+		p.NullPercent = nullPercent(p.Nulls, profile.Rows)
+		if p.Mean != nil && (math.IsNaN(*p.Mean) || math.IsInf(*p.Mean, 0)) {
+			p.Mean = nil
+		}
+
+		// Non-nullable columns report their default value as the bounds of no rows
+		if p.Nulls == profile.Rows {
+			p.Min, p.Max = "", ""
+			continue
+		}
+		if aggregateKind(col.Type) == "AggregateFunction" || topValues == 0 {
+			continue
+		}
+		if p.TopValues, err = s.topValues(ctx, source, col.Name, topValues); err != nil {
+			return model.DataProfile{}, err
+		}
+	}
+	return profile, nil
+}
+
+// topValues returns the most frequent non-NULL values of a column of source
+func (s *ClickHouseServiceImpl) topValues(ctx context.Context, source, column string, limit int) ([]model.ValueCount, error) {
+	quoted, err := QuoteIdentifier(column)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(
+		"SELECT toString(%[1]s) AS _profile_value, count() AS _profile_count FROM %[2]s WHERE %[1]s IS NOT NULL GROUP BY _profile_value ORDER BY _profile_count DESC, _profile_value LIMIT %[3]d",
+		quoted, source, limit,
+	)
+	rows, err := s.conn.Query(queryContext(ctx), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the top values of %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	var values []model.ValueCount
+	for rows.Next() {
+		var value string
+		var count uint64
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, fmt.Errorf("failed to read the top values of %s: %w", column, err)
+		}
+		values = append(values, model.ValueCount{Value: value, Count: int64(count)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the top values of %s: %w", column, err)
+	}
+	return values, nil
+}
+
+// ProfileFile computes the statistics of the columns of the first sampleRows data
+// rows of a flat file. Empty values are NULLs; column types are inferred as schema
+// discovery does. Rows whose field count differs from the header are skipped.
+func (s *FlatFileServiceImpl) ProfileFile(ctx context.Context, filePath, delimiter string, columns []string, sampleRows, topValues int) (model.DataProfile, error) {
+	filePath, err := s.sandbox.Resolve(filePath)
+	if err != nil {
+		return model.DataProfile{}, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return model.DataProfile{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var delim rune = ','
+	if delims := []rune(delimiter); len(delims) > 0 {
+		delim = delims[0]
+	}
+	reader := csv.NewReader(s.bufferedReader(file))
+	reader.Comma = delim
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return model.DataProfile{}, fmt.Errorf("failed to read header: %w", err)
+	}
+	names, _ := normalizeHeader(header)
+	fileColumns := make([]model.Column, len(names))
+	for i, name := range names {
+		fileColumns[i] = model.Column{Name: name, Type: "String"}
+	}
+	selected, err := profileColumns(fileColumns, columns)
+	if err != nil {
+		return model.DataProfile{}, err
+	}
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+
+	// Count each column's distinct values; every statistic is derived from them
+	values := make([]map[string]int64, len(selected))
+	for i := range values {
+		values[i] = make(map[string]int64)
+	}
+	var rows int64
+	sampled := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return model.DataProfile{}, err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || len(record) != len(header) {
+			continue
+		}
+		if rows == int64(sampleRows) {
+			sampled = true
+			break
+		}
+		rows++
+		for i, col := range selected {
+			values[i][record[index[col.Name]]]++
+		}
+	}
+
+	profile := model.DataProfile{Rows: rows, Sampled: sampled, Columns: make([]model.ColumnProfile, len(selected))}
+	for i, col := range selected {
+		profile.Columns[i] = s.profileValues(col.Name, values[i], rows, topValues)
+	}
+	return profile, nil
+}
+
+// profileValues derives the statistics of a flat file column from the counts of
+// its values
+func (s *FlatFileServiceImpl) profileValues(name string, values map[string]int64, rows int64, topValues int) model.ColumnProfile {
+	p := model.ColumnProfile{Name: name, Nulls: values[""]}
+	p.NullPercent = nullPercent(p.Nulls, rows)
+	delete(values, "")
+	p.Distinct = int64(len(values))
+
+	types := make(map[string]int)
+	for value, count := range values {
+		types[s.inferType(value)] += int(count)
+	}
+	p.Type = s.getDominantType(types)
+
+	// Numeric columns are summarized over the values that parse as numbers
+	var sum float64
+	var numbers int64
+	var lo, hi float64
+	numeric := p.Type == "Int64" || p.Type == "Float64"
+	for value, count := range values {
+		if numeric {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				continue
+			}
+			if numbers == 0 || f < lo {
+				lo, p.Min = f, value
+			}
+			if numbers == 0 || f > hi {
+				hi, p.Max = f, value
+			}
+			sum += f * float64(count)
+			numbers += count
+			continue
+		}
+		if p.Min == "" || value < p.Min {
+			p.Min = value
+		}
+		if value > p.Max {
+			p.Max = value
+		}
+	}
+	if numbers > 0 {
+		mean := sum / float64(numbers)
+		p.Mean = &mean
+	}
+
+	if topValues > 0 {
+		top := make([]model.ValueCount, 0, len(values))
+		for value, count := range values {
+			top = append(top, model.ValueCount{Value: value, Count: count})
+		}
+		sort.Slice(top, func(i, j int) bool {
+			if top[i].Count != top[j].Count {
+				return top[i].Count > top[j].Count
+			}
+			return top[i].Value < top[j].Value
+		})
+		if len(top) > topValues {
+			top = top[:topValues]
+		}
+		p.TopValues = top
+	}
+	return p
+}
+
+// profileColumns returns the requested columns in the order given, or every column
+func profileColumns(columns []model.Column, names []string) ([]model.Column, error) {
+	if len(names) == 0 {
+		return columns, nil
+	}
+	selected := make([]model.Column, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, col := range columns {
+			if col.Name == name {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("column %s not found", name)
+		}
+	}
+	return selected, nil
+}
+
+// numericType reports whether a ClickHouse column holds numbers that can be averaged
+func numericType(chType string) bool {
+	t := baseType(chType)
+	return isNumericType(t) || strings.HasPrefix(t, "Decimal")
+}
+
+// orderableType reports whether the values of a ClickHouse column have a min and max
+func orderableType(chType string) bool {
+	t := baseType(chType)
+	switch {
+	case numericType(t), strings.HasPrefix(t, "Date"), strings.HasPrefix(t, "Enum"), strings.HasPrefix(t, "FixedString("):
+		return true
+	}
+	switch t {
+	case "String", "UUID", "IPv4", "IPv6", "Bool":
+		return true
+	}
+	return false
+}
+
+// nullPercent is the share of rows that are NULL, as a percentage
+func nullPercent(nulls, rows int64) float64 {
+	if rows == 0 {
+		return 0
+	}
+	return float64(nulls) * 100 / float64(rows)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/model"
+)
+
+// TableFunctionConnector is the target type of exports pushed down to ClickHouse,
+// which writes them itself through a table function such as s3()
+const TableFunctionConnector = "function"
+
+// truncateSettings let an export replace an existing object, per table function;
+// url() always overwrites
+var truncateSettings = map[string]string{
+	"s3":   "s3_truncate_on_insert",
+	"hdfs": "hdfs_truncate_on_insert",
+	"file": "engine_file_truncate_on_insert",
+}
+
+// ExportToTableFunction pushes a ClickHouse export down to the server as
+// INSERT INTO FUNCTION, for example s3('https://bucket/out.parquet', 'Parquet'):
+// ClickHouse reads and writes the rows, and this service only runs the query,
+// follows its progress and records the object written. Row-level processing
+// (JSON paths, deduplication, cursors and throttling) needs the rows here and is
+// not available.
+func (s *IngestServiceImpl) ExportToTableFunction(
+	ctx context.Context,
+	params model.IngestionParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	switch {
+	case params.TargetFunction == nil:
+		return model.IngestionResult{}, fmt.Errorf("targetFunction is required for %s targets", TableFunctionConnector)
+	case params.TableFunction != nil:
+		return model.IngestionResult{}, fmt.Errorf("table function sources cannot be exported to a table function")
+	case len(params.JSONPaths) > 0 || len(params.DedupKey) > 0 || params.CursorColumn != "" || params.MaxRowsPerSecond > 0 || len(params.ColumnMappings) > 0:
+		return model.IngestionResult{}, fmt.Errorf("jsonPaths, dedupKey, cursorColumn, maxRowsPerSecond and columnMappings are not supported for pushed-down exports")
+	}
+
+	target, err := TableFunctionSource(*params.TargetFunction, s.config)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	query, args, _, lineage, err := s.exportQuery(params, WarningsFromContext(ctx))
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+
+	var settings clickhouse.Settings
+	if params.OverwriteTarget {
+		name := strings.ToLower(strings.TrimSpace(params.TargetFunction.Name))
+		if setting, ok := truncateSettings[name]; ok {
+			settings = clickhouse.Settings{setting: 1}
+		}
+	}
+
+	rows, bytes, err := s.clickhouse(ctx).InsertIntoFunction(ctx, target, query, args, settings, progressCh)
+	if err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to export to %s: %w", params.TargetFunction.Name, err)
+	}
+
+	return model.IngestionResult{
+		TotalRecords: int(rows),
+		Query:        query,
+		Lineage:      lineage,
+		Objects: []model.ExportedObject{{
+			Function: params.TargetFunction.Name,
+			Location: params.TargetFunction.Args[0],
+			Rows:     rows,
+			Bytes:    bytes,
+		}},
+	}, nil
+}
+
+// InsertIntoFunction writes the rows of a query through a table function on the
+// server, returning the rows and bytes written
+func (s *ClickHouseServiceImpl) InsertIntoFunction(ctx context.Context, target, query string, args []interface{}, settings clickhouse.Settings, progressCh chan<- model.ProgressUpdate) (int64, int64, error) {
+	if s.conn == nil {
+		return 0, 0, fmt.Errorf("not connected to ClickHouse")
+	}
+	return s.execInsert(ctx, fmt.Sprintf("INSERT INTO FUNCTION %s %s", target, query), args, settings, progressCh)
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/model"
+)
+
+// ErrQueryNotReadOnly is returned for custom queries that could change data
+var ErrQueryNotReadOnly = errors.New("query is not read-only")
+
+// firstKeywordRe finds the statement keyword of a query stripped of comments
+var firstKeywordRe = regexp.MustCompile(`^\s*\(*\s*([A-Za-z]+)`)
+
+// readOnlyStatements are the statements custom queries may start with
+var readOnlyStatements = map[string]bool{"SELECT": true, "WITH": true}
+
+// wordRe finds the words of a query stripped of literals and comments
+var wordRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// writeKeywords start statements that change data, schemas or grants; they are
+// refused anywhere in a custom query, such as after a WITH clause. Columns with
+// these names must be quoted.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "ALTER": true, "CREATE": true, "DROP": true, "TRUNCATE": true,
+	"DELETE": true, "UPDATE": true, "RENAME": true, "EXCHANGE": true, "ATTACH": true,
+	"DETACH": true, "OPTIMIZE": true, "GRANT": true, "REVOKE": true, "KILL": true,
+}
+
+// CheckReadOnlyQuery checks that custom SQL is a single SELECT statement, so
+// running it as an export source cannot change data. Literals, quoted identifiers
+// and comments are skipped when looking for statement boundaries.
+func CheckReadOnlyQuery(query string) error {
+	stripped := stripSQL(query)
+	statement, rest, _ := strings.Cut(stripped, ";")
+	if strings.TrimSpace(rest) != "" {
+		return fmt.Errorf("only a single statement is allowed: %w", ErrQueryNotReadOnly)
+	}
+
+	m := firstKeywordRe.FindStringSubmatch(statement)
+	if m == nil {
+		return fmt.Errorf("query is empty")
+	}
+	if keyword := strings.ToUpper(m[1]); !readOnlyStatements[keyword] {
+		return fmt.Errorf("%s statements are not allowed, use SELECT: %w", keyword, ErrQueryNotReadOnly)
+	}
+	for _, word := range wordRe.FindAllString(statement, -1) {
+		if keyword := strings.ToUpper(word); writeKeywords[keyword] {
+			return fmt.Errorf("%s is not allowed in a SELECT: %w", keyword, ErrQueryNotReadOnly)
+		}
+	}
+	return nil
+}
+
+// readOnlyContext is queryContext for queries that must only read. ClickHouse
+// refuses them with readonly=1 if they would write, whatever CheckReadOnlyQuery
+// missed.
+func readOnlyContext(ctx context.Context) context.Context {
+	return settingsContext(ctx, clickhouse.Settings{"readonly": 1})
+}
+
+// stripSQL blanks out string literals, quoted identifiers and comments
+func stripSQL(query string) string {
+	var b strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// Skip to the closing quote; backslashes and doubled quotes escape it
+			for i++; i < len(query); i++ {
+				if query[i] == '\\' {
+					i++
+				} else if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteString(" ")
+		case c == '-' && i+1 < len(query) && query[i+1] == '-', c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			b.WriteString(" ")
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			b.WriteString(" ")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// ValidateQuery checks custom SQL without running it: that it is read-only, then
+// that ClickHouse can parse it
+func (s *IngestServiceImpl) ValidateQuery(ctx context.Context, query string) model.QueryValidation {
+	if err := CheckReadOnlyQuery(query); err != nil {
+		return model.QueryValidation{Error: err.Error()}
+	}
+
+	validation := model.QueryValidation{ReadOnly: true}
+	if err := s.clickhouse(ctx).ParseQuery(ctx, query); err != nil {
+		validation.Error = err.Error()
+		return validation
+	}
+	validation.Valid = true
+	return validation
+}
+
+// ParseQuery asks ClickHouse to parse a query with EXPLAIN AST, which neither runs
+// it nor resolves its tables
+func (s *ClickHouseServiceImpl) ParseQuery(ctx context.Context, query string) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected to ClickHouse")
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	rows, err := s.conn.Query(readOnlyContext(ctx), "EXPLAIN AST "+query)
+	if err != nil {
+		return fmt.Errorf("syntax error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("syntax error: %w", err)
+	}
+	return nil
+}
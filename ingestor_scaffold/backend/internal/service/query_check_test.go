@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReadOnlyQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		readOnly bool
+	}{
+		{"select", "SELECT id, name FROM users", true},
+		{"lowercase", "select 1", true},
+		{"trailing semicolon", "SELECT 1;", true},
+		{"trailing semicolon and comment", "SELECT 1; -- done", true},
+		{"parenthesized", "(SELECT 1) UNION ALL (SELECT 2)", true},
+		{"leading comment", "/* report */ SELECT 1", true},
+		{"with", "WITH recent AS (SELECT * FROM events WHERE ts > now() - 60) SELECT count() FROM recent", true},
+		{"keyword in string", "SELECT 'DROP TABLE users; INSERT' AS note", true},
+		{"keyword in quoted identifier", "SELECT `delete`, \"update\" FROM flags", true},
+		{"keyword in comment", "SELECT 1 -- INSERT INTO users", true},
+		{"keyword in block comment", "SELECT /* ALTER TABLE users */ 1", true},
+		{"keyword inside a word", "SELECT last_update, is_deleted FROM users", true},
+		{"semicolon in string", "SELECT ';' AS sep", true},
+		{"escaped quote in string", `SELECT 'it\'s; DROP TABLE users' AS s`, true},
+		{"doubled quote in string", "SELECT 'it''s; DROP TABLE users' AS s", true},
+
+		{"empty", "", false},
+		{"only comment", "-- SELECT 1", false},
+		{"insert", "INSERT INTO users SELECT * FROM staging", false},
+		{"drop", "DROP TABLE users", false},
+		{"alter", "ALTER TABLE users DELETE WHERE 1", false},
+		{"system", "SYSTEM SHUTDOWN", false},
+		{"set", "SET readonly = 0", false},
+		{"two statements", "SELECT 1; DROP TABLE users", false},
+		{"two selects", "SELECT 1; SELECT 2", false},
+		{"statement after comment", "SELECT 1; /* x */ DROP TABLE users", false},
+		{"hash comment hides nothing", "SELECT 1 # comment\n; DROP TABLE users", false},
+		{"dash comment hides nothing", "SELECT 1 -- comment\n; TRUNCATE TABLE users", false},
+		{"unterminated block comment", "DROP TABLE users /* SELECT", false},
+		{"string closed early", "SELECT 'a'; DROP TABLE users; SELECT 'b'", false},
+		{"with insert", "WITH 1 AS x INSERT INTO users SELECT x", false},
+		{"with cte then insert", "WITH t AS (SELECT 1) INSERT INTO users SELECT * FROM t", false},
+		{"with delete", "WITH 1 AS x DELETE FROM users WHERE id = x", false},
+		{"subquery insert", "SELECT * FROM (INSERT INTO users VALUES (1))", false},
+		{"parenthesized drop", "(DROP TABLE users)", false},
+		{"comment before drop", "/* SELECT */ DROP TABLE users", false},
+		{"grant", "SELECT 1 FROM users; GRANT ALL ON *.* TO evil", false},
+		{"kill", "KILL QUERY WHERE 1", false},
+		{"lowercase optimize", "select 1 from (optimize table users)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckReadOnlyQuery(tt.query)
+			if tt.readOnly {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// quotaWindow is the period quota usage accumulates over; windows start at UTC midnight
+const quotaWindow = 24 * time.Hour
+
+// ErrQuotaExceeded is returned for jobs started while one of their quotas is used up
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaService enforces daily row and byte quotas per user or label
+type QuotaService interface {
+	Load(path string) error
+	Check(user string, labels map[string]string) (model.QuotaUsage, bool)
+	Admit(ctx context.Context, jobID string, params model.IngestionParams, progressCh chan<- model.ProgressUpdate) error
+	RecordJob(job model.Job)
+	Usage() []model.QuotaUsage
+}
+
+// QuotaServiceImpl implements QuotaService in memory; usage restarts with the server
+type QuotaServiceImpl struct {
+	mu          sync.Mutex
+	quotas      []model.Quota
+	usage       []model.QuotaUsage // by quota index
+	windowStart time.Time
+	jobService  JobService
+	config      *config.Config
+	logger      *logrus.Logger
+}
+
+// NewQuotaService creates a quota service without quotas
+func NewQuotaService(jobService JobService, config *config.Config, logger *logrus.Logger) QuotaService {
+	return &QuotaServiceImpl{
+		jobService: jobService,
+		config:     config,
+		logger:     logger,
+	}
+}
+
+// Load replaces the quotas with those of a YAML quotas file
+func (s *QuotaServiceImpl) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read quotas file: %w", err)
+	}
+
+	// YAML keys follow the JSON field names used by the API
+	var file model.QuotaFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return fmt.Errorf("failed to parse quotas file: %w", err)
+	}
+	for i, quota := range file.Quotas {
+		if (quota.User == "") == (len(quota.Labels) == 0) {
+			return fmt.Errorf("quota %d: set either a user or labels", i+1)
+		}
+		if err := ValidateLabels(quota.Labels); err != nil {
+			return fmt.Errorf("quota %d: %w", i+1, err)
+		}
+		if quota.RowsPerDay < 0 || quota.BytesPerDay < 0 {
+			return fmt.Errorf("quota %d: limits must not be negative", i+1)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotas = file.Quotas
+	s.usage = make([]model.QuotaUsage, len(file.Quotas))
+	s.windowStart = time.Time{}
+	s.rollLocked(time.Now())
+
+	s.logger.WithField("quotas", len(file.Quotas)).Info("Loaded quotas")
+	return nil
+}
+
+// rollLocked starts a new window once the current one has ended
+func (s *QuotaServiceImpl) rollLocked(now time.Time) {
+	start := now.UTC().Truncate(quotaWindow)
+	if start.Equal(s.windowStart) {
+		return
+	}
+	s.windowStart = start
+	for i, quota := range s.quotas {
+		s.usage[i] = model.QuotaUsage{
+			Quota:       quota,
+			WindowStart: start,
+			ResetAt:     start.Add(quotaWindow),
+		}
+	}
+}
+
+// quotaMatch identifies a job for quota lookups
+type quotaMatch struct {
+	user   string
+	labels map[string]string
+}
+
+// applies reports whether a quota covers the jobs of a user with the given labels
+func (q quotaMatch) applies(quota model.Quota) bool {
+	if quota.User != "" {
+		return quota.User == q.user
+	}
+	return MatchLabels(q.labels, quota.Labels)
+}
+
+// exceeded reports whether a quota's usage has reached one of its limits
+func exceeded(usage model.QuotaUsage) bool {
+	return (usage.Quota.RowsPerDay > 0 && usage.Rows >= usage.Quota.RowsPerDay) ||
+		(usage.Quota.BytesPerDay > 0 && usage.Bytes >= usage.Quota.BytesPerDay)
+}
+
+// Check returns the first quota of a user or labels that is used up
+func (s *QuotaServiceImpl) Check(user string, labels map[string]string) (model.QuotaUsage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rollLocked(time.Now())
+	match := quotaMatch{user: user, labels: labels}
+	for i, quota := range s.quotas {
+		if match.applies(quota) && exceeded(s.usage[i]) {
+			usage := s.usage[i]
+			usage.Exceeded = true
+			return usage, true
+		}
+	}
+	return model.QuotaUsage{}, false
+}
+
+// Admit lets a job start once its quotas allow. Under the "reject" policy a job over
+// quota fails with ErrQuotaExceeded; under "queue" it is marked queued and waits for
+// the window to reset, or for ctx to be cancelled.
+func (s *QuotaServiceImpl) Admit(ctx context.Context, jobID string, params model.IngestionParams, progressCh chan<- model.ProgressUpdate) error {
+	for {
+		usage, over := s.Check(params.User, params.Labels)
+		if !over {
+			return nil
+		}
+		if s.config.QuotaPolicy != "queue" {
+			return fmt.Errorf("%s until %s: %w", describeQuota(usage), usage.ResetAt.Format(time.RFC3339), ErrQuotaExceeded)
+		}
+
+		s.jobService.MarkQueued(jobID, usage.ResetAt)
+		update := model.ProgressUpdate{
+			JobID:   jobID,
+			Status:  "queued",
+			Message: fmt.Sprintf("Queued until %s: %s", usage.ResetAt.Format(time.RFC3339), describeQuota(usage)),
+		}
+		select {
+		case progressCh <- update:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(time.Until(usage.ResetAt))
+		select {
+		case <-timer.C:
+			s.jobService.MarkRunning(jobID)
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// RecordJob adds the rows and bytes a finished job moved to the quotas covering it.
+// Bytes moved are the larger of the bytes read and written.
+func (s *QuotaServiceImpl) RecordJob(job model.Job) {
+	if job.Kind != "" {
+		return
+	}
+	bytes := job.Result.BytesRead
+	if job.Result.BytesWritten > bytes {
+		bytes = job.Result.BytesWritten
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rollLocked(time.Now())
+	match := quotaMatch{user: job.Params.User, labels: job.Params.Labels}
+	for i, quota := range s.quotas {
+		if match.applies(quota) {
+			s.usage[i].Rows += int64(job.Result.TotalRecords)
+			s.usage[i].Bytes += bytes
+		}
+	}
+}
+
+// Usage returns the consumption of every quota in the current window
+func (s *QuotaServiceImpl) Usage() []model.QuotaUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rollLocked(time.Now())
+	usage := make([]model.QuotaUsage, len(s.usage))
+	for i, u := range s.usage {
+		u.Exceeded = exceeded(u)
+		usage[i] = u
+	}
+	return usage
+}
+
+// describeQuota names a used-up quota and the limit it reached
+func describeQuota(usage model.QuotaUsage) string {
+	subject := "user " + usage.Quota.User
+	if usage.Quota.User == "" {
+		subject = fmt.Sprintf("labels %v", usage.Quota.Labels)
+	}
+	if usage.Quota.RowsPerDay > 0 && usage.Rows >= usage.Quota.RowsPerDay {
+		return fmt.Sprintf("daily quota of %d rows for %s used up", usage.Quota.RowsPerDay, subject)
+	}
+	return fmt.Sprintf("daily quota of %d bytes for %s used up", usage.Quota.BytesPerDay, subject)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const testQuotas = `quotas:
+  - user: alice
+    rowsPerDay: 100
+  - labels:
+      team: growth
+    bytesPerDay: 1000
+`
+
+func TestQuotaAdmit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	path := filepath.Join(t.TempDir(), "quotas.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(testQuotas), 0644))
+
+	growth := map[string]string{"team": "growth"}
+	tests := []struct {
+		name     string
+		used     []model.Job
+		params   model.IngestionParams
+		admitted bool
+	}{
+		{"no usage", nil, model.IngestionParams{User: "alice"}, true},
+		{"rows below limit", []model.Job{quotaJob("alice", nil, 99, 0)}, model.IngestionParams{User: "alice"}, true},
+		{"rows used up", []model.Job{quotaJob("alice", nil, 60, 0), quotaJob("alice", nil, 40, 0)}, model.IngestionParams{User: "alice"}, false},
+		{"other user", []model.Job{quotaJob("alice", nil, 100, 0)}, model.IngestionParams{User: "bob"}, true},
+		{"bytes used up by label", []model.Job{quotaJob("bob", growth, 1, 1000)}, model.IngestionParams{User: "carol", Labels: growth}, false},
+		{"other label", []model.Job{quotaJob("bob", growth, 1, 1000)}, model.IngestionParams{User: "carol", Labels: map[string]string{"team": "ads"}}, true},
+		{"subjobs not counted", []model.Job{{Kind: "discovery", Params: model.IngestionParams{User: "alice"}, Result: model.IngestionResult{TotalRecords: 100}}}, model.IngestionParams{User: "alice"}, true},
+	}
+	for _, tt := range tests {
+		s := NewQuotaService(nil, &config.Config{QuotaPolicy: "reject"}, logger)
+		assert.NoError(t, s.Load(path), tt.name)
+		for _, job := range tt.used {
+			s.RecordJob(job)
+		}
+
+		err := s.Admit(context.Background(), "job", tt.params, nil)
+		if tt.admitted {
+			assert.NoError(t, err, tt.name)
+		} else {
+			assert.ErrorIs(t, err, ErrQuotaExceeded, tt.name)
+		}
+	}
+}
+
+func TestQuotaAdmitQueues(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	path := filepath.Join(t.TempDir(), "quotas.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(testQuotas), 0644))
+
+	jobs := NewJobService(nil, &config.Config{}, logger)
+	job := jobs.CreateJob(model.IngestionParams{User: "alice"})
+	s := NewQuotaService(jobs, &config.Config{QuotaPolicy: "queue"}, logger)
+	assert.NoError(t, s.Load(path))
+	s.RecordJob(quotaJob("alice", nil, 100, 0))
+
+	// A queued job reports when it will start and waits until cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan model.ProgressUpdate, 1)
+	done := make(chan error, 1)
+	go func() { done <- s.Admit(ctx, job.ID, job.Params, progress) }()
+
+	update := <-progress
+	assert.Equal(t, "queued", update.Status)
+	queued, _ := jobs.GetJob(job.ID)
+	assert.Equal(t, "queued", queued.Status)
+	assert.NotNil(t, queued.QueuedUntil)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestQuotaLoadRejectsInvalidFiles(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	tests := []struct {
+		name, content string
+	}{
+		{"user and labels", "quotas:\n  - user: alice\n    labels:\n      team: growth\n    rowsPerDay: 1\n"},
+		{"neither", "quotas:\n  - rowsPerDay: 1\n"},
+		{"negative", "quotas:\n  - user: alice\n    rowsPerDay: -1\n"},
+		{"unknown field", "quotas:\n  - user: alice\n    rowsPerWeek: 1\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "quotas.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+		assert.Error(t, NewQuotaService(nil, &config.Config{}, logger).Load(path), tt.name)
+	}
+}
+
+// quotaJob is a finished job of user with labels that moved rows and bytes
+func quotaJob(user string, labels map[string]string, rows int, bytes int64) model.Job {
+	return model.Job{
+		Params: model.IngestionParams{User: user, Labels: labels},
+		Result: model.IngestionResult{TotalRecords: rows, BytesRead: bytes},
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import "github.com/ingestor/internal/model"
+
+// RedactParams drops the credentials of params' target connection. Params keep
+// them in memory to run, but API responses never echo them.
+func RedactParams(params model.IngestionParams) model.IngestionParams {
+	if params.TargetConnection != nil {
+		target := redactConnection(*params.TargetConnection)
+		params.TargetConnection = &target
+	}
+	return params
+}
+
+// RedactJob returns a job with the credentials of its params dropped
+func RedactJob(job model.Job) model.Job {
+	job.Params = RedactParams(job.Params)
+	return job
+}
+
+// RedactJobs redacts each of the given jobs
+func RedactJobs(jobs []model.Job) []model.Job {
+	redacted := make([]model.Job, len(jobs))
+	for i, job := range jobs {
+		redacted[i] = RedactJob(job)
+	}
+	return redacted
+}
+
+// RedactSchedule returns a schedule with the credentials of its params dropped
+func RedactSchedule(schedule model.Schedule) model.Schedule {
+	schedule.Params = RedactParams(schedule.Params)
+	return schedule
+}
+
+// RedactSchedules redacts each of the given schedules
+func RedactSchedules(schedules []model.Schedule) []model.Schedule {
+	redacted := make([]model.Schedule, len(schedules))
+	for i, schedule := range schedules {
+		redacted[i] = RedactSchedule(schedule)
+	}
+	return redacted
+}
+
+// RedactPipeline returns a pipeline with the credentials of its params and steps
+// dropped
+func RedactPipeline(pipeline model.Pipeline) model.Pipeline {
+	pipeline.Params = RedactParams(pipeline.Params)
+	steps := make([]model.PipelineStep, len(pipeline.Steps))
+	for i, step := range pipeline.Steps {
+		step.Params = RedactParams(step.Params)
+		steps[i] = step
+	}
+	if pipeline.Steps != nil {
+		pipeline.Steps = steps
+	}
+	return pipeline
+}
+
+// RedactPipelines redacts each of the given pipelines
+func RedactPipelines(pipelines []model.Pipeline) []model.Pipeline {
+	redacted := make([]model.Pipeline, len(pipelines))
+	for i, pipeline := range pipelines {
+		redacted[i] = RedactPipeline(pipeline)
+	}
+	return redacted
+}
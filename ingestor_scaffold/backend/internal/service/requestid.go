@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+type requestIDKey struct{}
+
+type userKey struct{}
+
+// querySeq distinguishes the ClickHouse queries issued for one request
+var querySeq uint64
+
+// WithRequestID returns a context carrying the ID of the request it serves
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithUser returns a context carrying the authenticated caller of a request
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the caller carried by ctx, or ""
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// queryContext tags ClickHouse queries with a query_id derived from the request ID,
+// so they can be found in system.query_log. Query IDs must be unique, so each gets a sequence suffix.
+// Job labels are set as the query's log_comment for cost attribution.
+func queryContext(ctx context.Context) context.Context {
+	return settingsContext(ctx, nil)
+}
+
+// settingsContext is queryContext with extra settings for the query
+func settingsContext(ctx context.Context, settings clickhouse.Settings) context.Context {
+	var opts []clickhouse.QueryOption
+	if id := RequestIDFromContext(ctx); id != "" {
+		queryID := fmt.Sprintf("%s-%d", id, atomic.AddUint64(&querySeq, 1))
+		opts = append(opts, clickhouse.WithQueryID(queryID))
+	}
+	merged := make(clickhouse.Settings, len(settings)+1)
+	for name, value := range settings {
+		merged[name] = value
+	}
+	if comment := labelComment(LabelsFromContext(ctx)); comment != "" {
+		merged["log_comment"] = comment
+	}
+	if len(merged) > 0 {
+		opts = append(opts, clickhouse.WithSettings(merged))
+	}
+	if len(opts) == 0 {
+		return ctx
+	}
+	return clickhouse.Context(ctx, opts...)
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Row error policies
+const (
+	ErrorPolicySkip    = "skip"
+	ErrorPolicyAbort   = "abort"
+	ErrorPolicyCollect = "collect"
+)
+
+// maxCollectedRowErrors bounds the bad rows a collecting job keeps in its report;
+// later ones are only counted
+const maxCollectedRowErrors = 10000
+
+// targetRejectedWarning counts the rows ClickHouse refused on insert
+const targetRejectedWarning = "rows skipped (rejected by ClickHouse)"
+
+type rowErrorsKey struct{}
+
+// RowErrorLog applies the error policy of a job to its bad rows: rows that cannot
+// be read, converted or inserted, or that break a data-quality rule
+type RowErrorLog struct {
+	policy     string
+	maxErrors  int
+	maxSamples int
+
+	mu        sync.Mutex
+	count     int
+	samples   []model.RowError
+	collected []model.RowError
+	err       error // why the policy stopped the job
+}
+
+// checkErrorPolicy validates the error policy of params
+func checkErrorPolicy(params model.IngestionParams) error {
+	switch params.ErrorPolicy {
+	case "", ErrorPolicySkip, ErrorPolicyAbort, ErrorPolicyCollect:
+	default:
+		return fmt.Errorf("unsupported error policy %q: must be skip, abort or collect", params.ErrorPolicy)
+	}
+	if params.MaxErrors < 0 {
+		return fmt.Errorf("maxErrors must not be negative")
+	}
+	if params.MaxErrors > 0 && params.ErrorPolicy != "" && params.ErrorPolicy != ErrorPolicySkip {
+		return fmt.Errorf("maxErrors applies to the skip error policy")
+	}
+	return nil
+}
+
+// NewRowErrorLog creates a log for the error policy of params, keeping the first
+// samples bad rows whatever the policy
+func NewRowErrorLog(params model.IngestionParams, samples int) *RowErrorLog {
+	policy := params.ErrorPolicy
+	if policy == "" {
+		policy = ErrorPolicySkip
+	}
+	return &RowErrorLog{policy: policy, maxErrors: params.MaxErrors, maxSamples: samples}
+}
+
+// WithRowErrors returns a context carrying the given log
+func WithRowErrors(ctx context.Context, l *RowErrorLog) context.Context {
+	return context.WithValue(ctx, rowErrorsKey{}, l)
+}
+
+// RowErrorsFromContext returns the log carried by ctx, or nil
+func RowErrorsFromContext(ctx context.Context) *RowErrorLog {
+	l, _ := ctx.Value(rowErrorsKey{}).(*RowErrorLog)
+	return l
+}
+
+// Record notes a bad row, numbered from 1 when its position is known, and returns
+// an error once the policy stops the job
+func (l *RowErrorLog) Record(row int, reason string, values []string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count++
+	if l.err != nil {
+		return l.err
+	}
+	rowErr := model.RowError{Row: row, Reason: reason, Values: append([]string(nil), values...)}
+	if len(l.samples) < l.maxSamples {
+		l.samples = append(l.samples, rowErr)
+	}
+	switch l.policy {
+	case ErrorPolicyAbort:
+		l.err = fmt.Errorf("bad row%s: %s (error policy abort)", rowPosition(row), reason)
+	case ErrorPolicyCollect:
+		if len(l.collected) < maxCollectedRowErrors {
+			l.collected = append(l.collected, rowErr)
+		}
+	default:
+		if l.maxErrors > 0 && l.count > l.maxErrors {
+			l.err = fmt.Errorf("more than %d bad rows, the last%s: %s", l.maxErrors, rowPosition(row), reason)
+		}
+	}
+	return l.err
+}
+
+// Samples returns the first bad rows of the job
+func (l *RowErrorLog) Samples() []model.RowError {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]model.RowError(nil), l.samples...)
+}
+
+// Err returns the error that stopped the job, if its policy did. Stages that
+// cannot fail drop their rows once it is set, and the job reports it.
+func (l *RowErrorLog) Err() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Collected returns the bad rows a collecting job kept
+func (l *RowErrorLog) Collected() []model.RowError {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]model.RowError(nil), l.collected...)
+}
+
+// SetRowCounts fills in the dropped and coerced row counts of a result from the
+// warnings its job raised
+func SetRowCounts(result *model.IngestionResult, warnings *WarningCollector) {
+	result.RejectedRecords = warnings.Total("rows skipped")
+	result.TargetRejectedRecords = warnings.Total(targetRejectedWarning)
+	result.SkippedRecords = result.RejectedRecords - result.TargetRejectedRecords
+	result.CoercedRecords = warnings.Total("rows coerced")
+	result.CoercedValues = warnings.Total("values coerced")
+}
+
+// rowPosition names the row a bad row error is about, if known
+func rowPosition(row int) string {
+	if row <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" %d", row)
+}
+
+// rowStrings renders row values for a row error
+func rowStrings(row []interface{}) []string {
+	values := make([]string, len(row))
+	for i, value := range row {
+		if value = derefValue(value); value != nil {
+			values[i] = fmt.Sprintf("%v", value)
+		}
+	}
+	return values
+}
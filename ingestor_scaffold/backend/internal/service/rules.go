@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Data-quality rules
+const (
+	RuleNotNull = "notNull"
+	RuleRegex   = "regex"
+	RuleRange   = "range"
+	RuleAllowed = "allowed"
+	RuleUnique  = "unique"
+)
+
+// columnRule is a compiled data-quality rule; pass reports whether a non-NULL value
+// keeps to it
+type columnRule struct {
+	column string
+	rule   string
+	idx    int
+	pass   func(value interface{}) bool
+}
+
+// RuleChecker evaluates the data-quality rules of a job on positional rows and
+// counts the rows each one rejected. A row is charged to the first rule it breaks.
+type RuleChecker struct {
+	rules []columnRule
+
+	mu         sync.Mutex
+	violations []int
+}
+
+// compileRules validates data-quality rules against the ingested columns. Unique
+// rules remember at most maxKeys values, so repeats further apart are missed, and
+// run last so rows rejected by another rule do not claim their values.
+func compileRules(rules []model.ColumnRule, columns []model.Column, maxKeys int) (*RuleChecker, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col.Name] = i
+	}
+
+	checker := &RuleChecker{violations: make([]int, len(rules))}
+	var unique []columnRule
+	for _, r := range rules {
+		idx, ok := index[r.Column]
+		if !ok {
+			return nil, fmt.Errorf("rule column %s is not selected", r.Column)
+		}
+		colType := baseType(columns[idx].Type)
+		compiled := columnRule{column: r.Column, rule: r.Rule, idx: idx}
+
+		switch r.Rule {
+		case RuleNotNull:
+		case RuleRegex:
+			if colType != "String" && !strings.HasPrefix(colType, "FixedString(") {
+				return nil, fmt.Errorf("regex rule needs a String column, %s is %s", r.Column, columns[idx].Type)
+			}
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for %s: %w", r.Column, err)
+			}
+			compiled.pass = func(value interface{}) bool {
+				return re.MatchString(fmt.Sprint(value))
+			}
+		case RuleRange:
+			if !isNumericType(colType) {
+				return nil, fmt.Errorf("range rule needs a numeric column, %s is %s", r.Column, columns[idx].Type)
+			}
+			if r.Min == nil && r.Max == nil {
+				return nil, fmt.Errorf("range rule for %s needs a min or a max", r.Column)
+			}
+			if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+				return nil, fmt.Errorf("range rule for %s has a min above its max", r.Column)
+			}
+			lo, hi := r.Min, r.Max
+			compiled.pass = func(value interface{}) bool {
+				v, ok := toFloat64(value)
+				return ok && (lo == nil || v >= *lo) && (hi == nil || v <= *hi)
+			}
+		case RuleAllowed:
+			if len(r.Values) == 0 {
+				return nil, fmt.Errorf("allowed rule for %s needs values", r.Column)
+			}
+			allowed := make(map[string]bool, len(r.Values))
+			for _, v := range r.Values {
+				allowed[v] = true
+			}
+			compiled.pass = func(value interface{}) bool {
+				return allowed[fmt.Sprint(value)]
+			}
+		case RuleUnique:
+			seen := NewDeduplicator(maxKeys)
+			compiled.pass = func(value interface{}) bool {
+				return !seen.IsDuplicate([]interface{}{value})
+			}
+			unique = append(unique, compiled)
+			continue
+		default:
+			return nil, fmt.Errorf("unknown rule %q for %s: must be notNull, regex, range, allowed or unique", r.Rule, r.Column)
+		}
+		checker.rules = append(checker.rules, compiled)
+	}
+	checker.rules = append(checker.rules, unique...)
+	return checker, nil
+}
+
+// check returns the first rule row breaks, or nil if it keeps to all of them
+func (c *RuleChecker) check(row []interface{}) *columnRule {
+	for i := range c.rules {
+		r := &c.rules[i]
+		value := derefValue(row[r.idx])
+		if value == nil {
+			if r.rule == RuleNotNull {
+				c.violated(i)
+				return r
+			}
+			continue
+		}
+		if r.pass != nil && !r.pass(value) {
+			c.violated(i)
+			return r
+		}
+	}
+	return nil
+}
+
+// violated counts a row rejected by rule i
+func (c *RuleChecker) violated(i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.violations[i]++
+}
+
+// Violations summarizes the rows each rule rejected, leaving out rules no row broke
+func (c *RuleChecker) Violations() []model.RuleViolation {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var violations []model.RuleViolation
+	for i, r := range c.rules {
+		if c.violations[i] > 0 {
+			violations = append(violations, model.RuleViolation{Column: r.column, Rule: r.rule, Rows: c.violations[i]})
+		}
+	}
+	return violations
+}
+
+// checkRows forwards the rows from in that keep to the rules; the others are bad
+// rows, dropped or failing the job as the error policy says
+func (s *IngestServiceImpl) checkRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	checker *RuleChecker,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	warnings := WarningsFromContext(ctx)
+	rowErrors := RowErrorsFromContext(ctx)
+
+	go func() {
+		defer close(out)
+
+		for row := range in {
+			// Drain the rows once the error policy stops the job
+			if rowErrors.Err() != nil {
+				continue
+			}
+			if r := checker.check(row); r != nil {
+				warnings.Count(fmt.Sprintf("rows skipped (column %s broke rule %s)", r.column, r.rule), 1)
+				rowErrors.Record(0, fmt.Sprintf("column %s broke rule %s", r.column, r.rule), rowStrings(row))
+				continue
+			}
+
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
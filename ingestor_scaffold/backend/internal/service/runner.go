@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// JobRunner runs ingestions in the background as tracked jobs
+type JobRunner struct {
+	ingestService  IngestService
+	jobService     JobService
+	sessionService SessionService
+	quotaService   QuotaService
+	config         *config.Config
+	logger         *logrus.Logger
+}
+
+// NewJobRunner creates a new job runner
+func NewJobRunner(ingestService IngestService, jobService JobService, sessionService SessionService, quotaService QuotaService, config *config.Config, logger *logrus.Logger) *JobRunner {
+	return &JobRunner{
+		ingestService:  ingestService,
+		jobService:     jobService,
+		sessionService: sessionService,
+		quotaService:   quotaService,
+		config:         config,
+		logger:         logger,
+	}
+}
+
+// withSession attaches the ClickHouse connection of the params' session to ctx.
+// The session is held open until release is called.
+func (r *JobRunner) withSession(ctx context.Context, params model.IngestionParams) (context.Context, func(), error) {
+	if params.SessionID == "" {
+		return ctx, func() {}, nil
+	}
+	conn, release, err := r.sessionService.Acquire(params.SessionID)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return WithClickHouse(ctx, conn), release, nil
+}
+
+// Start launches an ingestion as a tracked job and returns immediately.
+// The returned channel is closed once the job has completed.
+func (r *JobRunner) Start(params model.IngestionParams, fields logrus.Fields) (model.Job, <-chan struct{}) {
+	finished := make(chan struct{})
+	job := r.start(context.Background(), params, fields, nil, finished)
+	return job, finished
+}
+
+// JobStream relays a job's progress to the caller that started it, such as an
+// HTTP response. Updates ends with the job's final update and is then closed.
+type JobStream struct {
+	Updates  <-chan model.ProgressUpdate
+	Warnings *WarningCollector
+	Counters *ByteCounters
+
+	updates chan model.ProgressUpdate
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Close stops relaying updates; the job itself keeps running
+func (s *JobStream) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// send relays an update unless the stream was closed
+func (s *JobStream) send(update model.ProgressUpdate) {
+	select {
+	case s.updates <- update:
+	case <-s.done:
+	}
+}
+
+// StartStream launches an ingestion like Start, relaying its progress on the
+// returned stream. Its queries are tagged with the requestID of the caller.
+func (r *JobRunner) StartStream(requestID string, params model.IngestionParams, fields logrus.Fields) (model.Job, *JobStream) {
+	updates := make(chan model.ProgressUpdate, 10)
+	stream := &JobStream{
+		Updates:  updates,
+		Warnings: NewWarningCollector(),
+		Counters: NewByteCounters(),
+		updates:  updates,
+		done:     make(chan struct{}),
+	}
+	ctx := context.Background()
+	if requestID != "" {
+		ctx = WithRequestID(ctx, requestID)
+	}
+	job := r.start(ctx, params, fields, stream, make(chan struct{}))
+	return job, stream
+}
+
+// start runs an ingestion as a tracked job, closing finished once it completes
+func (r *JobRunner) start(base context.Context, params model.IngestionParams, fields logrus.Fields, stream *JobStream, finished chan struct{}) model.Job {
+	// Jobs carry their connection's labels under their own
+	params.Labels = MergeLabels(r.sessionService.Labels(params.SessionID), params.Labels)
+	job := r.jobService.CreateJob(params)
+	logger := r.logger.WithFields(fields).WithField("jobId", job.ID)
+	if len(params.Labels) > 0 {
+		logger = logger.WithField("labels", params.Labels)
+	}
+
+	warnings := NewWarningCollector()
+	counters := NewByteCounters()
+	if stream != nil {
+		warnings, counters = stream.Warnings, stream.Counters
+	}
+	deadLetter := NewJobDeadLetter(r.config, job.ID)
+	batchErrors := NewBatchErrorLog()
+	ctx, cancel := context.WithCancel(WithLabels(WithBatchErrors(WithDeadLetter(WithByteCounters(WithWarnings(base, warnings), counters), deadLetter), batchErrors), params.Labels))
+	r.jobService.AttachCancel(job.ID, cancel)
+
+	go func() {
+		defer close(finished)
+		defer cancel()
+
+		logger.Info("Starting background ingestion")
+
+		progressCh, drained := r.recordProgress(job.ID, stream)
+
+		// Jobs over quota wait for the window to reset before taking a session
+		var result model.IngestionResult
+		err := r.quotaService.Admit(ctx, job.ID, params, progressCh)
+		if err == nil {
+			var runCtx context.Context
+			var release func()
+			if runCtx, release, err = r.withSession(ctx, params); err == nil {
+				result, err = r.ingestService.Run(runCtx, params, progressCh)
+				release()
+			}
+		}
+		close(progressCh)
+		<-drained
+
+		result.Warnings = warnings.Snapshot()
+		SetRowCounts(&result, warnings)
+		if err := deadLetter.Close(); err != nil {
+			logger.WithError(err).Warn("Failed to write dead-letter file")
+		}
+		result.DeadLetterFile = deadLetter.Path()
+		result.BatchErrors = batchErrors.Snapshot()
+		result.BytesRead, result.BytesWritten = counters.Read(), counters.Written()
+		r.jobService.CompleteJob(job.ID, result, err)
+		if stream != nil {
+			stream.send(finalUpdate(job.ID, result, err))
+			close(stream.updates)
+		}
+		if err != nil {
+			logger.WithError(err).Error("Background ingestion failed")
+			return
+		}
+		logger.WithField("rows", result.TotalRecords).Info("Background ingestion completed")
+	}()
+
+	return job
+}
+
+// finalUpdate is the last progress update of a job, carrying its result
+func finalUpdate(jobID string, result model.IngestionResult, err error) model.ProgressUpdate {
+	if err != nil {
+		return model.ProgressUpdate{
+			JobID:     jobID,
+			Status:    "error",
+			Message:   err.Error(),
+			Completed: true,
+			Result:    &result,
+		}
+	}
+	return model.ProgressUpdate{
+		JobID:     jobID,
+		Status:    "success",
+		Message:   "Ingestion completed successfully",
+		Count:     result.TotalRecords,
+		Completed: true,
+		Result:    &result,
+	}
+}
+
+// StartDiscovery discovers a flat file's schema as a tracked job and returns
+// immediately; the columns are in the job result once it completes
+func (r *JobRunner) StartDiscovery(params model.FlatFileParams) model.Job {
+	job := r.jobService.CreateTask(model.JobKindSchemaDiscovery, model.IngestionParams{
+		SourceType:     "flatfile",
+		FlatFileParams: params,
+	})
+	logger := r.logger.WithField("jobId", job.ID)
+
+	counters := NewByteCounters()
+	ctx, cancel := context.WithCancel(WithByteCounters(context.Background(), counters))
+	r.jobService.AttachCancel(job.ID, cancel)
+
+	go func() {
+		defer cancel()
+
+		logger.WithField("file", params.FilePath).Info("Starting schema discovery")
+		progressCh, drained := r.recordProgress(job.ID, nil)
+		schema, err := r.ingestService.DiscoverSchema(ctx, params, progressCh)
+		close(progressCh)
+		<-drained
+
+		result := model.IngestionResult{
+			Schema:            schema.Columns,
+			SchemaFingerprint: schema.Fingerprint,
+			HeaderRenames:     schema.Renames,
+			Inference:         schema.Inference,
+			BytesRead:         counters.Read(),
+		}
+		r.jobService.CompleteJob(job.ID, result, err)
+		if err != nil {
+			logger.WithError(err).Error("Schema discovery failed")
+			return
+		}
+		logger.WithField("columns", len(schema.Columns)).Info("Schema discovery completed")
+	}()
+
+	return job
+}
+
+// recordProgress returns a channel whose updates are recorded on the job and
+// relayed to stream if set, and a channel closed once it has been closed and drained
+func (r *JobRunner) recordProgress(jobID string, stream *JobStream) (chan model.ProgressUpdate, <-chan struct{}) {
+	progressCh := make(chan model.ProgressUpdate, 10)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for update := range progressCh {
+			update.JobID = jobID
+			r.jobService.RecordUpdate(jobID, update)
+			if stream != nil {
+				stream.send(update)
+			}
+		}
+	}()
+	return progressCh, drained
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Schema discovery sampling strategies
+const (
+	SampleHead      = "head"
+	SampleRandom    = "random"
+	SampleReservoir = "reservoir"
+)
+
+// Sampling defaults and limits; reservoir samples are held in memory
+const (
+	defaultSampleSize = 100
+	defaultSampleRate = 0.1
+	maxReservoirSize  = 100000
+)
+
+// rowSampler picks the rows schema discovery infers types from
+type rowSampler struct {
+	strategy  string
+	size      int // 0 keeps every row
+	rate      float64
+	seed      int64
+	rng       *rand.Rand
+	seen      int
+	kept      int
+	reservoir [][]string
+}
+
+// newRowSampler validates the sampling settings of params. FullScan overrides them
+// with every row.
+func newRowSampler(params model.FlatFileParams) (*rowSampler, error) {
+	if params.FullScan {
+		return &rowSampler{strategy: SampleHead}, nil
+	}
+
+	r := &rowSampler{
+		strategy: params.SampleStrategy,
+		size:     params.SampleSize,
+		rate:     params.SampleRate,
+		seed:     params.SampleSeed,
+	}
+	if r.strategy == "" {
+		r.strategy = SampleHead
+	}
+	switch {
+	case r.size < 0:
+		return nil, fmt.Errorf("sampleSize must not be negative")
+	case r.size == 0:
+		r.size = defaultSampleSize
+	}
+
+	switch r.strategy {
+	case SampleHead:
+	case SampleRandom:
+		if r.rate == 0 {
+			r.rate = defaultSampleRate
+		}
+		if r.rate < 0 || r.rate > 1 {
+			return nil, fmt.Errorf("sampleRate must be between 0 and 1")
+		}
+	case SampleReservoir:
+		if r.size > maxReservoirSize {
+			return nil, fmt.Errorf("reservoir samples may hold at most %d rows", maxReservoirSize)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sample strategy %q: must be head, random or reservoir", r.strategy)
+	}
+
+	seed := r.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r.rng = rand.New(rand.NewSource(seed))
+	return r, nil
+}
+
+// cacheKey returns the suffix of the schema cache key for this sample, false when
+// the sample is random without a seed and so not worth caching. The default head
+// sample keeps the bare fingerprint.
+func (r *rowSampler) cacheKey() (string, bool) {
+	switch {
+	case r.size == 0:
+		return "/full", true
+	case r.strategy == SampleHead && r.size == defaultSampleSize:
+		return "", true
+	case r.strategy == SampleHead:
+		return fmt.Sprintf("/head:%d", r.size), true
+	case r.seed == 0:
+		return "", false
+	default:
+		return fmt.Sprintf("/%s:%d:%g:%d", r.strategy, r.size, r.rate, r.seed), true
+	}
+}
+
+// Offer reports whether a row is sampled and its types should be inferred now.
+// Reservoir samples instead keep rows until the file is read; see Reservoir.
+func (r *rowSampler) Offer(record []string) bool {
+	r.seen++
+	switch r.strategy {
+	case SampleRandom:
+		if r.rng.Float64() >= r.rate {
+			return false
+		}
+	case SampleReservoir:
+		// Records are not reused by the reader, so they may be kept as is
+		if len(r.reservoir) < r.size {
+			r.reservoir = append(r.reservoir, record)
+		} else if j := r.rng.Intn(r.seen); j < r.size {
+			r.reservoir[j] = record
+		}
+		return false
+	}
+	r.kept++
+	return true
+}
+
+// Done reports whether the sample is complete before the end of the file
+func (r *rowSampler) Done() bool {
+	return r.strategy != SampleReservoir && r.size > 0 && r.kept >= r.size
+}
+
+// Reservoir returns the rows of a reservoir sample
+func (r *rowSampler) Reservoir() [][]string {
+	return r.reservoir
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ingestor/internal/config"
+)
+
+// ErrPathNotAllowed is returned for flat file paths outside the allowed directories
+var ErrPathNotAllowed = errors.New("path is outside the allowed directories")
+
+// PathSandbox confines flat file paths to a set of root directories
+type PathSandbox struct {
+	roots    []string
+	confined bool
+}
+
+// NewPathSandbox creates a sandbox from comma-separated lists of root directories.
+// Only blank lists allow every path; lists naming no usable directory allow none.
+func NewPathSandbox(lists ...string) *PathSandbox {
+	sandbox := &PathSandbox{}
+	for _, list := range lists {
+		if strings.TrimSpace(list) != "" {
+			sandbox.confined = true
+		}
+		for _, root := range strings.Split(list, ",") {
+			if root = strings.TrimSpace(root); root == "" {
+				continue
+			}
+			if abs, err := filepath.Abs(root); err == nil {
+				sandbox.roots = append(sandbox.roots, resolveExisting(abs))
+			}
+		}
+	}
+	return sandbox
+}
+
+// newFileSandbox confines flat files to the configured roots. Dead-letter files can
+// be reingested, so their directory is allowed as well. Without roots, which config
+// only accepts in development, every path is allowed.
+func newFileSandbox(cfg *config.Config) *PathSandbox {
+	if strings.TrimSpace(cfg.AllowedFileRoots) == "" {
+		return NewPathSandbox()
+	}
+	return NewPathSandbox(cfg.AllowedFileRoots, cfg.DeadLetterDir)
+}
+
+// Resolve returns the absolute form of a path with its symlinks followed, failing
+// with ErrPathNotAllowed when that lies outside every root. Following symlinks
+// first means a link inside a root cannot point out of it, and ".." components
+// are gone before the check.
+func (p *PathSandbox) Resolve(path string) (string, error) {
+	if p == nil || !p.confined {
+		return path, nil
+	}
+	if path == "" {
+		return "", fmt.Errorf("file path is required")
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid file path %s: %w", path, err)
+	}
+	resolved := resolveExisting(abs)
+	for _, root := range p.roots {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", path, ErrPathNotAllowed)
+}
+
+// resolveExisting follows the symlinks of the longest existing prefix of an absolute
+// path, so files about to be created are checked by their real directory
+func resolveExisting(path string) string {
+	rest := ""
+	for {
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(real, rest)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest)
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ingestor/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSandbox creates a root directory holding data.csv, a directory outside it
+// holding secret.csv, and a sandbox confined to the root
+func newTestSandbox(t *testing.T) (sandbox *PathSandbox, root, outside string) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, outside = filepath.Join(dir, "root"), filepath.Join(dir, "outside")
+	for _, d := range []string{root, filepath.Join(root, "sub"), outside} {
+		if err := os.Mkdir(d, 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{filepath.Join(root, "data.csv"), filepath.Join(outside, "secret.csv")} {
+		if err := os.WriteFile(f, []byte("id\n1\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewPathSandbox(root), root, outside
+}
+
+func TestPathSandboxResolve(t *testing.T) {
+	sandbox, root, outside := newTestSandbox(t)
+
+	allowed := map[string]string{
+		filepath.Join(root, "data.csv"):              filepath.Join(root, "data.csv"),
+		filepath.Join(root, "sub", "..", "data.csv"): filepath.Join(root, "data.csv"),
+		filepath.Join(root, "sub", "new.csv"):        filepath.Join(root, "sub", "new.csv"),
+		filepath.Join(root, "new", "dir", "out.csv"): filepath.Join(root, "new", "dir", "out.csv"),
+		root: root,
+	}
+	for path, want := range allowed {
+		resolved, err := sandbox.Resolve(path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, want, resolved, path)
+	}
+
+	denied := []string{
+		filepath.Join(outside, "secret.csv"),
+		filepath.Join(root, "..", "outside", "secret.csv"),
+		filepath.Join(root, "sub", "..", "..", "outside", "secret.csv"),
+		filepath.Join(root, ".."),
+		root + "-sibling/data.csv",
+		"/etc/passwd",
+		"/",
+	}
+	for _, path := range denied {
+		_, err := sandbox.Resolve(path)
+		assert.True(t, errors.Is(err, ErrPathNotAllowed), path)
+	}
+
+	_, err := sandbox.Resolve("")
+	assert.Error(t, err)
+}
+
+func TestPathSandboxRelativePath(t *testing.T) {
+	sandbox, root, outside := newTestSandbox(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative paths resolve against the working directory
+	rel, err := filepath.Rel(wd, filepath.Join(root, "data.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := sandbox.Resolve(rel)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "data.csv"), resolved)
+
+	rel, err = filepath.Rel(wd, filepath.Join(outside, "secret.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sandbox.Resolve(rel)
+	assert.True(t, errors.Is(err, ErrPathNotAllowed))
+}
+
+func TestPathSandboxSymlinks(t *testing.T) {
+	sandbox, root, outside := newTestSandbox(t)
+
+	// Links out of the root are followed before the check, for files and directories
+	for name, target := range map[string]string{
+		"secret.csv": filepath.Join(outside, "secret.csv"),
+		"escape":     outside,
+	} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skip("symlinks unsupported:", err)
+		}
+	}
+	for _, path := range []string{
+		filepath.Join(root, "secret.csv"),
+		filepath.Join(root, "escape", "secret.csv"),
+		filepath.Join(root, "escape", "new.csv"),
+	} {
+		_, err := sandbox.Resolve(path)
+		assert.True(t, errors.Is(err, ErrPathNotAllowed), path)
+	}
+
+	// Links that stay inside the root resolve to their target
+	if err := os.Symlink(filepath.Join(root, "data.csv"), filepath.Join(root, "sub", "link.csv")); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := sandbox.Resolve(filepath.Join(root, "sub", "link.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "data.csv"), resolved)
+
+	// A root reached through a link is compared by its real path
+	link := filepath.Join(outside, "root-link")
+	if err := os.Symlink(root, link); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err = NewPathSandbox(link).Resolve(filepath.Join(root, "data.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "data.csv"), resolved)
+}
+
+func TestPathSandboxBlankRootList(t *testing.T) {
+	// A list naming no directory confines every path rather than none
+	sandbox := NewPathSandbox(" , ")
+	for _, path := range []string{"/etc/passwd", "data.csv"} {
+		_, err := sandbox.Resolve(path)
+		assert.ErrorIs(t, err, ErrPathNotAllowed, path)
+	}
+}
+
+func TestPathSandboxEmptyRoots(t *testing.T) {
+	// Without ALLOWED_FILE_ROOTS every path is allowed, unchanged
+	for _, sandbox := range []*PathSandbox{
+		nil,
+		NewPathSandbox(),
+		NewPathSandbox(""),
+		NewPathSandbox("  "),
+		newFileSandbox(&config.Config{DeadLetterDir: "/var/lib/ingestor/dead-letter"}),
+	} {
+		for _, path := range []string{"/etc/passwd", "../../data.csv", ""} {
+			resolved, err := sandbox.Resolve(path)
+			assert.NoError(t, err, path)
+			assert.Equal(t, path, resolved)
+		}
+	}
+
+	// With roots, the dead-letter directory is allowed as well
+	_, root, outside := newTestSandbox(t)
+	fileSandbox := newFileSandbox(&config.Config{AllowedFileRoots: root, DeadLetterDir: outside})
+	_, err := fileSandbox.Resolve(filepath.Join(outside, "secret.csv"))
+	assert.NoError(t, err)
+}
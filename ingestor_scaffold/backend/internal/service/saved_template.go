@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// SavedTemplateService defines operations for the saved template library
+type SavedTemplateService interface {
+	CreateTemplate(req model.SavedTemplateRequest, user string) (model.SavedTemplate, error)
+	UpdateTemplate(name string, req model.SavedTemplateRequest) (model.SavedTemplate, error)
+	ListTemplates() []model.SavedTemplate
+	GetTemplate(name string) (model.SavedTemplate, error)
+	DeleteTemplate(name string) error
+	Restore() error
+}
+
+// SavedTemplateServiceImpl implements SavedTemplateService in memory, persisted to
+// the state store
+type SavedTemplateServiceImpl struct {
+	mu        sync.RWMutex
+	templates map[string]*model.SavedTemplate
+	persistMu sync.Mutex
+	store     *StateStore
+	config    *config.Config
+	logger    *logrus.Logger
+}
+
+// NewSavedTemplateService creates a new saved template service
+func NewSavedTemplateService(
+	store *StateStore,
+	config *config.Config,
+	logger *logrus.Logger,
+) SavedTemplateService {
+	return &SavedTemplateServiceImpl{
+		templates: make(map[string]*model.SavedTemplate),
+		store:     store,
+		config:    config,
+		logger:    logger,
+	}
+}
+
+// connectionless strips the session, credentials and owner from saved params, so a
+// template runs against whichever session the caller brings
+func connectionless(params model.IngestionParams) model.IngestionParams {
+	params.SessionID = ""
+	params.TargetConnection = nil
+	params.User = ""
+	return params
+}
+
+// validateTemplate checks a template request before it is saved
+func validateTemplate(req model.SavedTemplateRequest) error {
+	if strings.TrimSpace(req.Name) == "" || strings.Contains(req.Name, "/") {
+		return fmt.Errorf("template name is required and may not contain '/'")
+	}
+	if req.Params.SourceType == "" || req.Params.TargetType == "" {
+		return fmt.Errorf("source and target types are required")
+	}
+	if req.Params.Query != "" {
+		if err := CheckReadOnlyQuery(req.Params.Query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateTemplate saves a new template owned by user
+func (s *SavedTemplateServiceImpl) CreateTemplate(req model.SavedTemplateRequest, user string) (model.SavedTemplate, error) {
+	if err := validateTemplate(req); err != nil {
+		return model.SavedTemplate{}, err
+	}
+
+	now := time.Now()
+	template := &model.SavedTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		Params:      connectionless(req.Params),
+		CreatedBy:   user,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	s.mu.Lock()
+	if _, exists := s.templates[req.Name]; exists {
+		s.mu.Unlock()
+		return model.SavedTemplate{}, fmt.Errorf("template %s already exists", req.Name)
+	}
+	s.templates[req.Name] = template
+	created := *template
+	s.mu.Unlock()
+
+	s.persist()
+	return created, nil
+}
+
+// UpdateTemplate replaces the description and params of a template, keeping its name
+// and owner
+func (s *SavedTemplateServiceImpl) UpdateTemplate(name string, req model.SavedTemplateRequest) (model.SavedTemplate, error) {
+	req.Name = name
+	if err := validateTemplate(req); err != nil {
+		return model.SavedTemplate{}, err
+	}
+
+	s.mu.Lock()
+	template, ok := s.templates[name]
+	if !ok {
+		s.mu.Unlock()
+		return model.SavedTemplate{}, fmt.Errorf("template %s not found", name)
+	}
+	template.Description = req.Description
+	template.Params = connectionless(req.Params)
+	template.UpdatedAt = time.Now()
+	updated := *template
+	s.mu.Unlock()
+
+	s.persist()
+	return updated, nil
+}
+
+// ListTemplates returns all templates ordered by name
+func (s *SavedTemplateServiceImpl) ListTemplates() []model.SavedTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	templates := make([]model.SavedTemplate, 0, len(s.templates))
+	for _, template := range s.templates {
+		templates = append(templates, *template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// GetTemplate returns a template by name
+func (s *SavedTemplateServiceImpl) GetTemplate(name string) (model.SavedTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	template, ok := s.templates[name]
+	if !ok {
+		return model.SavedTemplate{}, fmt.Errorf("template %s not found", name)
+	}
+	return *template, nil
+}
+
+// DeleteTemplate removes a template
+func (s *SavedTemplateServiceImpl) DeleteTemplate(name string) error {
+	s.mu.Lock()
+	if _, ok := s.templates[name]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("template %s not found", name)
+	}
+	delete(s.templates, name)
+	s.mu.Unlock()
+
+	s.persist()
+	return nil
+}
+
+// Restore reloads templates persisted before a restart
+func (s *SavedTemplateServiceImpl) Restore() error {
+	var templates []model.SavedTemplate
+	found, err := s.store.Load(stateTemplatesFile, &templates)
+	if err != nil || !found {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range templates {
+		template := templates[i]
+		if _, exists := s.templates[template.Name]; !exists {
+			s.templates[template.Name] = &template
+		}
+	}
+
+	s.logger.WithField("templates", len(templates)).Info("Restored saved templates")
+	return nil
+}
+
+// persist writes all templates to the state store
+func (s *SavedTemplateServiceImpl) persist() {
+	if s.store == nil {
+		return
+	}
+
+	// Serialize writers so the newest snapshot always lands last
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	templates := s.ListTemplates()
+	if err := s.store.Save(stateTemplatesFile, templates); err != nil {
+		s.logger.WithError(err).Warn("Failed to persist saved templates")
+	}
+}
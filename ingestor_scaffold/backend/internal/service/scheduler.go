@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// SchedulerService defines operations for recurring ingestions
+type SchedulerService interface {
+	CreateSchedule(req model.ScheduleRequest) (model.Schedule, error)
+	UpdateSchedule(id string, req model.ScheduleRequest) (model.Schedule, error)
+	ListSchedules() []model.Schedule
+	GetSchedule(id string) (model.Schedule, error)
+	DeleteSchedule(id string) error
+	SetEnabled(id string, enabled bool) (model.Schedule, error)
+	Restore() error
+	Preflight(ctx context.Context, id string) (model.PreflightResult, error)
+	Start()
+	Stop() context.Context
+}
+
+// SchedulerServiceImpl implements SchedulerService on top of a cron runner
+type SchedulerServiceImpl struct {
+	mu        sync.RWMutex
+	cron      *cron.Cron
+	schedules map[string]*model.Schedule
+	specs     map[string]cron.Schedule
+	entries   map[string]cron.EntryID
+	running   map[string]bool // schedules with a run in progress
+	runner    *JobRunner
+	notifier  NotificationService
+	stats     StatsService
+	persistMu sync.Mutex
+	store     *StateStore
+	stop      chan struct{}
+	config    *config.Config
+	logger    *logrus.Logger
+}
+
+// NewSchedulerService creates a new scheduler service
+func NewSchedulerService(
+	runner *JobRunner,
+	notificationService NotificationService,
+	statsService StatsService,
+	store *StateStore,
+	config *config.Config,
+	logger *logrus.Logger,
+) SchedulerService {
+	return &SchedulerServiceImpl{
+		cron:      cron.New(),
+		schedules: make(map[string]*model.Schedule),
+		specs:     make(map[string]cron.Schedule),
+		entries:   make(map[string]cron.EntryID),
+		running:   make(map[string]bool),
+		runner:    runner,
+		notifier:  notificationService,
+		stats:     statsService,
+		store:     store,
+		stop:      make(chan struct{}),
+		config:    config,
+		logger:    logger,
+	}
+}
+
+// Start begins firing enabled schedules and, when configured, warming them up
+func (s *SchedulerServiceImpl) Start() {
+	s.cron.Start()
+
+	if s.config.ScheduleWarmupLead <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.warmUp()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops firing schedules; the returned context is done once running jobs finish
+func (s *SchedulerServiceImpl) Stop() context.Context {
+	close(s.stop)
+	return s.cron.Stop()
+}
+
+// CreateSchedule validates and registers a new schedule
+func (s *SchedulerServiceImpl) CreateSchedule(req model.ScheduleRequest) (model.Schedule, error) {
+	spec, err := cron.ParseStandard(req.Cron)
+	if err != nil {
+		return model.Schedule{}, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	if req.Params.SourceType == "" || req.Params.TargetType == "" {
+		return model.Schedule{}, fmt.Errorf("source and target types are required")
+	}
+
+	schedule := &model.Schedule{
+		ID:        newJobID(),
+		Name:      req.Name,
+		Cron:      req.Cron,
+		Params:    req.Params,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		Transient: req.Transient,
+		Managed:   req.Managed,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.schedules[schedule.ID] = schedule
+	s.specs[schedule.ID] = spec
+	if schedule.Enabled {
+		s.addEntryLocked(schedule.ID)
+	}
+	created := s.snapshotLocked(schedule.ID)
+	s.mu.Unlock()
+
+	s.persist()
+	return created, nil
+}
+
+// UpdateSchedule replaces the name, cron expression, params and enabled state of a
+// schedule, keeping its ID and run history
+func (s *SchedulerServiceImpl) UpdateSchedule(id string, req model.ScheduleRequest) (model.Schedule, error) {
+	spec, err := cron.ParseStandard(req.Cron)
+	if err != nil {
+		return model.Schedule{}, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	if req.Params.SourceType == "" || req.Params.TargetType == "" {
+		return model.Schedule{}, fmt.Errorf("source and target types are required")
+	}
+
+	s.mu.Lock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		s.mu.Unlock()
+		return model.Schedule{}, fmt.Errorf("schedule %s not found", id)
+	}
+
+	s.removeEntryLocked(id)
+	schedule.Name = req.Name
+	schedule.Cron = req.Cron
+	schedule.Params = req.Params
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+	schedule.Managed = req.Managed
+	schedule.LastPreflight = nil
+	s.specs[id] = spec
+	if schedule.Enabled {
+		s.addEntryLocked(id)
+	}
+	updated := s.snapshotLocked(id)
+	s.mu.Unlock()
+
+	s.persist()
+	return updated, nil
+}
+
+// ListSchedules returns all schedules ordered by creation time
+func (s *SchedulerServiceImpl) ListSchedules() []model.Schedule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedules := make([]model.Schedule, 0, len(s.schedules))
+	for id := range s.schedules {
+		schedules = append(schedules, s.snapshotLocked(id))
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules
+}
+
+// GetSchedule returns a schedule by ID
+func (s *SchedulerServiceImpl) GetSchedule(id string) (model.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return model.Schedule{}, fmt.Errorf("schedule %s not found", id)
+	}
+	return s.snapshotLocked(id), nil
+}
+
+// DeleteSchedule removes a schedule
+func (s *SchedulerServiceImpl) DeleteSchedule(id string) error {
+	s.mu.Lock()
+	if _, ok := s.schedules[id]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("schedule %s not found", id)
+	}
+	s.removeEntryLocked(id)
+	delete(s.schedules, id)
+	delete(s.specs, id)
+	s.mu.Unlock()
+
+	s.persist()
+	return nil
+}
+
+// SetEnabled enables or disables a schedule
+func (s *SchedulerServiceImpl) SetEnabled(id string, enabled bool) (model.Schedule, error) {
+	s.mu.Lock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		s.mu.Unlock()
+		return model.Schedule{}, fmt.Errorf("schedule %s not found", id)
+	}
+
+	if enabled && !schedule.Enabled {
+		s.addEntryLocked(id)
+	} else if !enabled && schedule.Enabled {
+		s.removeEntryLocked(id)
+	}
+	schedule.Enabled = enabled
+	updated := s.snapshotLocked(id)
+	s.mu.Unlock()
+
+	s.persist()
+	return updated, nil
+}
+
+// Restore re-registers schedules persisted before a restart
+func (s *SchedulerServiceImpl) Restore() error {
+	var schedules []model.Schedule
+	found, err := s.store.Load(stateSchedulesFile, &schedules)
+	if err != nil || !found {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range schedules {
+		schedule := schedules[i]
+		spec, err := cron.ParseStandard(schedule.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression for schedule %s: %w", schedule.ID, err)
+		}
+		if _, exists := s.schedules[schedule.ID]; exists {
+			continue
+		}
+
+		schedule.NextRun = nil
+		s.schedules[schedule.ID] = &schedule
+		s.specs[schedule.ID] = spec
+		if schedule.Enabled {
+			s.addEntryLocked(schedule.ID)
+		}
+	}
+
+	s.logger.WithField("schedules", len(schedules)).Info("Restored schedules")
+	return nil
+}
+
+// persist writes all non-transient schedules to the state store
+func (s *SchedulerServiceImpl) persist() {
+	if s.store == nil {
+		return
+	}
+
+	// Serialize writers so the newest snapshot always lands last
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	s.mu.RLock()
+	schedules := make([]model.Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		if schedule.Transient {
+			continue
+		}
+		snapshot := *schedule
+		snapshot.Params = s.store.redactParams(snapshot.Params)
+		schedules = append(schedules, snapshot)
+	}
+	s.mu.RUnlock()
+
+	if err := s.store.Save(stateSchedulesFile, schedules); err != nil {
+		s.logger.WithError(err).Warn("Failed to persist schedules")
+	}
+}
+
+// Preflight validates the next run of a schedule now and records the result
+func (s *SchedulerServiceImpl) Preflight(ctx context.Context, id string) (model.PreflightResult, error) {
+	s.mu.RLock()
+	schedule, ok := s.schedules[id]
+	var params model.IngestionParams
+	var fireAt time.Time
+	if ok {
+		params = schedule.Params
+		fireAt = s.specs[id].Next(time.Now())
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return model.PreflightResult{}, fmt.Errorf("schedule %s not found", id)
+	}
+
+	var problems []string
+	if ctx, release, err := s.runner.withSession(ctx, params); err != nil {
+		problems = []string{err.Error()}
+	} else {
+		problems = s.runner.ingestService.Preflight(ctx, params)
+		release()
+	}
+	result := model.PreflightResult{
+		FireAt:    fireAt,
+		CheckedAt: time.Now(),
+		OK:        len(problems) == 0,
+		Problems:  problems,
+	}
+
+	s.mu.Lock()
+	if schedule, ok := s.schedules[id]; ok {
+		schedule.LastPreflight = &result
+	}
+	s.mu.Unlock()
+
+	return result, nil
+}
+
+// warmUp validates enabled schedules whose next run falls within the warm-up lead,
+// once per fire time, and alerts when a run is expected to fail
+func (s *SchedulerServiceImpl) warmUp() {
+	now := time.Now()
+
+	s.mu.RLock()
+	var due []string
+	for id, schedule := range s.schedules {
+		if !schedule.Enabled {
+			continue
+		}
+		next := s.specs[id].Next(now)
+		if next.Sub(now) > s.config.ScheduleWarmupLead {
+			continue
+		}
+		if schedule.LastPreflight != nil && schedule.LastPreflight.FireAt.Equal(next) {
+			continue
+		}
+		due = append(due, id)
+	}
+	s.mu.RUnlock()
+
+	for _, id := range due {
+		result, err := s.Preflight(context.Background(), id)
+		if err != nil || result.OK {
+			continue
+		}
+
+		schedule, err := s.GetSchedule(id)
+		if err != nil {
+			continue
+		}
+		s.logger.WithFields(logrus.Fields{
+			"scheduleId": id,
+			"fireAt":     result.FireAt,
+			"problems":   result.Problems,
+		}).Warn("Scheduled run is expected to fail")
+		s.notifier.NotifyPreflight(schedule, result)
+	}
+}
+
+// addEntryLocked registers a schedule with the cron runner
+func (s *SchedulerServiceImpl) addEntryLocked(id string) {
+	s.entries[id] = s.cron.Schedule(s.specs[id], cron.FuncJob(func() {
+		s.run(id)
+	}))
+}
+
+// removeEntryLocked unregisters a schedule from the cron runner
+func (s *SchedulerServiceImpl) removeEntryLocked(id string) {
+	if entryID, ok := s.entries[id]; ok {
+		s.cron.Remove(entryID)
+		delete(s.entries, id)
+	}
+}
+
+// snapshotLocked copies a schedule and fills in its next run time
+func (s *SchedulerServiceImpl) snapshotLocked(id string) model.Schedule {
+	schedule := *s.schedules[id]
+	if schedule.Enabled {
+		next := s.specs[id].Next(time.Now())
+		schedule.NextRun = &next
+	}
+	return schedule
+}
+
+// run executes one firing of a schedule as a tracked job. The cron runner fires
+// each entry on a goroutine of its own, so a firing while the previous run is still
+// going is skipped and counted rather than writing to the same target at once.
+func (s *SchedulerServiceImpl) run(id string) {
+	s.mu.Lock()
+	schedule, ok := s.schedules[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	if s.running[id] {
+		now := time.Now()
+		schedule.SkippedRuns++
+		schedule.LastSkipped = &now
+		lastJobID := schedule.LastJobID
+		s.mu.Unlock()
+
+		s.logger.WithFields(logrus.Fields{
+			"scheduleId": id,
+			"runningJob": lastJobID,
+		}).Warn("Skipped scheduled run, the previous run is still going")
+		s.persist()
+		return
+	}
+	s.running[id] = true
+	params := schedule.Params
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, id)
+		s.mu.Unlock()
+	}()
+
+	job, finished := s.runner.Start(params, logrus.Fields{"scheduleId": id})
+
+	s.mu.Lock()
+	if schedule, ok := s.schedules[id]; ok {
+		now := time.Now()
+		schedule.LastRun = &now
+		schedule.LastJobID = job.ID
+	}
+	s.mu.Unlock()
+	s.persist()
+
+	// The schedule counts as running until the job finishes
+	<-finished
+
+	// Track data quality of the run for trend alerts
+	if completed, err := s.runner.jobService.GetJob(job.ID); err == nil {
+		if schedule, err := s.GetSchedule(id); err == nil {
+			s.stats.RecordRun(schedule, completed)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestScheduler() *SchedulerServiceImpl {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewSchedulerService(nil, nil, nil, nil, &config.Config{}, logger).(*SchedulerServiceImpl)
+}
+
+func scheduleRequest(spec string, enabled bool) model.ScheduleRequest {
+	return model.ScheduleRequest{
+		Name:    "nightly",
+		Cron:    spec,
+		Enabled: &enabled,
+		Params:  model.IngestionParams{SourceType: "clickhouse", TargetType: "flatfile"},
+	}
+}
+
+func TestCreateScheduleCron(t *testing.T) {
+	valid := []string{"0 * * * *", "*/15 2-4 * * MON-FRI", "30 6 1 * *", "@hourly", "@every 90s", "CRON_TZ=Europe/Berlin 0 3 * * *"}
+	for _, spec := range valid {
+		_, err := newTestScheduler().CreateSchedule(scheduleRequest(spec, false))
+		assert.NoError(t, err, spec)
+	}
+
+	invalid := []string{"", "* * * *", "0 * * * * *", "60 * * * *", "0 25 * * *", "@fortnightly", "0 * * * FUNDAY"}
+	for _, spec := range invalid {
+		_, err := newTestScheduler().CreateSchedule(scheduleRequest(spec, false))
+		assert.Error(t, err, spec)
+	}
+
+	_, err := newTestScheduler().CreateSchedule(model.ScheduleRequest{Cron: "@hourly"})
+	assert.Error(t, err, "missing source and target types")
+}
+
+func TestScheduleNextRun(t *testing.T) {
+	s := newTestScheduler()
+
+	disabled, err := s.CreateSchedule(scheduleRequest("0 * * * *", false))
+	assert.NoError(t, err)
+	assert.Nil(t, disabled.NextRun)
+
+	before := time.Now()
+	enabled, err := s.CreateSchedule(scheduleRequest("0 * * * *", true))
+	assert.NoError(t, err)
+	if assert.NotNil(t, enabled.NextRun) {
+		assert.True(t, enabled.NextRun.After(before))
+		assert.True(t, enabled.NextRun.Sub(before) <= time.Hour)
+		assert.Zero(t, enabled.NextRun.Minute())
+		assert.Zero(t, enabled.NextRun.Second())
+	}
+
+	updated, err := s.UpdateSchedule(enabled.ID, scheduleRequest("30 6 * * *", true))
+	assert.NoError(t, err)
+	if assert.NotNil(t, updated.NextRun) {
+		assert.Equal(t, 6, updated.NextRun.Hour())
+		assert.Equal(t, 30, updated.NextRun.Minute())
+	}
+	_, err = s.UpdateSchedule(enabled.ID, scheduleRequest("not a cron", true))
+	assert.Error(t, err)
+
+	paused, err := s.SetEnabled(enabled.ID, false)
+	assert.NoError(t, err)
+	assert.Nil(t, paused.NextRun)
+	assert.Len(t, s.cron.Entries(), 0)
+}
+
+func TestScheduleNextRunSpec(t *testing.T) {
+	from := time.Date(2026, 3, 6, 23, 50, 0, 0, time.UTC) // a Friday
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"0 * * * *", time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{"30 6 1 * *", time.Date(2026, 4, 1, 6, 30, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		spec, err := cron.ParseStandard(tt.spec)
+		if assert.NoError(t, err, tt.spec) {
+			assert.Equal(t, tt.next, spec.Next(from), tt.spec)
+		}
+	}
+}
+
+func TestScheduleSkipsOverlappingRun(t *testing.T) {
+	s := newTestScheduler()
+	created, err := s.CreateSchedule(scheduleRequest("@every 1m", false))
+	assert.NoError(t, err)
+
+	// A firing while the previous run is going must not start another job
+	s.running[created.ID] = true
+	s.run(created.ID)
+	s.run(created.ID)
+
+	schedule, err := s.GetSchedule(created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, schedule.SkippedRuns)
+	assert.NotNil(t, schedule.LastSkipped)
+	assert.Nil(t, schedule.LastRun)
+}
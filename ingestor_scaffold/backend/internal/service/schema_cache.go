@@ -0,0 +1,83 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ingestor/internal/model"
+)
+
+// SchemaCache remembers discovered flat file schemas by file fingerprint, evicting
+// the oldest entry once full
+type SchemaCache struct {
+	mu      sync.Mutex
+	entries map[string]model.FileSchema
+	order   []string
+	size    int
+}
+
+// NewSchemaCache creates a cache of up to size schemas; 0 disables caching
+func NewSchemaCache(size int) *SchemaCache {
+	return &SchemaCache{
+		entries: make(map[string]model.FileSchema),
+		size:    size,
+	}
+}
+
+// Get returns the schema cached under a fingerprint
+func (c *SchemaCache) Get(fingerprint string) (model.FileSchema, bool) {
+	if c == nil || c.size <= 0 {
+		return model.FileSchema{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	schema, ok := c.entries[fingerprint]
+	if !ok {
+		return model.FileSchema{}, false
+	}
+	schema.Columns = append([]model.Column(nil), schema.Columns...)
+	return schema, true
+}
+
+// Put caches a schema under a fingerprint
+func (c *SchemaCache) Put(fingerprint string, schema model.FileSchema) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[fingerprint]; !ok {
+		c.order = append(c.order, fingerprint)
+	}
+	schema.Columns = append([]model.Column(nil), schema.Columns...)
+	c.entries[fingerprint] = schema
+
+	for len(c.order) > c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// FileFingerprint identifies a version of a flat file by its absolute path, size,
+// modification time and delimiter. Rewriting the file changes its fingerprint.
+func FileFingerprint(filePath, delimiter string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		absPath = filePath
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%s", absPath, info.Size(), info.ModTime().UnixNano(), delimiter)))
+	return hex.EncodeToString(sum[:16]), nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Rows sampled to check schema overrides, by default and at most
+const (
+	defaultOverrideSampleSize = 1000
+	maxOverrideSampleSize     = 100000
+)
+
+// ErrInvalidOverride is returned for schema overrides that cannot apply to the file
+var ErrInvalidOverride = errors.New("invalid schema override")
+
+// maxOverrideExamples bounds the failing values reported per column
+const maxOverrideExamples = 5
+
+// OverrideSchema applies column overrides to the discovered schema of a file and
+// counts the sampled rows whose values would not parse under them, so types can be
+// corrected before the target table is created
+func (s *FlatFileServiceImpl) OverrideSchema(ctx context.Context, req model.SchemaOverrideRequest) (model.SchemaOverrideResult, error) {
+	sampleSize := req.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultOverrideSampleSize
+	}
+	if sampleSize > maxOverrideSampleSize {
+		return model.SchemaOverrideResult{}, fmt.Errorf("%w: sampleSize may be at most %d", ErrInvalidOverride, maxOverrideSampleSize)
+	}
+
+	schema, err := s.DiscoverSchema(ctx, req.FlatFileParams, nil)
+	if err != nil {
+		return model.SchemaOverrideResult{}, err
+	}
+	columns, overridden, err := applyOverrides(schema.Columns, req.Overrides)
+	if err != nil {
+		return model.SchemaOverrideResult{}, fmt.Errorf("%w: %v", ErrInvalidOverride, err)
+	}
+	layouts, err := dateLayouts(columns)
+	if err != nil {
+		return model.SchemaOverrideResult{}, fmt.Errorf("%w: %v", ErrInvalidOverride, err)
+	}
+
+	result := model.SchemaOverrideResult{
+		Columns:     columns,
+		Fingerprint: schema.Fingerprint,
+		Checks:      make([]model.ColumnOverrideCheck, len(overridden)),
+	}
+	for i, idx := range overridden {
+		result.Checks[i] = model.ColumnOverrideCheck{Column: columns[idx].Name, Type: columns[idx].Type}
+	}
+
+	filePath, err := s.sandbox.Resolve(req.FlatFileParams.FilePath)
+	if err != nil {
+		return model.SchemaOverrideResult{}, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return model.SchemaOverrideResult{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = ','
+	if delims := []rune(req.FlatFileParams.Delimiter); len(delims) > 0 {
+		reader.Comma = delims[0]
+	}
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	// Discovered columns are in header order
+	header, err := reader.Read()
+	if err != nil {
+		return model.SchemaOverrideResult{}, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	for result.SampledRows < sampleSize {
+		select {
+		case <-ctx.Done():
+			return model.SchemaOverrideResult{}, ctx.Err()
+		default:
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || len(record) != len(header) {
+			continue
+		}
+		result.SampledRows++
+
+		failed := false
+		for i, idx := range overridden {
+			value := record[idx]
+			if s.valueParses(value, columns[idx], layouts[idx], req.FlatFileParams.BinaryEncoding) {
+				continue
+			}
+			failed = true
+			check := &result.Checks[i]
+			check.FailingRows++
+			if len(check.Examples) < maxOverrideExamples {
+				check.Examples = append(check.Examples, value)
+			}
+		}
+		if failed {
+			result.FailingRows++
+		}
+	}
+	return result, nil
+}
+
+// applyOverrides returns the columns with overrides applied and the positions of
+// the overridden columns
+func applyOverrides(columns []model.Column, overrides []model.ColumnOverride) ([]model.Column, []int, error) {
+	columns = append([]model.Column{}, columns...)
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col.Name] = i
+	}
+
+	var overridden []int
+	seen := make(map[string]bool, len(overrides))
+	for _, o := range overrides {
+		idx, ok := index[o.Column]
+		if !ok {
+			return nil, nil, fmt.Errorf("overridden column %s is not in the file", o.Column)
+		}
+		if seen[o.Column] {
+			return nil, nil, fmt.Errorf("column %s is overridden twice", o.Column)
+		}
+		seen[o.Column] = true
+
+		col := &columns[idx]
+		if o.Type != "" {
+			if err := validateColumnType(o.Type); err != nil {
+				return nil, nil, err
+			}
+			col.Type = o.Type
+		}
+		if o.Nullable != nil {
+			inner := col.Type
+			if strings.HasPrefix(inner, "Nullable(") && strings.HasSuffix(inner, ")") {
+				inner = inner[len("Nullable(") : len(inner)-1]
+			}
+			col.Type = inner
+			if *o.Nullable {
+				col.Type = "Nullable(" + inner + ")"
+			}
+		}
+		if o.Format != "" {
+			col.Format = o.Format
+		}
+		overridden = append(overridden, idx)
+	}
+	return columns, overridden, nil
+}
+
+// valueParses reports whether ingestion would load a flat file value into a column
+// as is, rather than reject or coerce it
+func (s *FlatFileServiceImpl) valueParses(value string, col model.Column, layout, binaryEncoding string) bool {
+	if binaryEncoding != "" && isBinaryType(col.Type) && value != "" {
+		_, err := decodeBinary(value, binaryEncoding)
+		return err == nil
+	}
+	if isJSONType(col.Type) && value != "" {
+		return json.Valid([]byte(value))
+	}
+	if typ := geoType(col.Type); typ != "" {
+		_, err := parseGeo(value, typ)
+		return err == nil
+	}
+	if layout != "" {
+		_, ok := parseDate(value, col.Type, layout)
+		return ok
+	}
+	_, ok := s.parseValue(value, col.Type)
+	return ok
+}
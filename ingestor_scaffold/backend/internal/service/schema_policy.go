@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/model"
+)
+
+// Policies for loading into an existing table whose schema differs from the source
+const (
+	SchemaPolicyFail   = "fail"
+	SchemaPolicyMap    = "map"
+	SchemaPolicyEvolve = "evolve"
+)
+
+// unknownTableCodes are the ClickHouse exception codes of a table that does not
+// exist: 60 UNKNOWN_TABLE and 81 UNKNOWN_DATABASE
+var unknownTableCodes = map[int32]bool{60: true, 81: true}
+
+// integerWidths orders the integer types so widening conversions can be recognised
+var integerWidths = map[string]int{
+	"Int8": 8, "Int16": 16, "Int32": 32, "Int64": 64, "Int128": 128, "Int256": 256,
+	"UInt8": 8, "UInt16": 16, "UInt32": 32, "UInt64": 64, "UInt128": 128, "UInt256": 256,
+}
+
+// schemaPlan is how the source columns are loaded into the target table
+type schemaPlan struct {
+	columns   []model.Column // columns inserted, in row order
+	positions []int          // position of each inserted column in the source row; nil keeps rows as is
+	diff      []model.SchemaDifference
+}
+
+// isUnknownTable reports whether err says the table does not exist
+func isUnknownTable(err error) bool {
+	var exception *clickhouse.Exception
+	return errors.As(err, &exception) && unknownTableCodes[exception.Code]
+}
+
+// planTargetSchema compares the columns about to be loaded with the target table, if
+// it already exists, and applies the schema policy: "fail" returns the differences
+// as an error, "map" loads only the columns the table can take, and "evolve" adds
+// the missing columns to the table as Nullable, since the rows already there have no
+// values for them. Columns only in the table are left to their
+// defaults under every policy. Incompatible types are never altered.
+func (s *IngestServiceImpl) planTargetSchema(ctx context.Context, params model.IngestionParams, tableName string, columns []model.Column) (schemaPlan, error) {
+	policy, err := s.schemaPolicy(params)
+	if err != nil {
+		return schemaPlan{}, err
+	}
+
+	plan := schemaPlan{columns: columns}
+	existing, err := s.clickhouse(ctx).GetTableColumns(ctx, tableName)
+	if err != nil {
+		if isUnknownTable(err) {
+			return plan, nil
+		}
+		return schemaPlan{}, fmt.Errorf("failed to inspect target table: %w", err)
+	}
+	plan.diff = compareSchemas(columns, existing)
+
+	var missing, mismatched []model.SchemaDifference
+	for _, d := range plan.diff {
+		switch d.Kind {
+		case "missing":
+			missing = append(missing, d)
+		case "type":
+			mismatched = append(mismatched, d)
+		}
+	}
+	if len(missing)+len(mismatched) == 0 {
+		return plan, nil
+	}
+
+	warnings := WarningsFromContext(ctx)
+	switch policy {
+	case SchemaPolicyFail:
+		return schemaPlan{}, fmt.Errorf("target table %s exists with a different schema: %s; set schemaPolicy to map or evolve",
+			tableName, describeSchemaDiff(append(missing, mismatched...)))
+	case SchemaPolicyEvolve:
+		if len(mismatched) > 0 {
+			return schemaPlan{}, fmt.Errorf("target table %s has incompatible column types: %s",
+				tableName, describeSchemaDiff(mismatched))
+		}
+		if err := s.addTableColumns(ctx, params, tableName, missing); err != nil {
+			return schemaPlan{}, err
+		}
+		for _, d := range missing {
+			warnings.Add("column %s added to target table %s as %s", d.Column, tableName, nullableType(d.SourceType))
+		}
+		return plan, nil
+	}
+
+	// Map: leave out the columns the table cannot take
+	skip := make(map[string]bool, len(missing)+len(mismatched))
+	for _, d := range append(missing, mismatched...) {
+		skip[d.Column] = true
+		warnings.Add("column %s not loaded into target table %s (%s)", d.Column, tableName, describeSchemaDiff([]model.SchemaDifference{d}))
+	}
+	plan.columns = nil
+	for i, col := range columns {
+		if skip[col.Name] {
+			continue
+		}
+		plan.columns = append(plan.columns, col)
+		plan.positions = append(plan.positions, i)
+	}
+	if len(plan.columns) == 0 {
+		return schemaPlan{}, fmt.Errorf("no source column matches target table %s", tableName)
+	}
+	return plan, nil
+}
+
+// schemaPolicy returns the schema policy of a job, defaulting to TARGET_SCHEMA_POLICY
+func (s *IngestServiceImpl) schemaPolicy(params model.IngestionParams) (string, error) {
+	policy := params.SchemaPolicy
+	if policy == "" {
+		policy = s.config.TargetSchemaPolicy
+	}
+	switch policy {
+	case SchemaPolicyFail, SchemaPolicyMap, SchemaPolicyEvolve:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported schema policy %q: must be fail, map or evolve", policy)
+	}
+}
+
+// projectRows keeps the values of the columns a mapped schema loads
+func (s *IngestServiceImpl) projectRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	positions []int,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	go func() {
+		defer close(out)
+
+		for row := range in {
+			projected := make([]interface{}, len(positions))
+			for i, pos := range positions {
+				projected[i] = row[pos]
+			}
+
+			select {
+			case out <- projected:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// addTableColumns adds the missing source columns to an existing table, as Nullable.
+// A distributed table gets them after its local tables.
+func (s *IngestServiceImpl) addTableColumns(ctx context.Context, params model.IngestionParams, tableName string, missing []model.SchemaDifference) error {
+	conn := s.clickhouse(ctx)
+	cluster, err := onCluster(jobCluster(params, conn))
+	if err != nil {
+		return err
+	}
+
+	adds := make([]string, len(missing))
+	for i, d := range missing {
+		name, err := QuoteIdentifier(d.Column)
+		if err != nil {
+			return err
+		}
+		if err := validateColumnType(d.SourceType); err != nil {
+			return err
+		}
+		adds[i] = fmt.Sprintf("ADD COLUMN IF NOT EXISTS %s %s", name, nullableType(d.SourceType))
+	}
+	tables := []string{tableName}
+	if params.Distributed != nil {
+		tables = []string{localTableName(tableName, params.Distributed), tableName}
+	}
+	for _, name := range tables {
+		table, err := QuoteTable(name)
+		if err != nil {
+			return err
+		}
+		ddl := fmt.Sprintf("ALTER TABLE %s%s %s", table, cluster, strings.Join(adds, ", "))
+		if err := conn.ExecDDL(ctx, ddl); err != nil {
+			return fmt.Errorf("failed to evolve target table: %w", err)
+		}
+	}
+	return nil
+}
+
+// nullableType makes a column type Nullable where ClickHouse allows it: inside
+// LowCardinality, and not for composite or geo types, which stay as they are
+func nullableType(t string) string {
+	t = strings.TrimSpace(t)
+	if strings.HasPrefix(t, "LowCardinality(") && strings.HasSuffix(t, ")") {
+		return "LowCardinality(" + nullableType(t[len("LowCardinality("):len(t)-1]) + ")"
+	}
+	if strings.HasPrefix(t, "Nullable(") || geoType(t) != "" || isJSONType(t) {
+		return t
+	}
+	for _, composite := range []string{"Array(", "Map(", "Tuple(", "Nested("} {
+		if strings.HasPrefix(t, composite) {
+			return t
+		}
+	}
+	return "Nullable(" + t + ")"
+}
+
+// compareSchemas lists the differences between the source columns and a table's
+func compareSchemas(source, target []model.Column) []model.SchemaDifference {
+	targetTypes := make(map[string]string, len(target))
+	for _, col := range target {
+		targetTypes[col.Name] = col.Type
+	}
+
+	var diff []model.SchemaDifference
+	seen := make(map[string]bool, len(source))
+	for _, col := range source {
+		seen[col.Name] = true
+		targetType, ok := targetTypes[col.Name]
+		switch {
+		case !ok:
+			diff = append(diff, model.SchemaDifference{Column: col.Name, Kind: "missing", SourceType: col.Type})
+		case !typesCompatible(col.Type, targetType):
+			diff = append(diff, model.SchemaDifference{Column: col.Name, Kind: "type", SourceType: col.Type, TargetType: targetType})
+		}
+	}
+	for _, col := range target {
+		if !seen[col.Name] {
+			diff = append(diff, model.SchemaDifference{Column: col.Name, Kind: "extra", TargetType: col.Type})
+		}
+	}
+	return diff
+}
+
+// typesCompatible reports whether values of the source type can be inserted into a
+// column of the target type without loss: the same type, possibly made Nullable or
+// LowCardinality, a wider integer or float, or a String. NULLs bound for a column
+// that is not Nullable are left to the insert, which rejects those rows alone.
+func typesCompatible(source, target string) bool {
+	source, target = unwrapType(source), unwrapType(target)
+	if source == target || target == "String" {
+		return true
+	}
+
+	srcWidth, srcInt := integerWidths[source]
+	dstWidth, dstInt := integerWidths[target]
+	switch {
+	case srcInt && dstInt:
+		srcUnsigned := strings.HasPrefix(source, "U")
+		dstUnsigned := strings.HasPrefix(target, "U")
+		if srcUnsigned == dstUnsigned {
+			return dstWidth >= srcWidth
+		}
+		// Unsigned values fit a strictly wider signed type
+		return srcUnsigned && dstWidth > srcWidth
+	case srcInt && target == "Float64":
+		return srcWidth <= 32
+	case source == "Float32" && target == "Float64":
+		return true
+	}
+	return false
+}
+
+// unwrapType strips the Nullable and LowCardinality wrappers of a type, which do not
+// change the values it accepts from a source column
+func unwrapType(t string) string {
+	t = strings.TrimSpace(t)
+	for _, wrapper := range []string{"Nullable(", "LowCardinality("} {
+		for strings.HasPrefix(t, wrapper) && strings.HasSuffix(t, ")") {
+			t = strings.TrimSpace(t[len(wrapper) : len(t)-1])
+		}
+	}
+	if strings.HasPrefix(t, "Nullable(") && strings.HasSuffix(t, ")") {
+		t = strings.TrimSpace(t[len("Nullable(") : len(t)-1])
+	}
+	return t
+}
+
+// describeSchemaDiff renders differences for errors and warnings
+func describeSchemaDiff(diff []model.SchemaDifference) string {
+	parts := make([]string, len(diff))
+	for i, d := range diff {
+		switch d.Kind {
+		case "missing":
+			parts[i] = fmt.Sprintf("%s %s is not in the table", d.Column, d.SourceType)
+		case "type":
+			parts[i] = fmt.Sprintf("%s is %s in the source but %s in the table", d.Column, d.SourceType, d.TargetType)
+		default:
+			parts[i] = fmt.Sprintf("%s %s is only in the table", d.Column, d.TargetType)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNullableType(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Int32", "Nullable(Int32)"},
+		{" String ", "Nullable(String)"},
+		{"DateTime64(3, 'UTC')", "Nullable(DateTime64(3, 'UTC'))"},
+		{"Nullable(Int32)", "Nullable(Int32)"},
+		{"LowCardinality(String)", "LowCardinality(Nullable(String))"},
+		{"LowCardinality(Nullable(String))", "LowCardinality(Nullable(String))"},
+		{"Array(Int32)", "Array(Int32)"},
+		{"Map(String, Int32)", "Map(String, Int32)"},
+		{"Tuple(Int32, String)", "Tuple(Int32, String)"},
+		{"Point", "Point"},
+		{"JSON", "JSON"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, nullableType(tt.in), tt.in)
+	}
+}
+
+func TestTypesCompatible(t *testing.T) {
+	tests := []struct {
+		source, target string
+		want           bool
+	}{
+		{"Int32", "Int32", true},
+		{"Int32", "Nullable(Int32)", true},
+		{"Nullable(String)", "LowCardinality(String)", true},
+		{"Int32", "Int64", true},
+		{"Int64", "Int32", false},
+		{"UInt32", "Int64", true},
+		{"UInt32", "Int32", false},
+		{"Int32", "UInt64", false},
+		{"Int32", "Float64", true},
+		{"Int64", "Float64", false},
+		{"Float32", "Float64", true},
+		{"Float64", "Float32", false},
+		{"Date", "String", true},
+		{"String", "Int32", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, typesCompatible(tt.source, tt.target), "%s into %s", tt.source, tt.target)
+	}
+}
+
+func TestCompareSchemas(t *testing.T) {
+	source := []model.Column{{Name: "id", Type: "UInt32"}, {Name: "name", Type: "String"}, {Name: "score", Type: "Float64"}}
+	target := []model.Column{{Name: "id", Type: "Int64"}, {Name: "score", Type: "Float32"}, {Name: "created", Type: "DateTime"}}
+
+	assert.Equal(t, []model.SchemaDifference{
+		{Column: "name", Kind: "missing", SourceType: "String"},
+		{Column: "score", Kind: "type", SourceType: "Float64", TargetType: "Float32"},
+		{Column: "created", Kind: "extra", TargetType: "DateTime"},
+	}, compareSchemas(source, target))
+	assert.Empty(t, compareSchemas(source, source))
+}
+
+// fakeSchemaTarget is a ClickHouse connection holding one table's columns and
+// recording the DDL run against it
+type fakeSchemaTarget struct {
+	ClickHouseService
+	columns []model.Column
+	ddl     []string
+}
+
+func (f *fakeSchemaTarget) GetTableColumns(ctx context.Context, tableName string) ([]model.Column, error) {
+	if f.columns == nil {
+		return nil, &clickhouse.Exception{Code: 60, Message: "Table default.events does not exist"}
+	}
+	return f.columns, nil
+}
+
+func (f *fakeSchemaTarget) ExecDDL(ctx context.Context, ddl string) error {
+	f.ddl = append(f.ddl, ddl)
+	return nil
+}
+
+func (f *fakeSchemaTarget) Cluster() string { return "" }
+
+func TestPlanTargetSchema(t *testing.T) {
+	source := []model.Column{{Name: "id", Type: "UInt32"}, {Name: "name", Type: "LowCardinality(String)"}, {Name: "score", Type: "Float64"}}
+	tests := []struct {
+		name      string
+		policy    string
+		table     []model.Column
+		columns   []string
+		positions []int
+		ddl       []string
+		fails     bool
+	}{
+		{"new table", SchemaPolicyFail, nil, []string{"id", "name", "score"}, nil, nil, false},
+		{"same schema", SchemaPolicyFail, source, []string{"id", "name", "score"}, nil, nil, false},
+		{"missing column fails", SchemaPolicyFail, source[:1], nil, nil, nil, true},
+		{"missing columns mapped", SchemaPolicyMap, []model.Column{{Name: "id", Type: "Int64"}, {Name: "score", Type: "Float32"}}, []string{"id"}, []int{0}, nil, false},
+		{"nothing to map", SchemaPolicyMap, []model.Column{{Name: "other", Type: "String"}}, nil, nil, nil, true},
+		{"missing columns evolved", SchemaPolicyEvolve, source[:1], []string{"id", "name", "score"}, nil, []string{
+			"ALTER TABLE `events` ADD COLUMN IF NOT EXISTS `name` LowCardinality(Nullable(String)), ADD COLUMN IF NOT EXISTS `score` Nullable(Float64)",
+		}, false},
+		{"incompatible type not evolved", SchemaPolicyEvolve, []model.Column{{Name: "id", Type: "String"}, {Name: "score", Type: "Int8"}}, nil, nil, nil, true},
+	}
+	for _, tt := range tests {
+		s := NewIngestService(nil, &config.Config{TargetSchemaPolicy: SchemaPolicyFail}, nil).(*IngestServiceImpl)
+		target := &fakeSchemaTarget{columns: tt.table}
+		ctx := WithClickHouse(context.Background(), target)
+
+		plan, err := s.planTargetSchema(ctx, model.IngestionParams{SchemaPolicy: tt.policy}, "events", source)
+		assert.Equal(t, tt.ddl, target.ddl, tt.name)
+		if tt.fails {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+		if !assert.NoError(t, err, tt.name) {
+			continue
+		}
+		var names []string
+		for _, col := range plan.columns {
+			names = append(names, col.Name)
+		}
+		assert.Equal(t, tt.columns, names, tt.name)
+		assert.Equal(t, tt.positions, plan.positions, tt.name)
+	}
+}
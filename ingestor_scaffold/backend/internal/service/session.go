@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+type clickhouseKey struct{}
+
+// SessionService manages one ClickHouse connection per client session, keeps them
+// alive, reaps idle ones and restores them after a restart
+type SessionService interface {
+	Open(ctx context.Context, params model.ClickHouseConnectionParams) (string, ClickHouseService, error)
+	Get(id string) (ClickHouseService, error)
+	Acquire(id string) (ClickHouseService, func(), error)
+	Labels(id string) map[string]string
+	RefreshToken(id, token string) error
+	Close(id string) error
+	Diagnose(ctx context.Context, params model.ClickHouseConnectionParams) model.ConnectionDiagnostics
+	Restore(ctx context.Context) error
+	Start()
+	Stop()
+}
+
+// session is an open ClickHouse connection and the parameters that made it
+type session struct {
+	params    model.ClickHouseConnectionParams
+	conn      ClickHouseService
+	createdAt time.Time
+	lastUsed  time.Time
+	inUse     int // running jobs; a session in use is never reaped
+
+	// Connections replaced by a reconnect while jobs still held them; closed once
+	// the last job releases the session
+	retired []ClickHouseService
+}
+
+// persistedSession is the stored form of a session
+type persistedSession struct {
+	ID        string                           `json:"id"`
+	Params    model.ClickHouseConnectionParams `json:"params"`
+	CreatedAt time.Time                        `json:"createdAt"`
+	LastUsed  time.Time                        `json:"lastUsed"`
+}
+
+// SessionServiceImpl implements SessionService
+type SessionServiceImpl struct {
+	mu        sync.Mutex
+	sessions  map[string]*session
+	persistMu sync.Mutex
+	store     *StateStore
+	config    *config.Config
+	logger    *logrus.Logger
+	stop      chan struct{}
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(
+	store *StateStore,
+	config *config.Config,
+	logger *logrus.Logger,
+) SessionService {
+	return &SessionServiceImpl{
+		sessions: make(map[string]*session),
+		store:    store,
+		config:   config,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+// WithClickHouse returns a context carrying the ClickHouse connection of a session
+func WithClickHouse(ctx context.Context, conn ClickHouseService) context.Context {
+	return context.WithValue(ctx, clickhouseKey{}, conn)
+}
+
+// ClickHouseFromContext returns the ClickHouse connection carried by ctx, or nil
+func ClickHouseFromContext(ctx context.Context) ClickHouseService {
+	conn, _ := ctx.Value(clickhouseKey{}).(ClickHouseService)
+	return conn
+}
+
+// Open connects with the given parameters and returns the new session's ID.
+// Credentials are only persisted when the state store is encrypted.
+func (s *SessionServiceImpl) Open(ctx context.Context, params model.ClickHouseConnectionParams) (string, ClickHouseService, error) {
+	conn := NewClickHouseService(s.config, s.logger)
+	if err := conn.Connect(ctx, params, params.Token); err != nil {
+		return "", nil, err
+	}
+
+	id := newJobID()
+	now := time.Now()
+	s.mu.Lock()
+	s.sessions[id] = &session{params: params, conn: conn, createdAt: now, lastUsed: now}
+	s.mu.Unlock()
+
+	s.persist()
+	s.logger.WithFields(logrus.Fields{"sessionId": id, "host": params.Host, "labels": params.Labels}).Info("Opened ClickHouse session")
+	return id, conn, nil
+}
+
+// Get returns the connection of a session and marks it as used
+func (s *SessionServiceImpl) Get(id string) (ClickHouseService, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %s not found or expired", id)
+	}
+	sess.lastUsed = time.Now()
+	return sess.conn, nil
+}
+
+// Diagnose connects with the given parameters without opening a session and
+// reports what the server looks like, or why connecting failed
+func (s *SessionServiceImpl) Diagnose(ctx context.Context, params model.ClickHouseConnectionParams) model.ConnectionDiagnostics {
+	conn := NewClickHouseService(s.config, s.logger)
+	defer conn.Close()
+
+	fail := func(err error) model.ConnectionDiagnostics {
+		return model.ConnectionDiagnostics{Error: err.Error(), Hint: ConnectionHint(err, params)}
+	}
+	if err := conn.Connect(ctx, params, params.Token); err != nil {
+		return fail(err)
+	}
+	diagnostics, err := conn.Diagnose(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	diagnostics.Connected = true
+	return diagnostics
+}
+
+// Acquire returns the connection of a session and holds it open until release is
+// called, so a long-running job is not reaped for looking idle
+func (s *SessionServiceImpl) Acquire(id string) (ClickHouseService, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("session %s not found or expired", id)
+	}
+	sess.inUse++
+	sess.lastUsed = time.Now()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			sess.inUse--
+			sess.lastUsed = time.Now()
+			var retired []ClickHouseService
+			if sess.inUse == 0 {
+				retired, sess.retired = sess.retired, nil
+			}
+			s.mu.Unlock()
+
+			for _, conn := range retired {
+				conn.Close()
+			}
+		})
+	}
+	return sess.conn, release, nil
+}
+
+// Labels returns the cost attribution labels of a session's connection, or nil
+func (s *SessionServiceImpl) Labels(id string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.sessions[id]; ok {
+		return sess.params.Labels
+	}
+	return nil
+}
+
+// RefreshToken replaces the JWT of a session, for its open connection and for
+// reconnecting after a restart
+func (s *SessionServiceImpl) RefreshToken(id, token string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("session %s not found or expired", id)
+	}
+	if err := sess.conn.SetToken(token); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	sess.params.Token = token
+	s.mu.Unlock()
+
+	s.persist()
+	return nil
+}
+
+// Close disconnects and forgets a session
+func (s *SessionServiceImpl) Close(id string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("session %s not found or expired", id)
+	}
+	s.persist()
+	return sess.close()
+}
+
+// close disconnects a session removed from the service, with any connections it
+// retired
+func (sess *session) close() error {
+	for _, conn := range sess.retired {
+		conn.Close()
+	}
+	return sess.conn.Close()
+}
+
+// Restore reopens the sessions persisted before a restart. Sessions that cannot
+// reconnect yet are kept unconnected for the keep-alive loop to retry, or dropped
+// when keep-alive is off.
+func (s *SessionServiceImpl) Restore(ctx context.Context) error {
+	var stored []persistedSession
+	found, err := s.store.Load(stateSessionsFile, &stored)
+	if err != nil || !found {
+		return err
+	}
+
+	var failed int
+	retry := s.config.ClickHouseKeepAlive > 0
+	for _, ps := range stored {
+		conn := NewClickHouseService(s.config, s.logger)
+		if err := conn.Connect(ctx, ps.Params, ps.Params.Token); err != nil {
+			s.logger.WithError(err).WithField("sessionId", ps.ID).Warn("Failed to restore ClickHouse session")
+			failed++
+			if !retry {
+				continue
+			}
+		}
+
+		s.mu.Lock()
+		s.sessions[ps.ID] = &session{params: ps.Params, conn: conn, createdAt: ps.CreatedAt, lastUsed: ps.LastUsed}
+		s.mu.Unlock()
+	}
+	if failed > 0 && !retry {
+		s.persist()
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"sessions": len(stored),
+		"failed":   failed,
+	}).Info("Restored ClickHouse sessions")
+	return nil
+}
+
+// Start runs the keep-alive loop, which pings sessions and reconnects dropped
+// ones, and the reaper, which closes sessions idle beyond the idle timeout
+func (s *SessionServiceImpl) Start() {
+	if s.config.ClickHouseKeepAlive > 0 {
+		go s.every(s.config.ClickHouseKeepAlive, s.keepAlive)
+	}
+	if s.config.SessionIdleTimeout > 0 && s.config.SessionReapInterval > 0 {
+		go s.every(s.config.SessionReapInterval, s.reapIdle)
+	}
+}
+
+// Stop ends the keep-alive loop and the reaper
+func (s *SessionServiceImpl) Stop() {
+	close(s.stop)
+}
+
+// every calls fn at each interval until the service stops
+func (s *SessionServiceImpl) every(interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// reapIdle closes sessions unused for longer than the idle timeout
+func (s *SessionServiceImpl) reapIdle() {
+	s.mu.Lock()
+	var expired []*session
+	for id, sess := range s.sessions {
+		if sess.inUse == 0 && time.Since(sess.lastUsed) > s.config.SessionIdleTimeout {
+			expired = append(expired, sess)
+			delete(s.sessions, id)
+			s.logger.WithField("sessionId", id).Info("Reaped idle ClickHouse session")
+		}
+	}
+	s.mu.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	for _, sess := range expired {
+		sess.close()
+	}
+	s.persist()
+}
+
+// keepAlive pings every session and re-establishes those that have dropped
+func (s *SessionServiceImpl) keepAlive() {
+	s.mu.Lock()
+	conns := make(map[string]ClickHouseService, len(s.sessions))
+	for id, sess := range s.sessions {
+		conns[id] = sess.conn
+	}
+	s.mu.Unlock()
+
+	for id, conn := range conns {
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.ClickHouseDialTimeout+s.config.ClickHouseKeepAlive)
+		if err := conn.Ping(ctx); err != nil {
+			s.logger.WithError(err).WithField("sessionId", id).Warn("ClickHouse session lost, reconnecting")
+			s.reconnect(ctx, id, conn)
+		}
+		cancel()
+	}
+}
+
+// reconnect opens a new connection for a session whose connection old was lost and
+// swaps it in. Jobs holding old keep it until they release the session.
+func (s *SessionServiceImpl) reconnect(ctx context.Context, id string, old ClickHouseService) {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	var params model.ClickHouseConnectionParams
+	if ok {
+		params = sess.params
+	}
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	conn := NewClickHouseService(s.config, s.logger)
+	if err := conn.Connect(ctx, params, params.Token); err != nil {
+		s.logger.WithError(err).WithField("sessionId", id).Error("Failed to re-establish ClickHouse session")
+		return
+	}
+
+	s.mu.Lock()
+	// The session may have been closed, reconnected or given a new token meanwhile
+	if sess, ok = s.sessions[id]; !ok || sess.conn != old {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	if sess.params.Token != params.Token {
+		conn.SetToken(sess.params.Token)
+	}
+	sess.conn = conn
+	if sess.inUse > 0 {
+		sess.retired = append(sess.retired, old)
+		old = nil
+	}
+	s.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	s.logger.WithField("sessionId", id).Info("Re-established ClickHouse session")
+}
+
+// persist writes all sessions to the state store
+func (s *SessionServiceImpl) persist() {
+	if s.store == nil {
+		return
+	}
+
+	// Serialize writers so the newest snapshot always lands last
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	s.mu.Lock()
+	stored := make([]persistedSession, 0, len(s.sessions))
+	for id, sess := range s.sessions {
+		params := sess.params
+		if !s.store.Encrypted() {
+			params = redactConnection(params)
+		}
+		stored = append(stored, persistedSession{ID: id, Params: params, CreatedAt: sess.createdAt, LastUsed: sess.lastUsed})
+	}
+	s.mu.Unlock()
+
+	if err := s.store.Save(stateSessionsFile, stored); err != nil {
+		s.logger.WithError(err).Warn("Failed to persist ClickHouse sessions")
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrSourceTooLarge is returned for flat file sources beyond the configured limits
+var ErrSourceTooLarge = errors.New("source file exceeds the configured limits")
+
+// checkSourceSize refuses source files larger than MaxSourceFileBytes before any
+// of them is read
+func (s *FlatFileServiceImpl) checkSourceSize(filePath string) error {
+	limit := s.config.MaxSourceFileBytes
+	if limit <= 0 {
+		return nil
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.Size() > int64(limit) {
+		return fmt.Errorf("%s is %d bytes, above the %d byte limit (MAX_SOURCE_FILE_BYTES): %w", filePath, info.Size(), limit, ErrSourceTooLarge)
+	}
+	return nil
+}
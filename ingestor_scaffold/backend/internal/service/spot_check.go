@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Sample verification defaults and limits; each sampled row is looked up with a
+// query of its own
+const (
+	defaultVerifySampleRows = 100
+	maxVerifySampleRows     = 10000
+	maxMissingSamples       = 10
+)
+
+// spotCheckType reports whether rows can be matched exactly on a column of this
+// type; floats, decimals, bytes and nested values are left out
+func spotCheckType(chType string) bool {
+	t := baseType(chType)
+	switch {
+	case strings.HasPrefix(t, "Int"), strings.HasPrefix(t, "UInt"), strings.HasPrefix(t, "Date"), strings.HasPrefix(t, "Enum"):
+		return true
+	}
+	switch t {
+	case "String", "Bool", "UUID":
+		return true
+	}
+	return false
+}
+
+// spotCheck looks up random rows of the job's file in its table: the rows of a
+// loaded file in the target, those of an exported file in the source query
+func (s *IngestServiceImpl) spotCheck(ctx context.Context, params model.IngestionParams, result model.IngestionResult, v *model.Verification) error {
+	n := params.Verify.SampleRows
+	if n == 0 {
+		n = defaultVerifySampleRows
+	}
+
+	// The file's columns as the job converts them, and their names in the table
+	var columns []model.Column
+	var names []string
+	var transforms []rowTransform
+	var source, table string
+	var args []interface{}
+	files := []string{params.FlatFileParams.FilePath}
+	if params.SourceType == "flatfile" {
+		columns = params.Columns
+		if len(columns) == 0 {
+			schema, err := s.flatFileService.DiscoverSchema(ctx, params.FlatFileParams, nil)
+			if err != nil {
+				return fmt.Errorf("failed to discover schema: %w", err)
+			}
+			columns = schema.Columns
+		}
+		renames, err := columnRenames(params.ColumnMappings, columns)
+		if err != nil {
+			return err
+		}
+		if transforms, err = compileTransforms(params.Transforms, columns); err != nil {
+			return err
+		}
+		if source, err = QuoteTable(params.TableName); err != nil {
+			return err
+		}
+		for _, col := range renameColumns(columns, renames) {
+			names = append(names, col.Name)
+		}
+		table = "target"
+	} else {
+		query, queryArgs, exported, _, err := s.exportQuery(params, nil)
+		if err != nil {
+			return err
+		}
+		if len(exported) == 0 && params.Query == "" && params.Join == nil && params.TableFunction == nil {
+			if exported, err = s.clickhouse(ctx).GetTableColumns(ctx, params.TableName); err != nil {
+				return err
+			}
+		}
+		columns, names = exported, selectedColumnNames(exported)
+		source, args = "("+query+")", queryArgs
+		if len(result.PartFiles) > 0 {
+			files = result.PartFiles
+		}
+		table = "source"
+	}
+
+	// Rows are matched on the columns whose values compare exactly
+	var matched []int
+	for i, col := range columns {
+		if spotCheckType(col.Type) {
+			matched = append(matched, i)
+			v.SampleColumns = append(v.SampleColumns, names[i])
+		}
+	}
+	if len(matched) == 0 {
+		return fmt.Errorf("sample verification needs an integer, string, date or boolean column to match rows on")
+	}
+
+	conn := s.clickhouse(ctx)
+	perFile := (n + len(files) - 1) / len(files)
+	for _, file := range files {
+		fileParams := params.FlatFileParams
+		fileParams.FilePath = file
+		rows, err := s.flatFileService.SampleRows(ctx, fileParams, columns, perFile, params.Verify.SampleSeed)
+		if err != nil {
+			return fmt.Errorf("failed to sample %s: %w", file, err)
+		}
+
+		for _, row := range rows {
+			for _, transform := range transforms {
+				transform(row)
+			}
+			values := make([]interface{}, len(matched))
+			for j, i := range matched {
+				values[j] = derefValue(row[i])
+			}
+			found, err := conn.RowExists(ctx, source, args, v.SampleColumns, values)
+			if err != nil {
+				return err
+			}
+			v.SampledRows++
+			if found {
+				continue
+			}
+			v.MissingRows++
+			if len(v.Missing) < maxMissingSamples {
+				v.Missing = append(v.Missing, model.RowError{Reason: "not found in the " + table, Values: rowStrings(row)})
+			}
+		}
+	}
+	v.Passed = v.MissingRows == 0
+	return nil
+}
+
+// RowExists reports whether source, a table or a parenthesized query taking args,
+// holds a row with the given values in columns; nil values match NULLs
+func (s *ClickHouseServiceImpl) RowExists(ctx context.Context, source string, args []interface{}, columns []string, values []interface{}) (bool, error) {
+	if s.conn == nil {
+		return false, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	conditions := make([]string, len(columns))
+	args = append([]interface{}{}, args...)
+	for i, name := range columns {
+		quoted, err := QuoteIdentifier(name)
+		if err != nil {
+			return false, err
+		}
+		if values[i] == nil {
+			conditions[i] = fmt.Sprintf("isNull(%s)", quoted)
+			continue
+		}
+		conditions[i] = quoted + " = ?"
+		args = append(args, values[i])
+	}
+
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE %s LIMIT 1", source, strings.Join(conditions, " AND "))
+	rows, err := s.conn.Query(readOnlyContext(ctx), query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up row: %w", err)
+	}
+	defer rows.Close()
+	found := rows.Next()
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to look up row: %w", err)
+	}
+	return found, nil
+}
+
+// SampleRows draws up to n data rows uniformly from a flat file, reading it whole,
+// and converts them to rows of columns as a load would. Records a load would
+// reject are left out, so fewer rows may be returned.
+func (s *FlatFileServiceImpl) SampleRows(ctx context.Context, params model.FlatFileParams, columns []model.Column, n int, seed int64) ([][]interface{}, error) {
+	filePath, err := s.sandbox.Resolve(params.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := s.newRecordConverter(params, columns, nil)
+	if err != nil {
+		return nil, err
+	}
+	sampler, err := newRowSampler(model.FlatFileParams{SampleStrategy: SampleReservoir, SampleSize: n, SampleSeed: seed})
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var delim rune = ','
+	if delims := []rune(params.Delimiter); len(delims) > 0 {
+		delim = delims[0]
+	}
+	reader := csv.NewReader(s.bufferedReader(file))
+	reader.Comma = delim
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	names, _ := normalizeHeader(header)
+	conv.index(names)
+
+	// The reservoir keeps records, so the reader must not reuse them
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || len(record) != len(header) {
+			continue
+		}
+		sampler.Offer(record)
+	}
+
+	rows := make([][]interface{}, 0, len(sampler.Reservoir()))
+	for _, record := range sampler.Reservoir() {
+		if row, skip := conv.convert(record); skip == "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
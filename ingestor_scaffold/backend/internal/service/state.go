@@ -0,0 +1,155 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ingestor/internal/model"
+)
+
+// State file names
+const (
+	stateSessionsFile  = "sessions.json"
+	stateSchedulesFile = "schedules.json"
+	stateJobsFile      = "jobs.json"
+	stateAppliedFile   = "applied.json"
+	stateTemplatesFile = "templates.json"
+)
+
+// StateStore persists server state as JSON files in a directory, optionally
+// sealed with AES-GCM. A nil store disables persistence.
+type StateStore struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// NewStateStore opens the state directory; an empty dir returns a nil store.
+// key is a base64-encoded 32-byte AES key; without it files are written in the clear.
+func NewStateStore(dir, key string) (*StateStore, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	store := &StateStore{dir: dir}
+	if key == "" {
+		return store, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("state encryption key must be 32 bytes, base64-encoded")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	if store.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return store, nil
+}
+
+// Encrypted reports whether state is sealed at rest, i.e. safe for credentials
+func (s *StateStore) Encrypted() bool {
+	return s != nil && s.aead != nil
+}
+
+// Save writes v to the named file, replacing it atomically
+func (s *StateStore) Save(name string, v interface{}) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	if s.aead != nil {
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		data = s.aead.Seal(nonce, nonce, data, []byte(name))
+	}
+
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", name, err)
+	}
+	return nil
+}
+
+// Load reads the named file into v, reporting false when it does not exist
+func (s *StateStore) Load(name string, v interface{}) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if s.aead != nil {
+		size := s.aead.NonceSize()
+		if len(data) < size {
+			return false, fmt.Errorf("failed to decrypt %s: file too short", name)
+		}
+		if data, err = s.aead.Open(nil, data[:size], data[size:], []byte(name)); err != nil {
+			return false, fmt.Errorf("failed to decrypt %s: %w", name, err)
+		}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// redactParams drops credentials from ingestion params unless the store is encrypted
+func (s *StateStore) redactParams(params model.IngestionParams) model.IngestionParams {
+	if s.Encrypted() || params.TargetConnection == nil {
+		return params
+	}
+	target := redactConnection(*params.TargetConnection)
+	params.TargetConnection = &target
+	return params
+}
+
+// redactConnection drops the token, password and client key of a connection
+func redactConnection(params model.ClickHouseConnectionParams) model.ClickHouseConnectionParams {
+	params.Token = ""
+	params.Password = ""
+	params.ClientKey = ""
+	return params
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// StatsService tracks data quality trends of recurring jobs
+type StatsService interface {
+	RecordRun(schedule model.Schedule, job model.Job)
+	RecordJob(job model.Job)
+	GetScheduleStats(id string) (model.ScheduleStats, error)
+	ListScheduleStats() []model.ScheduleStats
+	WritePrometheus(w io.Writer) error
+}
+
+// labelValue is one value of a cost attribution label
+type labelValue struct {
+	key, value string
+}
+
+// labelTotals are the totals of the finished jobs carrying one label value
+type labelTotals struct {
+	jobs         map[string]int // by status
+	rows         int
+	bytesRead    int64
+	bytesWritten int64
+	cost         float64
+}
+
+// StatsServiceImpl implements StatsService in memory
+type StatsServiceImpl struct {
+	mu       sync.RWMutex
+	stats    map[string]*model.ScheduleStats
+	labels   map[labelValue]*labelTotals
+	notifier NotificationService
+	config   *config.Config
+	logger   *logrus.Logger
+}
+
+// NewStatsService creates a new stats service
+func NewStatsService(
+	notificationService NotificationService,
+	config *config.Config,
+	logger *logrus.Logger,
+) StatsService {
+	return &StatsServiceImpl{
+		stats:    make(map[string]*model.ScheduleStats),
+		labels:   make(map[labelValue]*labelTotals),
+		notifier: notificationService,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// RecordRun adds a finished run of a schedule and re-evaluates its alert thresholds.
+// Recipients are notified when a schedule starts alerting, not on every run.
+func (s *StatsServiceImpl) RecordRun(schedule model.Schedule, job model.Job) {
+	run := model.ScheduleRunStats{
+		JobID:    job.ID,
+		Status:   job.Status,
+		Rows:     job.Result.TotalRecords,
+		Rejected: job.Result.RejectedRecords,
+		Coerced:  job.Result.CoercedRecords,
+	}
+	if job.FinishedAt != nil {
+		run.FinishedAt = *job.FinishedAt
+	}
+	run.RejectRate, run.CoercionRate = qualityRates(run.Rows, run.Rejected, run.Coerced)
+
+	s.mu.Lock()
+	stats, ok := s.stats[schedule.ID]
+	if !ok {
+		stats = &model.ScheduleStats{ScheduleID: schedule.ID}
+		s.stats[schedule.ID] = stats
+	}
+	stats.ScheduleName = schedule.Name
+	stats.Runs++
+	stats.TotalRows += run.Rows
+	stats.TotalRejected += run.Rejected
+	stats.TotalCoerced += run.Coerced
+
+	stats.History = append(stats.History, run)
+	if limit := s.config.StatsHistorySize; limit > 0 && len(stats.History) > limit {
+		stats.History = append([]model.ScheduleRunStats(nil), stats.History[len(stats.History)-limit:]...)
+	}
+
+	wasAlerting := len(stats.Alerts) > 0
+	s.evaluateLocked(stats)
+	alerts := append([]string(nil), stats.Alerts...)
+	s.mu.Unlock()
+
+	if len(alerts) == 0 || wasAlerting {
+		return
+	}
+	s.logger.WithFields(logrus.Fields{
+		"scheduleId": schedule.ID,
+		"alerts":     alerts,
+	}).Warn("Data quality degrading for schedule")
+	s.notifier.NotifyDataQuality(schedule, alerts)
+}
+
+// RecordJob adds a finished job to the totals of each of its labels, so load can be
+// attributed to teams and projects
+func (s *StatsServiceImpl) RecordJob(job model.Job) {
+	if len(job.Params.Labels) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, value := range job.Params.Labels {
+		totals, ok := s.labels[labelValue{key, value}]
+		if !ok {
+			totals = &labelTotals{jobs: make(map[string]int)}
+			s.labels[labelValue{key, value}] = totals
+		}
+		totals.jobs[job.Status]++
+		totals.rows += job.Result.TotalRecords
+		totals.bytesRead += job.Result.BytesRead
+		totals.bytesWritten += job.Result.BytesWritten
+		if job.Result.Cost != nil {
+			totals.cost += job.Result.Cost.Total
+		}
+	}
+}
+
+// evaluateLocked recomputes the window rates and alerts of a schedule
+func (s *StatsServiceImpl) evaluateLocked(stats *model.ScheduleStats) {
+	window := stats.History
+	if n := s.config.StatsWindow; n > 0 && len(window) > n {
+		window = window[len(window)-n:]
+	}
+
+	var rows, rejected, coerced int
+	for _, run := range window {
+		rows += run.Rows
+		rejected += run.Rejected
+		coerced += run.Coerced
+	}
+	stats.WindowRejectRate, stats.WindowCoercionRate = qualityRates(rows, rejected, coerced)
+
+	stats.Alerts = nil
+	if s.config.StatsRejectRateAlert > 0 && stats.WindowRejectRate > s.config.StatsRejectRateAlert {
+		stats.Alerts = append(stats.Alerts, fmt.Sprintf("reject rate %.4f over the last %d runs exceeds %.4f", stats.WindowRejectRate, len(window), s.config.StatsRejectRateAlert))
+	}
+	if s.config.StatsCoercionRateAlert > 0 && stats.WindowCoercionRate > s.config.StatsCoercionRateAlert {
+		stats.Alerts = append(stats.Alerts, fmt.Sprintf("coercion rate %.4f over the last %d runs exceeds %.4f", stats.WindowCoercionRate, len(window), s.config.StatsCoercionRateAlert))
+	}
+}
+
+// GetScheduleStats returns the data quality stats of a schedule
+func (s *StatsServiceImpl) GetScheduleStats(id string) (model.ScheduleStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats, ok := s.stats[id]
+	if !ok {
+		return model.ScheduleStats{}, fmt.Errorf("no runs recorded for schedule %s", id)
+	}
+	return copyStats(stats), nil
+}
+
+// ListScheduleStats returns the stats of every schedule that has run, ordered by ID
+func (s *StatsServiceImpl) ListScheduleStats() []model.ScheduleStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]model.ScheduleStats, 0, len(s.stats))
+	for _, stats := range s.stats {
+		list = append(list, copyStats(stats))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ScheduleID < list[j].ScheduleID
+	})
+	return list
+}
+
+// WritePrometheus renders the per-schedule stats in the Prometheus text exposition format
+func (s *StatsServiceImpl) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+
+	metrics := []struct {
+		name, kind, help string
+		value            func(stats model.ScheduleStats) float64
+	}{
+		{"ingestor_schedule_runs_total", "counter", "Runs recorded per schedule.", func(st model.ScheduleStats) float64 { return float64(st.Runs) }},
+		{"ingestor_schedule_rows_total", "counter", "Rows ingested per schedule.", func(st model.ScheduleStats) float64 { return float64(st.TotalRows) }},
+		{"ingestor_schedule_rejected_rows_total", "counter", "Rows rejected per schedule.", func(st model.ScheduleStats) float64 { return float64(st.TotalRejected) }},
+		{"ingestor_schedule_coerced_rows_total", "counter", "Rows with coerced values per schedule.", func(st model.ScheduleStats) float64 { return float64(st.TotalCoerced) }},
+		{"ingestor_schedule_reject_rate", "gauge", "Reject rate over the recent run window.", func(st model.ScheduleStats) float64 { return st.WindowRejectRate }},
+		{"ingestor_schedule_coercion_rate", "gauge", "Coercion rate over the recent run window.", func(st model.ScheduleStats) float64 { return st.WindowCoercionRate }},
+		{"ingestor_schedule_quality_alert", "gauge", "1 when a data quality threshold is exceeded.", func(st model.ScheduleStats) float64 {
+			if len(st.Alerts) > 0 {
+				return 1
+			}
+			return 0
+		}},
+	}
+
+	list := s.ListScheduleStats()
+	for _, metric := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, stats := range list {
+			fmt.Fprintf(&b, "%s{schedule_id=\"%s\",schedule_name=\"%s\"} %g\n",
+				metric.name, promLabel(stats.ScheduleID), promLabel(stats.ScheduleName), metric.value(stats))
+		}
+	}
+
+	s.writeLabelMetrics(&b)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeLabelMetrics renders the job totals per label value
+func (s *StatsServiceImpl) writeLabelMetrics(b *strings.Builder) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make([]labelValue, 0, len(s.labels))
+	for lv := range s.labels {
+		values = append(values, lv)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].key != values[j].key {
+			return values[i].key < values[j].key
+		}
+		return values[i].value < values[j].value
+	})
+
+	b.WriteString("# HELP ingestor_labeled_jobs_total Finished jobs per label value and status.\n# TYPE ingestor_labeled_jobs_total counter\n")
+	for _, lv := range values {
+		totals := s.labels[lv]
+		statuses := make([]string, 0, len(totals.jobs))
+		for status := range totals.jobs {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(b, "ingestor_labeled_jobs_total{label=\"%s\",value=\"%s\",status=\"%s\"} %d\n",
+				promLabel(lv.key), promLabel(lv.value), promLabel(status), totals.jobs[status])
+		}
+	}
+
+	metrics := []struct {
+		name, help string
+		value      func(t *labelTotals) float64
+	}{
+		{"ingestor_labeled_rows_total", "Rows moved per label value.", func(t *labelTotals) float64 { return float64(t.rows) }},
+		{"ingestor_labeled_bytes_read_total", "Bytes read per label value.", func(t *labelTotals) float64 { return float64(t.bytesRead) }},
+		{"ingestor_labeled_bytes_written_total", "Bytes written per label value.", func(t *labelTotals) float64 { return float64(t.bytesWritten) }},
+		{"ingestor_labeled_cost_total", "Cost of the bytes moved per label value, in COST_CURRENCY.", func(t *labelTotals) float64 { return t.cost }},
+	}
+	for _, metric := range metrics {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", metric.name, metric.help, metric.name)
+		for _, lv := range values {
+			fmt.Fprintf(b, "%s{label=\"%s\",value=\"%s\"} %g\n",
+				metric.name, promLabel(lv.key), promLabel(lv.value), metric.value(s.labels[lv]))
+		}
+	}
+}
+
+// qualityRates returns reject and coercion rates as fractions of all rows read
+func qualityRates(rows, rejected, coerced int) (float64, float64) {
+	seen := rows + rejected
+	if seen == 0 {
+		return 0, 0
+	}
+	return float64(rejected) / float64(seen), float64(coerced) / float64(seen)
+}
+
+// copyStats returns a copy that shares no slices with the stored stats
+func copyStats(stats *model.ScheduleStats) model.ScheduleStats {
+	c := *stats
+	c.History = append([]model.ScheduleRunStats(nil), stats.History...)
+	c.Alerts = append([]string(nil), stats.Alerts...)
+	return c
+}
+
+// promLabel escapes a Prometheus label value
+func promLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+)
+
+// maxTableFunctionArgs bounds the arguments of a table function; s3 takes the most
+const maxTableFunctionArgs = 8
+
+// TableFunctionSource validates a table function against ALLOWED_TABLE_FUNCTIONS and
+// renders the call, every argument quoted as a string literal so none can be read
+// as SQL
+func TableFunctionSource(fn model.TableFunction, cfg *config.Config) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(fn.Name))
+	allowed := false
+	for _, entry := range strings.Split(cfg.AllowedTableFunctions, ",") {
+		if strings.TrimSpace(entry) == name && name != "" {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("table function %q is not allowed (ALLOWED_TABLE_FUNCTIONS)", fn.Name)
+	}
+	if len(fn.Args) == 0 || len(fn.Args) > maxTableFunctionArgs {
+		return "", fmt.Errorf("table function %s takes 1 to %d arguments", name, maxTableFunctionArgs)
+	}
+
+	args := make([]string, len(fn.Args))
+	for i, arg := range fn.Args {
+		args[i] = quoteString(arg)
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", ")), nil
+}
+
+// quoteString renders s as a ClickHouse string literal
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// IngestTableFunction loads a table function into a table of the same ClickHouse
+// server with INSERT ... SELECT, so the data never passes through this service.
+// Row-level processing (CDC, deduplication, cursors and throttling) needs the rows
+// here and is not available.
+func (s *IngestServiceImpl) IngestTableFunction(
+	ctx context.Context,
+	params model.IngestionParams,
+	progressCh chan<- model.ProgressUpdate,
+) (model.IngestionResult, error) {
+	switch {
+	case params.TargetTableName == "":
+		return model.IngestionResult{}, fmt.Errorf("targetTableName is required to load a table function")
+	case params.TargetConnection != nil:
+		return model.IngestionResult{}, fmt.Errorf("table functions load into the source server; targetConnection is not supported")
+	case params.Mode == "cdc" || len(params.DedupKey) > 0 || params.CursorColumn != "" || params.MaxRowsPerSecond > 0 || len(params.ColumnMappings) > 0:
+		return model.IngestionResult{}, fmt.Errorf("cdc mode, dedupKey, cursorColumn, maxRowsPerSecond and columnMappings are not supported for table function loads")
+	}
+
+	source, err := TableFunctionSource(*params.TableFunction, s.config)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	conn := s.clickhouse(ctx)
+
+	// Columns default to the structure ClickHouse infers for the function
+	columns := params.Columns
+	if len(columns) == 0 {
+		if columns, err = conn.DescribeSource(ctx, source); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+
+	tableOpts, err := buildTableOptions(params)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	targetSchema, err := s.planTargetSchema(ctx, params, params.TargetTableName, columns)
+	if err != nil {
+		return model.IngestionResult{}, err
+	}
+	if err := conn.CreateTable(ctx, params.TargetTableName, columns, clusterOptions(tableOpts, params, conn)); err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to create table: %w", err)
+	}
+
+	count, err := conn.InsertSelect(ctx, params.TargetTableName, targetSchema.columns, source, progressCh)
+	if err != nil {
+		return model.IngestionResult{}, fmt.Errorf("failed to load table function: %w", err)
+	}
+
+	if params.Mode == "upsert" && params.OptimizeFinal {
+		if err := conn.OptimizeTable(ctx, localTableName(params.TargetTableName, params.Distributed), params.Cluster); err != nil {
+			return model.IngestionResult{}, err
+		}
+	}
+	return model.IngestionResult{TotalRecords: count, SchemaDiff: targetSchema.diff}, nil
+}
+
+// DescribeSource returns the columns of a table expression such as a table function
+func (s *ClickHouseServiceImpl) DescribeSource(ctx context.Context, source string) ([]model.Column, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	rows, err := s.conn.Query(queryContext(ctx), "DESCRIBE TABLE "+source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe source: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []model.Column
+	for rows.Next() {
+		var name, dataType, defaultType, defaultExpression string
+		var comment interface{}
+		if err := rows.Scan(&name, &dataType, &defaultType, &defaultExpression, &comment); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns = append(columns, model.Column{Name: name, Type: dataType})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return columns, nil
+}
+
+// InsertSelect copies the columns of a table expression into a table on the server,
+// reporting the rows written as ClickHouse sends progress
+func (s *ClickHouseServiceImpl) InsertSelect(ctx context.Context, tableName string, columns []model.Column, source string, progressCh chan<- model.ProgressUpdate) (int, error) {
+	if s.conn == nil {
+		return 0, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+	names, err := quoteIdentifiers(selectedColumnNames(columns))
+	if err != nil {
+		return 0, err
+	}
+	list := strings.Join(names, ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", table, list, list, source)
+
+	rows, _, err := s.execInsert(ctx, query, nil, nil, progressCh)
+	return int(rows), err
+}
+
+// execInsert runs an INSERT ... SELECT on the server, counting the bytes it reads
+// and writes and reporting the rows written as ClickHouse sends progress
+func (s *ClickHouseServiceImpl) execInsert(ctx context.Context, query string, args []interface{}, settings clickhouse.Settings, progressCh chan<- model.ProgressUpdate) (int64, int64, error) {
+	var rows, bytes, reported int64
+	counters := ByteCountersFromContext(ctx)
+	opts := []clickhouse.QueryOption{clickhouse.WithProgress(func(p *clickhouse.Progress) {
+		written := atomic.AddInt64(&rows, int64(p.WroteRows))
+		atomic.AddInt64(&bytes, int64(p.WroteBytes))
+		counters.AddRead(int64(p.Bytes))
+		counters.AddWritten(int64(p.WroteBytes))
+		if written-reported < int64(s.config.ProgressReportSize) {
+			return
+		}
+		reported = written
+		select {
+		case progressCh <- model.ProgressUpdate{
+			Status:  "processing",
+			Message: fmt.Sprintf("Written %d rows", written),
+			Count:   int(written),
+		}:
+		default:
+		}
+	})}
+	if len(settings) > 0 {
+		// Settings given here replace the log_comment set by queryContext
+		if comment := labelComment(LabelsFromContext(ctx)); comment != "" {
+			settings["log_comment"] = comment
+		}
+		opts = append(opts, clickhouse.WithSettings(settings))
+	}
+
+	err := s.conn.Exec(clickhouse.Context(queryContext(ctx), opts...), query, args...)
+	if err != nil {
+		err = fmt.Errorf("failed to execute query: %w", err)
+	}
+	return atomic.LoadInt64(&rows), atomic.LoadInt64(&bytes), err
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// partitionRe matches a partition expression: a column, or a column wrapped in
+// one function call
+var partitionRe = regexp.MustCompile(`^\s*(?:(\w+)\(\s*([^()]+?)\s*\)|([^()]+?))\s*$`)
+
+// partitionFunctions are the functions a partition expression may apply to its
+// column, all bucketing dates and times
+var partitionFunctions = map[string]bool{
+	"toYYYYMM":       true,
+	"toYYYYMMDD":     true,
+	"toYear":         true,
+	"toMonday":       true,
+	"toDate":         true,
+	"toStartOfDay":   true,
+	"toStartOfWeek":  true,
+	"toStartOfMonth": true,
+}
+
+// parsePartition splits a partition expression into its function, empty for a bare
+// column, and its column
+func parsePartition(expr string) (fn string, column string, err error) {
+	m := partitionRe.FindStringSubmatch(expr)
+	if m == nil {
+		return "", "", fmt.Errorf("invalid partition expression %q: use a column or a function of one column", expr)
+	}
+	if m[3] != "" {
+		return "", m[3], nil
+	}
+	if !partitionFunctions[m[1]] {
+		return "", "", fmt.Errorf("unsupported partition function %s", m[1])
+	}
+	return m[1], m[2], nil
+}
+
+// partitionClause renders a partition expression with its column quoted
+func partitionClause(expr string) (string, error) {
+	fn, column, err := parsePartition(expr)
+	if err != nil {
+		return "", err
+	}
+	name, err := QuoteIdentifier(column)
+	if err != nil {
+		return "", err
+	}
+	if fn == "" {
+		return name, nil
+	}
+	return fmt.Sprintf("%s(%s)", fn, name), nil
+}
+
+// renamePartition returns a partition expression over the target column name
+func renamePartition(expr string, renames map[string]string) string {
+	fn, column, err := parsePartition(expr)
+	if err != nil {
+		return expr
+	}
+	target, ok := renames[column]
+	if !ok {
+		return expr
+	}
+	if fn == "" {
+		return target
+	}
+	return fmt.Sprintf("%s(%s)", fn, target)
+}
+
+// sortingKey returns the columns a table is sorted by: its ORDER BY columns, or its
+// primary key when it has none
+func sortingKey(opts model.TableOptions) []string {
+	if len(opts.OrderBy) > 0 {
+		return opts.OrderBy
+	}
+	return opts.PrimaryKey
+}
+
+// checkTableKeys validates the primary key and partition expression of table
+// options. The primary key must be a prefix of the sorting key.
+func checkTableKeys(opts model.TableOptions) error {
+	orderBy := sortingKey(opts)
+	if len(opts.PrimaryKey) > len(orderBy) {
+		return fmt.Errorf("primary key must be a prefix of the sorting key (%s)", strings.Join(orderBy, ", "))
+	}
+	for i, key := range opts.PrimaryKey {
+		if orderBy[i] != key {
+			return fmt.Errorf("primary key must be a prefix of the sorting key (%s)", strings.Join(orderBy, ", "))
+		}
+	}
+	if opts.PartitionBy != "" {
+		if _, err := partitionClause(opts.PartitionBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyColumns returns the columns named by the sorting, primary and partition keys
+// of table options
+func keyColumns(opts model.TableOptions) []string {
+	keys := append(append([]string{}, opts.OrderBy...), opts.PrimaryKey...)
+	if opts.PartitionBy != "" {
+		if _, column, err := parsePartition(opts.PartitionBy); err == nil {
+			keys = append(keys, column)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/ingestor/internal/model"
+)
+
+// stepOutputs are the template variables a finished pipeline step exposes to later steps
+func stepOutputs(job model.Job) map[string]interface{} {
+	outputs := map[string]interface{}{
+		"jobId":     job.ID,
+		"rows":      job.Result.TotalRecords,
+		"rejected":  job.Result.RejectedRecords,
+		"maxCursor": job.Result.MaxCursor,
+		"table":     job.Params.TableName,
+	}
+	if job.Params.TargetType == "clickhouse" && job.Params.TargetTableName != "" {
+		outputs["table"] = job.Params.TargetTableName
+	}
+	if job.Params.TargetType == "flatfile" {
+		outputs["file"] = job.Params.FlatFileParams.FilePath
+	}
+	if job.Result.DeadLetterFile != "" {
+		outputs["deadLetterFile"] = job.Result.DeadLetterFile
+	}
+	if job.Result.ManifestFile != "" {
+		outputs["manifestFile"] = job.Result.ManifestFile
+	}
+	return outputs
+}
+
+// renderStepParams expands template variables in every string of a step's params.
+// Referencing a step that has not run yet, or an unknown output, is an error.
+func renderStepParams(params model.IngestionParams, steps map[string]map[string]interface{}) (model.IngestionParams, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return params, fmt.Errorf("failed to encode step params: %w", err)
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return params, fmt.Errorf("failed to decode step params: %w", err)
+	}
+
+	vars := map[string]interface{}{"steps": steps}
+	tree, err = renderTree(tree, vars)
+	if err != nil {
+		return params, err
+	}
+
+	if data, err = json.Marshal(tree); err != nil {
+		return params, fmt.Errorf("failed to encode step params: %w", err)
+	}
+	var rendered model.IngestionParams
+	if err := json.Unmarshal(data, &rendered); err != nil {
+		return params, fmt.Errorf("failed to decode step params: %w", err)
+	}
+	return rendered, nil
+}
+
+// renderTree walks decoded JSON and executes strings containing template actions
+func renderTree(node interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch v := node.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := template.New("step").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", v, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, vars); err != nil {
+			return nil, fmt.Errorf("failed to render %q: %w", v, err)
+		}
+		return b.String(), nil
+	case []interface{}:
+		for i, item := range v {
+			rendered, err := renderTree(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+		return v, nil
+	case map[string]interface{}:
+		for key, item := range v {
+			rendered, err := renderTree(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// validateTemplates checks that the templates in a step's params parse
+func validateTemplates(params model.IngestionParams) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return err
+	}
+	return walkStrings(tree, func(s string) error {
+		if !strings.Contains(s, "{{") {
+			return nil
+		}
+		if _, err := template.New("step").Parse(s); err != nil {
+			return fmt.Errorf("invalid template %q: %w", s, err)
+		}
+		return nil
+	})
+}
+
+// walkStrings calls fn for every string in decoded JSON
+func walkStrings(node interface{}, fn func(string) error) error {
+	switch v := node.(type) {
+	case string:
+		return fn(v)
+	case []interface{}:
+		for _, item := range v {
+			if err := walkStrings(item, fn); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := walkStrings(item, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces row throughput using a token bucket
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a rate limiter allowing rowsPerSecond rows per second.
+// A non-positive rate disables throttling and returns nil.
+func NewRateLimiter(rowsPerSecond int) *RateLimiter {
+	if rowsPerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		rate:   float64(rowsPerSecond),
+		burst:  float64(rowsPerSecond),
+		tokens: float64(rowsPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until n tokens are available or the context is done
+func (l *RateLimiter) Wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+
+		// Requests larger than the bucket only need a full bucket
+		need := float64(n)
+		if need > l.burst {
+			need = l.burst
+		}
+		if l.tokens >= need {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// effectiveRowRate returns the job rate capped by the configured server limit, or
+// the limit when the job sets no rate; jobs may slow down but never exceed it
+func effectiveRowRate(jobRate, serverRate int) int {
+	if jobRate > 0 && (serverRate <= 0 || jobRate < serverRate) {
+		return jobRate
+	}
+	return serverRate
+}
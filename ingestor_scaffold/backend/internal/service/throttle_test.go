@@ -0,0 +1,23 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveRowRate(t *testing.T) {
+	tests := []struct {
+		job, server, want int
+	}{
+		{0, 0, 0},
+		{500, 0, 500},
+		{0, 1000, 1000},
+		{500, 1000, 500},
+		{1000, 1000, 1000},
+		{5000, 1000, 1000},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, effectiveRowRate(tt.job, tt.server), "job %d, server %d", tt.job, tt.server)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"github.com/ingestor/internal/model"
+)
+
+// tlsConfig builds the TLS settings of a ClickHouse connection, or nil for plain TCP
+func tlsConfig(params model.ClickHouseConnectionParams) (*tls.Config, error) {
+	if !params.Secure && params.CACert == "" && params.ClientCert == "" && params.ClientKey == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         params.TLSServerName,
+		InsecureSkipVerify: params.InsecureSkipVerify,
+	}
+
+	if params.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(params.CACert)) {
+			return nil, fmt.Errorf("invalid CA certificate: no PEM certificates found")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if params.ClientCert != "" || params.ClientKey != "" {
+		if params.ClientCert == "" || params.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key must be provided together")
+		}
+		cert, err := tls.X509KeyPair([]byte(params.ClientCert), []byte(params.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// tlsDialer wraps dial so connections are upgraded to TLS. The driver only applies
+// its own TLS settings when it dials itself, so proxied connections need this.
+func tlsDialer(dial dialFunc, cfg *tls.Config) dialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		config := cfg.Clone()
+		if config.ServerName == "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			config.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with %s failed: %w", addr, err)
+		}
+		return tlsConn, nil
+	}
+}
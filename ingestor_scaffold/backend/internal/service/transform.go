@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// transformFunctions are the supported column transforms
+var transformFunctions = map[string]bool{
+	"trim": true, "upper": true, "lower": true, "substring": true, "replace": true, "concat": true, "scale": true,
+}
+
+// rowTransform rewrites one cell of a positional row in place
+type rowTransform func(row []interface{})
+
+// compileTransforms validates column transforms against the ingested columns and
+// compiles them, in order, into functions over positional rows
+func compileTransforms(transforms []model.ColumnTransform, columns []model.Column) ([]rowTransform, error) {
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col.Name] = i
+	}
+
+	compiled := make([]rowTransform, 0, len(transforms))
+	for _, t := range transforms {
+		idx, ok := index[t.Column]
+		if !ok {
+			return nil, fmt.Errorf("transformed column %s is not selected", t.Column)
+		}
+		colType := baseType(columns[idx].Type)
+
+		function := strings.ToLower(t.Function)
+		if !transformFunctions[function] {
+			return nil, fmt.Errorf("unknown transform %q for %s: must be trim, upper, lower, substring, replace, concat or scale", t.Function, t.Column)
+		}
+		if function == "scale" {
+			if !isNumericType(colType) {
+				return nil, fmt.Errorf("scale needs a numeric column, %s is %s", t.Column, columns[idx].Type)
+			}
+		} else if colType != "String" {
+			return nil, fmt.Errorf("%s needs a String column, %s is %s", function, t.Column, columns[idx].Type)
+		}
+
+		var fn func(string) string
+		switch function {
+		case "trim":
+			fn = strings.TrimSpace
+		case "upper":
+			fn = strings.ToUpper
+		case "lower":
+			fn = strings.ToLower
+		case "substring":
+			if t.Start < 1 || t.Length < 0 {
+				return nil, fmt.Errorf("substring of %s needs a start from 1 and a non-negative length", t.Column)
+			}
+			start, length := t.Start, t.Length
+			fn = func(s string) string { return substring(s, start, length) }
+		case "replace":
+			re, err := regexp.Compile(t.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for %s: %w", t.Column, err)
+			}
+			replacement := t.Replacement
+			fn = func(s string) string { return re.ReplaceAllString(s, replacement) }
+		case "concat":
+			parts := make([]int, len(t.Columns))
+			for i, name := range t.Columns {
+				if parts[i], ok = index[name]; !ok {
+					return nil, fmt.Errorf("concatenated column %s is not selected", name)
+				}
+			}
+			if len(parts) == 0 {
+				return nil, fmt.Errorf("concat into %s needs columns", t.Column)
+			}
+			compiled = append(compiled, concatTransform(idx, parts, t.Separator))
+			continue
+		case "scale":
+			compiled = append(compiled, scaleTransform(idx, t.Factor, t.Offset, strings.HasPrefix(colType, "Float")))
+			continue
+		}
+		compiled = append(compiled, stringTransform(idx, fn))
+	}
+	return compiled, nil
+}
+
+// isNumericType reports whether a ClickHouse type is an integer or float
+func isNumericType(chType string) bool {
+	return strings.HasPrefix(chType, "Int") || strings.HasPrefix(chType, "UInt") || strings.HasPrefix(chType, "Float")
+}
+
+// stringTransform applies fn to the string values of a column; NULLs stay NULL
+func stringTransform(idx int, fn func(string) string) rowTransform {
+	return func(row []interface{}) {
+		if s, ok := row[idx].(string); ok {
+			row[idx] = fn(s)
+		}
+	}
+}
+
+// substring returns length characters of s from start, counting from 1; a length
+// of 0 runs to the end
+func substring(s string, start, length int) string {
+	runes := []rune(s)
+	if start > len(runes) {
+		return ""
+	}
+	runes = runes[start-1:]
+	if length > 0 && length < len(runes) {
+		runes = runes[:length]
+	}
+	return string(runes)
+}
+
+// concatTransform sets a column to other columns joined with sep; NULLs join as
+// empty strings
+func concatTransform(idx int, parts []int, sep string) rowTransform {
+	return func(row []interface{}) {
+		values := make([]string, len(parts))
+		for i, part := range parts {
+			if row[part] != nil {
+				values[i] = fmt.Sprint(row[part])
+			}
+		}
+		row[idx] = strings.Join(values, sep)
+	}
+}
+
+// scaleTransform sets a numeric column to value * factor + offset, rounding for
+// integer columns; NULLs stay NULL
+func scaleTransform(idx int, factor, offset float64, float bool) rowTransform {
+	if factor == 0 {
+		factor = 1
+	}
+	return func(row []interface{}) {
+		v, ok := toFloat64(row[idx])
+		if !ok {
+			return
+		}
+		scaled := v*factor + offset
+		if float {
+			row[idx] = scaled
+		} else {
+			row[idx] = int64(math.Round(scaled))
+		}
+	}
+}
+
+// transformRows applies column transforms to each row
+func (s *IngestServiceImpl) transformRows(
+	ctx context.Context,
+	in <-chan []interface{},
+	transforms []rowTransform,
+) <-chan []interface{} {
+	out := make(chan []interface{}, cap(in))
+
+	go func() {
+		defer close(out)
+
+		for row := range in {
+			for _, transform := range transforms {
+				transform(row)
+			}
+
+			select {
+			case out <- row:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// ttlUnits maps TTL interval units to their SQL keywords
+var ttlUnits = map[string]string{
+	"second":  "SECOND",
+	"minute":  "MINUTE",
+	"hour":    "HOUR",
+	"day":     "DAY",
+	"week":    "WEEK",
+	"month":   "MONTH",
+	"quarter": "QUARTER",
+	"year":    "YEAR",
+}
+
+// checkTTL validates the interval of a TTL
+func checkTTL(ttl model.TTL) error {
+	if ttl.DateColumn == "" {
+		return fmt.Errorf("TTL requires a date column")
+	}
+	if ttl.Interval <= 0 {
+		return fmt.Errorf("TTL interval must be positive")
+	}
+	if _, ok := ttlUnits[strings.ToLower(ttl.Unit)]; !ok {
+		return fmt.Errorf("unsupported TTL unit %q: must be second, minute, hour, day, week, month, quarter or year", ttl.Unit)
+	}
+	return nil
+}
+
+// checkTTLs validates the row and column TTLs of table options. Key columns
+// cannot expire, and each column takes one TTL.
+func checkTTLs(opts model.TableOptions) error {
+	if opts.TTL != nil {
+		if err := checkTTL(*opts.TTL); err != nil {
+			return err
+		}
+	}
+
+	isKey := make(map[string]bool)
+	for _, key := range append(keyColumns(opts), opts.EngineArgs...) {
+		isKey[key] = true
+	}
+	seen := make(map[string]bool, len(opts.ColumnTTLs))
+	for _, c := range opts.ColumnTTLs {
+		if isKey[c.Column] {
+			return fmt.Errorf("key column %s cannot have a TTL", c.Column)
+		}
+		if seen[c.Column] {
+			return fmt.Errorf("column %s has two TTLs", c.Column)
+		}
+		seen[c.Column] = true
+		if err := checkTTL(c.TTL); err != nil {
+			return fmt.Errorf("column %s: %w", c.Column, err)
+		}
+	}
+	return nil
+}
+
+// ttlColumns returns the columns named by the TTLs of table options: date
+// columns first, then expiring columns
+func ttlColumns(opts model.TableOptions) (dates []string, expiring []string) {
+	if opts.TTL != nil {
+		dates = append(dates, opts.TTL.DateColumn)
+	}
+	for _, c := range opts.ColumnTTLs {
+		dates = append(dates, c.TTL.DateColumn)
+		expiring = append(expiring, c.Column)
+	}
+	return dates, expiring
+}
+
+// ttlExpression renders a TTL over the given table columns. The date column must
+// be a Date or DateTime that is not Nullable.
+func ttlExpression(ttl model.TTL, columns []model.Column) (string, error) {
+	var dateType string
+	for _, col := range columns {
+		if col.Name == ttl.DateColumn {
+			dateType = col.Type
+		}
+	}
+	if dateType == "" {
+		return "", fmt.Errorf("TTL date column %s is not a table column", ttl.DateColumn)
+	}
+	if !strings.HasPrefix(baseType(dateType), "Date") || strings.Contains(dateType, "Nullable(") {
+		return "", fmt.Errorf("TTL date column %s must be a Date or DateTime that is not Nullable, not %s", ttl.DateColumn, dateType)
+	}
+
+	name, err := QuoteIdentifier(ttl.DateColumn)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s + INTERVAL %d %s", name, ttl.Interval, ttlUnits[strings.ToLower(ttl.Unit)]), nil
+}
+
+// renameTTLs returns row and column TTLs under the target column names
+func renameTTLs(opts model.TableOptions, renames map[string]string) model.TableOptions {
+	rename := func(name string) string {
+		if target, ok := renames[name]; ok {
+			return target
+		}
+		return name
+	}
+	if opts.TTL != nil {
+		ttl := *opts.TTL
+		ttl.DateColumn = rename(ttl.DateColumn)
+		opts.TTL = &ttl
+	}
+	if opts.ColumnTTLs != nil {
+		columnTTLs := make([]model.ColumnTTL, len(opts.ColumnTTLs))
+		for i, c := range opts.ColumnTTLs {
+			c.Column = rename(c.Column)
+			c.TTL.DateColumn = rename(c.TTL.DateColumn)
+			columnTTLs[i] = c
+		}
+		opts.ColumnTTLs = columnTTLs
+	}
+	return opts
+}
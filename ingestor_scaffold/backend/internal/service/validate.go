@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ingestor/internal/model"
+)
+
+// ValidateIngestion cross-checks the columns a flat file load would insert against
+// the target table, or the CREATE TABLE it would run, before any data moves. Problems
+// with the job itself are errors; incompatibilities are listed in the result.
+func (s *IngestServiceImpl) ValidateIngestion(ctx context.Context, params model.IngestionParams) (model.IngestionValidation, error) {
+	if params.SourceType != "flatfile" || params.TargetType != "clickhouse" {
+		return model.IngestionValidation{}, fmt.Errorf("compatibility checks support flat file to ClickHouse ingestion")
+	}
+	if err := checkParseWorkers(params.FlatFileParams, s.config); err != nil {
+		return model.IngestionValidation{}, err
+	}
+	policy, err := s.schemaPolicy(params)
+	if err != nil {
+		return model.IngestionValidation{}, err
+	}
+	tableOpts, err := buildTableOptions(params)
+	if err != nil {
+		return model.IngestionValidation{}, err
+	}
+
+	// The loaded columns, as ingestion computes them
+	columns := params.Columns
+	if len(columns) == 0 {
+		schema, err := s.flatFileService.DiscoverSchema(ctx, params.FlatFileParams, nil)
+		if err != nil {
+			return model.IngestionValidation{}, fmt.Errorf("failed to discover schema: %w", err)
+		}
+		columns = schema.Columns
+	}
+	renames, err := columnRenames(params.ColumnMappings, columns)
+	if err != nil {
+		return model.IngestionValidation{}, err
+	}
+	if _, err := compileTransforms(params.Transforms, columns); err != nil {
+		return model.IngestionValidation{}, err
+	}
+	if _, err := compileRules(params.Rules, columns, s.config.DedupMaxKeys); err != nil {
+		return model.IngestionValidation{}, err
+	}
+	derived, err := compileDerivedColumns(params.DerivedColumns, columns, renames)
+	if err != nil {
+		return model.IngestionValidation{}, err
+	}
+	targetColumns := append(append([]model.Column{}, renameColumns(columns, renames)...), derivedTargetColumns(derived)...)
+	if params.Mode == "cdc" {
+		targetColumns = append(targetColumns, cdcColumns(params)...)
+	}
+
+	validation := model.IngestionValidation{
+		Table:        params.TableName,
+		SchemaPolicy: policy,
+		Columns:      targetColumns,
+	}
+
+	conn := s.clickhouse(ctx)
+	existing, err := conn.GetTableColumns(ctx, params.TableName)
+	if err != nil {
+		if !isUnknownTable(err) {
+			return model.IngestionValidation{}, fmt.Errorf("failed to inspect target table: %w", err)
+		}
+
+		// A new table only needs a valid definition
+		tableOpts = clusterOptions(tableOpts, params, conn)
+		stmts, err := createTableStatements(params.TableName, targetColumns, renameTableOptions(tableOpts, renames), s.config.DDLColumnChunk)
+		if err != nil {
+			validation.Incompatibilities = append(validation.Incompatibilities, err.Error())
+		} else {
+			validation.CreateTable = stmts.create
+			validation.AlterTable = stmts.alters
+			validation.CreateDistributed = stmts.distributed
+		}
+		validation.Compatible = len(validation.Incompatibilities) == 0
+		return validation, nil
+	}
+	validation.TableExists = true
+	validation.Differences = compareSchemas(targetColumns, existing)
+
+	// Mismatched types never load, and are left out under the map policy; missing
+	// columns only fail under the fail policy
+	loaded := len(targetColumns)
+	for _, d := range validation.Differences {
+		switch {
+		case d.Kind == "type" && policy == SchemaPolicyMap:
+			loaded--
+			validation.Incompatibilities = append(validation.Incompatibilities, describeSchemaDiff([]model.SchemaDifference{d})+"; the column is not loaded")
+		case d.Kind == "type":
+			validation.Incompatibilities = append(validation.Incompatibilities, describeSchemaDiff([]model.SchemaDifference{d}))
+		case d.Kind == "missing" && policy == SchemaPolicyFail:
+			validation.Incompatibilities = append(validation.Incompatibilities, describeSchemaDiff([]model.SchemaDifference{d})+"; set schemaPolicy to map or evolve")
+		case d.Kind == "missing" && policy == SchemaPolicyMap:
+			loaded--
+		}
+	}
+	if loaded == 0 {
+		validation.Incompatibilities = append(validation.Incompatibilities, fmt.Sprintf("no source column matches target table %s", params.TableName))
+	}
+
+	// Defaults must name table columns the source lacks
+	if _, err := compileColumnDefaults(ctx, params.ColumnDefaults, columns, validation.Differences); err != nil {
+		validation.Incompatibilities = append(validation.Incompatibilities, err.Error())
+	}
+
+	validation.Compatible = len(validation.Incompatibilities) == 0
+	return validation, nil
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// Verification modes
+const (
+	VerifyCount    = "count"
+	VerifyChecksum = "checksum"
+	VerifySample   = "sample"
+)
+
+// verifySumTolerance is the relative difference allowed between column sums, which
+// add up floats in different orders
+const verifySumTolerance = 1e-6
+
+// checkVerify validates the verification of params
+func checkVerify(params model.IngestionParams) error {
+	spec := params.Verify
+	switch spec.Mode {
+	case VerifyCount, VerifyChecksum:
+		if spec.SampleRows != 0 || spec.SampleSeed != 0 {
+			return fmt.Errorf("sampleRows and sampleSeed apply to the sample verification mode")
+		}
+	case VerifySample:
+		if spec.SampleRows < 0 || spec.SampleRows > maxVerifySampleRows {
+			return fmt.Errorf("sampleRows must be between 0 and %d", maxVerifySampleRows)
+		}
+	default:
+		return fmt.Errorf("unsupported verification mode %q: must be count, checksum or sample", spec.Mode)
+	}
+
+	switch {
+	case params.SourceType == "flatfile" && params.TargetType == "clickhouse":
+		if params.OptimizeFinal && spec.Mode != VerifySample {
+			return fmt.Errorf("verification cannot count a table that optimizeFinal collapses")
+		}
+		if params.InsertMode == InsertAsync && params.AsyncInsert != nil && params.AsyncInsert.Wait != nil && !*params.AsyncInsert.Wait {
+			return fmt.Errorf("verification needs async inserts that wait for their rows to be written")
+		}
+	case params.SourceType == "clickhouse" && params.TargetType == "flatfile":
+		if params.ServerFormat != "" {
+			return fmt.Errorf("verification reads exports back as CSV, so it does not support serverFormat")
+		}
+	default:
+		return fmt.Errorf("verification supports flat file to ClickHouse and ClickHouse to flat file jobs")
+	}
+	return nil
+}
+
+// checksum is the row count of a source or target and the sums of its numeric
+// columns
+type checksum struct {
+	rows int64
+	sums map[string]float64
+}
+
+// verifyBaseline measures the target table of a load before it runs, so that only
+// the rows the job adds are verified. A table not created yet measures empty;
+// other jobs writing to the table meanwhile make verification fail.
+func (s *IngestServiceImpl) verifyBaseline(ctx context.Context, params model.IngestionParams) (checksum, error) {
+	if params.TargetType != "clickhouse" || params.Verify.Mode == VerifySample {
+		return checksum{}, nil
+	}
+	baseline, err := s.tableChecksum(ctx, params.TableName, params.Verify.Mode == VerifyChecksum)
+	if err != nil {
+		return checksum{}, fmt.Errorf("failed to measure the target before verification: %w", err)
+	}
+	return baseline, nil
+}
+
+// tableChecksum measures a table, with the sums of its numeric columns if asked
+func (s *IngestServiceImpl) tableChecksum(ctx context.Context, tableName string, sums bool) (checksum, error) {
+	conn := s.clickhouse(ctx)
+	columns, err := conn.GetTableColumns(ctx, tableName)
+	if err != nil {
+		if isUnknownTable(err) {
+			return checksum{}, nil
+		}
+		return checksum{}, err
+	}
+	table, err := QuoteTable(tableName)
+	if err != nil {
+		return checksum{}, err
+	}
+
+	var names []string
+	if sums {
+		names = numericColumns(columns)
+	}
+	rows, values, err := conn.ChecksumQuery(ctx, "SELECT * FROM "+table, nil, names)
+	if err != nil {
+		return checksum{}, err
+	}
+	return checksum{rows: rows, sums: values}, nil
+}
+
+// verify compares what a successful job wrote with its source. Verification that
+// cannot run is reported in the result rather than failing the job.
+func (s *IngestServiceImpl) verify(ctx context.Context, params model.IngestionParams, result model.IngestionResult, baseline checksum) *model.Verification {
+	v := &model.Verification{Mode: params.Verify.Mode}
+	v.DroppedRows = int64(WarningsFromContext(ctx).Total("rows skipped") + result.DuplicateRecords)
+	if params.Verify.Mode == VerifySample {
+		if err := s.spotCheck(ctx, params, result, v); err != nil {
+			v.Error = err.Error()
+		}
+		return v
+	}
+	sums := params.Verify.Mode == VerifyChecksum
+
+	var source, target checksum
+	var columns [][2]string // source and target names of compared columns
+	var err error
+	if params.SourceType == "flatfile" {
+		source, target, columns, err = s.verifyLoad(ctx, params, baseline, sums)
+	} else {
+		source, target, columns, err = s.verifyExport(ctx, params, result, sums)
+	}
+	if err != nil {
+		v.Error = err.Error()
+		return v
+	}
+
+	v.SourceRows, v.TargetRows = source.rows, target.rows
+	v.ExpectedRows = v.SourceRows - v.DroppedRows
+	if v.TargetRows != v.ExpectedRows {
+		v.Mismatches = append(v.Mismatches, fmt.Sprintf("target has %d rows, expected %d", v.TargetRows, v.ExpectedRows))
+	}
+
+	switch {
+	case !sums:
+	case v.DroppedRows > 0:
+		v.SumsSkipped = "rows were dropped by the job"
+	default:
+		for _, names := range columns {
+			c := model.ColumnChecksum{Column: names[0], Source: source.sums[names[0]], Target: target.sums[names[1]]}
+			c.Match = sumsMatch(c.Source, c.Target)
+			if !c.Match {
+				v.Mismatches = append(v.Mismatches, fmt.Sprintf("sum of %s is %g in the source and %g in the target", c.Column, c.Source, c.Target))
+			}
+			v.Columns = append(v.Columns, c)
+		}
+	}
+	v.Passed = len(v.Mismatches) == 0
+	return v
+}
+
+// verifyLoad measures a loaded flat file and the rows the load added to its table.
+// Columns scaled by a transform are not compared.
+func (s *IngestServiceImpl) verifyLoad(ctx context.Context, params model.IngestionParams, baseline checksum, sums bool) (checksum, checksum, [][2]string, error) {
+	after, err := s.tableChecksum(ctx, params.TableName, sums)
+	if err != nil {
+		return checksum{}, checksum{}, nil, fmt.Errorf("failed to measure the target: %w", err)
+	}
+	target := checksum{rows: after.rows - baseline.rows, sums: make(map[string]float64, len(after.sums))}
+	for name, sum := range after.sums {
+		target.sums[name] = sum - baseline.sums[name]
+	}
+
+	// Mapped target columns are read under their source names
+	sourceNames := make(map[string]string, len(params.ColumnMappings))
+	for _, m := range params.ColumnMappings {
+		sourceNames[m.TargetColumn] = m.SourceColumn
+	}
+	scaled := make(map[string]bool)
+	for _, t := range params.Transforms {
+		if strings.EqualFold(t.Function, "scale") {
+			scaled[t.Column] = true
+		}
+	}
+	var columns [][2]string
+	var names []string
+	for name := range target.sums {
+		sourceName := name
+		if mapped, ok := sourceNames[name]; ok {
+			sourceName = mapped
+		}
+		if !scaled[sourceName] {
+			columns = append(columns, [2]string{sourceName, name})
+			names = append(names, sourceName)
+		}
+	}
+
+	rows, values, err := s.flatFileService.ChecksumFile(ctx, params.FlatFileParams.FilePath, params.FlatFileParams.Delimiter, names)
+	if err != nil {
+		return checksum{}, checksum{}, nil, fmt.Errorf("failed to measure the source: %w", err)
+	}
+
+	// Derived and default columns have no source to compare with
+	compared := columns[:0]
+	for _, names := range columns {
+		if _, ok := values[names[0]]; ok {
+			compared = append(compared, names)
+		}
+	}
+	sort.Slice(compared, func(i, j int) bool { return compared[i][0] < compared[j][0] })
+	return checksum{rows: rows, sums: values}, target, compared, nil
+}
+
+// verifyExport measures the query of an export and the file, or part files, it
+// wrote
+func (s *IngestServiceImpl) verifyExport(ctx context.Context, params model.IngestionParams, result model.IngestionResult, sums bool) (checksum, checksum, [][2]string, error) {
+	query, args, exported, _, err := s.exportQuery(params, nil)
+	if err != nil {
+		return checksum{}, checksum{}, nil, err
+	}
+
+	// Exports of a whole table list no columns
+	if len(exported) == 0 && params.Query == "" && params.Join == nil && params.TableFunction == nil {
+		if exported, err = s.clickhouse(ctx).GetTableColumns(ctx, params.TableName); err != nil {
+			return checksum{}, checksum{}, nil, err
+		}
+	}
+	var names []string
+	if sums {
+		names = numericColumns(exported)
+	}
+	rows, values, err := s.clickhouse(ctx).ChecksumQuery(ctx, query, args, names)
+	if err != nil {
+		return checksum{}, checksum{}, nil, fmt.Errorf("failed to measure the source: %w", err)
+	}
+	source := checksum{rows: rows, sums: values}
+
+	files := result.PartFiles
+	if len(files) == 0 {
+		files = []string{params.FlatFileParams.FilePath}
+	}
+	target := checksum{sums: make(map[string]float64, len(names))}
+	for _, file := range files {
+		rows, values, err := s.flatFileService.ChecksumFile(ctx, file, params.FlatFileParams.Delimiter, names)
+		if err != nil {
+			return checksum{}, checksum{}, nil, fmt.Errorf("failed to measure the target: %w", err)
+		}
+		target.rows += rows
+		for name, sum := range values {
+			target.sums[name] += sum
+		}
+	}
+
+	columns := make([][2]string, len(names))
+	for i, name := range names {
+		columns[i] = [2]string{name, name}
+	}
+	return source, target, columns, nil
+}
+
+// ChecksumQuery counts the rows of a query and sums the given numeric columns of
+// its result
+func (s *ClickHouseServiceImpl) ChecksumQuery(ctx context.Context, query string, args []interface{}, columns []string) (int64, map[string]float64, error) {
+	if s.conn == nil {
+		return 0, nil, fmt.Errorf("not connected to ClickHouse")
+	}
+
+	var rows uint64
+	values := make([]float64, len(columns))
+	selectList := []string{"count()"}
+	dest := []interface{}{&rows}
+	for i, name := range columns {
+		quoted, err := QuoteIdentifier(name)
+		if err != nil {
+			return 0, nil, err
+		}
+		selectList = append(selectList, fmt.Sprintf("ifNull(sum(toFloat64(%s)), 0)", quoted))
+		dest = append(dest, &values[i])
+	}
+	query = fmt.Sprintf("SELECT %s FROM (%s)", strings.Join(selectList, ", "), query)
+	if err := s.conn.QueryRow(readOnlyContext(ctx), query, args...).Scan(dest...); err != nil {
+		return 0, nil, fmt.Errorf("failed to checksum rows: %w", err)
+	}
+
+	sums := make(map[string]float64, len(columns))
+	for i, name := range columns {
+		sums[name] = values[i]
+	}
+	return int64(rows), sums, nil
+}
+
+// ChecksumFile counts the data records of a flat file, malformed ones included,
+// and sums the numeric values of the given columns over the well-formed ones.
+// Columns missing from the file are left out of the sums.
+func (s *FlatFileServiceImpl) ChecksumFile(ctx context.Context, filePath, delimiter string, columns []string) (int64, map[string]float64, error) {
+	filePath, err := s.sandbox.Resolve(filePath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var delim rune = ','
+	if delims := []rune(delimiter); len(delims) > 0 {
+		delim = delims[0]
+	}
+	reader := csv.NewReader(s.bufferedReader(file))
+	reader.Comma = delim
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	names, _ := normalizeHeader(header)
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+	sums := make(map[string]float64, len(columns))
+	positions := make(map[string]int, len(columns))
+	for _, col := range columns {
+		if i, ok := index[col]; ok {
+			positions[col] = i
+			sums[col] = 0
+		}
+	}
+
+	var rows int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, nil, err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		rows++
+		if err != nil || len(record) != len(header) {
+			continue
+		}
+		for col, i := range positions {
+			if f, err := strconv.ParseFloat(record[i], 64); err == nil {
+				sums[col] += f
+			}
+		}
+	}
+	return rows, sums, nil
+}
+
+// numericColumns returns the names of the columns holding numbers
+func numericColumns(columns []model.Column) []string {
+	var names []string
+	for _, col := range columns {
+		if numericType(col.Type) {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+// sumsMatch reports whether two column sums are equal within verifySumTolerance
+func sumsMatch(a, b float64) bool {
+	scale := math.Max(math.Abs(a), math.Abs(b))
+	return math.Abs(a-b) <= verifySumTolerance*math.Max(scale, 1)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxWarningMessages bounds the number of distinct one-off warnings kept per job
+const maxWarningMessages = 100
+
+type warningsKey struct{}
+
+// WarningCollector accumulates non-fatal issues raised while a job runs.
+// Repeated issues are aggregated into counters rather than listed per row.
+type WarningCollector struct {
+	mu       sync.Mutex
+	counts   map[string]int
+	order    []string
+	messages []string
+}
+
+// NewWarningCollector creates an empty warning collector
+func NewWarningCollector() *WarningCollector {
+	return &WarningCollector{
+		counts: make(map[string]int),
+	}
+}
+
+// WithWarnings returns a context carrying the given collector
+func WithWarnings(ctx context.Context, w *WarningCollector) context.Context {
+	return context.WithValue(ctx, warningsKey{}, w)
+}
+
+// WarningsFromContext returns the collector carried by ctx, or nil
+func WarningsFromContext(ctx context.Context) *WarningCollector {
+	w, _ := ctx.Value(warningsKey{}).(*WarningCollector)
+	return w
+}
+
+// Add records a one-off warning message
+func (w *WarningCollector) Add(format string, args ...interface{}) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.messages) < maxWarningMessages {
+		w.messages = append(w.messages, fmt.Sprintf(format, args...))
+	}
+}
+
+// Count adds n occurrences of an aggregated warning, e.g. "rows coerced"
+func (w *WarningCollector) Count(what string, n int) {
+	if w == nil || n <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.counts[what]; !ok {
+		w.order = append(w.order, what)
+	}
+	w.counts[what] += n
+}
+
+// Total sums the aggregated warnings whose description starts with prefix,
+// e.g. "rows skipped" across all skip reasons
+func (w *WarningCollector) Total(prefix string) int {
+	if w == nil {
+		return 0
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	total := 0
+	for what, n := range w.counts {
+		if strings.HasPrefix(what, prefix) {
+			total += n
+		}
+	}
+	return total
+}
+
+// Snapshot returns all warnings recorded so far
+func (w *WarningCollector) Snapshot() []string {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.order) == 0 && len(w.messages) == 0 {
+		return nil
+	}
+	warnings := make([]string, 0, len(w.order)+len(w.messages))
+	for _, what := range w.order {
+		warnings = append(warnings, fmt.Sprintf("%d %s", w.counts[what], what))
+	}
+	return append(warnings, w.messages...)
+}
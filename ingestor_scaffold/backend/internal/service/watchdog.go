@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"time"
+
+	"github.com/ingestor/internal/config"
+	"github.com/ingestor/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// maxGoroutineDump bounds the goroutine dump stored with stalled jobs
+const maxGoroutineDump = 64 * 1024
+
+// WatchdogService detects jobs that stopped making progress
+type WatchdogService interface {
+	Start()
+	Stop()
+	CheckJobs(ctx context.Context)
+}
+
+// WatchdogServiceImpl implements WatchdogService
+type WatchdogServiceImpl struct {
+	jobService     JobService
+	sessionService SessionService
+	config         *config.Config
+	logger         *logrus.Logger
+	stop           chan struct{}
+}
+
+// NewWatchdogService creates a new watchdog service
+func NewWatchdogService(
+	jobService JobService,
+	sessionService SessionService,
+	config *config.Config,
+	logger *logrus.Logger,
+) WatchdogService {
+	return &WatchdogServiceImpl{
+		jobService:     jobService,
+		sessionService: sessionService,
+		config:         config,
+		logger:         logger,
+		stop:           make(chan struct{}),
+	}
+}
+
+// Start runs periodic checks in the background
+func (s *WatchdogServiceImpl) Start() {
+	if s.config.WatchdogInterval <= 0 || s.config.StuckJobTimeout <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.config.WatchdogInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.CheckJobs(context.Background())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background checks
+func (s *WatchdogServiceImpl) Stop() {
+	close(s.stop)
+}
+
+// CheckJobs flags running jobs without progress beyond the timeout and applies the policy
+func (s *WatchdogServiceImpl) CheckJobs(ctx context.Context) {
+	for _, job := range s.jobService.ListRunningJobs() {
+		stalledFor := time.Since(job.LastProgressAt)
+		if stalledFor < s.config.StuckJobTimeout || job.Stalled {
+			continue
+		}
+
+		diagnostics := s.collectDiagnostics(ctx, job, stalledFor)
+		logger := s.logger.WithFields(logrus.Fields{
+			"jobId":      job.ID,
+			"stalledFor": diagnostics.StalledFor,
+			"lastCount":  job.LastCount,
+		})
+
+		if s.config.StuckJobPolicy == "cancel" {
+			diagnostics.Action = "cancelled"
+			s.jobService.MarkStalled(job.ID, diagnostics)
+			if err := s.jobService.CancelJob(job.ID, "cancelled by watchdog: no progress for "+diagnostics.StalledFor); err != nil {
+				logger.WithError(err).Warn("Failed to cancel stalled job")
+			}
+			logger.Error("Stalled job cancelled")
+			continue
+		}
+
+		diagnostics.Action = "alerted"
+		s.jobService.MarkStalled(job.ID, diagnostics)
+		logger.Error("Job has stalled")
+	}
+}
+
+// collectDiagnostics captures goroutine stacks and running ClickHouse queries
+func (s *WatchdogServiceImpl) collectDiagnostics(ctx context.Context, job model.Job, stalledFor time.Duration) model.JobDiagnostics {
+	diagnostics := model.JobDiagnostics{
+		DetectedAt: time.Now(),
+		StalledFor: stalledFor.Round(time.Second).String(),
+	}
+
+	var buf bytes.Buffer
+	if profile := pprof.Lookup("goroutine"); profile != nil {
+		if err := profile.WriteTo(&buf, 1); err == nil {
+			dump := buf.String()
+			if len(dump) > maxGoroutineDump {
+				dump = dump[:maxGoroutineDump]
+			}
+			diagnostics.Goroutines = dump
+		}
+	}
+
+	// Running queries are listed on the job's own session
+	if job.Params.SessionID == "" {
+		return diagnostics
+	}
+	conn, err := s.sessionService.Get(job.Params.SessionID)
+	if err != nil {
+		diagnostics.QueryError = err.Error()
+		return diagnostics
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	queries, err := conn.RunningQueries(queryCtx)
+	if err != nil {
+		diagnostics.QueryError = err.Error()
+	} else {
+		diagnostics.RunningQueries = queries
+	}
+
+	return diagnostics
+}
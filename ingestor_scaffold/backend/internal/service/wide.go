@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ingestor/internal/model"
+)
+
+// wideTableMode reports whether an export moves rows as positional slices rather
+// than maps, either on request or because the table has many columns
+func (s *IngestServiceImpl) wideTableMode(params model.IngestionParams, columns []model.Column) bool {
+	if len(params.JSONPaths) > 0 {
+		return false
+	}
+	return params.WideTable || (s.config.WideTableColumns > 0 && len(columns) >= s.config.WideTableColumns)
+}
+
+// rowPositions maps each column to its index among the result's column names, -1 if
+// absent. It returns nil when the result is already in column order.
+func rowPositions(columns []model.Column, names []string) []int {
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+
+	positions := make([]int, len(columns))
+	identity := len(columns) == len(names)
+	for i, col := range columns {
+		pos, ok := index[col.Name]
+		if !ok {
+			pos = -1
+		}
+		positions[i] = pos
+		identity = identity && pos == i
+	}
+	if identity {
+		return nil
+	}
+	return positions
+}
+
+// reorderRow puts a result row in column order; nil positions keep the row as is
+func reorderRow(values []interface{}, positions []int) []interface{} {
+	if positions == nil {
+		return values
+	}
+	row := make([]interface{}, len(positions))
+	for i, pos := range positions {
+		if pos < 0 {
+			row[i] = missingCell{}
+			continue
+		}
+		row[i] = values[pos]
+	}
+	return row
+}
+
+// chunkColumns splits the column definitions of a wide CREATE TABLE into the ones
+// created with the table and ALTER TABLE statements adding the rest, at most size
+// per statement. Key columns are always created with the table; every added column
+// names the column it follows, so the table keeps the given order.
+func chunkColumns(table string, columns []model.Column, defs []string, keys []string, size int) (create []string, alters []string) {
+	if size <= 0 || len(defs) <= size {
+		return defs, nil
+	}
+
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+
+	var batch []string
+	flush := func() {
+		if len(batch) > 0 {
+			alters = append(alters, fmt.Sprintf("ALTER TABLE %s %s", table, strings.Join(batch, ", ")))
+			batch = nil
+		}
+	}
+	for i, col := range columns {
+		if i < size || isKey[col.Name] {
+			create = append(create, defs[i])
+			continue
+		}
+		previous, _ := QuoteIdentifier(columns[i-1].Name)
+		batch = append(batch, fmt.Sprintf("ADD COLUMN IF NOT EXISTS %s AFTER %s", defs[i], previous))
+		if len(batch) == size {
+			flush()
+		}
+	}
+	flush()
+	return create, alters
+}
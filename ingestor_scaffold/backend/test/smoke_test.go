@@ -2,14 +2,11 @@ package test
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ingestor/internal/config"
@@ -23,30 +20,30 @@ func TestHealthCheck(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	logger := logrus.New()
 	logger.SetOutput(os.Stdout)
-	
+
 	cfg, err := config.Load()
 	assert.NoError(t, err)
-	
+
 	r := router.SetupRouter(cfg, logger)
-	
+
 	// Create test request
 	req, err := http.NewRequest(http.MethodGet, "/health", nil)
 	assert.NoError(t, err)
-	
+
 	// Create response recorder
 	w := httptest.NewRecorder()
-	
+
 	// Serve request
 	r.ServeHTTP(w, req)
-	
+
 	// Check response
 	assert.Equal(t, http.StatusOK, w.Code)
-	
+
 	// Parse response
 	var response map[string]interface{}
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	
+
 	// Check response body
 	assert.Equal(t, "up", response["status"])
 }
@@ -56,27 +53,27 @@ func TestDiscoverFlatFileSchema(t *testing.T) {
 	if os.Getenv("INTEGRATION_TEST") != "true" {
 		t.Skip("Skipping integration test")
 	}
-	
+
 	// Setup
 	gin.SetMode(gin.TestMode)
 	logger := logrus.New()
 	logger.SetOutput(os.Stdout)
-	
+
 	cfg, err := config.Load()
 	assert.NoError(t, err)
-	
+
 	r := router.SetupRouter(cfg, logger)
-	
+
 	// Create temp CSV file
 	tempFile, err := os.CreateTemp("", "test-*.csv")
 	assert.NoError(t, err)
 	defer os.Remove(tempFile.Name())
-	
+
 	// Write test data
 	_, err = tempFile.WriteString("id,name,value\n1,test1,10.5\n2,test2,20.3\n")
 	assert.NoError(t, err)
 	tempFile.Close()
-	
+
 	// Create request body
 	requestBody := map[string]string{
 		"filePath":  tempFile.Name(),
@@ -84,31 +81,145 @@ func TestDiscoverFlatFileSchema(t *testing.T) {
 	}
 	requestJSON, err := json.Marshal(requestBody)
 	assert.NoError(t, err)
-	
+
 	// Create test request
 	req, err := http.NewRequest(http.MethodPost, "/api/v1/flatfile/schema", bytes.NewBuffer(requestJSON))
 	assert.NoError(t, err)
 	req.Header.Set("Content-Type", "application/json")
-	
+
 	// Create response recorder
 	w := httptest.NewRecorder()
-	
+
 	// Serve request
 	r.ServeHTTP(w, req)
-	
+
 	// Check response
 	assert.Equal(t, http.StatusOK, w.Code)
-	
+
 	// Parse response
 	var response map[string]interface{}
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	
+
 	// Check response body
 	assert.Equal(t, "success", response["status"])
-	
+
 	// Check columns
 	columns, ok := response["columns"].([]interface{})
 	assert.True(t, ok)
 	assert.Equal(t, 3, len(columns))
-}
\ No newline at end of file
+}
+
+func TestJobSummaryNotFound(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+
+	r := router.SetupRouter(cfg, logger)
+
+	// Create test request for an unknown job
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/jobs/does-not-exist/summary", nil)
+	assert.NoError(t, err)
+
+	// Create response recorder
+	w := httptest.NewRecorder()
+
+	// Serve request
+	r.ServeHTTP(w, req)
+
+	// Check response
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+
+	r := router.SetupRouter(cfg, logger)
+
+	// A supplied request ID is echoed back
+	req, err := http.NewRequest(http.MethodGet, "/health", nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-Request-ID", "trace-123")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "trace-123", w.Header().Get("X-Request-ID"))
+
+	// A missing request ID is generated
+	req, err = http.NewRequest(http.MethodGet, "/health", nil)
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+}
+
+func TestJWTAuthRejectsMissingToken(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+	cfg.JWKSURL = "http://127.0.0.1:1/jwks.json"
+
+	r := router.SetupRouter(cfg, logger)
+
+	// API routes require a bearer token
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/schedules", nil)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Health stays open
+	req, err = http.NewRequest(http.MethodGet, "/health", nil)
+	assert.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+
+	cfg, err := config.Load()
+	assert.NoError(t, err)
+	cfg.APIKeys = "ci=s3cret"
+
+	r := router.SetupRouter(cfg, logger)
+
+	// A configured key is accepted
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/schedules", nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-API-Key", "s3cret")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// An unknown key is rejected
+	req, err = http.NewRequest(http.MethodGet, "/api/v1/schedules", nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-API-Key", "wrong")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}